@@ -13,8 +13,11 @@ var (
 )
 
 func main() {
+	ctx, stop := cmd.NotifyContext()
 	rootCmd := cmd.NewRootCmd(version, commit, buildDate)
-	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+	if err != nil {
+		os.Exit(cmd.ExitCode(err))
 	}
 }
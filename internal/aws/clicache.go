@@ -0,0 +1,91 @@
+package aws
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultCLICacheDir returns the directory where the AWS CLI caches the
+// credentials of role profiles
+func DefaultCLICacheDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".aws", "cli", "cache"), nil
+}
+
+// CLICacheKey returns the name (without ".json") of the AWS CLI cache entry
+// of a role profile that sets role_arn and source_profile only: the SHA-1 of
+// the AssumeRole arguments the CLI would send, as Python's json.dumps
+// renders them. duration_seconds, external_id or mfa_serial in the profile
+// change the key.
+func CLICacheKey(roleARN string) string {
+	arn, _ := json.Marshal(roleARN)
+	sum := sha1.Sum([]byte(`{"RoleArn": ` + string(arn) + `}`))
+	return hex.EncodeToString(sum[:])
+}
+
+// cliCacheEntry is the cached AssumeRole response the AWS CLI reads
+type cliCacheEntry struct {
+	Credentials struct {
+		AccessKeyID     string `json:"AccessKeyId"`
+		SecretAccessKey string `json:"SecretAccessKey"`
+		SessionToken    string `json:"SessionToken"`
+		Expiration      string `json:"Expiration"`
+	} `json:"Credentials"`
+	AssumedRoleUser *struct {
+		Arn string `json:"Arn"`
+	} `json:"AssumedRoleUser,omitempty"`
+}
+
+// SaveCLICache writes credentials of roleARN to the AWS CLI cache in dir, so
+// the CLI uses them for a role profile of roleARN instead of assuming the
+// role itself until they expire
+func SaveCLICache(dir, roleARN string, creds *Credentials) error {
+	var entry cliCacheEntry
+	entry.Credentials.AccessKeyID = creds.AccessKeyID
+	entry.Credentials.SecretAccessKey = creds.SecretAccessKey
+	entry.Credentials.SessionToken = creds.SessionToken
+	entry.Credentials.Expiration = creds.Expiration.UTC().Format(time.RFC3339)
+	if creds.AssumedRoleARN != "" {
+		entry.AssumedRoleUser = &struct {
+			Arn string `json:"Arn"`
+		}{Arn: creds.AssumedRoleARN}
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode CLI cache entry: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	path := filepath.Join(dir, CLICacheKey(roleARN)+".json")
+	tmp, err := os.CreateTemp(dir, ".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to save %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to set permissions of %s: %w", path, err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save %s: %w", path, err)
+	}
+	return nil
+}
@@ -2,36 +2,62 @@ package aws
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/user/azure2aws/internal/partition"
 )
 
+const Issuer = "azure2aws"
+
+// Console session duration limits of the federation endpoint
 const (
-	FederationEndpoint = "https://signin.aws.amazon.com/federation"
-	ConsoleURL         = "https://console.aws.amazon.com/"
-	Issuer             = "azure2aws"
+	MinConsoleSessionDuration = 15 * time.Minute
+	MaxConsoleSessionDuration = 12 * time.Hour
 )
 
+// ErrConsoleOutlastsCredentials is returned when a console session would
+// last longer than the credentials it is created from
+var ErrConsoleOutlastsCredentials = errors.New("console session outlasts the credentials")
+
+// ConsoleDestination is the console page opened after sign-in
+type ConsoleDestination struct {
+	Service string // Service such as "ec2"; empty opens the console home
+	Region  string // Region to show; empty uses the console default
+	Path    string // Page below the service ("/buckets") and/or fragment ("#Instances")
+}
+
 type SigninTokenResponse struct {
 	SigninToken string `json:"SigninToken"`
 }
 
-func GetFederatedLoginURL(creds *Credentials, service string) (string, error) {
-	signinToken, err := getSigninToken(creds)
+// GetFederatedLoginURL returns a console sign-in URL for creds. A non-zero
+// duration sets the console session length; otherwise the federation
+// endpoint's default (one hour) applies.
+func (c *Client) GetFederatedLoginURL(creds *Credentials, dest ConsoleDestination, duration time.Duration) (string, error) {
+	part := credentialsPartition(creds)
+
+	if dest.Region != "" && partition.ForRegion(dest.Region) != part {
+		return "", fmt.Errorf("region %s is not in partition %s", dest.Region, part.ID)
+	}
+	destination, err := part.ConsolePageURL(dest.Service, dest.Region, dest.Path)
 	if err != nil {
-		return "", fmt.Errorf("failed to get signin token: %w", err)
+		return "", err
 	}
 
-	destination := ConsoleURL
-	if service != "" {
-		destination = fmt.Sprintf("https://%s.console.aws.amazon.com/", service)
+	signinToken, err := c.getSigninToken(part.FederationURL, creds, duration)
+	if err != nil {
+		return "", fmt.Errorf("failed to get signin token: %w", err)
 	}
 
 	loginURL := fmt.Sprintf(
 		"%s?Action=login&Issuer=%s&Destination=%s&SigninToken=%s",
-		FederationEndpoint,
+		part.FederationURL,
 		url.QueryEscape(Issuer),
 		url.QueryEscape(destination),
 		url.QueryEscape(signinToken),
@@ -40,7 +66,35 @@ func GetFederatedLoginURL(creds *Credentials, service string) (string, error) {
 	return loginURL, nil
 }
 
-func getSigninToken(creds *Credentials) (string, error) {
+// ConsoleLogoutURL returns a URL that signs out of any console session in the
+// partition of creds, then continues to loginURL
+func ConsoleLogoutURL(creds *Credentials, loginURL string) string {
+	return credentialsPartition(creds).LogoutURL + "&redirect_uri=" + url.QueryEscape(loginURL)
+}
+
+// CheckConsoleDuration checks that a console session duration is within the
+// federation endpoint's limits and ends before the credentials expire
+func CheckConsoleDuration(duration time.Duration, expiration, now time.Time) error {
+	if duration < MinConsoleSessionDuration || duration > MaxConsoleSessionDuration {
+		return fmt.Errorf("console session duration %s is out of range (%s to %s)", duration, MinConsoleSessionDuration, MaxConsoleSessionDuration)
+	}
+	if !expiration.IsZero() && now.Add(duration).After(expiration) {
+		remaining := expiration.Sub(now).Truncate(time.Minute)
+		return fmt.Errorf("%w: %s requested, credentials expire in %s", ErrConsoleOutlastsCredentials, duration, remaining)
+	}
+	return nil
+}
+
+// credentialsPartition returns the partition of the role behind creds, or of
+// their region
+func credentialsPartition(creds *Credentials) *partition.Partition {
+	if p := partition.ForARN(creds.AssumedRoleARN); p != nil {
+		return p
+	}
+	return partition.ForRegion(creds.Region)
+}
+
+func (c *Client) getSigninToken(federationURL string, creds *Credentials, duration time.Duration) (string, error) {
 	sessionJSON, err := json.Marshal(map[string]string{
 		"sessionId":    creds.AccessKeyID,
 		"sessionKey":   creds.SecretAccessKey,
@@ -50,7 +104,7 @@ func getSigninToken(creds *Credentials) (string, error) {
 		return "", fmt.Errorf("failed to marshal session: %w", err)
 	}
 
-	req, err := http.NewRequest("GET", FederationEndpoint, nil)
+	req, err := http.NewRequest("GET", federationURL, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
@@ -58,9 +112,12 @@ func getSigninToken(creds *Credentials) (string, error) {
 	q := req.URL.Query()
 	q.Add("Action", "getSigninToken")
 	q.Add("Session", string(sessionJSON))
+	if duration > 0 {
+		q.Add("SessionDuration", strconv.Itoa(int(duration.Seconds())))
+	}
 	req.URL.RawQuery = q.Encode()
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.federationClient().Do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to execute request: %w", err)
 	}
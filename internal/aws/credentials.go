@@ -5,8 +5,6 @@ import (
 	"os"
 	"path/filepath"
 	"time"
-
-	"gopkg.in/ini.v1"
 )
 
 type Credentials struct {
@@ -43,44 +41,14 @@ func DefaultConfigPath() (string, error) {
 	return filepath.Join(home, ".aws", "config"), nil
 }
 
+// SaveCredentials writes credentials to the shared credentials file (and the
+// region and output to the config file)
 func SaveCredentials(profile string, creds *Credentials) error {
-	credPath, err := DefaultCredentialsPath()
+	store, err := NewFileStore()
 	if err != nil {
 		return err
 	}
-
-	if err := os.MkdirAll(filepath.Dir(credPath), 0700); err != nil {
-		return fmt.Errorf("failed to create credentials directory: %w", err)
-	}
-
-	cfg, err := ini.LooseLoad(credPath)
-	if err != nil {
-		return fmt.Errorf("failed to load credentials file: %w", err)
-	}
-
-	section, err := cfg.NewSection(profile)
-	if err != nil {
-		section = cfg.Section(profile)
-	}
-
-	section.Key("aws_access_key_id").SetValue(creds.AccessKeyID)
-	section.Key("aws_secret_access_key").SetValue(creds.SecretAccessKey)
-	section.Key("aws_session_token").SetValue(creds.SessionToken)
-	section.Key("x_security_token_expires").SetValue(creds.Expiration.Format(time.RFC3339))
-
-	if err := cfg.SaveTo(credPath); err != nil {
-		return fmt.Errorf("failed to save credentials file: %w", err)
-	}
-
-	if err := os.Chmod(credPath, 0600); err != nil {
-		return fmt.Errorf("failed to set credentials file permissions: %w", err)
-	}
-
-	if err := SaveAWSConfig(profile, creds.Region, creds.Output); err != nil {
-		return fmt.Errorf("failed to save AWS config: %w", err)
-	}
-
-	return nil
+	return store.Save(profile, creds)
 }
 
 func SaveAWSConfig(profile, region, output string) error {
@@ -88,113 +56,104 @@ func SaveAWSConfig(profile, region, output string) error {
 	if err != nil {
 		return err
 	}
+	return saveAWSConfig(configPath, profile, region, output)
+}
 
-	if err := os.MkdirAll(filepath.Dir(configPath), 0700); err != nil {
-		return fmt.Errorf("failed to create config directory: %w", err)
-	}
+// saveAWSConfig writes a profile's region and output to the config file at
+// configPath
+func saveAWSConfig(configPath, profile, region, output string) error {
+	return saveConfigProfile(configPath, profile,
+		configValue{"region", region},
+		configValue{"output", outputOrJSON(output)})
+}
 
-	cfg, err := ini.LooseLoad(configPath)
+// SaveChainedProfileConfig writes role_arn/source_profile entries to the AWS
+// config file so the AWS CLI/SDK performs the role chaining itself
+func SaveChainedProfileConfig(profile, roleARN, sourceProfile, region, output string) error {
+	configPath, err := DefaultConfigPath()
 	if err != nil {
-		return fmt.Errorf("failed to load config file: %w", err)
+		return err
 	}
 
-	sectionName := profile
-	if profile != "default" {
-		sectionName = "profile " + profile
-	}
+	return saveConfigProfile(configPath, profile,
+		configValue{"role_arn", roleARN},
+		configValue{"source_profile", sourceProfile},
+		configValue{"region", region},
+		configValue{"output", output})
+}
 
-	section, err := cfg.NewSection(sectionName)
+// SaveProcessProfileConfig writes a profile whose credentials come from a
+// credential_process command to the AWS config file
+func SaveProcessProfileConfig(profile, command, region, output string) error {
+	configPath, err := DefaultConfigPath()
 	if err != nil {
-		section = cfg.Section(sectionName)
+		return err
 	}
 
-	if region != "" {
-		section.Key("region").SetValue(region)
-	}
+	return saveConfigProfile(configPath, profile,
+		configValue{"credential_process", command},
+		configValue{"region", region},
+		configValue{"output", outputOrJSON(output)})
+}
 
-	if output != "" {
-		section.Key("output").SetValue(output)
-	} else {
-		section.Key("output").SetValue("json")
-	}
+// configValue is a key of a profile in the AWS config file
+type configValue struct {
+	key, value string
+}
 
-	if err := cfg.SaveTo(configPath); err != nil {
-		return fmt.Errorf("failed to save config file: %w", err)
-	}
+// saveConfigProfile sets values in a profile's section of the config file at
+// configPath, keeping the other keys. Empty values are left unchanged.
+func saveConfigProfile(configPath, profile string, values ...configValue) error {
+	return updateINI(configPath, func(doc *iniDoc) error {
+		section := configSection(profile)
+		for _, v := range values {
+			if v.value != "" {
+				doc.set(section, v.key, v.value)
+			}
+		}
+		return nil
+	})
+}
 
-	if err := os.Chmod(configPath, 0600); err != nil {
-		return fmt.Errorf("failed to set config file permissions: %w", err)
+// outputOrJSON returns output, defaulting to the AWS CLI's json
+func outputOrJSON(output string) string {
+	if output == "" {
+		return "json"
 	}
+	return output
+}
 
-	return nil
+// configSection returns the config file section of a profile
+func configSection(profile string) string {
+	if profile == "default" {
+		return profile
+	}
+	return "profile " + profile
 }
 
 // LoadCredentials loads AWS credentials from the credentials file
 func LoadCredentials(profile string) (*Credentials, error) {
-	credPath, err := DefaultCredentialsPath()
+	store, err := NewFileStore()
 	if err != nil {
 		return nil, err
 	}
-
-	cfg, err := ini.Load(credPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load credentials file: %w", err)
-	}
-
-	section, err := cfg.GetSection(profile)
-	if err != nil {
-		return nil, fmt.Errorf("profile %s not found: %w", profile, err)
-	}
-
-	creds := &Credentials{
-		AccessKeyID:     section.Key("aws_access_key_id").String(),
-		SecretAccessKey: section.Key("aws_secret_access_key").String(),
-		SessionToken:    section.Key("aws_session_token").String(),
-		Region:          section.Key("region").String(),
-	}
-
-	// Parse expiration time if present
-	if expStr := section.Key("x_security_token_expires").String(); expStr != "" {
-		if exp, err := time.Parse(time.RFC3339, expStr); err == nil {
-			creds.Expiration = exp
-		}
-	}
-
-	return creds, nil
+	return store.Load(profile)
 }
 
 // CredentialsExpired checks if credentials for a profile are expired
 func CredentialsExpired(profile string) bool {
-	creds, err := LoadCredentials(profile)
+	store, err := NewFileStore()
 	if err != nil {
-		return true // If we can't load, assume expired
-	}
-
-	// If no expiration set, assume expired
-	if creds.Expiration.IsZero() {
 		return true
 	}
-
-	return IsExpired(creds.Expiration)
+	return Expired(store, profile)
 }
 
 // DeleteCredentials removes credentials for a profile
 func DeleteCredentials(profile string) error {
-	credPath, err := DefaultCredentialsPath()
+	store, err := NewFileStore()
 	if err != nil {
 		return err
 	}
-
-	cfg, err := ini.Load(credPath)
-	if err != nil {
-		return fmt.Errorf("failed to load credentials file: %w", err)
-	}
-
-	cfg.DeleteSection(profile)
-
-	if err := cfg.SaveTo(credPath); err != nil {
-		return fmt.Errorf("failed to save credentials file: %w", err)
-	}
-
-	return nil
+	return store.Delete(profile)
 }
@@ -0,0 +1,104 @@
+package aws
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/user/azure2aws/internal/provider"
+)
+
+// Client sends STS, IAM and console federation requests
+type Client struct {
+	httpClient *http.Client // nil uses the defaults
+	sts        STSOptions
+}
+
+// ClientOptions configures a Client
+type ClientOptions struct {
+	// CABundle is a PEM file of certificates trusted in addition to the
+	// system roots. Empty uses the system roots only.
+	CABundle string
+	// STS selects the endpoint of STS and IAM requests
+	STS STSOptions
+}
+
+// STSOptions selects the endpoint of STS (and IAM) requests
+type STSOptions struct {
+	// Region overrides the region of STS requests, so that they go to that
+	// regional endpoint. Empty uses the region of the request.
+	Region string
+	// UseFIPS sends requests to FIPS 140 validated endpoints
+	UseFIPS bool
+	// Endpoint is a custom STS endpoint URL (such as a VPC endpoint). It takes
+	// precedence over the endpoint selected by Region and UseFIPS.
+	Endpoint string
+}
+
+// NewClient creates a client for STS, IAM and console federation requests
+func NewClient(opts ClientOptions) (*Client, error) {
+	c := &Client{sts: opts.STS}
+	if opts.CABundle == "" {
+		return c, nil
+	}
+
+	pool, err := provider.LoadCABundle(opts.CABundle)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		RootCAs:    pool,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	c.httpClient = &http.Client{
+		Transport: transport,
+		Timeout:   60 * time.Second,
+	}
+	return c, nil
+}
+
+// newSTSClient creates an STS client using the configured HTTP client and
+// endpoint options
+func (c *Client) newSTSClient(cfg aws.Config) *sts.Client {
+	if c.httpClient != nil {
+		cfg.HTTPClient = c.httpClient
+	}
+	if c.sts.Region != "" {
+		cfg.Region = c.sts.Region
+	}
+	return sts.NewFromConfig(cfg, func(o *sts.Options) {
+		switch {
+		case c.sts.Endpoint != "":
+			o.BaseEndpoint = aws.String(c.sts.Endpoint)
+		case c.sts.UseFIPS:
+			o.EndpointOptions.UseFIPSEndpoint = aws.FIPSEndpointStateEnabled
+		}
+	})
+}
+
+// newIAMClient creates an IAM client using the configured HTTP client. IAM
+// is a global service, so only the FIPS option applies.
+func (c *Client) newIAMClient(cfg aws.Config) *iam.Client {
+	if c.httpClient != nil {
+		cfg.HTTPClient = c.httpClient
+	}
+	return iam.NewFromConfig(cfg, func(o *iam.Options) {
+		if c.sts.UseFIPS {
+			o.EndpointOptions.UseFIPSEndpoint = aws.FIPSEndpointStateEnabled
+		}
+	})
+}
+
+// federationClient returns the HTTP client for console federation requests
+func (c *Client) federationClient() *http.Client {
+	if c.httpClient != nil {
+		return c.httpClient
+	}
+	return http.DefaultClient
+}
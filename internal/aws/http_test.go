@@ -0,0 +1,45 @@
+package aws
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewClientCABundle(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(bundle, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	trusting, err := NewClient(ClientOptions{CABundle: bundle})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	plain, err := NewClient(ClientOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Each client keeps its own trust roots
+	resp, err := trusting.federationClient().Get(srv.URL)
+	if err != nil {
+		t.Fatalf("expected the CA bundle to be trusted: %v", err)
+	}
+	resp.Body.Close()
+	if resp, err := plain.federationClient().Get(srv.URL); err == nil {
+		resp.Body.Close()
+		t.Error("expected a client without the CA bundle to reject the certificate")
+	}
+
+	if _, err := NewClient(ClientOptions{CABundle: filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+		t.Error("expected error for a missing CA bundle")
+	}
+}
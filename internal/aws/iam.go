@@ -0,0 +1,34 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+)
+
+// GetAccountAlias calls iam:ListAccountAliases using the given credentials and
+// returns the account alias, or "" if the account has none
+func (c *Client) GetAccountAlias(ctx context.Context, creds *Credentials) (string, error) {
+	region := creds.Region
+	if region == "" {
+		region = defaultRegion(creds.AssumedRoleARN)
+	}
+
+	cfg := aws.Config{
+		Region:      region,
+		Credentials: staticCredentialsProvider(creds),
+	}
+
+	result, err := c.newIAMClient(cfg).ListAccountAliases(ctx, &iam.ListAccountAliasesInput{})
+	if err != nil {
+		return "", fmt.Errorf("failed to list account aliases: %w", err)
+	}
+
+	// An account has at most one alias
+	if len(result.AccountAliases) == 0 {
+		return "", nil
+	}
+	return result.AccountAliases[0], nil
+}
@@ -0,0 +1,269 @@
+package aws
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// errNoChange tells updateINI that update left the file as it was
+var errNoChange = errors.New("no change")
+
+// iniDoc is an ini file edited line by line: only the keys and sections
+// changed through it differ when it is written back, and comments, ordering,
+// formatting and keys other tools set stay byte-identical
+type iniDoc struct {
+	lines           []string // Without their "\n"; a "\r" is kept
+	newline         string   // For added lines
+	trailingNewline bool
+}
+
+// parseINI splits an ini file into lines
+func parseINI(data []byte) *iniDoc {
+	doc := &iniDoc{newline: "\n", trailingNewline: true}
+	if len(data) == 0 {
+		return doc
+	}
+	if bytes.Contains(data, []byte("\r\n")) {
+		doc.newline = "\r\n"
+	}
+	doc.lines = strings.Split(string(data), "\n")
+	if last := len(doc.lines) - 1; doc.lines[last] == "" {
+		doc.lines = doc.lines[:last]
+	} else {
+		doc.trailingNewline = false
+	}
+	return doc
+}
+
+// bytes renders the file
+func (d *iniDoc) bytes() []byte {
+	if len(d.lines) == 0 {
+		return nil
+	}
+	out := strings.Join(d.lines, "\n")
+	if d.trailingNewline {
+		out += "\n"
+	}
+	return []byte(out)
+}
+
+// sectionName returns the name in a section header line
+func sectionName(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
+		return "", false
+	}
+	return strings.TrimSpace(line[1 : len(line)-1]), true
+}
+
+// section returns the header line of a section and the index of the line
+// after it (the next header, or the end of the file)
+func (d *iniDoc) section(name string) (header, end int, ok bool) {
+	header = -1
+	for i, line := range d.lines {
+		n, isHeader := sectionName(line)
+		switch {
+		case !isHeader:
+		case header >= 0:
+			return header, i, true
+		case n == name:
+			header = i
+		}
+	}
+	return header, len(d.lines), header >= 0
+}
+
+// keyLine returns the key of a top-level "key = value" line. Indented lines
+// are sub-properties (such as those under "s3 =" in the config file).
+func keyLine(line string) (key string, eq int, ok bool) {
+	trimmed := strings.TrimRight(line, "\r")
+	if trimmed == "" || trimmed[0] == ' ' || trimmed[0] == '\t' || trimmed[0] == '#' || trimmed[0] == ';' {
+		return "", 0, false
+	}
+	eq = strings.IndexByte(trimmed, '=')
+	if eq < 0 {
+		return "", 0, false
+	}
+	return strings.TrimSpace(trimmed[:eq]), eq, true
+}
+
+// sections returns the section names, in file order
+func (d *iniDoc) sections() []string {
+	var names []string
+	for _, line := range d.lines {
+		if name, ok := sectionName(line); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// get returns the value of a key of a section
+func (d *iniDoc) get(section, key string) (string, bool) {
+	header, end, ok := d.section(section)
+	if !ok {
+		return "", false
+	}
+	for i := header + 1; i < end; i++ {
+		line := strings.TrimRight(d.lines[i], "\r")
+		if k, eq, isKey := keyLine(line); isKey && k == key {
+			return strings.TrimSpace(line[eq+1:]), true
+		}
+	}
+	return "", false
+}
+
+// set sets a key of a section, adding the key after the section's last
+// property (before comments that belong to the next section), or the
+// section at the end of the file
+func (d *iniDoc) set(section, key, value string) {
+	header, end, ok := d.section(section)
+	if !ok {
+		if n := len(d.lines); n > 0 && strings.TrimSpace(d.lines[n-1]) != "" {
+			d.insert(n, "")
+		}
+		d.insert(len(d.lines), "["+section+"]")
+		d.insert(len(d.lines), key+" = "+value)
+		return
+	}
+
+	found := false
+	last := header
+	for i := header + 1; i < end; i++ {
+		line := d.lines[i]
+		if strings.TrimSpace(line) != "" && !isComment(line) {
+			last = i
+		}
+		if k, eq, isKey := keyLine(line); isKey && k == key {
+			// Keep the spacing after '=' and the line ending
+			space := ""
+			if eq+1 < len(line) && line[eq+1] == ' ' {
+				space = " "
+			}
+			cr := ""
+			if strings.HasSuffix(line, "\r") {
+				cr = "\r"
+			}
+			d.lines[i] = line[:eq+1] + space + value + cr
+			found = true
+		}
+	}
+	if !found {
+		d.insert(last+1, key+" = "+value)
+	}
+}
+
+// unset removes a key of a section
+func (d *iniDoc) unset(section, key string) {
+	header, end, ok := d.section(section)
+	if !ok {
+		return
+	}
+	for i := end - 1; i > header; i-- {
+		if k, _, isKey := keyLine(d.lines[i]); isKey && k == key {
+			d.lines = append(d.lines[:i], d.lines[i+1:]...)
+		}
+	}
+}
+
+// insert adds a line before index i with the file's line ending
+func (d *iniDoc) insert(i int, line string) {
+	if d.newline == "\r\n" {
+		line += "\r"
+	}
+	if i == len(d.lines) {
+		// The previous last line gets a line ending, and so does the new one
+		d.trailingNewline = true
+	}
+	d.lines = append(d.lines[:i], append([]string{line}, d.lines[i:]...)...)
+}
+
+// isComment reports whether a line is a comment
+func isComment(line string) bool {
+	line = strings.TrimSpace(line)
+	return strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";")
+}
+
+// deleteSection removes a section, keeping the comments written right above
+// the next one; it reports whether the section existed
+func (d *iniDoc) deleteSection(name string) bool {
+	header, end, ok := d.section(name)
+	if !ok {
+		return false
+	}
+	if end < len(d.lines) {
+		for end-1 > header && isComment(d.lines[end-1]) {
+			end--
+		}
+	} else {
+		// Don't leave the blank lines that separated the last section
+		for header > 0 && strings.TrimSpace(d.lines[header-1]) == "" {
+			header--
+		}
+	}
+	d.lines = append(d.lines[:header], d.lines[end:]...)
+	return true
+}
+
+// updateINI applies update to the ini file at path (created if missing) as
+// one read-modify-write. An advisory lock on path+".lock" serializes
+// concurrent azure2aws processes, and the result is written to a temporary
+// file renamed over path, so readers never see a partial file. A symlinked
+// path is updated at its target.
+func updateINI(path string, update func(*iniDoc) error) error {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	lock, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open lock file: %w", err)
+	}
+	defer lock.Close()
+	if err := lockFile(lock); err != nil {
+		return fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	defer unlockFile(lock)
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to load %s: %w", path, err)
+	}
+	doc := parseINI(data)
+	if err := update(doc); err != nil {
+		if errors.Is(err, errNoChange) {
+			return nil
+		}
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to save %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to set permissions of %s: %w", path, err)
+	}
+	if _, err := tmp.Write(doc.bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save %s: %w", path, err)
+	}
+	return nil
+}
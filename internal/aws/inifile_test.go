@@ -0,0 +1,190 @@
+package aws
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUpdateINIPreservesFile(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		update func(*iniDoc) error
+		after  string
+	}{
+		{
+			name: "replace keys in place",
+			before: "# managed by hand\n[default]\nregion=eu-west-1\naws_access_key_id = OLD\n\n" +
+				"; production\n[prod]\ncli_pager =\naws_access_key_id = PROD\n",
+			update: func(d *iniDoc) error {
+				d.set("default", "aws_access_key_id", "NEW")
+				d.set("default", "region", "us-east-1")
+				return nil
+			},
+			after: "# managed by hand\n[default]\nregion=us-east-1\naws_access_key_id = NEW\n\n" +
+				"; production\n[prod]\ncli_pager =\naws_access_key_id = PROD\n",
+		},
+		{
+			name:   "add key before the next section's comments",
+			before: "[dev]\nregion = eu-west-1\n\n# prod\n[prod]\nregion = us-east-1\n",
+			update: func(d *iniDoc) error {
+				d.set("dev", "output", "json")
+				return nil
+			},
+			after: "[dev]\nregion = eu-west-1\noutput = json\n\n# prod\n[prod]\nregion = us-east-1\n",
+		},
+		{
+			name:   "add section",
+			before: "[dev]\nregion = eu-west-1",
+			update: func(d *iniDoc) error {
+				d.set("profile prod", "region", "us-east-1")
+				return nil
+			},
+			after: "[dev]\nregion = eu-west-1\n\n[profile prod]\nregion = us-east-1\n",
+		},
+		{
+			name:   "keep sub-properties and CRLF",
+			before: "[profile dev]\r\ns3 =\r\n  region = eu-west-1\r\nregion = eu-west-1\r\n",
+			update: func(d *iniDoc) error {
+				d.set("profile dev", "region", "us-east-1")
+				d.set("profile dev", "output", "json")
+				return nil
+			},
+			after: "[profile dev]\r\ns3 =\r\n  region = eu-west-1\r\nregion = us-east-1\r\noutput = json\r\n",
+		},
+		{
+			name:   "delete section",
+			before: "[dev]\naws_access_key_id = DEV\n\n# prod\n[prod]\naws_access_key_id = PROD\n",
+			update: func(d *iniDoc) error {
+				d.deleteSection("dev")
+				return nil
+			},
+			after: "# prod\n[prod]\naws_access_key_id = PROD\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config")
+			if err := os.WriteFile(path, []byte(tt.before), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := updateINI(path, tt.update); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.after {
+				t.Errorf("got:\n%q\nwant:\n%q", got, tt.after)
+			}
+		})
+	}
+}
+
+func TestFileStoreKeepsUnmanagedKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	before := "# personal\n[personal]\naws_access_key_id = AKIAPERSONAL\n\n[dev]\n# set by azure2aws\naws_access_key_id = OLD\nmfa_serial = arn:aws:iam::111111111111:mfa/user\n"
+	if err := os.WriteFile(path, []byte(before), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	store := &FileStore{CredentialsPath: path}
+	expiration := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := store.Save("dev", &Credentials{AccessKeyID: "NEW", SecretAccessKey: "secret", SessionToken: "token", Expiration: expiration}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# personal\n[personal]\naws_access_key_id = AKIAPERSONAL\n\n[dev]\n# set by azure2aws\naws_access_key_id = NEW\nmfa_serial = arn:aws:iam::111111111111:mfa/user\n" +
+		"aws_secret_access_key = secret\naws_session_token = token\nx_security_token_expires = 2030-01-01T00:00:00Z\n"
+	if string(got) != want {
+		t.Errorf("got:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestFileStoreExpirationKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	store := &FileStore{CredentialsPath: path, ExpirationKeys: []string{"x_security_token_expires", "aws_expiration"}}
+	expiration := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := store.Save("dev", &Credentials{AccessKeyID: "AKIA", Expiration: expiration}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Keys no longer selected are removed; the expiration is read back from
+	// any of them
+	store.ExpirationKeys = []string{"expiration"}
+	if err := store.Save("dev", &Credentials{AccessKeyID: "AKIA", Expiration: expiration}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[dev]\naws_access_key_id = AKIA\naws_secret_access_key = \naws_session_token = \nexpiration = 2030-01-01T00:00:00Z\n"
+	if string(got) != want {
+		t.Errorf("got:\n%q\nwant:\n%q", got, want)
+	}
+
+	creds, err := store.Load("dev")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !creds.Expiration.Equal(expiration) {
+		t.Errorf("expected expiration %s, got %s", expiration, creds.Expiration)
+	}
+}
+
+func TestSaveConfigProfile(t *testing.T) {
+	before := "[profile dev]\nregion = eu-west-1\ncli_pager =\n"
+	tests := []struct {
+		name    string
+		profile string
+		values  []configValue
+		after   string
+	}{
+		{
+			name:    "update a profile, keeping other keys",
+			profile: "dev",
+			values:  []configValue{{"region", "us-east-1"}, {"output", outputOrJSON("")}},
+			after:   "[profile dev]\nregion = us-east-1\ncli_pager =\noutput = json\n",
+		},
+		{
+			name:    "empty values are left unchanged",
+			profile: "dev",
+			values:  []configValue{{"role_arn", "arn:aws:iam::123456789012:role/Admin"}, {"source_profile", "base"}, {"region", ""}, {"output", ""}},
+			after:   "[profile dev]\nregion = eu-west-1\ncli_pager =\nrole_arn = arn:aws:iam::123456789012:role/Admin\nsource_profile = base\n",
+		},
+		{
+			name:    "default profile section",
+			profile: "default",
+			values:  []configValue{{"credential_process", "azure2aws credential-process"}},
+			after:   before + "\n[default]\ncredential_process = azure2aws credential-process\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config")
+			if err := os.WriteFile(path, []byte(before), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := saveConfigProfile(path, tt.profile, tt.values...); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.after {
+				t.Errorf("got:\n%s\nwant:\n%s", data, tt.after)
+			}
+		})
+	}
+}
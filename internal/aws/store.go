@@ -0,0 +1,333 @@
+package aws
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/user/azure2aws/internal/cache"
+	"github.com/user/azure2aws/internal/keyring"
+	"github.com/user/azure2aws/internal/state"
+	"gopkg.in/ini.v1"
+)
+
+// ErrCredentialsNotFound is returned when a store has no credentials for a
+// profile
+var ErrCredentialsNotFound = errors.New("credentials not found")
+
+// CredentialStore saves and loads the AWS credentials of profiles
+type CredentialStore interface {
+	// Save stores the credentials, replacing any existing ones
+	Save(profile string, creds *Credentials) error
+	// Load returns the credentials, or ErrCredentialsNotFound
+	Load(profile string) (*Credentials, error)
+	// Delete removes the credentials, or returns ErrCredentialsNotFound
+	Delete(profile string) error
+}
+
+// Expired reports whether a profile's credentials in store are missing,
+// have no expiration, or are about to expire
+func Expired(store CredentialStore, profile string) bool {
+	creds, err := store.Load(profile)
+	if err != nil || creds.Expiration.IsZero() {
+		return true
+	}
+	return IsExpired(creds.Expiration)
+}
+
+// FileStore keeps credentials in the shared credentials file, where the AWS
+// CLI and SDKs read them
+type FileStore struct {
+	CredentialsPath string
+	// ConfigPath receives the region and output of saved profiles; empty
+	// leaves the config file alone
+	ConfigPath string
+	// ExpirationKeys are the keys the expiration is written under (see
+	// expirationKeys); empty writes x_security_token_expires
+	ExpirationKeys []string
+}
+
+// expirationKeys are the keys an expiration is read from, in order: azure2aws's
+// own, then those of other tools. Save removes the ones not selected, so a
+// stale expiration is never left behind.
+var expirationKeys = []string{"x_security_token_expires", "aws_expiration", "expiration"}
+
+// NewFileStore returns a store on the default shared files (honoring
+// AWS_SHARED_CREDENTIALS_FILE and AWS_CONFIG_FILE)
+func NewFileStore() (*FileStore, error) {
+	credPath, err := DefaultCredentialsPath()
+	if err != nil {
+		return nil, err
+	}
+	configPath, err := DefaultConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	return &FileStore{CredentialsPath: credPath, ConfigPath: configPath}, nil
+}
+
+// Save implements CredentialStore
+func (s *FileStore) Save(profile string, creds *Credentials) error {
+	err := updateINI(s.CredentialsPath, func(doc *iniDoc) error {
+		doc.set(profile, "aws_access_key_id", creds.AccessKeyID)
+		doc.set(profile, "aws_secret_access_key", creds.SecretAccessKey)
+		doc.set(profile, "aws_session_token", creds.SessionToken)
+
+		selected := s.ExpirationKeys
+		if len(selected) == 0 {
+			selected = expirationKeys[:1]
+		}
+		expires := make(map[string]bool)
+		for _, key := range selected {
+			expires[key] = true
+		}
+		for _, key := range expirationKeys {
+			if expires[key] {
+				doc.set(profile, key, creds.Expiration.Format(time.RFC3339))
+			} else {
+				doc.unset(profile, key)
+			}
+		}
+
+		if creds.AssumedRoleARN != "" {
+			doc.set(profile, "x_principal_arn", creds.AssumedRoleARN)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save credentials: %w", err)
+	}
+
+	if s.ConfigPath != "" {
+		if err := saveAWSConfig(s.ConfigPath, profile, creds.Region, creds.Output); err != nil {
+			return fmt.Errorf("failed to save AWS config: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Load implements CredentialStore
+func (s *FileStore) Load(profile string) (*Credentials, error) {
+	cfg, err := ini.Load(s.CredentialsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load credentials file: %w", err)
+	}
+
+	section, err := cfg.GetSection(profile)
+	if err != nil {
+		return nil, fmt.Errorf("profile %s not found: %w", profile, ErrCredentialsNotFound)
+	}
+
+	creds := &Credentials{
+		AccessKeyID:     section.Key("aws_access_key_id").String(),
+		SecretAccessKey: section.Key("aws_secret_access_key").String(),
+		SessionToken:    section.Key("aws_session_token").String(),
+		Region:          section.Key("region").String(),
+		AssumedRoleARN:  section.Key("x_principal_arn").String(),
+	}
+
+	// Parse expiration time if present
+	for _, key := range expirationKeys {
+		if expStr := section.Key(key).String(); expStr != "" {
+			if exp, err := time.Parse(time.RFC3339, expStr); err == nil {
+				creds.Expiration = exp
+				break
+			}
+		}
+	}
+
+	return creds, nil
+}
+
+// Delete implements CredentialStore
+func (s *FileStore) Delete(profile string) error {
+	if _, err := os.Stat(s.CredentialsPath); errors.Is(err, os.ErrNotExist) {
+		return ErrCredentialsNotFound
+	}
+
+	found := false
+	err := updateINI(s.CredentialsPath, func(doc *iniDoc) error {
+		if found = doc.deleteSection(profile); !found {
+			return errNoChange
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete credentials: %w", err)
+	}
+	if !found {
+		return ErrCredentialsNotFound
+	}
+	return nil
+}
+
+// Prune removes the profiles whose expiration (under any of the keys
+// azure2aws writes) is before now, and returns their names and expirations.
+// Profiles without one are not azure2aws's and are kept. With dryRun, the
+// file is left alone.
+func (s *FileStore) Prune(now time.Time, dryRun bool) (map[string]time.Time, error) {
+	if _, err := os.Stat(s.CredentialsPath); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	pruned := make(map[string]time.Time)
+	err := updateINI(s.CredentialsPath, func(doc *iniDoc) error {
+		for _, profile := range doc.sections() {
+			for _, key := range expirationKeys {
+				value, _ := doc.get(profile, key)
+				exp, err := time.Parse(time.RFC3339, value)
+				if err != nil {
+					continue
+				}
+				if exp.Before(now) {
+					pruned[profile] = exp
+				}
+				break
+			}
+		}
+		if dryRun || len(pruned) == 0 {
+			return errNoChange
+		}
+		for profile := range pruned {
+			doc.deleteSection(profile)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to prune credentials: %w", err)
+	}
+	return pruned, nil
+}
+
+// KeyringStore keeps credentials in the keyring. Their expiration and role
+// are recorded in the profile's state file, so they can be listed without
+// unlocking the keyring.
+type KeyringStore struct {
+	// Backend is the keyring backend Save stores credentials in
+	Backend keyring.Backend
+	// Options configure the backend the credentials are read back with
+	Options keyring.Options
+}
+
+// Save implements CredentialStore
+func (s *KeyringStore) Save(profile string, creds *Credentials) error {
+	data, err := json.Marshal(creds)
+	if err != nil {
+		return fmt.Errorf("failed to encode credentials: %w", err)
+	}
+	if err := cache.New(s.Backend).Put(profile, cache.KindAWSCredentials, string(data), creds.Expiration); err != nil {
+		return err
+	}
+
+	st, err := state.Load(profile)
+	if err != nil {
+		// Start over rather than fail on a corrupt state file
+		st = &state.State{}
+	}
+	st.KeyringCredentials = &state.KeyringCredentials{
+		Backend:        s.Backend.Name(),
+		Expiration:     creds.Expiration.UTC(),
+		AssumedRoleARN: creds.AssumedRoleARN,
+	}
+	return state.Save(profile, st)
+}
+
+// Load implements CredentialStore; it reads from the backend the credentials
+// were saved with
+func (s *KeyringStore) Load(profile string) (*Credentials, error) {
+	st, err := state.Load(profile)
+	if err != nil || st.KeyringCredentials == nil {
+		return nil, ErrCredentialsNotFound
+	}
+
+	artifacts, err := s.savedIn(st)
+	if err != nil {
+		return nil, err
+	}
+	data, err := artifacts.Get(profile, cache.KindAWSCredentials, 0)
+	if errors.Is(err, cache.ErrMiss) {
+		return nil, fmt.Errorf("%w in the keyring (expired at %s)", ErrCredentialsNotFound, st.KeyringCredentials.Expiration.Local().Format(time.RFC3339))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var creds Credentials
+	if err := json.Unmarshal([]byte(data), &creds); err != nil {
+		return nil, fmt.Errorf("failed to decode credentials from the keyring: %w", err)
+	}
+	return &creds, nil
+}
+
+// Delete implements CredentialStore
+func (s *KeyringStore) Delete(profile string) error {
+	st, err := state.Load(profile)
+	if err != nil || st.KeyringCredentials == nil {
+		return ErrCredentialsNotFound
+	}
+
+	artifacts, err := s.savedIn(st)
+	if err != nil {
+		return err
+	}
+	if err := artifacts.Delete(profile, cache.KindAWSCredentials); err != nil {
+		return err
+	}
+	st.KeyringCredentials = nil
+	return state.Save(profile, st)
+}
+
+// savedIn returns the cache in the backend a profile's credentials were
+// saved with
+func (s *KeyringStore) savedIn(st *state.State) (*cache.Cache, error) {
+	backend, err := keyring.SelectBackend(st.KeyringCredentials.Backend, s.Options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select keyring backend: %w", err)
+	}
+	return cache.New(backend), nil
+}
+
+// MemoryStore keeps credentials in memory, for the credential daemon
+type MemoryStore struct {
+	mu    sync.Mutex
+	creds map[string]*Credentials
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{creds: make(map[string]*Credentials)}
+}
+
+// Save implements CredentialStore
+func (s *MemoryStore) Save(profile string, creds *Credentials) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.creds[profile] = creds
+	return nil
+}
+
+// Load implements CredentialStore
+func (s *MemoryStore) Load(profile string) (*Credentials, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	creds, ok := s.creds[profile]
+	if !ok {
+		return nil, ErrCredentialsNotFound
+	}
+	return creds, nil
+}
+
+// Delete implements CredentialStore
+func (s *MemoryStore) Delete(profile string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.creds[profile]; !ok {
+		return ErrCredentialsNotFound
+	}
+	delete(s.creds, profile)
+	return nil
+}
@@ -0,0 +1,187 @@
+package aws
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	store := &FileStore{
+		CredentialsPath: filepath.Join(dir, "credentials"),
+		ConfigPath:      filepath.Join(dir, "config"),
+	}
+
+	if _, err := store.Load("dev"); err == nil {
+		t.Error("expected error loading from a missing file")
+	}
+	if err := store.Delete("dev"); !errors.Is(err, ErrCredentialsNotFound) {
+		t.Errorf("expected ErrCredentialsNotFound deleting from a missing file, got %v", err)
+	}
+
+	expiration := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+	creds := &Credentials{
+		AccessKeyID:     "AKIAEXAMPLE",
+		SecretAccessKey: "secret",
+		SessionToken:    "token",
+		Expiration:      expiration,
+		AssumedRoleARN:  "arn:aws:sts::111111111111:assumed-role/Admin/user",
+	}
+	if err := store.Save("dev", creds); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := store.Load("dev")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.AccessKeyID != creds.AccessKeyID || got.SessionToken != creds.SessionToken ||
+		!got.Expiration.Equal(expiration) || got.AssumedRoleARN != creds.AssumedRoleARN {
+		t.Errorf("credentials did not round-trip: %+v", got)
+	}
+	if Expired(store, "dev") {
+		t.Error("expected credentials valid for an hour not to be expired")
+	}
+
+	if _, err := store.Load("prod"); !errors.Is(err, ErrCredentialsNotFound) {
+		t.Errorf("expected ErrCredentialsNotFound, got %v", err)
+	}
+	if !Expired(store, "prod") {
+		t.Error("expected missing credentials to be expired")
+	}
+
+	if err := store.Delete("dev"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := store.Load("dev"); !errors.Is(err, ErrCredentialsNotFound) {
+		t.Errorf("expected ErrCredentialsNotFound after delete, got %v", err)
+	}
+}
+
+func TestFileStoreConcurrentSaves(t *testing.T) {
+	dir := t.TempDir()
+	store := &FileStore{CredentialsPath: filepath.Join(dir, "credentials")}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			creds := &Credentials{AccessKeyID: fmt.Sprintf("AKIA%d", i), Expiration: time.Now().Add(time.Hour)}
+			if err := store.Save(fmt.Sprintf("profile-%d", i), creds); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < 20; i++ {
+		if _, err := store.Load(fmt.Sprintf("profile-%d", i)); err != nil {
+			t.Errorf("profile-%d lost by a concurrent save: %v", i, err)
+		}
+	}
+
+	info, err := os.Stat(store.CredentialsPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if perm := info.Mode().Perm(); runtime.GOOS != "windows" && perm != 0600 {
+		t.Errorf("expected permissions 0600, got %o", perm)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+
+	creds := &Credentials{AccessKeyID: "AKIAEXAMPLE", Expiration: time.Now().Add(2 * time.Minute)}
+	if err := store.Save("dev", creds); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, err := store.Load("dev"); err != nil || got != creds {
+		t.Errorf("expected the saved credentials, got %v, %v", got, err)
+	}
+	if !Expired(store, "dev") {
+		t.Error("expected credentials expiring within 5 minutes to be expired")
+	}
+
+	if err := store.Delete("dev"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.Delete("dev"); !errors.Is(err, ErrCredentialsNotFound) {
+		t.Errorf("expected ErrCredentialsNotFound, got %v", err)
+	}
+}
+
+func TestSaveCLICache(t *testing.T) {
+	roleARN := "arn:aws:iam::123456789012:role/path/Admin"
+	// hashlib.sha1(json.dumps({"RoleArn": roleARN}, sort_keys=True).encode()).hexdigest()
+	const key = "d75d062a74fcf35b59b67bc43c6a1305eb97edd4"
+	if got := CLICacheKey(roleARN); got != key {
+		t.Fatalf("expected cache key %s, got %s", key, got)
+	}
+
+	dir := filepath.Join(t.TempDir(), "cache")
+	creds := &Credentials{
+		AccessKeyID:     "ASIAEXAMPLE",
+		SecretAccessKey: "secret",
+		SessionToken:    "token",
+		Expiration:      time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC),
+		AssumedRoleARN:  "arn:aws:sts::123456789012:assumed-role/Admin/azure2aws",
+	}
+	if err := SaveCLICache(dir, roleARN, creds); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, key+".json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var entry struct {
+		Credentials     map[string]string
+		AssumedRoleUser map[string]string
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if entry.Credentials["AccessKeyId"] != "ASIAEXAMPLE" || entry.Credentials["SessionToken"] != "token" ||
+		entry.Credentials["Expiration"] != "2030-01-01T12:00:00Z" || entry.AssumedRoleUser["Arn"] != creds.AssumedRoleARN {
+		t.Errorf("unexpected cache entry: %s", data)
+	}
+}
+
+func TestFileStorePrune(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	before := "[static]\naws_access_key_id = AKIASTATIC\n\n# live\n[live]\nx_security_token_expires = 2030-01-01T00:00:00Z\n\n" +
+		"[old]\nx_security_token_expires = 2020-01-01T00:00:00Z\n\n[other]\naws_expiration = 2020-01-01T00:00:00Z\n"
+	if err := os.WriteFile(path, []byte(before), 0600); err != nil {
+		t.Fatal(err)
+	}
+	store := &FileStore{CredentialsPath: path}
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	pruned, err := store.Prune(now, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pruned) != 2 || pruned["old"].IsZero() || pruned["other"].IsZero() {
+		t.Errorf("expected old and other to be pruned, got %v", pruned)
+	}
+	if got, _ := os.ReadFile(path); string(got) != before {
+		t.Errorf("dry run changed the file:\n%q", got)
+	}
+
+	if _, err := store.Prune(now, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "[static]\naws_access_key_id = AKIASTATIC\n\n# live\n[live]\nx_security_token_expires = 2030-01-01T00:00:00Z\n"
+	if got, _ := os.ReadFile(path); string(got) != want {
+		t.Errorf("got:\n%q\nwant:\n%q", got, want)
+	}
+}
@@ -2,26 +2,37 @@ package aws
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/aws/smithy-go"
+	"github.com/user/azure2aws/internal/partition"
 	"github.com/user/azure2aws/internal/saml"
 )
 
-func AssumeRoleWithSAML(role *saml.AWSRole, samlAssertion string, durationSeconds int32, region, output string) (*Credentials, error) {
-	ctx := context.Background()
+// SessionPolicy limits the permissions of a session below those of the role
+type SessionPolicy struct {
+	Policy     string   // Inline policy JSON
+	PolicyARNs []string // ARNs of managed policies
+}
 
+// AssumeRoleWithSAML exchanges a SAML assertion for role credentials. policy
+// may be nil.
+func (c *Client) AssumeRoleWithSAML(ctx context.Context, role *saml.AWSRole, samlAssertion string, durationSeconds int32, region, output string, policy *SessionPolicy) (*Credentials, error) {
 	if region == "" {
-		region = "us-east-1"
+		region = defaultRegion(role.RoleARN)
 	}
 
 	cfg := aws.Config{
 		Region: region,
 	}
 
-	stsClient := sts.NewFromConfig(cfg)
+	stsClient := c.newSTSClient(cfg)
 
 	input := &sts.AssumeRoleWithSAMLInput{
 		RoleArn:         aws.String(role.RoleARN),
@@ -29,6 +40,14 @@ func AssumeRoleWithSAML(role *saml.AWSRole, samlAssertion string, durationSecond
 		SAMLAssertion:   aws.String(samlAssertion),
 		DurationSeconds: aws.Int32(durationSeconds),
 	}
+	if policy != nil {
+		if policy.Policy != "" {
+			input.Policy = aws.String(policy.Policy)
+		}
+		for _, arn := range policy.PolicyARNs {
+			input.PolicyArns = append(input.PolicyArns, types.PolicyDescriptorType{Arn: aws.String(arn)})
+		}
+	}
 
 	result, err := stsClient.AssumeRoleWithSAML(ctx, input)
 	if err != nil {
@@ -55,6 +74,157 @@ func AssumeRoleWithSAML(role *saml.AWSRole, samlAssertion string, durationSecond
 	return creds, nil
 }
 
+// AssumeRole assumes a chained role using existing credentials as the source
+func (c *Client) AssumeRole(ctx context.Context, source *Credentials, roleARN, sessionName string, durationSeconds int32, region, output string) (*Credentials, error) {
+	if region == "" {
+		region = source.Region
+	}
+	if region == "" {
+		region = defaultRegion(roleARN)
+	}
+
+	cfg := aws.Config{
+		Region:      region,
+		Credentials: staticCredentialsProvider(source),
+	}
+
+	stsClient := c.newSTSClient(cfg)
+
+	input := &sts.AssumeRoleInput{
+		RoleArn:         aws.String(roleARN),
+		RoleSessionName: aws.String(sessionName),
+		DurationSeconds: aws.Int32(durationSeconds),
+	}
+
+	result, err := stsClient.AssumeRole(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to assume role: %w", err)
+	}
+
+	if result.Credentials == nil {
+		return nil, fmt.Errorf("no credentials returned from AssumeRole")
+	}
+
+	creds := &Credentials{
+		AccessKeyID:     aws.ToString(result.Credentials.AccessKeyId),
+		SecretAccessKey: aws.ToString(result.Credentials.SecretAccessKey),
+		SessionToken:    aws.ToString(result.Credentials.SessionToken),
+		Expiration:      aws.ToTime(result.Credentials.Expiration),
+		Region:          region,
+		Output:          output,
+	}
+
+	if result.AssumedRoleUser != nil {
+		creds.AssumedRoleARN = aws.ToString(result.AssumedRoleUser.Arn)
+	}
+
+	return creds, nil
+}
+
+// CallerIdentity describes the principal behind a set of credentials
+type CallerIdentity struct {
+	Account string
+	ARN     string
+	UserID  string
+}
+
+// GetCallerIdentity calls sts:GetCallerIdentity using the given credentials
+func (c *Client) GetCallerIdentity(ctx context.Context, creds *Credentials) (*CallerIdentity, error) {
+	region := creds.Region
+	if region == "" {
+		region = defaultRegion(creds.AssumedRoleARN)
+	}
+
+	cfg := aws.Config{
+		Region:      region,
+		Credentials: staticCredentialsProvider(creds),
+	}
+
+	stsClient := c.newSTSClient(cfg)
+
+	result, err := stsClient.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller identity: %w", err)
+	}
+
+	return &CallerIdentity{
+		Account: aws.ToString(result.Account),
+		ARN:     aws.ToString(result.Arn),
+		UserID:  aws.ToString(result.UserId),
+	}, nil
+}
+
+// defaultRegion returns the default region of the partition an ARN is in
+func defaultRegion(arn string) string {
+	if p := partition.ForARN(arn); p != nil {
+		return p.DefaultRegion
+	}
+	return partition.ForRegion("").DefaultRegion
+}
+
+// staticCredentialsProvider adapts stored credentials to the SDK provider interface
+func staticCredentialsProvider(creds *Credentials) aws.CredentialsProvider {
+	return aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		return aws.Credentials{
+			AccessKeyID:     creds.AccessKeyID,
+			SecretAccessKey: creds.SecretAccessKey,
+			SessionToken:    creds.SessionToken,
+			Source:          "azure2aws",
+			CanExpire:       !creds.Expiration.IsZero(),
+			Expires:         creds.Expiration,
+		}, nil
+	})
+}
+
+// RoleARNFromAssumedRole converts an STS assumed-role ARN
+// (arn:aws:sts::123456789012:assumed-role/RoleName/session) to the IAM role
+// ARN (arn:aws:iam::123456789012:role/RoleName). Role paths are not preserved.
+// Returns an empty string if the ARN is not an assumed-role ARN.
+func RoleARNFromAssumedRole(assumedRoleARN string) string {
+	parts := strings.SplitN(assumedRoleARN, ":", 6)
+	if len(parts) != 6 || parts[2] != "sts" {
+		return ""
+	}
+
+	resource := strings.Split(parts[5], "/")
+	if len(resource) < 2 || resource[0] != "assumed-role" {
+		return ""
+	}
+
+	return fmt.Sprintf("arn:%s:iam::%s:role/%s", parts[1], parts[4], resource[1])
+}
+
+// SameRole reports whether a role ARN refers to the role behind an assumed-role ARN
+func SameRole(roleARN, assumedRoleARN string) bool {
+	current := RoleARNFromAssumedRole(assumedRoleARN)
+	if current == "" {
+		return false
+	}
+
+	// Role paths are not part of the assumed-role ARN, so compare without them
+	return stripRolePath(roleARN) == current
+}
+
+// stripRolePath removes the path from an IAM role ARN
+func stripRolePath(roleARN string) string {
+	idx := strings.Index(roleARN, ":role/")
+	if idx < 0 {
+		return roleARN
+	}
+	name := roleARN[idx+len(":role/"):]
+	if slash := strings.LastIndex(name, "/"); slash >= 0 {
+		name = name[slash+1:]
+	}
+	return roleARN[:idx] + ":role/" + name
+}
+
+// MaxChainedSessionDuration is the maximum session duration AWS allows for role chaining
+const MaxChainedSessionDuration = 3600
+
+// DefaultSessionDuration is the session duration used when none is configured.
+// It is the default MaxSessionDuration of IAM roles, so every role allows it.
+const DefaultSessionDuration = 3600
+
 func GetSessionDuration(configuredDuration int, samlDuration int64) int32 {
 	if configuredDuration > 0 {
 		return int32(configuredDuration)
@@ -62,7 +232,16 @@ func GetSessionDuration(configuredDuration int, samlDuration int64) int32 {
 	if samlDuration > 0 {
 		return int32(samlDuration)
 	}
-	return 3600
+	return DefaultSessionDuration
+}
+
+// IsDurationTooLong reports whether STS rejected a request because the
+// requested DurationSeconds exceeds the role's MaxSessionDuration
+func IsDurationTooLong(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) &&
+		apiErr.ErrorCode() == "ValidationError" &&
+		strings.Contains(apiErr.ErrorMessage(), "MaxSessionDuration")
 }
 
 func IsExpired(expiration time.Time) bool {
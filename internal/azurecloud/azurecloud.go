@@ -0,0 +1,101 @@
+// Package azurecloud describes the Azure clouds azure2aws can sign in with:
+// the global cloud, Azure Government and Azure China (operated by 21Vianet).
+package azurecloud
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/user/azure2aws/internal/partition"
+)
+
+// Cloud IDs, as used in the azure_cloud setting
+const (
+	Public       = "public"
+	USGovernment = "usgovernment"
+	China        = "china"
+)
+
+// Cloud holds the Azure AD endpoints of a cloud
+type Cloud struct {
+	ID string
+	// LoginURL is the Azure AD sign-in host
+	LoginURL string
+	// AppsURL is the default base URL starting sign-ins to applications
+	AppsURL string
+	// IssuerPrefix starts the Issuer of the SAML assertions it issues,
+	// followed by the tenant ID
+	IssuerPrefix string
+	// Partition is the AWS partition the cloud is paired with
+	Partition string
+}
+
+var clouds = map[string]*Cloud{
+	Public: {
+		ID:           Public,
+		LoginURL:     "https://login.microsoftonline.com/",
+		AppsURL:      "https://account.activedirectory.windowsazure.com",
+		IssuerPrefix: "https://sts.windows.net/",
+		Partition:    partition.AWS,
+	},
+	USGovernment: {
+		ID:           USGovernment,
+		LoginURL:     "https://login.microsoftonline.us/",
+		AppsURL:      "https://account.activedirectory.windowsazure.us",
+		IssuerPrefix: "https://login.microsoftonline.us/",
+		Partition:    partition.GovCloud,
+	},
+	China: {
+		ID:           China,
+		LoginURL:     "https://login.chinacloudapi.cn/",
+		AppsURL:      "https://account.activedirectory.windowsazure.cn",
+		IssuerPrefix: "https://sts.chinacloudapi.cn/",
+		Partition:    partition.China,
+	},
+}
+
+// tenantIDPattern matches a tenant ID (a GUID), as opposed to a domain
+var tenantIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// IsTenantID reports whether s is a tenant ID rather than a domain
+func IsTenantID(s string) bool {
+	return tenantIDPattern.MatchString(s)
+}
+
+// Issuer returns the Issuer of the SAML assertions a tenant of the cloud
+// issues
+func (c *Cloud) Issuer(tenantID string) string {
+	return c.IssuerPrefix + strings.ToLower(tenantID) + "/"
+}
+
+// IDs lists the supported cloud IDs
+var IDs = []string{Public, USGovernment, China}
+
+// Lookup returns the cloud with the given ID; "" is the global cloud
+func Lookup(id string) (*Cloud, error) {
+	if id == "" {
+		return clouds[Public], nil
+	}
+	c, ok := clouds[strings.ToLower(id)]
+	if !ok {
+		return nil, fmt.Errorf("unknown azure_cloud %q (supported: %s)", id, strings.Join(IDs, ", "))
+	}
+	return c, nil
+}
+
+// ForIssuer returns the cloud and tenant of a SAML assertion's Issuer
+func ForIssuer(issuer string) (*Cloud, string, error) {
+	for _, id := range IDs {
+		c := clouds[id]
+		if !strings.HasPrefix(issuer, c.IssuerPrefix) {
+			continue
+		}
+		tenant := strings.Trim(strings.TrimPrefix(issuer, c.IssuerPrefix), "/")
+		if tenant == "" || strings.Contains(tenant, "/") {
+			return nil, "", fmt.Errorf("failed to determine tenant from issuer %q", issuer)
+		}
+		return c, tenant, nil
+	}
+	return nil, "", fmt.Errorf("issuer %q is not an Azure AD tenant", issuer)
+}
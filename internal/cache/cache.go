@@ -0,0 +1,120 @@
+// Package cache stores short-lived authentication artifacts (SAML assertions,
+// Azure AD session cookies) in the keyring so later commands can reuse them
+// without re-prompting for a password or MFA.
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/user/azure2aws/internal/keyring"
+)
+
+// ServiceName is the keyring service holding cached artifacts
+const ServiceName = "azure2aws-cache"
+
+// Artifact kinds
+const (
+	// KindSAMLAssertion is the last base64-encoded SAML response
+	KindSAMLAssertion = "saml-assertion"
+	// KindAzureCookies are the Azure AD session cookies (JSON)
+	KindAzureCookies = "azure-cookies"
+	// KindAWSCredentials are the AWS credentials of a profile with
+	// credential_storage: keyring (JSON)
+	KindAWSCredentials = "aws-credentials"
+)
+
+// kinds lists every artifact kind, for Clear
+var kinds = []string{KindSAMLAssertion, KindAzureCookies, KindAWSCredentials}
+
+// ErrMiss is returned when an artifact is not cached or has expired
+var ErrMiss = errors.New("cache miss")
+
+// entry is the stored form of a cached artifact
+type entry struct {
+	Value     string    `json:"value"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Cache reads and writes artifacts for profiles
+type Cache struct {
+	kr  *keyring.Keyring
+	now func() time.Time
+}
+
+// New creates a cache stored in a keyring backend
+func New(backend keyring.Backend) *Cache {
+	return &Cache{
+		kr:  keyring.NewWithBackend(ServiceName, backend),
+		now: time.Now,
+	}
+}
+
+// Put stores an artifact until expiresAt
+func (c *Cache) Put(profile, kind, value string, expiresAt time.Time) error {
+	if !expiresAt.After(c.now()) {
+		return fmt.Errorf("refusing to cache already expired %s", kind)
+	}
+
+	data, err := json.Marshal(entry{Value: value, ExpiresAt: expiresAt.UTC()})
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+
+	if err := c.kr.SavePassword(key(profile, kind), string(data)); err != nil {
+		return fmt.Errorf("failed to cache %s: %w", kind, err)
+	}
+	return nil
+}
+
+// Get returns an artifact that is still valid for at least minValidity,
+// or ErrMiss. Expired entries are removed.
+func (c *Cache) Get(profile, kind string, minValidity time.Duration) (string, error) {
+	data, err := c.kr.GetPassword(key(profile, kind))
+	if errors.Is(err, keyring.ErrPasswordNotFound) {
+		return "", ErrMiss
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var e entry
+	if err := json.Unmarshal([]byte(data), &e); err != nil {
+		_ = c.Delete(profile, kind)
+		return "", ErrMiss
+	}
+
+	if !e.ExpiresAt.After(c.now().Add(minValidity)) {
+		if !e.ExpiresAt.After(c.now()) {
+			_ = c.Delete(profile, kind)
+		}
+		return "", ErrMiss
+	}
+
+	return e.Value, nil
+}
+
+// Delete removes an artifact
+func (c *Cache) Delete(profile, kind string) error {
+	err := c.kr.DeletePassword(key(profile, kind))
+	if errors.Is(err, keyring.ErrPasswordNotFound) {
+		return nil
+	}
+	return err
+}
+
+// Clear removes all artifacts for a profile
+func (c *Cache) Clear(profile string) error {
+	for _, kind := range kinds {
+		if err := c.Delete(profile, kind); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func key(profile, kind string) string {
+	return profile + ":" + kind
+}
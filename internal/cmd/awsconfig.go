@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/user/azure2aws/internal/aws"
+	"github.com/user/azure2aws/internal/config"
+	"github.com/user/azure2aws/internal/output"
+	"github.com/user/azure2aws/internal/saml"
+	"github.com/user/azure2aws/internal/state"
+)
+
+type awsConfigGenerateOptions struct {
+	prefix string
+	group  string
+	dryRun bool
+}
+
+func newAWSConfigGenerateCmd(cc *CommandContext) *cobra.Command {
+	var opts awsConfigGenerateOptions
+
+	cmd := &cobra.Command{
+		Use:   "aws-config-generate",
+		Short: "Generate AWS config profiles for every role of a profile",
+		Long: `Creates a profile for every role offered to a profile at its last login
+(see 'list-roles'), named <prefix><account>-<role>, where the account is its
+name from the accounts config section or discovered alias, or else its ID.
+
+Each generated profile is a copy of the profile with role_arn set to the role
+and credential_storage set to keyring, and is added to a group. Its section in
+~/.aws/config gets the region, the output and a credential_process entry
+running 'azure2aws credential-process', so the AWS CLI and SDKs read the
+credentials from the keyring. Log in to all of them with one Azure AD
+sign-in with 'azure2aws login --group <group>'.
+
+Running it again updates the generated profiles and adds new roles. A profile
+of the same name with another role_arn is left alone.
+
+Example:
+  azure2aws list-roles --profile production
+  azure2aws aws-config-generate --profile production
+  azure2aws login --group production`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !cmd.Flags().Changed("prefix") {
+				opts.prefix = cc.Profile + "-"
+			}
+			if opts.group == "" {
+				opts.group = cc.Profile
+			}
+			return runAWSConfigGenerate(cc, opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.prefix, "prefix", "", `Prefix of the generated profile names (default: "<profile>-")`)
+	cmd.Flags().StringVar(&opts.group, "group", "", "Group the generated profiles are added to (default: the profile name)")
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "Print the AWS config sections without writing anything")
+
+	return cmd
+}
+
+func runAWSConfigGenerate(cc *CommandContext, opts awsConfigGenerateOptions) error {
+	profileName := cc.Profile
+
+	cfg, err := cc.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	source, ok := cfg.Profiles[profileName]
+	if !ok {
+		return fmt.Errorf("profile %q not found in config\nRun 'azure2aws configure --profile %s' to set it up", profileName, profileName)
+	}
+	profile, err := cfg.GetProfile(profileName)
+	if err != nil {
+		return err
+	}
+
+	st, err := state.Load(profileName)
+	if err != nil {
+		return err
+	}
+	if len(st.Roles) == 0 {
+		return fmt.Errorf("no roles recorded for profile '%s'\nRun 'azure2aws login --profile %s' first", profileName, profileName)
+	}
+
+	command := "azure2aws credential-process"
+	if defaultPath, err := config.DefaultConfigPath(); err != nil || cc.ConfigFile != defaultPath {
+		command += " --config " + posixQuote(cc.ConfigFile)
+	}
+
+	accounts := accountNames(profileName, profile)
+	var generated []string
+	for _, r := range st.Roles {
+		role := &saml.AWSRole{RoleARN: r.RoleARN, Name: r.RoleARN[strings.LastIndex(r.RoleARN, "/")+1:]}
+		account := accounts[role.AccountID()]
+		if account == "" {
+			account = role.AccountID()
+		}
+		name := opts.prefix + profileNameSafe(account+"-"+role.Name)
+
+		if existing, ok := cfg.Profiles[name]; ok && existing.RoleARN != role.RoleARN {
+			output.Statusf("Skipping %s: profile '%s' already exists with role_arn %s\n", role.RoleARN, name, existing.RoleARN)
+			continue
+		}
+
+		generatedProfile := source
+		generatedProfile.RoleARN = role.RoleARN
+		generatedProfile.RoleFilter = ""
+		generatedProfile.ChainedRoles = nil
+		generatedProfile.ChainMode = ""
+		generatedProfile.CredentialStorage = config.CredentialStorageKeyring
+
+		process := command + " --profile " + name
+		if opts.dryRun {
+			output.Printf("[%s]\ncredential_process = %s\n", awsConfigSection(name), process)
+			if profile.Region != "" {
+				output.Printf("region = %s\n", profile.Region)
+			}
+			output.Printf("output = %s\n\n", outputOrDefault(profile.Output))
+			continue
+		}
+
+		cfg.SetProfile(name, generatedProfile)
+		if err := aws.SaveProcessProfileConfig(name, process, profile.Region, profile.Output); err != nil {
+			return fmt.Errorf("failed to write AWS profile %s: %w", name, err)
+		}
+		generated = append(generated, name)
+	}
+
+	if opts.dryRun || len(generated) == 0 {
+		return nil
+	}
+
+	if cfg.Groups == nil {
+		cfg.Groups = make(map[string][]string)
+	}
+	members := cfg.Groups[opts.group]
+	for _, name := range generated {
+		found := false
+		for _, member := range members {
+			found = found || member == name
+		}
+		if !found {
+			members = append(members, name)
+		}
+	}
+	cfg.Groups[opts.group] = members
+
+	if err := config.SaveConfig(cfg, cc.ConfigFile); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	output.Statusf("Generated %d profiles in group '%s':\n", len(generated), opts.group)
+	for _, name := range generated {
+		output.Statusf("  %s\n", name)
+	}
+	output.Statusf("\nRun 'azure2aws login --group %s' to sign in to all of them\n", opts.group)
+	return nil
+}
+
+// profileNameSafe replaces the characters of s that are awkward in profile
+// names and shell commands with '-'
+func profileNameSafe(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '-'
+	}, s)
+}
+
+// awsConfigSection returns the ~/.aws/config section name of a profile
+func awsConfigSection(name string) string {
+	if name == "default" {
+		return name
+	}
+	return "profile " + name
+}
+
+// outputOrDefault returns the AWS CLI output format, json when unset
+func outputOrDefault(format string) string {
+	if format == "" {
+		return "json"
+	}
+	return format
+}
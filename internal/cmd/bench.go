@@ -0,0 +1,264 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/user/azure2aws/internal/aws"
+	"github.com/user/azure2aws/internal/cache"
+	"github.com/user/azure2aws/internal/config"
+	"github.com/user/azure2aws/internal/keyring"
+	"github.com/user/azure2aws/internal/output"
+	"github.com/user/azure2aws/internal/provider"
+	"github.com/user/azure2aws/internal/provider/azuread"
+	"github.com/user/azure2aws/internal/saml"
+	"github.com/user/azure2aws/internal/state"
+)
+
+// Benchmark phases, in report order
+const (
+	phaseConnect    = "connect"
+	phaseTLS        = "tls"
+	phaseServerWait = "idp-wait"
+	phaseAzureAD    = "azure-ad"
+	phaseParse      = "saml-parse"
+	phaseSTS        = "sts"
+	phaseTotal      = "total"
+)
+
+var benchPhases = []string{phaseConnect, phaseTLS, phaseServerWait, phaseAzureAD, phaseParse, phaseSTS, phaseTotal}
+
+type benchOptions struct {
+	iterations int
+}
+
+func newBenchCmd(cc *CommandContext) *cobra.Command {
+	var opts benchOptions
+
+	cmd := &cobra.Command{
+		Use:   "bench",
+		Short: "Measure login latency per phase",
+		Long: `Repeatedly runs the login pipeline with the cached Azure AD session (see
+'login --cache-saml') and reports latency percentiles per phase:
+
+  connect     TCP connects to Azure AD (or the proxy)
+  tls         TLS handshakes with Azure AD (or the proxy)
+  idp-wait    Azure AD server time (request sent to first response byte)
+  azure-ad    Whole Azure AD sign-in, up to the SAML assertion
+  saml-parse  Parsing the SAML assertion
+  sts         sts:AssumeRoleWithSAML
+
+Credentials obtained during the benchmark are discarded.
+
+Example:
+  azure2aws bench --profile production --iterations 10`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runBench(cmd.Context(), cc, opts)
+		},
+	}
+
+	cmd.Flags().IntVarP(&opts.iterations, "iterations", "n", 5, "Number of logins to run")
+
+	return cmd
+}
+
+func runBench(ctx context.Context, cc *CommandContext, opts benchOptions) error {
+	profileName := cc.Profile
+
+	if opts.iterations < 1 {
+		return fmt.Errorf("--iterations must be at least 1")
+	}
+
+	cfg, err := cc.LoadOrCreateConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	backend, err := cc.KeyringBackend()
+	if err != nil {
+		return err
+	}
+	kr := keyring.New(backend)
+
+	profile, err := cfg.GetProfile(profileName)
+	if err != nil {
+		return err
+	}
+	awsClient, err := newAWSClient(profile)
+	if err != nil {
+		return err
+	}
+
+	cookies, err := cache.New(backend).Get(profileName, cache.KindAzureCookies, 0)
+	if errors.Is(err, cache.ErrMiss) {
+		return fmt.Errorf("no cached Azure AD session for profile '%s'\nRun 'azure2aws login --profile %s --cache-saml' first", profileName, profileName)
+	}
+	if err != nil {
+		return err
+	}
+
+	var password string
+	if profile.ADFSAuth != config.ADFSAuthWIA && profile.ClientCert == "" {
+		if password, _, err = getPassword(cc.Prompts(profileName), kr, profileName, profile.Username, false); err != nil {
+			return fmt.Errorf("failed to get password: %w", err)
+		}
+	}
+
+	samples := make(map[string][]time.Duration, len(benchPhases))
+	for i := 1; i <= opts.iterations; i++ {
+		output.Statusf("Iteration %d/%d...\n", i, opts.iterations)
+
+		timings, err := benchIteration(ctx, profileName, profile, kr, awsClient, password, cookies)
+		if err != nil {
+			return fmt.Errorf("iteration %d: %w", i, err)
+		}
+		for phase, d := range timings {
+			samples[phase] = append(samples[phase], d)
+		}
+	}
+
+	output.Println(formatBenchReport(samples))
+	return nil
+}
+
+// benchIteration runs one login from the cached session and returns the
+// duration of each phase
+func benchIteration(ctx context.Context, profileName string, profile *config.MergedProfile, kr *keyring.Keyring, awsClient *aws.Client, password, cookies string) (map[string]time.Duration, error) {
+	timings := make(map[string]time.Duration, len(benchPhases))
+	start := time.Now()
+
+	client, err := azuread.NewClient(&azuread.ClientOptions{
+		URL:          profile.URL,
+		AppID:        profile.AppID,
+		RequireMFA:   profile.RequireMFA,
+		MFATimeout:   time.Duration(profile.MFATimeout) * time.Second,
+		MaxAuthSteps: profile.MaxAuthSteps,
+		CABundle:     profile.CABundle,
+		HTTP:         httpClientOptions(profile),
+		Profile:      profileName,
+
+		StaySignedIn: profile.StaySignedIn,
+		WindowsAuth:  profile.ADFSAuth == config.ADFSAuthWIA,
+		LoginURL:     azureLoginURL(profile),
+		TenantID:     profile.TenantID,
+		HomeTenant:   profile.HomeTenant,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Azure AD client: %w", err)
+	}
+	if err := client.RestoreSessionCookies(cookies); err != nil {
+		return nil, err
+	}
+	client.EnableHTTPStats()
+
+	loginCreds := provider.NewLoginCredentials(profile.Username, password)
+	if seed, err := kr.GetTOTPSeed(profileName); err == nil {
+		loginCreds.TOTPSeed = seed
+	}
+
+	phaseStart := time.Now()
+	samlAssertion, err := client.Authenticate(ctx, loginCreds)
+	if err != nil {
+		return nil, fmt.Errorf("authentication failed: %w", err)
+	}
+	timings[phaseAzureAD] = time.Since(phaseStart)
+
+	stats := client.HTTPStats()
+	timings[phaseConnect] = stats.Connect
+	timings[phaseTLS] = stats.TLS
+	timings[phaseServerWait] = stats.ServerWait
+
+	phaseStart = time.Now()
+	roles, err := saml.ParseAssertion(samlAssertion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SAML assertion: %w", err)
+	}
+	timings[phaseParse] = time.Since(phaseStart)
+
+	if roles, err = filterRoles(roles, profile.RoleFilter); err != nil {
+		return nil, err
+	}
+	role, err := benchRole(profileName, profile, roles)
+	if err != nil {
+		return nil, err
+	}
+
+	samlDuration, _ := saml.ExtractSessionDuration(samlAssertion)
+	phaseStart = time.Now()
+	if _, err := assumeRoleWithSAML(ctx, awsClient, role, samlAssertion, aws.GetSessionDuration(profile.SessionDuration, samlDuration), profile); err != nil {
+		return nil, err
+	}
+	timings[phaseSTS] = time.Since(phaseStart)
+
+	timings[phaseTotal] = time.Since(start)
+	return timings, nil
+}
+
+// benchRole picks the configured role, else the remembered or last used one,
+// else the first
+func benchRole(profileName string, profile *config.MergedProfile, roles []*saml.AWSRole) (*saml.AWSRole, error) {
+	if len(roles) == 0 {
+		return nil, fmt.Errorf("no AWS roles found in SAML assertion")
+	}
+
+	want := profile.RoleARN
+	if want == "" {
+		if st, err := state.Load(profileName); err == nil {
+			want = st.RememberedRoleARN
+			if want == "" {
+				want = st.LastRoleARN
+			}
+		}
+	}
+	for _, role := range roles {
+		if role.RoleARN == want {
+			return role, nil
+		}
+	}
+	if profile.RoleARN != "" {
+		return nil, fmt.Errorf("configured role %s not found in SAML assertion", profile.RoleARN)
+	}
+
+	return roles[0], nil
+}
+
+// formatBenchReport renders latency percentiles per phase
+func formatBenchReport(samples map[string][]time.Duration) string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "%-11s %9s %9s %9s %9s %9s\n", "PHASE", "MIN", "P50", "P90", "P99", "MAX")
+	for _, phase := range benchPhases {
+		durations := samples[phase]
+		if len(durations) == 0 {
+			continue
+		}
+		sorted := append([]time.Duration(nil), durations...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+		fmt.Fprintf(&sb, "%-11s %9s %9s %9s %9s %9s\n", phase,
+			formatLatency(sorted[0]),
+			formatLatency(percentile(sorted, 50)),
+			formatLatency(percentile(sorted, 90)),
+			formatLatency(percentile(sorted, 99)),
+			formatLatency(sorted[len(sorted)-1]))
+	}
+
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// percentile returns the nearest-rank percentile of sorted durations
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func formatLatency(d time.Duration) string {
+	return d.Round(time.Millisecond).String()
+}
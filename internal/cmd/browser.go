@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/pkg/browser"
+)
+
+// knownBrowser describes how to launch a browser on each platform
+type knownBrowser struct {
+	linux     []string // Executables to try, in order
+	macApp    string   // Application name for 'open -a'
+	windows   string   // Name for 'start'
+	incognito string   // Flag opening a private window
+}
+
+var knownBrowsers = map[string]knownBrowser{
+	"chrome": {
+		linux:     []string{"google-chrome", "google-chrome-stable", "chromium", "chromium-browser"},
+		macApp:    "Google Chrome",
+		windows:   "chrome",
+		incognito: "--incognito",
+	},
+	"firefox": {
+		linux:     []string{"firefox"},
+		macApp:    "Firefox",
+		windows:   "firefox",
+		incognito: "--private-window",
+	},
+	"edge": {
+		linux:     []string{"microsoft-edge", "microsoft-edge-stable"},
+		macApp:    "Microsoft Edge",
+		windows:   "msedge",
+		incognito: "--inprivate",
+	},
+}
+
+// openURL opens target in a browser: the default one when name is empty,
+// chrome, firefox or edge, or else name is run as a command line with the
+// URL appended. incognito opens a private window and needs a known browser.
+func openURL(target, name string, incognito bool) error {
+	if name == "" {
+		if incognito {
+			return fmt.Errorf("--incognito requires --browser chrome, firefox or edge")
+		}
+		return browser.OpenURL(target)
+	}
+
+	known, ok := knownBrowsers[strings.ToLower(name)]
+	if !ok {
+		if incognito {
+			return fmt.Errorf("--incognito requires --browser chrome, firefox or edge; add the private window flag to a custom browser command instead")
+		}
+		args := strings.Fields(name)
+		return startBrowser(exec.Command(args[0], append(args[1:], target)...))
+	}
+
+	var args []string
+	if incognito {
+		args = append(args, known.incognito)
+	}
+	args = append(args, target)
+
+	switch runtime.GOOS {
+	case "darwin":
+		if incognito {
+			// -n starts a new instance so the flag is not ignored
+			return startBrowser(exec.Command("open", append([]string{"-na", known.macApp, "--args"}, args...)...))
+		}
+		return startBrowser(exec.Command("open", "-a", known.macApp, target))
+	case "windows":
+		// start resolves browsers from the App Paths registry (the empty
+		// argument is the window title); cmd would split the URL at '&'
+		// unless escaped
+		cmdArgs := []string{"/c", "start", "", known.windows}
+		for _, arg := range args {
+			cmdArgs = append(cmdArgs, escapeCmd(arg))
+		}
+		return startBrowser(exec.Command("cmd", cmdArgs...))
+	default:
+		for _, executable := range known.linux {
+			if path, err := exec.LookPath(executable); err == nil {
+				return startBrowser(exec.Command(path, args...))
+			}
+		}
+		return fmt.Errorf("%s not found (tried %s)", name, strings.Join(known.linux, ", "))
+	}
+}
+
+// startBrowser starts a browser without waiting for it to exit
+func startBrowser(cmd *exec.Cmd) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// escapeCmd escapes cmd.exe metacharacters
+func escapeCmd(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune("^&|<>()", r) {
+			sb.WriteRune('^')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
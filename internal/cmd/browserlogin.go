@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/azure2aws/internal/config"
+	"github.com/user/azure2aws/internal/logging"
+	"github.com/user/azure2aws/internal/output"
+	"github.com/user/azure2aws/internal/partition"
+	"github.com/user/azure2aws/internal/provider/azuread"
+)
+
+// defaultCallbackPort is where 'login --browser' receives the SAML response
+const defaultCallbackPort = 9913
+
+// browserSignInTimeout bounds how long 'login --browser' waits for the SAML
+// response
+const browserSignInTimeout = 10 * time.Minute
+
+// callbackPath receives the SAML response from the bookmarklet or the paste
+// form, below a per-run random token
+const callbackPath = "/saml/"
+
+// checkBrowserSignIn refuses 'login --browser' for a profile with
+// require_mfa: the response comes from the browser, so whether Azure AD
+// challenged for MFA is unknown
+func checkBrowserSignIn(profile *config.MergedProfile) error {
+	if profile.RequireMFA {
+		return fmt.Errorf("--browser cannot be used with require_mfa, as azure2aws cannot tell whether the browser sign-in challenged for MFA\nLog in to profile '%s' without --browser", profile.Name)
+	}
+	return nil
+}
+
+// browserSignIn signs in through the system browser. A local page links to
+// the application's sign-in URL; the SAML response is sent back to it from
+// the AWS role selection page with a bookmarklet, or pasted into it.
+func browserSignIn(ctx context.Context, profileName string, profile *config.MergedProfile, port int) (string, error) {
+	ln, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		return "", fmt.Errorf("failed to listen for the browser sign-in: %w", err)
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		ln.Close()
+		return "", fmt.Errorf("failed to generate callback token: %w", err)
+	}
+
+	callback := newCallbackServer(profileName, azuread.SignInURL(profile.URL, profile.AppID, profile.TenantID),
+		ln.Addr().String(), hex.EncodeToString(token))
+	base := "http://" + callback.host
+	server := &http.Server{Handler: callback, ReadHeaderTimeout: 10 * time.Second}
+	go server.Serve(ln)
+	defer server.Close()
+
+	output.Statusf("Sign in for profile '%s' in the browser: %s\n", profileName, base)
+	if err := openURL(base, "", false); err != nil {
+		output.Statusf("Could not open a browser (%v); open the URL above\n", err)
+	}
+	output.Statusln("Waiting for the SAML response (press Ctrl+C to cancel)...")
+
+	select {
+	case samlAssertion := <-callback.responses:
+		output.Statusln("SAML response received")
+		return samlAssertion, nil
+	case <-time.After(browserSignInTimeout):
+		return "", fmt.Errorf("timed out waiting for the browser sign-in")
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// callbackServer serves the browser sign-in page and receives the SAML
+// response. Requests must name the listening address as Host (against DNS
+// rebinding), and the response must be posted to the callback path of this
+// run, from the local page or the AWS sign-in page.
+type callbackServer struct {
+	profile   string
+	signInURL string
+	host      string
+	path      string
+	callback  string
+	responses chan string
+}
+
+// newCallbackServer returns a callbackServer listening on host (as in
+// "127.0.0.1:9913") whose callback path ends with token
+func newCallbackServer(profile, signInURL, host, token string) *callbackServer {
+	return &callbackServer{
+		profile:   profile,
+		signInURL: signInURL,
+		host:      host,
+		path:      callbackPath + token,
+		callback:  "http://" + host + callbackPath + token,
+		responses: make(chan string, 1),
+	}
+}
+
+// ServeHTTP implements http.Handler
+func (s *callbackServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logging.Debug("Browser sign-in request", "method", r.Method, "path", r.URL.Path)
+
+	if r.Host != s.host {
+		http.Error(w, "unexpected Host", http.StatusForbidden)
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/" && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		callbackPage.Execute(w, map[string]interface{}{
+			"Profile":     s.profile,
+			"SignInURL":   s.signInURL,
+			"Callback":    s.callback,
+			"Bookmarklet": template.URL(bookmarklet(s.callback)),
+		})
+
+	case r.URL.Path == s.path && r.Method == http.MethodPost:
+		if origin := r.Header.Get("Origin"); origin != "" && origin != "http://"+s.host && !partition.IsSignInOrigin(origin) {
+			http.Error(w, "unexpected Origin", http.StatusForbidden)
+			return
+		}
+		samlAssertion, err := normalizeSAMLResponse(r.PostFormValue("SAMLResponse"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		select {
+		case s.responses <- samlAssertion:
+		default:
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, callbackDonePage)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// normalizeSAMLResponse cleans up a pasted SAML response (line breaks, URL
+// encoding) and checks that it is one
+func normalizeSAMLResponse(v string) (string, error) {
+	v = strings.Join(strings.Fields(v), "")
+	if strings.Contains(v, "%") {
+		if unescaped, err := url.QueryUnescape(v); err == nil {
+			v = unescaped
+		}
+	}
+	if v == "" {
+		return "", fmt.Errorf("no SAMLResponse received")
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(v)
+	if err != nil || !strings.Contains(string(decoded), "Assertion") {
+		return "", fmt.Errorf("the SAMLResponse is not a base64-encoded SAML response")
+	}
+	return v, nil
+}
+
+// bookmarklet posts the SAMLResponse of the AWS role selection page to the
+// callback
+func bookmarklet(callback string) string {
+	return "javascript:(function(){" +
+		"var i=document.querySelector('input[name=SAMLResponse]');" +
+		"if(!i){alert('No SAML response on this page');return}" +
+		"var f=document.createElement('form');f.method='POST';f.action='" + callback + "';" +
+		"var v=document.createElement('input');v.type='hidden';v.name='SAMLResponse';v.value=i.value;" +
+		"f.appendChild(v);document.body.appendChild(f);f.submit()})()"
+}
+
+var callbackPage = template.Must(template.New("callback").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>azure2aws sign-in</title></head>
+<body style="font-family: sans-serif; max-width: 44em; margin: 2em auto">
+<h1>azure2aws sign-in ({{.Profile}})</h1>
+<ol>
+<li>Drag this link to your bookmarks bar, replacing the one of an earlier sign-in
+(it only works for this one): <a href="{{.Bookmarklet}}">Send to azure2aws</a></li>
+<li><a href="{{.SignInURL}}">Sign in to AWS</a> with Azure AD.</li>
+<li>On the AWS role selection page, click the <em>Send to azure2aws</em> bookmark.</li>
+</ol>
+<p>Alternatively, copy the <code>SAMLResponse</code> form value posted to
+<code>https://signin.aws.amazon.com/saml</code> from the browser's developer tools
+and paste it here:</p>
+<form method="post" action="{{.Callback}}">
+<textarea name="SAMLResponse" rows="8" style="width: 100%"></textarea>
+<p><button type="submit">Send</button></p>
+</form>
+</body>
+</html>
+`))
+
+const callbackDonePage = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>azure2aws sign-in</title></head>
+<body style="font-family: sans-serif; max-width: 44em; margin: 2em auto">
+<p>SAML response received. You can close this window and return to the terminal.</p>
+</body>
+</html>
+`
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/user/azure2aws/internal/config"
+)
+
+func TestCallbackServer(t *testing.T) {
+	const host = "127.0.0.1:9913"
+	samlResponse := base64.StdEncoding.EncodeToString([]byte("<Response><Assertion/></Response>"))
+	form := url.Values{"SAMLResponse": {samlResponse}}.Encode()
+
+	tests := []struct {
+		name     string
+		method   string
+		host     string
+		path     string
+		origin   string
+		wantCode int
+	}{
+		{name: "page", method: http.MethodGet, host: host, path: "/", wantCode: http.StatusOK},
+		{name: "bookmarklet", method: http.MethodPost, host: host, path: "/saml/token", origin: "https://signin.aws.amazon.com", wantCode: http.StatusOK},
+		{name: "regional sign-in", method: http.MethodPost, host: host, path: "/saml/token", origin: "https://us-east-2.signin.aws.amazon.com", wantCode: http.StatusOK},
+		{name: "paste form", method: http.MethodPost, host: host, path: "/saml/token", origin: "http://" + host, wantCode: http.StatusOK},
+		{name: "wrong token", method: http.MethodPost, host: host, path: "/saml/guess", wantCode: http.StatusNotFound},
+		{name: "no token", method: http.MethodPost, host: host, path: "/saml/", wantCode: http.StatusNotFound},
+		{name: "wrong method", method: http.MethodGet, host: host, path: "/saml/token", wantCode: http.StatusNotFound},
+		{name: "foreign origin", method: http.MethodPost, host: host, path: "/saml/token", origin: "https://evil.example", wantCode: http.StatusForbidden},
+		{name: "look-alike origin", method: http.MethodPost, host: host, path: "/saml/token", origin: "https://signin.aws.amazon.com.evil.example", wantCode: http.StatusForbidden},
+		{name: "rebound host", method: http.MethodPost, host: "evil.example:9913", path: "/saml/token", wantCode: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newCallbackServer("test", "https://example.com/signin", host, "token")
+
+			req := httptest.NewRequest(tt.method, "http://"+tt.host+tt.path, strings.NewReader(form))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected status %d, got %d", tt.wantCode, rec.Code)
+			}
+			received := len(s.responses) == 1
+			if wantReceived := tt.method == http.MethodPost && tt.wantCode == http.StatusOK; received != wantReceived {
+				t.Errorf("expected response received = %v, got %v", wantReceived, received)
+			}
+		})
+	}
+}
+
+func TestCheckBrowserSignIn(t *testing.T) {
+	tests := []struct {
+		name       string
+		requireMFA bool
+		wantErr    bool
+	}{
+		{name: "no MFA requirement", requireMFA: false},
+		{name: "require_mfa", requireMFA: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkBrowserSignIn(&config.MergedProfile{Name: "prod", RequireMFA: tt.requireMFA})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkBrowserSignIn() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
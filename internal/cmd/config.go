@@ -0,0 +1,342 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/user/azure2aws/internal/config"
+	"github.com/user/azure2aws/internal/output"
+	"github.com/user/azure2aws/internal/prompter"
+)
+
+func newConfigCmd(cc *CommandContext) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Share and protect profile configuration",
+		Long: `Export and import profile bundles so teams can distribute a standard
+set of profiles. Bundles never contain passwords or AWS credentials.
+
+The config file can also be encrypted at rest with a passphrase.`,
+	}
+
+	cmd.AddCommand(newConfigExportCmd(cc))
+	cmd.AddCommand(newConfigImportCmd(cc))
+	cmd.AddCommand(newConfigEncryptCmd(cc))
+	cmd.AddCommand(newConfigDecryptCmd(cc))
+
+	return cmd
+}
+
+func newConfigExportCmd(cc *CommandContext) *cobra.Command {
+	var (
+		redact     bool
+		format     string
+		outputFile string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "export [profile...]",
+		Short: "Export profiles as a shareable YAML/JSON bundle",
+		Long: `Exports the given profiles (or all profiles) as a bundle.
+
+Use --redact to remove personal fields such as usernames before sharing.
+
+Examples:
+  azure2aws config export --redact > team.yaml
+  azure2aws config export production staging --redact --format json --file team.json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConfigExport(cc, args, redact, format, outputFile)
+		},
+	}
+
+	cmd.Flags().BoolVar(&redact, "redact", false, "Remove personal fields (usernames) from the bundle")
+	cmd.Flags().StringVar(&format, "format", config.BundleFormatYAML, "Bundle format (yaml, json)")
+	cmd.Flags().StringVar(&outputFile, "file", "", "Write the bundle to a file instead of stdout")
+
+	return cmd
+}
+
+func runConfigExport(cc *CommandContext, names []string, redact bool, format, outputFile string) error {
+	cfg, err := cc.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	bundle, err := config.NewBundle(cfg, names, redact)
+	if err != nil {
+		return err
+	}
+
+	data, err := bundle.Marshal(format)
+	if err != nil {
+		return err
+	}
+
+	if outputFile == "" {
+		_, err := output.Data().Write(data)
+		return err
+	}
+
+	if err := os.WriteFile(outputFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+
+	output.Statusf("Exported %d profile(s) to %s\n", len(bundle.Profiles), outputFile)
+	return nil
+}
+
+func newConfigImportCmd(cc *CommandContext) *cobra.Command {
+	var (
+		overwrite      bool
+		importDefaults bool
+		username       string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Import profiles from a bundle",
+		Long: `Merges the profiles in a YAML/JSON bundle into the config file.
+
+When a profile already exists with different settings, you are asked whether
+to keep it, overwrite it, or import the bundle's profile under a new name.
+Redacted profiles keep the existing username, or use --username.
+
+With --defaults, the bundle's defaults fill the defaults that are unset in
+the config, or replace them with --overwrite. Each one set is printed.
+
+Examples:
+  azure2aws config import team.yaml
+  azure2aws config import team.yaml --overwrite --username user@example.com`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConfigImport(cc, args[0], overwrite, importDefaults, username)
+		},
+	}
+
+	cmd.Flags().BoolVar(&overwrite, "overwrite", false, "Overwrite conflicting profiles (and defaults with --defaults) without prompting")
+	cmd.Flags().BoolVar(&importDefaults, "defaults", false, "Also import the bundle's defaults")
+	cmd.Flags().StringVar(&username, "username", "", "Username for redacted profiles")
+
+	return cmd
+}
+
+func runConfigImport(cc *CommandContext, path string, overwrite, importDefaults bool, username string) error {
+	bundle, err := config.LoadBundle(path)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := cc.LoadOrCreateConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if importDefaults && bundle.Defaults != nil {
+		for _, key := range config.MergeDefaults(&cfg.Defaults, *bundle.Defaults, overwrite) {
+			output.Statusf("Set defaults.%s from the bundle\n", key)
+		}
+	}
+
+	imported, err := importProfiles(cc.Prompts(""), cfg, bundle, overwrite, username)
+	if err != nil {
+		return err
+	}
+
+	if err := config.SaveConfig(cfg, cc.ConfigFile); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	output.Statusf("\nImported %d profile(s) into %s\n", imported, cc.ConfigFile)
+	return nil
+}
+
+// importProfiles merges a bundle's profiles into cfg, asking how to resolve
+// conflicts through prompts unless overwrite is set, and returns the number
+// imported
+func importProfiles(p *prompter.Scope, cfg *config.Config, bundle *config.Bundle, overwrite bool, username string) (int, error) {
+	names := make([]string, 0, len(bundle.Profiles))
+	for name := range bundle.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	imported := 0
+
+	for _, name := range names {
+		profile := bundle.Profiles[name]
+		targetName := name
+
+		existing, exists := cfg.Profiles[name]
+		if profile.Username == "" {
+			switch {
+			case username != "":
+				profile.Username = username
+			case exists:
+				profile.Username = existing.Username
+			}
+		}
+
+		if exists && !reflect.DeepEqual(existing, profile) && !overwrite {
+			choice, err := p.Select(fmt.Sprintf("Profile '%s' already exists with different settings:", name),
+				[]string{"Keep existing", "Overwrite", "Import under a new name"})
+			if err != nil {
+				return 0, err
+			}
+
+			switch choice {
+			case 0:
+				output.Statusf("Skipped profile '%s'\n", name)
+				continue
+			case 2:
+				targetName, err = p.String("New profile name", name+"-imported")
+				if err != nil {
+					return 0, err
+				}
+				if cfg.HasProfile(targetName) {
+					return 0, fmt.Errorf("profile '%s' already exists", targetName)
+				}
+			}
+		}
+
+		if profile.Username == "" {
+			var err error
+			profile.Username, err = p.String(fmt.Sprintf("Username for profile '%s'", targetName), "")
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		cfg.SetProfile(targetName, profile)
+		imported++
+		output.Statusf("Imported profile '%s'\n", targetName)
+	}
+
+	if n := mergeNames(&cfg.RoleLabels, bundle.RoleLabels, overwrite); n > 0 {
+		output.Statusf("Imported %d role label(s)\n", n)
+	}
+	if n := mergeNames(&cfg.Accounts, bundle.Accounts, overwrite); n > 0 {
+		output.Statusf("Imported %d account name(s)\n", n)
+	}
+
+	return imported, nil
+}
+
+// mergeNames adds the entries of src to *dst and returns how many were
+// added. Entries already set locally are kept unless overwrite is set.
+func mergeNames(dst *map[string]string, src map[string]string, overwrite bool) int {
+	added := 0
+	for key, value := range src {
+		if _, exists := (*dst)[key]; exists && !overwrite {
+			continue
+		}
+		if *dst == nil {
+			*dst = make(map[string]string, len(src))
+		}
+		(*dst)[key] = value
+		added++
+	}
+	return added
+}
+
+func newConfigEncryptCmd(cc *CommandContext) *cobra.Command {
+	var savePassphrase bool
+
+	cmd := &cobra.Command{
+		Use:   "encrypt",
+		Short: "Encrypt the config file with a passphrase",
+		Long: `Encrypts the config file at rest. Commands decrypt it transparently,
+reading the passphrase from AZURE2AWS_CONFIG_PASSPHRASE, the system keyring,
+or an interactive prompt.
+
+Running encrypt on an already encrypted file changes its passphrase.
+
+Examples:
+  azure2aws config encrypt
+  azure2aws config encrypt --save-passphrase`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConfigEncrypt(cc, savePassphrase)
+		},
+	}
+
+	cmd.Flags().BoolVar(&savePassphrase, "save-passphrase", false, "Store the passphrase in the system keyring")
+
+	return cmd
+}
+
+func runConfigEncrypt(cc *CommandContext, savePassphrase bool) error {
+	cfg, err := cc.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	passphrase := os.Getenv(config.EnvConfigPassphrase)
+	if passphrase == "" {
+		passphrase, err = cc.Prompts("").Password("New passphrase")
+		if err != nil {
+			return err
+		}
+		if passphrase == "" {
+			return fmt.Errorf("passphrase cannot be empty")
+		}
+
+		confirm, err := cc.Prompts("").Password("Confirm passphrase")
+		if err != nil {
+			return err
+		}
+		if confirm != passphrase {
+			return fmt.Errorf("passphrases do not match")
+		}
+	}
+
+	cfg.SetPassphrase(passphrase)
+	if err := config.SaveConfig(cfg, cc.ConfigFile); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	if savePassphrase {
+		if err := config.SavePassphrase(cc.ConfigFile, passphrase, cc.Prompts("")); err != nil {
+			output.Statusf("Warning: failed to save passphrase to keyring: %v\n", err)
+		}
+	} else {
+		// A stored passphrase for the old key would no longer work
+		_ = config.DeletePassphrase(cc.ConfigFile, cc.Prompts(""))
+	}
+
+	output.Statusf("Encrypted %s\n", cc.ConfigFile)
+	return nil
+}
+
+func newConfigDecryptCmd(cc *CommandContext) *cobra.Command {
+	return &cobra.Command{
+		Use:   "decrypt",
+		Short: "Store the config file in plaintext again",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConfigDecrypt(cc)
+		},
+	}
+}
+
+func runConfigDecrypt(cc *CommandContext) error {
+	cfg, err := cc.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if !cfg.Encrypted() {
+		output.Statusf("%s is not encrypted\n", cc.ConfigFile)
+		return nil
+	}
+
+	cfg.SetPassphrase("")
+	if err := config.SaveConfig(cfg, cc.ConfigFile); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	_ = config.DeletePassphrase(cc.ConfigFile, cc.Prompts(""))
+
+	output.Statusf("Decrypted %s\n", cc.ConfigFile)
+	return nil
+}
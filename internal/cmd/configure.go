@@ -2,14 +2,22 @@ package cmd
 
 import (
 	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/user/azure2aws/internal/config"
 	"github.com/user/azure2aws/internal/keyring"
-	"github.com/user/azure2aws/internal/prompter"
+	"github.com/user/azure2aws/internal/output"
+	"github.com/user/azure2aws/internal/provider"
 )
 
-func newConfigureCmd() *cobra.Command {
+// maxPresetSize bounds the size of a downloaded preset or signature
+const maxPresetSize = 1 << 20
+
+func newConfigureCmd(cc *CommandContext) *cobra.Command {
 	var (
 		flagURL             string
 		flagAppID           string
@@ -17,6 +25,10 @@ func newConfigureCmd() *cobra.Command {
 		flagRegion          string
 		flagOutput          string
 		flagSessionDuration int
+		flagTenantID        string
+		flagFromURL         string
+		flagSignatureURL    string
+		flagPublicKey       string
 	)
 
 	cmd := &cobra.Command{
@@ -31,11 +43,23 @@ This will prompt for:
 - AWS region (optional)
 - AWS CLI output format (optional)
 - Session duration (optional)
+- Azure AD tenant ID or domain, which SAML responses are verified against
 
 If --url, --app-id, and --username flags are all provided,
-the command runs in non-interactive mode.`,
+the command runs in non-interactive mode.
+
+With --from-url, a signed team preset (a profile bundle, see 'config export')
+is downloaded, verified against an Ed25519 public key (--public-key or
+defaults.preset_public_key) and merged into the config. The signature is read
+from <url>.sig unless --signature-url is set.
+
+Example:
+  azure2aws configure --from-url https://intranet/azure2aws/preset.yaml --public-key team.pem`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runConfigure(flagURL, flagAppID, flagUsername, flagRegion, flagOutput, flagSessionDuration)
+			if flagFromURL != "" {
+				return runConfigurePreset(cc, flagFromURL, flagSignatureURL, flagPublicKey)
+			}
+			return runConfigure(cc, flagURL, flagAppID, flagUsername, flagRegion, flagOutput, flagSessionDuration, flagTenantID)
 		},
 	}
 
@@ -43,59 +67,63 @@ the command runs in non-interactive mode.`,
 	cmd.Flags().StringVar(&flagAppID, "app-id", "", "Azure AD application ID (non-interactive)")
 	cmd.Flags().StringVar(&flagUsername, "username", "", "Username/email (non-interactive)")
 	cmd.Flags().StringVar(&flagRegion, "region", "", "AWS region (e.g., us-east-1)")
-	cmd.Flags().StringVar(&flagOutput, "output", "", "AWS CLI output format (json, text, table)")
+	cmd.Flags().StringVar(&flagOutput, "output", "", "AWS CLI output format (json, yaml, yaml-stream, text, table)")
 	cmd.Flags().IntVar(&flagSessionDuration, "session-duration", 0, "Session duration in seconds (900-43200, default: 3600)")
+	cmd.Flags().StringVar(&flagTenantID, "tenant-id", "", "Azure AD tenant ID or domain SAML responses must come from")
+	cmd.Flags().StringVar(&flagFromURL, "from-url", "", "Import a signed team preset from a URL")
+	cmd.Flags().StringVar(&flagSignatureURL, "signature-url", "", "Preset signature URL (default: <from-url>.sig)")
+	cmd.Flags().StringVar(&flagPublicKey, "public-key", "", "Ed25519 PEM public key verifying the preset (default: defaults.preset_public_key)")
 
 	return cmd
 }
 
-func runConfigure(flagURL, flagAppID, flagUsername, flagRegion, flagOutput string, flagSessionDuration int) error {
-	profileName := GetProfile()
-	configPath := GetConfigFile()
+func runConfigure(cc *CommandContext, flagURL, flagAppID, flagUsername, flagRegion, flagOutput string, flagSessionDuration int, flagTenantID string) error {
+	profileName := cc.Profile
+	configPath := cc.ConfigFile
 
-	cfg, err := config.LoadOrCreateConfig(configPath)
+	cfg, err := cc.LoadOrCreateConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
+	backend, err := cc.KeyringBackend()
+	if err != nil {
+		return err
+	}
+	kr := keyring.New(backend)
+
 	var existingProfile config.Profile
 	if cfg.HasProfile(profileName) {
-		mp, _ := cfg.GetProfile(profileName)
-		existingProfile = config.Profile{
-			URL:             mp.URL,
-			AppID:           mp.AppID,
-			Username:        mp.Username,
-			RoleARN:         mp.RoleARN,
-			Region:          mp.Region,
-			Output:          mp.Output,
-			SessionDuration: mp.SessionDuration,
-		}
-		fmt.Printf("Updating existing profile: %s\n", profileName)
+		existingProfile = cfg.Profiles[profileName]
+		output.Statusf("Updating existing profile: %s\n", profileName)
 	} else {
-		fmt.Printf("Creating new profile: %s\n", profileName)
+		output.Statusf("Creating new profile: %s\n", profileName)
 	}
 
 	nonInteractive := flagURL != "" && flagAppID != "" && flagUsername != ""
 
-	var newProfile config.Profile
+	// Start from the existing profile so settings not covered by configure
+	// (role chaining, SAML validation, ...) are preserved
+	newProfile := existingProfile
 
 	if nonInteractive {
-		newProfile = config.Profile{
-			URL:             flagURL,
-			AppID:           flagAppID,
-			Username:        flagUsername,
-			Region:          flagRegion,
-			Output:          flagOutput,
-			SessionDuration: flagSessionDuration,
+		newProfile.URL = flagURL
+		newProfile.AppID = flagAppID
+		newProfile.Username = flagUsername
+		newProfile.Region = flagRegion
+		newProfile.Output = flagOutput
+		newProfile.SessionDuration = flagSessionDuration
+		if flagTenantID != "" {
+			newProfile.TenantID = flagTenantID
 		}
 	} else {
-		p := prompter.New()
+		p := cc.Prompts("")
 
 		defaultURL := existingProfile.URL
 		if flagURL != "" {
 			defaultURL = flagURL
 		}
-		url, err := p.PromptString("Azure AD App URL", defaultURL)
+		url, err := p.String("Azure AD App URL", defaultURL)
 		if err != nil {
 			return err
 		}
@@ -104,7 +132,7 @@ func runConfigure(flagURL, flagAppID, flagUsername, flagRegion, flagOutput strin
 		if flagAppID != "" {
 			defaultAppID = flagAppID
 		}
-		appID, err := p.PromptString("Azure AD Application ID", defaultAppID)
+		appID, err := p.String("Azure AD Application ID", defaultAppID)
 		if err != nil {
 			return err
 		}
@@ -113,7 +141,7 @@ func runConfigure(flagURL, flagAppID, flagUsername, flagRegion, flagOutput strin
 		if flagUsername != "" {
 			defaultUsername = flagUsername
 		}
-		username, err := p.PromptString("Username (email)", defaultUsername)
+		username, err := p.String("Username (email)", defaultUsername)
 		if err != nil {
 			return err
 		}
@@ -125,7 +153,7 @@ func runConfigure(flagURL, flagAppID, flagUsername, flagRegion, flagOutput strin
 		if defaultRegion == "" {
 			defaultRegion = cfg.Defaults.Region
 		}
-		region, err := p.PromptString("AWS Region", defaultRegion)
+		region, err := p.String("AWS Region", defaultRegion)
 		if err != nil {
 			return err
 		}
@@ -137,7 +165,7 @@ func runConfigure(flagURL, flagAppID, flagUsername, flagRegion, flagOutput strin
 		if defaultOutput == "" {
 			defaultOutput = "json"
 		}
-		output, err := p.PromptString("AWS CLI output format (json/text/table)", defaultOutput)
+		outputFormat, err := p.String("AWS CLI output format ("+strings.Join(config.OutputFormats, "/")+")", defaultOutput)
 		if err != nil {
 			return err
 		}
@@ -150,10 +178,19 @@ func runConfigure(flagURL, flagAppID, flagUsername, flagRegion, flagOutput strin
 			defaultSessionDuration = cfg.Defaults.SessionDuration
 		}
 		sessionDurationStr := fmt.Sprintf("%d", defaultSessionDuration)
-		sessionDurationInput, err := p.PromptString("Session duration in seconds (900-43200)", sessionDurationStr)
+		sessionDurationInput, err := p.String("Session duration in seconds (900-43200)", sessionDurationStr)
 		if err != nil {
 			return err
 		}
+		defaultTenantID := existingProfile.TenantID
+		if flagTenantID != "" {
+			defaultTenantID = flagTenantID
+		}
+		tenantID, err := p.String("Azure AD tenant ID or domain", defaultTenantID)
+		if err != nil {
+			return err
+		}
+
 		var sessionDuration int
 		if sessionDurationInput != "" {
 			if _, err := fmt.Sscanf(sessionDurationInput, "%d", &sessionDuration); err != nil {
@@ -166,32 +203,31 @@ func runConfigure(flagURL, flagAppID, flagUsername, flagRegion, flagOutput strin
 			sessionDuration = defaultSessionDuration
 		}
 
-		newProfile = config.Profile{
-			URL:             url,
-			AppID:           appID,
-			Username:        username,
-			Region:          region,
-			Output:          output,
-			SessionDuration: sessionDuration,
-		}
+		newProfile.URL = url
+		newProfile.AppID = appID
+		newProfile.Username = username
+		newProfile.Region = region
+		newProfile.Output = outputFormat
+		newProfile.SessionDuration = sessionDuration
+		newProfile.TenantID = tenantID
 
-		if keyring.IsAvailable() {
-			savePassword, err := p.PromptConfirm("Save password to keyring?", false)
+		if kr.IsAvailable() {
+			savePassword, err := p.Confirm("Save password to keyring?", false)
 			if err != nil {
 				return err
 			}
 
 			if savePassword {
-				password, err := p.PromptPassword("Password")
+				password, err := p.Password("Password")
 				if err != nil {
 					return err
 				}
 
 				if password != "" {
-					if err := keyring.SavePassword(profileName, password); err != nil {
-						fmt.Printf("Warning: Failed to save password to keyring: %v\n", err)
+					if err := kr.SavePassword(profileName, password); err != nil {
+						output.Statusf("Warning: Failed to save password to keyring: %v\n", err)
 					} else {
-						fmt.Println("Password saved to keyring.")
+						output.Statusln("Password saved to keyring.")
 					}
 				}
 			}
@@ -207,6 +243,9 @@ func runConfigure(flagURL, flagAppID, flagUsername, flagRegion, flagOutput strin
 	if newProfile.Username == "" {
 		return fmt.Errorf("Username is required")
 	}
+	if newProfile.Output, err = config.NormalizeOutput(newProfile.Output); err != nil {
+		return err
+	}
 	if newProfile.SessionDuration > 0 {
 		if newProfile.SessionDuration < 900 || newProfile.SessionDuration > 43200 {
 			return fmt.Errorf("session duration must be between 900 and 43200 seconds")
@@ -219,20 +258,106 @@ func runConfigure(flagURL, flagAppID, flagUsername, flagRegion, flagOutput strin
 		return fmt.Errorf("failed to save config: %w", err)
 	}
 
-	fmt.Printf("\nProfile '%s' saved to %s\n", profileName, configPath)
-	fmt.Println("\nConfiguration:")
-	fmt.Printf("  URL:      %s\n", newProfile.URL)
-	fmt.Printf("  App ID:   %s\n", newProfile.AppID)
-	fmt.Printf("  Username: %s\n", newProfile.Username)
+	output.Statusf("\nProfile '%s' saved to %s\n", profileName, configPath)
+	output.Statusln("\nConfiguration:")
+	output.Statusf("  URL:      %s\n", newProfile.URL)
+	output.Statusf("  App ID:   %s\n", newProfile.AppID)
+	output.Statusf("  Username: %s\n", newProfile.Username)
 	if newProfile.Region != "" {
-		fmt.Printf("  Region:   %s\n", newProfile.Region)
+		output.Statusf("  Region:   %s\n", newProfile.Region)
 	}
 	if newProfile.Output != "" {
-		fmt.Printf("  Output:   %s\n", newProfile.Output)
+		output.Statusf("  Output:   %s\n", newProfile.Output)
 	}
 	if newProfile.SessionDuration > 0 {
-		fmt.Printf("  Session Duration: %d seconds (%d hours)\n", newProfile.SessionDuration, newProfile.SessionDuration/3600)
+		output.Statusf("  Session Duration: %d seconds (%d hours)\n", newProfile.SessionDuration, newProfile.SessionDuration/3600)
 	}
 
 	return nil
 }
+
+// runConfigurePreset downloads a signed preset, verifies it and merges its
+// defaults and profiles into the config
+func runConfigurePreset(cc *CommandContext, presetURL, signatureURL, publicKeyPath string) error {
+	cfg, err := cc.LoadOrCreateConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if publicKeyPath == "" {
+		publicKeyPath = cfg.Defaults.PresetPublicKey
+	}
+	if publicKeyPath == "" {
+		return fmt.Errorf("a public key is required to verify the preset (use --public-key or set defaults.preset_public_key)")
+	}
+	publicKey, err := config.LoadPresetPublicKey(publicKeyPath)
+	if err != nil {
+		return err
+	}
+
+	if signatureURL == "" {
+		signatureURL = presetURL + ".sig"
+	}
+
+	httpOpts := provider.DefaultHTTPClientOptions()
+	httpOpts.CABundle = cfg.Defaults.CABundle
+	client, err := provider.NewHTTPClient(httpOpts)
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP client: %w", err)
+	}
+
+	output.Statusf("Downloading preset from %s...\n", presetURL)
+	data, err := fetchURL(client, presetURL)
+	if err != nil {
+		return fmt.Errorf("failed to download preset: %w", err)
+	}
+	signature, err := fetchURL(client, signatureURL)
+	if err != nil {
+		return fmt.Errorf("failed to download preset signature: %w", err)
+	}
+
+	bundle, err := config.ParsePreset(data, signature, publicKey)
+	if err != nil {
+		return err
+	}
+	output.Statusln("Preset signature verified.")
+
+	if bundle.Defaults != nil {
+		for _, key := range config.MergePresetDefaults(&cfg.Defaults, *bundle.Defaults) {
+			output.Statusf("Set defaults.%s from the preset\n", key)
+		}
+	}
+	if cfg.Defaults.PresetPublicKey == "" {
+		// Remember the key so later updates can omit --public-key
+		if abs, err := filepath.Abs(publicKeyPath); err == nil {
+			cfg.Defaults.PresetPublicKey = abs
+		}
+	}
+
+	imported, err := importProfiles(cc.Prompts(""), cfg, bundle, false, "")
+	if err != nil {
+		return err
+	}
+
+	if err := config.SaveConfig(cfg, cc.ConfigFile); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	output.Statusf("\nImported %d profile(s) from the preset into %s\n", imported, cc.ConfigFile)
+	return nil
+}
+
+// fetchURL downloads a small document
+func fetchURL(client *provider.HTTPClient, rawURL string) ([]byte, error) {
+	res, err := client.Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned status %s", rawURL, res.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(res.Body, maxPresetSize))
+}
@@ -1,16 +1,43 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"os"
+	"net/url"
+	"strings"
 	"time"
 
-	"github.com/pkg/browser"
 	"github.com/spf13/cobra"
 	"github.com/user/azure2aws/internal/aws"
+	"github.com/user/azure2aws/internal/config"
+	"github.com/user/azure2aws/internal/output"
 )
 
-func newConsoleCmd() *cobra.Command {
+// consoleOptions controls how the console sign-in URL is built and opened
+type consoleOptions struct {
+	linkOnly bool   // Print URL instead of opening browser
+	service  string // AWS service to open
+	region   string // Region to open the service in
+	path     string // Page below the service and/or fragment
+	roleARN  string // Role to assume before opening the console
+
+	duration time.Duration // Console session length; 0 uses console_duration
+
+	firefoxContainer string // Firefox container to open the console in
+	logoutFirst      bool   // Sign out of the current console session first
+
+	browser   string // Browser to open the console in; empty uses the default
+	incognito bool   // Open a private window
+}
+
+// containerProfilePlaceholder is replaced by the profile name in
+// --firefox-container names; it is also the flag's value when given alone
+const containerProfilePlaceholder = "{profile}"
+
+func newConsoleCmd(cc *CommandContext) *cobra.Command {
+	var opts consoleOptions
+
 	cmd := &cobra.Command{
 		Use:   "console",
 		Short: "Open AWS Console in browser",
@@ -20,56 +47,157 @@ Uses AWS Federation to create a temporary sign-in URL with your current credenti
 
 If credentials are expired, an error is returned (use 'azure2aws login' first).
 
+Use --role-arn to open the console as a different role. When it differs from
+the role held by the stored credentials, sts:AssumeRole is called first using
+the stored credentials as the source.
+
+--region and --path open a specific page: the path goes below the service
+("/buckets") and may end with a fragment ("#Instances").
+
+--duration (or console_duration in the config, in seconds) sets how long the
+console session lasts, from 15m to 12h. It must end before the credentials
+expire, so long console sessions need a matching session_duration. Without
+it, console sessions last one hour.
+
+--firefox-container opens the console in a Firefox container, named after the
+profile or given as --firefox-container=NAME ("{profile}" in NAME is replaced
+by the profile name), so
+several accounts can be signed in side by side. This needs Firefox as the
+default browser and the "Open external links in a container" extension.
+
+--logout-first signs out of the console session open in the browser before
+signing in, instead of stopping at "you are already signed in" when switching
+accounts.
+
+--browser opens the console in chrome, firefox or edge instead of the default
+browser; any other value is run as a command with the URL appended (for
+example "google-chrome --profile-directory=Work"). --incognito opens a private
+window of chrome, firefox or edge.
+
 Examples:
   azure2aws console --profile production
   azure2aws console --profile production --link
-  azure2aws console --profile production --service ec2`,
-		RunE: runConsole,
+  azure2aws console --profile production --service ec2
+  azure2aws console --profile production --service ec2 --region eu-west-1 --path "#Instances"
+  azure2aws console --profile production --duration 8h
+  azure2aws console --profile production --logout-first
+  azure2aws console --profile production --browser firefox --incognito
+  azure2aws console --profile production --firefox-container
+  azure2aws console --profile production --firefox-container="AWS {profile}"
+  azure2aws console --profile production --role-arn arn:aws:iam::210987654321:role/ReadOnly`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConsole(cmd.Context(), cc, opts)
+		},
 	}
 
-	cmd.Flags().Bool("link", false, "Print URL instead of opening browser")
-	cmd.Flags().String("service", "", "AWS service to open (e.g., ec2, s3)")
+	cmd.Flags().BoolVar(&opts.linkOnly, "link", false, "Print URL instead of opening browser")
+	cmd.Flags().StringVar(&opts.service, "service", "", "AWS service to open (e.g., ec2, s3)")
+	cmd.Flags().StringVar(&opts.region, "region", "", "Region to open the service in (e.g., eu-west-1)")
+	cmd.Flags().StringVar(&opts.path, "path", "", "Page below the service and/or fragment (e.g., \"#Instances\", \"/buckets\")")
+	cmd.Flags().StringVar(&opts.roleARN, "role-arn", "", "Assume this role before opening the console")
+	cmd.Flags().DurationVar(&opts.duration, "duration", 0, "Console session length (15m to 12h, default console_duration or 1h)")
+	cmd.Flags().StringVar(&opts.firefoxContainer, "firefox-container", "", "Open the console in this Firefox container (default: the profile name)")
+	cmd.Flags().Lookup("firefox-container").NoOptDefVal = containerProfilePlaceholder
+	cmd.Flags().BoolVar(&opts.logoutFirst, "logout-first", false, "Sign out of the current console session before signing in")
+	cmd.Flags().StringVar(&opts.browser, "browser", "", "Browser to use: chrome, firefox, edge, or a command (default: system browser)")
+	cmd.Flags().BoolVar(&opts.incognito, "incognito", false, "Open a private window (chrome, firefox or edge)")
 
 	return cmd
 }
 
-func runConsole(cmd *cobra.Command, args []string) error {
-	profileName := GetProfile()
+func runConsole(ctx context.Context, cc *CommandContext, opts consoleOptions) error {
+	profileName := cc.Profile
+
+	creds, err := loadValidCredentials(cc, profileName)
+	if err != nil {
+		return err
+	}
 
-	creds, err := aws.LoadCredentials(profileName)
+	awsClient, err := profileAWSClient(cc, profileName)
 	if err != nil {
-		return fmt.Errorf("failed to load credentials for profile %q: %w\nRun 'azure2aws login --profile %s' first", profileName, err, profileName)
+		return err
 	}
 
-	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
-		return fmt.Errorf("credentials for profile %q are empty\nRun 'azure2aws login --profile %s' first", profileName, profileName)
+	roleARN := opts.roleARN
+	if roleARN != "" && !aws.SameRole(roleARN, creds.AssumedRoleARN) {
+		if cc.Verbose {
+			output.Statusf("Assuming role %s...\n", roleARN)
+		}
+		creds, err = awsClient.AssumeRole(ctx, creds, roleARN, "azure2aws-console", aws.MaxChainedSessionDuration, creds.Region, "")
+		if err != nil {
+			return fmt.Errorf("failed to assume role %s: %w", roleARN, err)
+		}
 	}
 
-	if !creds.Expiration.IsZero() && aws.IsExpired(creds.Expiration) {
-		return fmt.Errorf("credentials for profile %q have expired at %s\nRun 'azure2aws login --profile %s' to refresh",
-			profileName, creds.Expiration.Format(time.RFC3339), profileName)
+	duration := opts.duration
+	if duration == 0 {
+		if duration, err = consoleDuration(cc, profileName); err != nil {
+			return err
+		}
+	}
+	if duration != 0 {
+		err := aws.CheckConsoleDuration(duration, creds.Expiration, time.Now())
+		if errors.Is(err, aws.ErrConsoleOutlastsCredentials) {
+			return fmt.Errorf("%w\nLower --duration or console_duration, or log in with a longer session_duration", err)
+		}
+		if err != nil {
+			return err
+		}
 	}
 
-	service, _ := cmd.Flags().GetString("service")
-	loginURL, err := aws.GetFederatedLoginURL(creds, service)
+	dest := aws.ConsoleDestination{Service: opts.service, Region: opts.region, Path: opts.path}
+	loginURL, err := awsClient.GetFederatedLoginURL(creds, dest, duration)
 	if err != nil {
 		return fmt.Errorf("failed to generate console URL: %w", err)
 	}
 
-	linkOnly, _ := cmd.Flags().GetBool("link")
-	if linkOnly {
-		fmt.Println(loginURL)
+	if opts.logoutFirst {
+		loginURL = aws.ConsoleLogoutURL(creds, loginURL)
+	}
+
+	if opts.firefoxContainer != "" {
+		name := strings.ReplaceAll(opts.firefoxContainer, containerProfilePlaceholder, profileName)
+		loginURL = firefoxContainerURL(name, loginURL)
+	}
+
+	if opts.linkOnly {
+		output.Println(loginURL)
 		return nil
 	}
 
-	if IsVerbose() {
-		fmt.Fprintf(os.Stderr, "Opening AWS Console for profile: %s\n", profileName)
+	if cc.Verbose {
+		output.Statusf("Opening AWS Console for profile: %s\n", profileName)
 	}
 
-	if err := browser.OpenURL(loginURL); err != nil {
+	if err := openURL(loginURL, opts.browser, opts.incognito); err != nil {
 		return fmt.Errorf("failed to open browser: %w\nURL: %s", err, loginURL)
 	}
 
-	fmt.Println("AWS Console opened in your default browser")
+	output.Statusln("AWS Console opened in your default browser")
 	return nil
 }
+
+// consoleDuration returns the console_duration configured for a profile.
+// Profiles that are not in the config (such as chained profiles) use the
+// default.
+func consoleDuration(cc *CommandContext, profileName string) (time.Duration, error) {
+	cfg, err := cc.LoadConfig()
+	if errors.Is(err, config.ErrConfigNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	seconds := cfg.Defaults.ConsoleDuration
+	if profile, ok := cfg.Profiles[profileName]; ok && profile.ConsoleDuration > 0 {
+		seconds = profile.ConsoleDuration
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// firefoxContainerURL wraps a URL in the ext+container: scheme handled by the
+// "Open external links in a container" Firefox extension
+func firefoxContainerURL(container, target string) string {
+	return "ext+container:name=" + url.QueryEscape(container) + "&url=" + url.QueryEscape(target)
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/user/azure2aws/internal/config"
+	"github.com/user/azure2aws/internal/keyring"
+	"github.com/user/azure2aws/internal/prompter"
+)
+
+// CommandContext carries the runtime settings shared by all subcommands.
+// It replaces package-level globals so that commands can be embedded and
+// run concurrently without sharing state.
+type CommandContext struct {
+	Profile    string // Selected profile name
+	ConfigFile string // Path to the azure2aws config file
+	Verbose    bool   // Verbose output enabled
+	Debug      bool   // Debug mode enabled
+	NoInput    bool   // Fail instead of prompting
+
+	Version   string // Build version
+	Commit    string // Build commit
+	BuildDate string // Build date
+
+	configs  *config.Cache    // Shared by copies made for other profiles
+	keyrings *keyringCache    // Shared like configs
+	prompts  *prompter.Broker // Shared like configs
+}
+
+// keyringCache holds the keyring backend of a command once it is selected
+type keyringCache struct {
+	mu      sync.Mutex
+	backend keyring.Backend
+}
+
+// NewCommandContext creates a CommandContext with the given build information.
+// The profile and config file default to AZURE2AWS_PROFILE and AZURE2AWS_CONFIG,
+// and prompting is disabled by AZURE2AWS_NO_INPUT.
+func NewCommandContext(version, commit, buildDate string) *CommandContext {
+	profile := os.Getenv(config.EnvProfile)
+	if profile == "" {
+		profile = "default"
+	}
+
+	noInput, _ := strconv.ParseBool(os.Getenv(config.EnvNoInput))
+
+	prompts := prompter.NewBroker(prompter.New())
+	prompts.SetNoInput(noInput)
+
+	return &CommandContext{
+		Profile:    profile,
+		ConfigFile: os.Getenv(config.EnvConfig),
+		NoInput:    noInput,
+		Version:    version,
+		Commit:     commit,
+		BuildDate:  buildDate,
+		configs:    &config.Cache{Prompts: prompts.Scope("")},
+		keyrings:   &keyringCache{},
+		prompts:    prompts,
+	}
+}
+
+// Prompts returns the prompt scope of the command for label (typically the
+// profile name). Prompts fail with prompter.ErrNoInput when NoInput was set
+// as the command started.
+func (cc *CommandContext) Prompts(label string) *prompter.Scope {
+	return cc.prompts.Scope(label)
+}
+
+// LoadConfig returns the config file, loading and decrypting it once for the
+// command
+func (cc *CommandContext) LoadConfig() (*config.Config, error) {
+	return cc.configs.Load(cc.ConfigFile)
+}
+
+// LoadOrCreateConfig is LoadConfig, returning a new config when the file does
+// not exist
+func (cc *CommandContext) LoadOrCreateConfig() (*config.Config, error) {
+	return cc.configs.LoadOrCreate(cc.ConfigFile)
+}
+
+// KeyringBackend returns the keyring backend named by
+// AZURE2AWS_KEYRING_BACKEND or the keyring_backend of the config, creating it
+// once for the command
+func (cc *CommandContext) KeyringBackend() (keyring.Backend, error) {
+	cc.keyrings.mu.Lock()
+	defer cc.keyrings.mu.Unlock()
+	if cc.keyrings.backend != nil {
+		return cc.keyrings.backend, nil
+	}
+
+	cfg, err := cc.LoadConfig()
+	if errors.Is(err, config.ErrConfigNotFound) {
+		cfg, err = config.NewConfig(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	backend, err := keyring.SelectBackend(cfg.Defaults.KeyringBackend, cc.keyringOptions(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("failed to select keyring backend: %w", err)
+	}
+	cc.keyrings.backend = backend
+	return backend, nil
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/user/azure2aws/internal/output"
+)
+
+// processCredentials is the credential_process output format (version 1)
+type processCredentials struct {
+	Version         int    `json:"Version"`
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	SessionToken    string `json:"SessionToken,omitempty"`
+	Expiration      string `json:"Expiration,omitempty"`
+}
+
+func newCredentialProcessCmd(cc *CommandContext) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "credential-process",
+		Short: "Print a profile's credentials for credential_process",
+		Long: `Prints the stored credentials of a profile as the JSON the AWS CLI and
+SDKs expect from a credential_process command, so tools read them without
+the credentials file (for example with credential_storage: keyring).
+
+It never signs in or prompts: when the credentials are missing or expired, it
+fails and the error tells to run 'azure2aws login'.
+
+Example ~/.aws/config entry:
+  [profile production]
+  credential_process = azure2aws credential-process --profile production`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCredentialProcess(cc)
+		},
+	}
+
+	return cmd
+}
+
+func runCredentialProcess(cc *CommandContext) error {
+	creds, err := loadValidCredentials(cc, cc.Profile)
+	if err != nil {
+		return err
+	}
+
+	out := processCredentials{
+		Version:         1,
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+	}
+	if !creds.Expiration.IsZero() {
+		out.Expiration = creds.Expiration.UTC().Format(time.RFC3339)
+	}
+
+	data, err := json.Marshal(out)
+	if err != nil {
+		return fmt.Errorf("failed to encode credentials: %w", err)
+	}
+	output.Println(string(data))
+	return nil
+}
@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/user/azure2aws/internal/aws"
+	"github.com/user/azure2aws/internal/config"
+	"github.com/user/azure2aws/internal/daemon"
+	"github.com/user/azure2aws/internal/keyring"
+	"github.com/user/azure2aws/internal/logging"
+	"github.com/user/azure2aws/internal/state"
+)
+
+// keyringOptions returns the keyring backend options configured in cfg,
+// prompting through the command's prompts
+func (cc *CommandContext) keyringOptions(cfg *config.Config) keyring.Options {
+	opts := keyring.Options{
+		OnePasswordVault: cfg.Defaults.OnePasswordVault,
+		OnePasswordRefs:  make(map[string]string),
+		VaultPath:        cfg.Defaults.VaultPath,
+		VaultPaths:       make(map[string]string),
+		Prompts:          cc.Prompts(""),
+	}
+	for name, profile := range cfg.Profiles {
+		if profile.OnePasswordRef != "" {
+			opts.OnePasswordRefs[name] = profile.OnePasswordRef
+		}
+		if profile.VaultPath != "" {
+			opts.VaultPaths[name] = profile.VaultPath
+		}
+	}
+
+	return opts
+}
+
+// newAWSClient creates the STS and console federation client of a merged
+// profile
+func newAWSClient(profile *config.MergedProfile) (*aws.Client, error) {
+	return aws.NewClient(aws.ClientOptions{
+		CABundle: profile.CABundle,
+		STS: aws.STSOptions{
+			Region:   profile.STSRegion,
+			UseFIPS:  profile.UseFIPSEndpoint,
+			Endpoint: profile.STSEndpoint,
+		},
+	})
+}
+
+// profileAWSClient creates the STS and console federation client with the CA
+// bundle and STS endpoint options configured for a profile. Profiles that are
+// not in the config (such as chained profiles) use the defaults.
+func profileAWSClient(cc *CommandContext, profileName string) (*aws.Client, error) {
+	var (
+		caBundle    string
+		stsRegion   string
+		useFIPS     bool
+		stsEndpoint string
+	)
+
+	cfg, err := cc.LoadConfig()
+	if err != nil && !errors.Is(err, config.ErrConfigNotFound) {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg != nil {
+		caBundle = cfg.Defaults.CABundle
+		stsRegion = cfg.Defaults.STSRegion
+		if cfg.Defaults.UseFIPSEndpoint != nil {
+			useFIPS = *cfg.Defaults.UseFIPSEndpoint
+		}
+		if profile, ok := cfg.Profiles[profileName]; ok {
+			if profile.CABundle != "" {
+				caBundle = profile.CABundle
+			}
+			if profile.STSRegion != "" {
+				stsRegion = profile.STSRegion
+			}
+			if profile.UseFIPSEndpoint != nil {
+				useFIPS = *profile.UseFIPSEndpoint
+			}
+			stsEndpoint = profile.STSEndpoint
+		}
+	}
+
+	if v := os.Getenv(config.EnvCABundle); v != "" {
+		caBundle = v
+	}
+	if v := os.Getenv(config.EnvSTSRegion); v != "" {
+		stsRegion = v
+	}
+	if v := os.Getenv(config.EnvSTSEndpoint); v != "" {
+		stsEndpoint = v
+	}
+	if v := os.Getenv(config.EnvUseFIPSEndpoint); v != "" {
+		if useFIPS, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", config.EnvUseFIPSEndpoint, v, err)
+		}
+	}
+
+	return aws.NewClient(aws.ClientOptions{
+		CABundle: caBundle,
+		STS:      aws.STSOptions{Region: stsRegion, UseFIPS: useFIPS, Endpoint: stsEndpoint},
+	})
+}
+
+// loadValidCredentials returns a profile's credentials from the credential
+// daemon when one runs, or else the stored ones
+func loadValidCredentials(cc *CommandContext, profileName string) (*aws.Credentials, error) {
+	if path, err := daemon.SocketPath(); err == nil {
+		creds, err := daemon.Fetch(path, profileName)
+		if err == nil {
+			return creds, nil
+		}
+		if !errors.Is(err, daemon.ErrNotRunning) {
+			logging.Debug("Credential daemon unavailable; reading stored credentials", "error", err)
+		}
+	}
+	return loadStoredCredentials(cc, profileName)
+}
+
+// loadStoredCredentials loads stored credentials for a profile and ensures
+// they are present and not expired
+func loadStoredCredentials(cc *CommandContext, profileName string) (*aws.Credentials, error) {
+	creds, err := readCredentials(cc, profileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load credentials for profile %q: %w\nRun 'azure2aws login --profile %s' first", profileName, err, profileName)
+	}
+
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, fmt.Errorf("credentials for profile %q are empty\nRun 'azure2aws login --profile %s' first", profileName, profileName)
+	}
+
+	if !creds.Expiration.IsZero() && aws.IsExpired(creds.Expiration) {
+		return nil, fmt.Errorf("credentials for profile %q have expired at %s\nRun 'azure2aws login --profile %s' to refresh",
+			profileName, creds.Expiration.Format(time.RFC3339), profileName)
+	}
+
+	return creds, nil
+}
+
+// credentialStores returns the store the profile's credential_storage
+// selects, and the other one
+func credentialStores(cc *CommandContext, profile *config.MergedProfile) (selected, other aws.CredentialStore, err error) {
+	files, err := aws.NewFileStore()
+	if err != nil {
+		return nil, nil, err
+	}
+	files.ExpirationKeys = profile.ExpirationKeys
+	keys, err := keyringStore(cc)
+	if err != nil {
+		return nil, nil, err
+	}
+	if profile.CredentialStorage == config.CredentialStorageKeyring {
+		return keys, files, nil
+	}
+	return files, keys, nil
+}
+
+// keyringStore returns the keyring credential store of the command's
+// keyring backend
+func keyringStore(cc *CommandContext) (*aws.KeyringStore, error) {
+	backend, err := cc.KeyringBackend()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := cc.LoadOrCreateConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	return &aws.KeyringStore{Backend: backend, Options: cc.keyringOptions(cfg)}, nil
+}
+
+// saveCredentials stores a profile's credentials where its
+// credential_storage says, removing any copy left in the other store
+func saveCredentials(cc *CommandContext, profileName string, profile *config.MergedProfile, creds *aws.Credentials) error {
+	selected, other, err := credentialStores(cc, profile)
+	if err != nil {
+		return err
+	}
+	if err := selected.Save(profileName, creds); err != nil {
+		return err
+	}
+	if err := other.Delete(profileName); err != nil && !errors.Is(err, aws.ErrCredentialsNotFound) {
+		return fmt.Errorf("failed to remove the previous copy of the credentials: %w", err)
+	}
+	return nil
+}
+
+// readCredentials returns a profile's credentials from the keyring when
+// login stored them there, or else from the credentials file
+func readCredentials(cc *CommandContext, profileName string) (*aws.Credentials, error) {
+	if st, err := state.Load(profileName); err == nil && st.KeyringCredentials != nil {
+		// Load reads from the backend the credentials were saved with
+		cfg, err := cc.LoadOrCreateConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load config: %w", err)
+		}
+		keys := &aws.KeyringStore{Options: cc.keyringOptions(cfg)}
+		return keys.Load(profileName)
+	}
+	return aws.LoadCredentials(profileName)
+}
+
+// storedCredentialsInfo returns the expiration and role of a profile's
+// stored credentials without reading secrets from the keyring; ok is false
+// when there are none
+func storedCredentialsInfo(profileName string) (expiration time.Time, roleARN string, ok bool) {
+	if st, err := state.Load(profileName); err == nil && st.KeyringCredentials != nil {
+		return st.KeyringCredentials.Expiration, st.KeyringCredentials.AssumedRoleARN, true
+	}
+	creds, err := aws.LoadCredentials(profileName)
+	if err != nil || creds.AccessKeyID == "" {
+		return time.Time{}, "", false
+	}
+	return creds.Expiration, creds.AssumedRoleARN, true
+}
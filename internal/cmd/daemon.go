@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/user/azure2aws/internal/aws"
+	"github.com/user/azure2aws/internal/daemon"
+	"github.com/user/azure2aws/internal/output"
+)
+
+// expiryCheckInterval is how often 'daemon --auto-refresh' and
+// '--notify-before' check the expiration of the stored credentials
+const expiryCheckInterval = time.Minute
+
+type daemonOptions struct {
+	socket        string
+	cacheSAML     bool
+	autoRefresh   bool
+	refreshBefore time.Duration
+	notifyBefore  time.Duration
+}
+
+func newDaemonCmd(cc *CommandContext) *cobra.Command {
+	var opts daemonOptions
+
+	cmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Serve credentials from memory to other azure2aws commands",
+		Long: `Runs a credential daemon on a unix socket (~/.azure2aws/daemon.sock, or
+AZURE2AWS_DAEMON_SOCKET). While it runs, 'exec', 'console' and 'whoami' get
+credentials from the daemon's memory instead of reading the credentials file,
+so many concurrent invocations cost one read.
+
+Credentials are loaded on first use and refreshed through the SAML flow when
+they are about to expire, with the stored password and the last used role.
+The socket is only accessible to the current user, and its directory must
+only be writable by the current user. On Windows, where the socket is a
+unix socket too rather than a named pipe, the ACL of its directory protects it.
+
+With --auto-refresh, the daemon also logs in again to every profile whose
+credentials expire within --refresh-before, without prompting, so that a
+still valid Azure AD session (--cache-saml) spares the hourly MFA prompt.
+Profiles whose credentials have already expired are left alone, and a
+profile whose refresh failed is not retried until it is logged in again.
+
+With --notify-before, a desktop notification is shown when a profile's
+credentials will expire within that time (once per login).
+
+Example:
+  azure2aws daemon --auto-refresh --cache-saml &
+  azure2aws exec --profile production -- terraform plan`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDaemon(cmd.Context(), cc, opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.socket, "socket", "", "Socket to listen on (default ~/.azure2aws/daemon.sock)")
+	cmd.Flags().BoolVar(&opts.cacheSAML, "cache-saml", false, "Reuse a cached SAML assertion and Azure AD session when refreshing")
+	cmd.Flags().BoolVar(&opts.autoRefresh, "auto-refresh", false, "Refresh the credentials of all profiles before they expire")
+	cmd.Flags().DurationVar(&opts.refreshBefore, "refresh-before", 10*time.Minute, "How long before expiry --auto-refresh logs in again")
+	cmd.Flags().DurationVar(&opts.notifyBefore, "notify-before", 0, "Show a desktop notification this long before credentials expire")
+
+	return cmd
+}
+
+func runDaemon(ctx context.Context, cc *CommandContext, opts daemonOptions) error {
+	path := opts.socket
+	if path == "" {
+		var err error
+		if path, err = daemon.SocketPath(); err != nil {
+			return err
+		}
+	}
+
+	if opts.autoRefresh && opts.refreshBefore <= 0 {
+		return fmt.Errorf("--refresh-before must be positive")
+	}
+
+	var mu sync.Mutex
+	sources := make(map[string]*refreshingCredentials)
+	source := func(profileName string) *refreshingCredentials {
+		mu.Lock()
+		defer mu.Unlock()
+		s, ok := sources[profileName]
+		if !ok {
+			profileCC := *cc
+			profileCC.Profile = profileName
+			s = &refreshingCredentials{ctx: ctx, cc: &profileCC, profileName: profileName, cacheSAML: opts.cacheSAML}
+			sources[profileName] = s
+		}
+		return s
+	}
+
+	server := daemon.NewServer(func(profileName string) (*aws.Credentials, error) {
+		return source(profileName).get(true)
+	})
+
+	if opts.notifyBefore < 0 {
+		return fmt.Errorf("--notify-before must not be negative")
+	}
+
+	if opts.autoRefresh || opts.notifyBefore > 0 {
+		names, err := selectProfiles(cc, true, nil, "")
+		if err != nil {
+			return err
+		}
+		if opts.autoRefresh {
+			go autoRefresh(ctx, names, opts.refreshBefore, source)
+			output.Statusf("Refreshing credentials %s before they expire\n", opts.refreshBefore)
+		}
+		if opts.notifyBefore > 0 {
+			go notifyExpiring(ctx, names, opts.notifyBefore)
+			output.Statusf("Notifying %s before credentials expire\n", opts.notifyBefore)
+		}
+	}
+
+	output.Statusf("Serving credentials on %s (press Ctrl+C to stop)\n", path)
+	return server.ListenAndServe(ctx, path)
+}
+
+// autoRefresh logs in again to the profiles whose credentials are still
+// valid but expire within margin, until ctx ends. A failed profile is
+// skipped until its stored credentials change, so that a lapsed Azure AD
+// session does not send an MFA request every minute.
+func autoRefresh(ctx context.Context, names []string, margin time.Duration, source func(string) *refreshingCredentials) {
+	failed := make(map[string]time.Time)
+	tick := time.NewTicker(expiryCheckInterval)
+	defer tick.Stop()
+
+	for {
+		for _, name := range names {
+			expiration, _, ok := storedCredentialsInfo(name)
+			if !ok || !time.Now().Before(expiration) || time.Until(expiration) >= margin {
+				continue
+			}
+			if last, ok := failed[name]; ok && last.Equal(expiration) {
+				continue
+			}
+			if _, err := source(name).getValidFor(margin, true); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				failed[name] = expiration
+				continue
+			}
+			delete(failed, name)
+			output.Statusf("Refreshed credentials for profile '%s'\n", name)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// notifyExpiring shows a desktop notification once for each login whose
+// credentials expire within margin, until ctx ends
+func notifyExpiring(ctx context.Context, names []string, margin time.Duration) {
+	notified := make(map[string]time.Time)
+	tick := time.NewTicker(expiryCheckInterval)
+	defer tick.Stop()
+
+	for {
+		for _, name := range names {
+			expiration, _, ok := storedCredentialsInfo(name)
+			if !ok || !time.Now().Before(expiration) || time.Until(expiration) >= margin {
+				continue
+			}
+			if last, ok := notified[name]; ok && last.Equal(expiration) {
+				continue
+			}
+			notified[name] = expiration
+
+			minutes := int(time.Until(expiration).Round(time.Minute) / time.Minute)
+			message := fmt.Sprintf("Credentials for profile '%s' expire in %d min (at %s). Run 'azure2aws login --profile %s --force'.",
+				name, minutes, expiration.Local().Format("15:04"), name)
+			if err := sendNotification("azure2aws", message); err != nil {
+				output.Statusf("Warning: %v\n", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/user/azure2aws/internal/output"
+)
+
+type direnvInitOptions struct {
+	login bool
+}
+
+func newDirenvCmd(cc *CommandContext) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "direnv",
+		Short: "Integrate profiles with direnv",
+		Long: `Generates .envrc snippets for direnv (https://direnv.net), so that a
+project directory selects its AWS profile when entering it.`,
+	}
+
+	cmd.AddCommand(newDirenvInitCmd(cc))
+
+	return cmd
+}
+
+func newDirenvInitCmd(cc *CommandContext) *cobra.Command {
+	var opts direnvInitOptions
+
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Print an .envrc snippet selecting a profile",
+		Long: `Prints an .envrc snippet that sets AWS_PROFILE to the profile and warns
+when its credentials are missing or expired. The snippet watches the
+credentials file, so direnv reloads it after a login.
+
+With --login, the snippet runs 'azure2aws login' instead of warning.
+
+Example:
+  azure2aws direnv init --profile production >> .envrc
+  direnv allow`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDirenvInit(cc, opts)
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.login, "login", false, "Log in when entering the directory if the credentials are missing or expired")
+
+	return cmd
+}
+
+func runDirenvInit(cc *CommandContext, opts direnvInitOptions) error {
+	cfg, err := cc.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if !cfg.HasProfile(cc.Profile) {
+		return fmt.Errorf("profile %q not found in config\nRun 'azure2aws configure --profile %s' to set it up", cc.Profile, cc.Profile)
+	}
+
+	output.Printf("%s", direnvSnippet(cc.Profile, opts.login))
+	return nil
+}
+
+// direnvSnippet renders the .envrc lines for a profile. 'azure2aws prompt'
+// prints nothing when the credentials are missing or expired.
+func direnvSnippet(profileName string, login bool) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# azure2aws: use the AWS profile %s\n", profileName)
+	fmt.Fprintf(&sb, "export AWS_PROFILE=%s\n", posixQuote(profileName))
+	sb.WriteString("watch_file \"${AWS_SHARED_CREDENTIALS_FILE:-$HOME/.aws/credentials}\"\n")
+	sb.WriteString("if [ -z \"$(azure2aws prompt --profile \"$AWS_PROFILE\")\" ]; then\n")
+	if login {
+		sb.WriteString("  azure2aws login --profile \"$AWS_PROFILE\" --no-usage\n")
+	} else {
+		sb.WriteString("  log_error \"azure2aws: credentials for $AWS_PROFILE are missing or expired; run 'azure2aws login --profile $AWS_PROFILE'\"\n")
+	}
+	sb.WriteString("fi\n")
+	return sb.String()
+}
+
+// posixQuote quotes s for a POSIX shell when it contains anything but
+// characters safe in a word
+func posixQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_.@/") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,372 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/user/azure2aws/internal/aws"
+	"github.com/user/azure2aws/internal/azurecloud"
+	"github.com/user/azure2aws/internal/config"
+	"github.com/user/azure2aws/internal/keyring"
+	"github.com/user/azure2aws/internal/output"
+	"github.com/user/azure2aws/internal/partition"
+	"github.com/user/azure2aws/internal/state"
+	"gopkg.in/ini.v1"
+)
+
+const (
+	doctorSTSURL = "https://sts.amazonaws.com/"
+
+	// maxClockSkew is the skew beyond which SAML assertions and STS requests start failing
+	maxClockSkew = 5 * time.Minute
+)
+
+// doctorResult is the outcome of a single diagnostic check
+type doctorResult struct {
+	ok     bool
+	warn   bool
+	detail string
+	hint   string
+}
+
+// doctorCheck is a named diagnostic check
+type doctorCheck struct {
+	name string
+	run  func() doctorResult
+}
+
+func newDoctorCmd(cc *CommandContext) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Diagnose common setup problems",
+		Long: `Runs end-to-end diagnostics and prints pass/fail with remediation hints.
+
+Checks:
+- Config file presence, permissions, and the selected profile
+- Keyring availability
+- Network reachability of Azure AD (login.microsoftonline.com, or the
+  profile's azure_cloud) and AWS STS
+- Clock skew against AWS
+- Credentials file health for the selected profile
+
+Example:
+  azure2aws doctor --profile production`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDoctor(cc)
+		},
+	}
+
+	return cmd
+}
+
+func runDoctor(cc *CommandContext) error {
+	stsURL := doctorSTSEndpoint(cc)
+	azureURL := doctorAzureEndpoint(cc)
+
+	checks := []doctorCheck{
+		{"Config file", func() doctorResult { return checkConfigFile(cc) }},
+		{"Profile", func() doctorResult { return checkProfile(cc) }},
+		{"Keyring", func() doctorResult { return checkKeyring(cc) }},
+		{"Azure AD reachability", func() doctorResult { return checkReachable(azureURL) }},
+		{"AWS STS reachability", func() doctorResult { return checkReachable(stsURL) }},
+		{"Clock skew", func() doctorResult { return checkClockSkew(stsURL) }},
+		{"Credentials file", func() doctorResult { return checkCredentialsFile(cc.Profile) }},
+	}
+
+	failed := 0
+	for _, check := range checks {
+		result := check.run()
+
+		status := "PASS"
+		switch {
+		case !result.ok:
+			status = "FAIL"
+			failed++
+		case result.warn:
+			status = "WARN"
+		}
+
+		output.Printf("[%s] %s: %s\n", status, check.name, result.detail)
+		if result.hint != "" && (!result.ok || result.warn) {
+			output.Printf("       → %s\n", result.hint)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d check(s) failed", failed)
+	}
+
+	output.Println("\nAll checks passed.")
+	return nil
+}
+
+// doctorAzureEndpoint returns the Azure AD sign-in host of the profile's
+// cloud, or the global one if the profile cannot be loaded
+func doctorAzureEndpoint(cc *CommandContext) string {
+	cloud, _ := azurecloud.Lookup("")
+	if cfg, err := cc.LoadConfig(); err == nil {
+		if profile, err := cfg.GetProfile(cc.Profile); err == nil {
+			if c, err := azurecloud.Lookup(profile.AzureCloud); err == nil {
+				cloud = c
+			}
+		}
+	}
+	return cloud.LoginURL
+}
+
+// doctorSTSEndpoint returns the STS endpoint login uses for the profile
+// (sts_endpoint, or partition, sts_region and use_fips_endpoint), or the global
+// endpoint if the profile cannot be loaded
+func doctorSTSEndpoint(cc *CommandContext) string {
+	cfg, err := cc.LoadConfig()
+	if err != nil {
+		return doctorSTSURL
+	}
+	profile, err := cfg.GetProfile(cc.Profile)
+	if err != nil {
+		return doctorSTSURL
+	}
+	if profile.STSEndpoint != "" {
+		return profile.STSEndpoint
+	}
+	if profile.Partition == partition.AWS && profile.STSRegion == "" && !profile.UseFIPSEndpoint {
+		return doctorSTSURL
+	}
+
+	part, err := partition.Lookup(profile.Partition)
+	if err != nil {
+		return doctorSTSURL
+	}
+	region := profile.STSRegion
+	if region == "" {
+		region = profile.Region
+	}
+	if region == "" {
+		region = part.DefaultRegion
+	}
+	return part.STSURL(region, profile.UseFIPSEndpoint)
+}
+
+func checkConfigFile(cc *CommandContext) doctorResult {
+	path := cc.ConfigFile
+	if _, err := os.Stat(path); err != nil {
+		return doctorResult{
+			detail: fmt.Sprintf("%s not found", path),
+			hint:   "Run 'azure2aws configure' to create it",
+		}
+	}
+
+	if _, err := cc.LoadConfig(); err != nil {
+		return doctorResult{
+			detail: err.Error(),
+			hint:   fmt.Sprintf("Fix the YAML syntax in %s", path),
+		}
+	}
+
+	if warning := config.WarnInsecurePermissions(path); warning != "" {
+		return doctorResult{
+			ok:     true,
+			warn:   true,
+			detail: warning,
+			hint:   fmt.Sprintf("Run 'chmod 600 %s'", path),
+		}
+	}
+
+	return doctorResult{ok: true, detail: path}
+}
+
+func checkProfile(cc *CommandContext) doctorResult {
+	profileName := cc.Profile
+	cfg, err := cc.LoadConfig()
+	if err != nil {
+		return doctorResult{
+			detail: "config not loaded",
+			hint:   "Fix the config file first",
+		}
+	}
+
+	profile, err := cfg.GetProfile(profileName)
+	if err != nil && !errors.Is(err, config.ErrProfileNotFound) {
+		return doctorResult{
+			detail: err.Error(),
+			hint:   fmt.Sprintf("Run 'azure2aws configure --profile %s'", profileName),
+		}
+	}
+	if err != nil {
+		return doctorResult{
+			detail: fmt.Sprintf("profile '%s' not found", profileName),
+			hint:   fmt.Sprintf("Run 'azure2aws configure --profile %s'", profileName),
+		}
+	}
+
+	if profile.URL == "" || profile.AppID == "" || profile.Username == "" {
+		return doctorResult{
+			detail: fmt.Sprintf("profile '%s' is missing url, app_id, or username", profileName),
+			hint:   fmt.Sprintf("Run 'azure2aws configure --profile %s'", profileName),
+		}
+	}
+
+	return doctorResult{ok: true, detail: fmt.Sprintf("%s (%s)", profileName, profile.Username)}
+}
+
+func checkKeyring(cc *CommandContext) doctorResult {
+	backend, err := cc.KeyringBackend()
+	if err != nil {
+		return doctorResult{
+			detail: err.Error(),
+			hint:   fmt.Sprintf("Set keyring_backend to one of: %s", strings.Join(keyring.Backends(), ", ")),
+		}
+	}
+
+	kr := keyring.New(backend)
+	if !kr.IsAvailable() {
+		return doctorResult{
+			ok:     true,
+			warn:   true,
+			detail: fmt.Sprintf("%s keyring is not available", kr.Backend()),
+			hint:   "Passwords will be prompted on every login; install/unlock a keyring service or set keyring_backend",
+		}
+	}
+
+	if kr.Fallback() {
+		return doctorResult{
+			ok:     true,
+			warn:   true,
+			detail: fmt.Sprintf("%s (the system keyring is unavailable)", kr.Backend()),
+			hint:   "Install/unlock a keyring service, or set keyring_backend: file to use the encrypted file on purpose",
+		}
+	}
+
+	return doctorResult{ok: true, detail: fmt.Sprintf("%s (available)", kr.Backend())}
+}
+
+func checkReachable(url string) doctorResult {
+	if _, err := fetchServerTime(url); err != nil {
+		return doctorResult{
+			detail: err.Error(),
+			hint:   "Check your network connection, proxy settings (HTTPS_PROXY), and firewall",
+		}
+	}
+
+	return doctorResult{ok: true, detail: url}
+}
+
+func checkClockSkew(stsURL string) doctorResult {
+	serverTime, err := fetchServerTime(stsURL)
+	if err != nil || serverTime.IsZero() {
+		return doctorResult{
+			ok:     true,
+			warn:   true,
+			detail: "could not determine server time",
+			hint:   "Fix STS reachability to check clock skew",
+		}
+	}
+
+	skew := time.Since(serverTime).Round(time.Second)
+	if skew < 0 {
+		skew = -skew
+	}
+
+	if skew > maxClockSkew {
+		return doctorResult{
+			detail: fmt.Sprintf("local clock differs from AWS by %s", skew),
+			hint:   "Synchronize your system clock (e.g., enable NTP)",
+		}
+	}
+
+	return doctorResult{ok: true, detail: skew.String()}
+}
+
+func checkCredentialsFile(profileName string) doctorResult {
+	// credential_storage: keyring keeps them out of the file
+	if st, err := state.Load(profileName); err == nil && st.KeyringCredentials != nil {
+		result := credentialsExpiryResult(profileName, st.KeyringCredentials.Expiration)
+		result.detail += " (in the keyring)"
+		return result
+	}
+
+	credPath, err := aws.DefaultCredentialsPath()
+	if err != nil {
+		return doctorResult{detail: err.Error()}
+	}
+
+	if _, err := os.Stat(credPath); err != nil {
+		return doctorResult{
+			ok:     true,
+			warn:   true,
+			detail: fmt.Sprintf("%s not found", credPath),
+			hint:   fmt.Sprintf("Run 'azure2aws login --profile %s'", profileName),
+		}
+	}
+
+	if _, err := ini.Load(credPath); err != nil {
+		return doctorResult{
+			detail: fmt.Sprintf("failed to parse %s: %v", credPath, err),
+			hint:   "Fix or remove the malformed entries in the credentials file",
+		}
+	}
+
+	if warning := config.WarnInsecurePermissions(credPath); warning != "" {
+		return doctorResult{
+			ok:     true,
+			warn:   true,
+			detail: warning,
+			hint:   fmt.Sprintf("Run 'chmod 600 %s'", credPath),
+		}
+	}
+
+	creds, err := aws.LoadCredentials(profileName)
+	if err != nil {
+		return doctorResult{
+			ok:     true,
+			warn:   true,
+			detail: fmt.Sprintf("no credentials for profile '%s'", profileName),
+			hint:   fmt.Sprintf("Run 'azure2aws login --profile %s'", profileName),
+		}
+	}
+
+	return credentialsExpiryResult(profileName, creds.Expiration)
+}
+
+// credentialsExpiryResult reports whether a profile's credentials are valid
+func credentialsExpiryResult(profileName string, expiration time.Time) doctorResult {
+	if expiration.IsZero() || aws.IsExpired(expiration) {
+		return doctorResult{
+			ok:     true,
+			warn:   true,
+			detail: fmt.Sprintf("credentials for profile '%s' are expired", profileName),
+			hint:   fmt.Sprintf("Run 'azure2aws login --profile %s'", profileName),
+		}
+	}
+
+	return doctorResult{
+		ok:     true,
+		detail: fmt.Sprintf("profile '%s' valid until %s", profileName, expiration.Local().Format("2006-01-02 15:04:05")),
+	}
+}
+
+// fetchServerTime performs a HEAD request and returns the server's Date header.
+// A zero time is returned if the server did not send a usable Date header.
+func fetchServerTime(url string) (time.Time, error) {
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+	}
+
+	resp, err := client.Head(url)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	serverTime, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return time.Time{}, nil
+	}
+
+	return serverTime, nil
+}
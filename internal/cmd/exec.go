@@ -4,13 +4,17 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/user/azure2aws/internal/aws"
+	"github.com/user/azure2aws/internal/output"
 )
 
-func newExecCmd() *cobra.Command {
+func newExecCmd(cc *CommandContext) *cobra.Command {
+	var group string
+
 	cmd := &cobra.Command{
 		Use:   "exec [flags] -- command [args...]",
 		Short: "Execute a command with AWS credentials",
@@ -26,17 +30,25 @@ The following environment variables are set:
 
 If credentials are expired, an error is returned (use 'azure2aws login' first).
 
+With --group, the command runs once for each profile of the group, one after
+another. A failure does not stop the remaining profiles.
+
 Example:
   azure2aws exec --profile production -- aws s3 ls
-  azure2aws exec --profile production -- env | grep AWS`,
-		RunE:               runExec,
+  azure2aws exec --profile production -- env | grep AWS
+  azure2aws exec --group prod -- aws sts get-caller-identity`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runExec(cc, cmd, args, group)
+		},
 		DisableFlagParsing: false,
 	}
 
+	cmd.Flags().StringVar(&group, "group", "", "Run the command for each profile of this group")
+
 	return cmd
 }
 
-func runExec(cmd *cobra.Command, args []string) error {
+func runExec(cc *CommandContext, cmd *cobra.Command, args []string, group string) error {
 	cmdArgs := args
 	for i, arg := range os.Args {
 		if arg == "--" {
@@ -49,26 +61,21 @@ func runExec(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("command to execute is required\n\nUsage: azure2aws exec [flags] -- command [args...]")
 	}
 
-	profileName := GetProfile()
-
-	creds, err := aws.LoadCredentials(profileName)
-	if err != nil {
-		return fmt.Errorf("failed to load credentials for profile %q: %w\nRun 'azure2aws login --profile %s' first", profileName, err, profileName)
+	if group != "" {
+		return runExecGroup(cc, cmdArgs, group)
 	}
 
-	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
-		return fmt.Errorf("credentials for profile %q are empty\nRun 'azure2aws login --profile %s' first", profileName, profileName)
-	}
+	profileName := cc.Profile
 
-	if !creds.Expiration.IsZero() && aws.IsExpired(creds.Expiration) {
-		return fmt.Errorf("credentials for profile %q have expired at %s\nRun 'azure2aws login --profile %s' to refresh",
-			profileName, creds.Expiration.Format(time.RFC3339), profileName)
+	creds, err := loadValidCredentials(cc, profileName)
+	if err != nil {
+		return err
 	}
 
-	if IsVerbose() {
-		fmt.Fprintf(os.Stderr, "Using credentials for profile: %s\n", profileName)
+	if cc.Verbose {
+		output.Statusf("Using credentials for profile: %s\n", profileName)
 		if !creds.Expiration.IsZero() {
-			fmt.Fprintf(os.Stderr, "Credentials expire at: %s\n", creds.Expiration.Format(time.RFC3339))
+			output.Statusf("Credentials expire at: %s\n", creds.Expiration.Format(time.RFC3339))
 		}
 	}
 
@@ -76,6 +83,36 @@ func runExec(cmd *cobra.Command, args []string) error {
 	return execCommand(cmdArgs, envVars)
 }
 
+// runExecGroup runs the command with the credentials of each profile in a group
+func runExecGroup(cc *CommandContext, cmdArgs []string, group string) error {
+	names, err := selectProfiles(cc, false, nil, group)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for i, name := range names {
+		if i > 0 {
+			output.Statusln("")
+		}
+		output.Statusf("==> Profile '%s'\n", name)
+
+		creds, err := loadValidCredentials(cc, name)
+		if err == nil {
+			err = runCommand(cmdArgs, buildEnvVars(creds, name))
+		}
+		if err != nil {
+			output.Statusf("Command for profile '%s' failed: %v\n", name, err)
+			failed = append(failed, name)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("command failed for %d of %d profiles: %s", len(failed), len(names), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
 func buildEnvVars(creds *aws.Credentials, profile string) []string {
 	vars := []string{
 		fmt.Sprintf("AWS_ACCESS_KEY_ID=%s", creds.AccessKeyID),
@@ -103,7 +140,18 @@ func buildEnvVars(creds *aws.Credentials, profile string) []string {
 	return vars
 }
 
+// execCommand runs a command and exits with its exit code if it fails
 func execCommand(cmdline []string, envVars []string) error {
+	err := runCommand(cmdline, envVars)
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		os.Exit(exitErr.ExitCode())
+	}
+	return err
+}
+
+// runCommand runs a command with additional environment variables. A non-zero
+// exit is returned as an *exec.ExitError.
+func runCommand(cmdline []string, envVars []string) error {
 	execCmd := exec.Command(cmdline[0], cmdline[1:]...)
 	execCmd.Stdin = os.Stdin
 	execCmd.Stdout = os.Stdout
@@ -112,8 +160,8 @@ func execCommand(cmdline []string, envVars []string) error {
 
 	err := execCmd.Run()
 	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			os.Exit(exitErr.ExitCode())
+		if _, ok := err.(*exec.ExitError); ok {
+			return err
 		}
 		return fmt.Errorf("failed to execute command: %w", err)
 	}
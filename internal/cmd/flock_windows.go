@@ -0,0 +1,18 @@
+//go:build windows
+
+package cmd
+
+import (
+	"errors"
+	"os"
+)
+
+// flock is not available on Windows; acquireLockWindows uses an exclusively
+// created lock file instead
+func flock(f *os.File) error {
+	return errors.New("flock is not supported on windows")
+}
+
+func funlock(f *os.File) error {
+	return nil
+}
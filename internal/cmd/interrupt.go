@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// interruptGrace is how long a command has to stop after Ctrl+C before the
+// process exits; a prompt reading the terminal cannot be cancelled
+const interruptGrace = 3 * time.Second
+
+// NotifyContext returns a context cancelled by Ctrl+C or SIGTERM, so that
+// requests, MFA polling and STS calls stop and clean up. A command still
+// running after interruptGrace (such as one waiting at a prompt) is ended
+// with ExitInterrupted, as is any command on a second signal.
+func NotifyContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Restore echo if a password prompt is interrupted
+	fd := int(os.Stdin.Fd())
+	terminal, _ := term.GetState(fd)
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-signals:
+		case <-ctx.Done():
+			return
+		}
+		cancel()
+
+		select {
+		case <-signals:
+		case <-time.After(interruptGrace):
+		}
+		if terminal != nil {
+			term.Restore(fd, terminal)
+		}
+		os.Stderr.WriteString("\nInterrupted\n")
+		os.Exit(ExitInterrupted)
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
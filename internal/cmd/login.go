@@ -1,87 +1,286 @@
 package cmd
 
 import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/user/azure2aws/internal/aws"
+	"github.com/user/azure2aws/internal/azurecloud"
+	"github.com/user/azure2aws/internal/cache"
 	"github.com/user/azure2aws/internal/config"
 	"github.com/user/azure2aws/internal/keyring"
+	"github.com/user/azure2aws/internal/logging"
+	"github.com/user/azure2aws/internal/output"
 	"github.com/user/azure2aws/internal/prompter"
 	"github.com/user/azure2aws/internal/provider"
 	"github.com/user/azure2aws/internal/provider/azuread"
 	"github.com/user/azure2aws/internal/saml"
+	"github.com/user/azure2aws/internal/state"
 )
 
-func newLoginCmd() *cobra.Command {
-	var (
-		force      bool
-		skipPrompt bool
-	)
+type loginOptions struct {
+	force      bool
+	skipPrompt bool
+	noUsage    bool
+	quiet      bool
+	cacheSAML  bool
+	chooseRole bool
+	role       string
+	roleFilter string
+	shell      string
+	traceFile  string
+	mfaToken   string
+	userAgent  string
+
+	// targetProfile is the AWS profile the credentials are saved under,
+	// when it differs from the azure2aws profile
+	targetProfile string
+
+	// browser signs in through the system browser, receiving the SAML
+	// response on a local callback
+	browser      bool
+	callbackPort int
+
+	// authMode is authModeHTTP, or authModeHeadless to continue sign-ins
+	// the HTTP flow does not recognise in a headless browser
+	authMode string
+
+	// password is given with --password-stdin or AZURE2AWS_PASSWORD and
+	// bypasses the keyring and the prompt
+	password      string
+	passwordStdin bool
+
+	// all, profiles and group log into several profiles in one run
+	all      bool
+	profiles []string
+	group    string
+
+	// preferLastRole picks the last used role without prompting when it is
+	// still available (used for unattended refreshes)
+	preferLastRole bool
+
+	// session shares the Azure AD sign-in between the profiles of one run
+	session *azureSession
+}
+
+// Login auth modes
+const (
+	authModeHTTP     = "http"
+	authModeHeadless = "headless"
+)
+
+// mfaTokenPattern matches one-time MFA codes
+var mfaTokenPattern = regexp.MustCompile(`^[0-9]{6,8}$`)
+
+const (
+	// samlCacheMinValidity is how long a cached SAML assertion must remain
+	// valid to be reused
+	samlCacheMinValidity = time.Minute
+
+	// azureSessionCacheTTL bounds how long Azure AD session cookies are kept
+	azureSessionCacheTTL = 8 * time.Hour
+
+	// persistentSessionCacheTTL bounds how long the cookies of a persistent
+	// session (stay_signed_in) are kept; Azure AD may end it sooner
+	persistentSessionCacheTTL = 7 * 24 * time.Hour
+)
+
+func newLoginCmd(cc *CommandContext) *cobra.Command {
+	var opts loginOptions
 
 	cmd := &cobra.Command{
 		Use:   "login",
 		Short: "Authenticate and retrieve AWS credentials",
 		Long: `Authenticates with Azure AD and retrieves temporary AWS credentials via SAML.
 
-The credentials are stored in ~/.aws/credentials under the specified profile.`,
+The credentials are stored in ~/.aws/credentials under the specified profile.
+
+After login, snippets to activate the profile are printed for your shell
+(detected from $SHELL, or set with --shell). Use --no-usage to hide them.
+
+With --cache-saml, the SAML assertion and Azure AD session cookies are kept
+in the keyring and reused while valid, so logging in again (for example to
+pick another role) does not prompt for a password or MFA.
+
+--role selects the role by full ARN, role name, or label from role_labels,
+overriding role_arn from the config and skipping the role prompt.
+
+--target-profile saves the credentials under another AWS profile name than
+the azure2aws profile, so one Azure AD profile can fill role-specific AWS
+profiles (for example 'login --profile corp --role Admin --target-profile
+prod-admin'). Chained roles then use the target profile as their source.
+
+After picking a role from the prompt, you are offered to remember it; later
+logins then use it without asking. Use --choose-role to pick again.
+
+--all logs into every configured profile, --profiles into the listed ones and
+--group into the profiles of a group from the groups config section.
+Profiles with the same username share one Azure AD sign-in: the password and
+MFA are asked for once, as long as the Azure AD session allows it, and
+profiles of the same application reuse the SAML assertion.
+
+--mfa-token (or AZURE2AWS_MFA_TOKEN) answers an authenticator app or hardware
+token code prompt with a one-time code obtained elsewhere, for fully
+non-interactive logins. It cannot answer texted codes or push notifications.
+
+--password-stdin reads the password from the first line of stdin, and
+AZURE2AWS_PASSWORD provides it from the environment. Either bypasses the
+keyring and the password prompt, for CI systems without an OS keyring.
+
+--browser signs in through the system browser instead, for Conditional
+Access policies that cannot be satisfied from the command line. The SAML
+response is sent back from the AWS role selection page with a bookmarklet, or
+pasted, to a local page on --callback-port. Role selection and STS stay in
+the CLI. Profiles with require_mfa cannot use it, as whether the browser
+sign-in challenged for MFA is unknown.
+
+--auth-mode headless continues the sign-in in headless Chrome or Chromium
+when it reaches a page the HTTP flow does not recognise, such as JavaScript
+device compliance or terms of use pages. The browser reuses the session
+established so far and needs no interaction.
+
+Examples:
+  azure2aws login --profile production
+  azure2aws login --browser
+  azure2aws login --profile corp --role ReadOnly --target-profile prod-readonly
+  azure2aws login --all
+  azure2aws login --profiles production,staging,sandbox
+  azure2aws login --group prod`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runLogin(force, skipPrompt)
+			if opts.mfaToken == "" {
+				opts.mfaToken = os.Getenv(config.EnvMFAToken)
+			}
+			if opts.mfaToken != "" && !mfaTokenPattern.MatchString(opts.mfaToken) {
+				return fmt.Errorf("invalid MFA token: expected a numeric one-time code")
+			}
+			if opts.authMode != authModeHTTP && opts.authMode != authModeHeadless {
+				return fmt.Errorf("invalid --auth-mode %q (expected %s or %s)", opts.authMode, authModeHTTP, authModeHeadless)
+			}
+			if opts.browser && cc.NoInput {
+				return fmt.Errorf("--browser needs a user to sign in: %w", prompter.ErrNoInput)
+			}
+			if opts.browser && opts.skipPrompt {
+				return fmt.Errorf("--browser cannot be used with --skip-prompt")
+			}
+			if cmd.Flags().Changed("target-profile") && !validTargetProfile(opts.targetProfile) {
+				return fmt.Errorf("invalid --target-profile %q: expected an AWS profile name", opts.targetProfile)
+			}
+			if opts.passwordStdin {
+				password, err := readPasswordStdin()
+				if err != nil {
+					return err
+				}
+				opts.password = password
+			} else {
+				opts.password = os.Getenv(config.EnvPassword)
+			}
+			return runLogin(cmd.Context(), cc, opts)
 		},
 	}
 
-	cmd.Flags().BoolVar(&force, "force", false, "Force re-authentication even if credentials are valid")
-	cmd.Flags().BoolVar(&skipPrompt, "skip-prompt", false, "Skip interactive prompts (use stored credentials)")
+	cmd.Flags().BoolVar(&opts.force, "force", false, "Force re-authentication even if credentials are valid")
+	cmd.Flags().BoolVar(&opts.skipPrompt, "skip-prompt", false, "Skip interactive prompts (use stored credentials)")
+	cmd.Flags().BoolVar(&opts.cacheSAML, "cache-saml", false, "Reuse a cached SAML assertion and Azure AD session from the keyring")
+	cmd.Flags().StringVar(&opts.role, "role", "", "Role to assume (ARN, role name or label), overriding role_arn")
+	cmd.Flags().StringVar(&opts.roleFilter, "role-filter", "", "Only offer roles whose ARN or name matches this regex (overrides role_filter)")
+	cmd.Flags().StringVar(&opts.targetProfile, "target-profile", "", "AWS profile to save the credentials under (default: the --profile name)")
+	cmd.Flags().BoolVar(&opts.chooseRole, "choose-role", false, "Prompt for the role even if one was remembered")
+	cmd.Flags().BoolVar(&opts.noUsage, "no-usage", false, "Don't print usage instructions after login")
+	cmd.Flags().BoolVarP(&opts.quiet, "quiet", "q", false, "Don't show the progress spinner during sign-in")
+	cmd.Flags().BoolVar(&opts.passwordStdin, "password-stdin", false, "Read the password from stdin instead of the keyring or a prompt")
+	cmd.Flags().StringVar(&opts.mfaToken, "mfa-token", "", "One-time MFA code (authenticator app or hardware token) to use instead of prompting")
+	cmd.Flags().BoolVar(&opts.browser, "browser", false, "Sign in through the system browser")
+	cmd.Flags().IntVar(&opts.callbackPort, "callback-port", defaultCallbackPort, "Local port receiving the SAML response with --browser")
+	cmd.Flags().StringVar(&opts.authMode, "auth-mode", authModeHTTP, "Sign-in mode: http, or headless to fall back to a headless browser on unrecognised pages")
+	cmd.Flags().StringVar(&opts.userAgent, "user-agent", "", `User-Agent of Azure AD requests, or "browser" for a browser's (overrides user_agent)`)
+	cmd.Flags().StringVar(&opts.traceFile, "trace-file", "", "Record the Azure AD requests and responses (secrets redacted) to a HAR file")
+	cmd.Flags().StringVar(&opts.shell, "shell", "", "Shell for usage snippets (bash, zsh, fish, powershell, cmd)")
+	cmd.Flags().BoolVar(&opts.all, "all", false, "Log into all configured profiles")
+	cmd.Flags().StringSliceVar(&opts.profiles, "profiles", nil, "Log into these profiles (comma-separated)")
+	cmd.Flags().StringVar(&opts.group, "group", "", "Log into the profiles of this group")
 
 	return cmd
 }
 
-func runLogin(force, skipPrompt bool) error {
-	profileName := GetProfile()
-	configPath := GetConfigFile()
+func runLogin(ctx context.Context, cc *CommandContext, opts loginOptions) error {
+	if opts.all || len(opts.profiles) > 0 || opts.group != "" {
+		return runLoginProfiles(ctx, cc, opts)
+	}
+
+	profileName := cc.Profile
+	defer cc.prompts.Begin(profileName)()
+
+	shell, err := normalizeShell(opts.shell)
+	if err != nil {
+		return err
+	}
 
 	// Load configuration
-	cfg, err := config.LoadConfig(configPath)
+	// A missing config file is fine when the profile comes from AZURE2AWS_* variables
+	cfg, err := cc.LoadOrCreateConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w\nRun 'azure2aws configure --profile %s' to set up a profile", err, profileName)
 	}
 
-	profile, err := cfg.GetProfile(profileName)
+	backend, err := cc.KeyringBackend()
 	if err != nil {
+		return err
+	}
+	kr := keyring.New(backend)
+
+	profile, err := cfg.GetProfile(profileName)
+	if errors.Is(err, config.ErrProfileNotFound) {
 		return fmt.Errorf("profile '%s' not found\nRun 'azure2aws configure --profile %s' to set up a profile", profileName, profileName)
 	}
+	if err != nil {
+		return err
+	}
+
+	awsClient, err := newAWSClient(profile)
+	if err != nil {
+		return err
+	}
+
+	if opts.role, err = profile.ResolveRoleLabel(opts.role); err != nil {
+		return err
+	}
+
+	// awsProfile is where the credentials are saved
+	awsProfile := profileName
+	if opts.targetProfile != "" {
+		awsProfile = opts.targetProfile
+	}
 
 	// Check if credentials are still valid (unless force is specified)
-	if !force && !aws.CredentialsExpired(profileName) {
-		creds, err := aws.LoadCredentials(profileName)
-		if err == nil && creds != nil {
-			fmt.Printf("Credentials for profile '%s' are still valid (expires: %s)\n", profileName, creds.Expiration.Local().Format("2006-01-02 15:04:05"))
-			fmt.Println("Use --force to re-authenticate")
+	if expiration, assumedRoleARN, ok := storedCredentialsInfo(awsProfile); !opts.force && ok && !expiration.IsZero() && !aws.IsExpired(expiration) {
+		if opts.role == "" || assumedRoleMatches(opts.role, assumedRoleARN) {
+			output.Statusf("Credentials for profile '%s' are still valid (expires: %s)\n", awsProfile, expiration.Local().Format("2006-01-02 15:04:05"))
+			output.Statusln("Use --force to re-authenticate")
 			return nil
 		}
 	}
 
-	// Get password
-	password, err := getPassword(profileName, profile.Username, skipPrompt)
+	samlAssertion, password, err := authenticate(ctx, cc.Prompts(profileName), profileName, profile, backend, opts)
 	if err != nil {
-		return fmt.Errorf("failed to get password: %w", err)
+		return err
 	}
 
-	// Create Azure AD client
-	client, err := azuread.NewClient(&azuread.ClientOptions{
-		URL:   profile.URL,
-		AppID: profile.AppID,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to create Azure AD client: %w", err)
-	}
-
-	// Authenticate
-	fmt.Printf("Authenticating as %s...\n", profile.Username)
-	samlAssertion, err := client.Authenticate(provider.NewLoginCredentials(profile.Username, password))
-	if err != nil {
-		return fmt.Errorf("authentication failed: %w", err)
+	if err := validateSAMLSignature(profile, samlAssertion); err != nil {
+		if opts.cacheSAML {
+			// Never reuse an assertion that failed validation
+			_ = cache.New(backend).Delete(profileName, cache.KindSAMLAssertion)
+		}
+		return err
 	}
 
 	// Parse SAML assertion to get roles
@@ -94,11 +293,26 @@ func runLogin(force, skipPrompt bool) error {
 		return fmt.Errorf("no AWS roles found in SAML assertion")
 	}
 
+	roleFilter := profile.RoleFilter
+	if opts.roleFilter != "" {
+		roleFilter = opts.roleFilter
+	}
+	allRoles := roles
+	if roles, err = filterRoles(roles, roleFilter); err != nil {
+		return err
+	}
+
 	// Select role
 	var selectedRole *saml.AWSRole
-	if len(roles) == 1 {
+	if opts.role != "" {
+		selectedRole, err = matchRole(roles, opts.role)
+		if err != nil {
+			return err
+		}
+		output.Statusf("Using role: %s\n", selectedRole.Name)
+	} else if len(roles) == 1 {
 		selectedRole = roles[0]
-		fmt.Printf("Using role: %s\n", selectedRole.Name)
+		output.Statusf("Using role: %s\n", selectedRole.Name)
 	} else if profile.RoleARN != "" {
 		// Use configured role ARN
 		for _, role := range roles {
@@ -111,68 +325,885 @@ func runLogin(force, skipPrompt bool) error {
 			return fmt.Errorf("configured role %s not found in SAML assertion", profile.RoleARN)
 		}
 	} else {
-		// Prompt user to select role
-		selectedRole, err = selectRole(roles)
-		if err != nil {
-			return fmt.Errorf("failed to select role: %w", err)
+		// Prompt user to select role, marking the one used last time
+		var lastRoleARN, rememberedRoleARN string
+		if st, err := state.Load(profileName); err == nil {
+			lastRoleARN = st.LastRoleARN
+			rememberedRoleARN = st.RememberedRoleARN
+		}
+		if !opts.chooseRole && rememberedRoleARN != "" {
+			selectedRole = findRole(roles, rememberedRoleARN)
+			if selectedRole != nil {
+				output.Statusf("Using remembered role: %s (use --choose-role to pick another)\n", selectedRole.Name)
+			}
+		}
+		if selectedRole == nil && opts.preferLastRole {
+			selectedRole = findRole(roles, lastRoleARN)
+			if selectedRole != nil {
+				output.Statusf("Using last used role: %s\n", selectedRole.Name)
+			}
+		}
+		if selectedRole == nil {
+			selectedRole, err = selectRole(cc.Prompts(profileName), roles, lastRoleARN, profile.RoleLabels, accountNames(profileName, profile))
+			if err != nil {
+				return fmt.Errorf("failed to select role: %w", err)
+			}
+			if !opts.skipPrompt && selectedRole.RoleARN != rememberedRoleARN {
+				offerRememberRole(cc.Prompts(profileName), profileName, selectedRole)
+			}
 		}
 	}
 
 	samlDuration, _ := saml.ExtractSessionDuration(samlAssertion)
 	sessionDuration := aws.GetSessionDuration(profile.SessionDuration, samlDuration)
 
-	fmt.Printf("Assuming role %s...\n", selectedRole.Name)
-	creds, err := aws.AssumeRoleWithSAML(selectedRole, samlAssertion, sessionDuration, profile.Region, profile.Output)
+	output.Statusf("Assuming role %s...\n", selectedRole.Name)
+	creds, err := assumeRoleWithSAML(ctx, awsClient, selectedRole, samlAssertion, sessionDuration, profile)
 	if err != nil {
 		return fmt.Errorf("failed to assume role: %w", err)
 	}
 
-	if err := aws.SaveCredentials(profileName, creds); err != nil {
+	if err := saveCredentials(cc, awsProfile, profile, creds); err != nil {
 		return fmt.Errorf("failed to save credentials: %w", err)
 	}
 
-	fmt.Println("\n" + formatCredentialsSummary(profileName, creds))
-	fmt.Println("\n" + formatUsageInstructions(profileName))
+	if err := chainRoles(ctx, cc, awsClient, awsProfile, profile, creds); err != nil {
+		return err
+	}
+
+	var accountAlias string
+	if profile.DiscoverAccountAliases {
+		if accountAlias, err = awsClient.GetAccountAlias(ctx, creds); err != nil {
+			logging.Debug("Failed to discover account alias", "error", err)
+		}
+	}
+
+	if err := recordLogin(profileName, selectedRole, allRoles, accountAlias); err != nil {
+		logging.Debug("Failed to save profile state", "error", err)
+	}
+
+	output.Statusln("\n" + formatCredentialsSummary(awsProfile, creds))
+	if !opts.noUsage {
+		if profile.CredentialStorage == config.CredentialStorageKeyring {
+			output.Statusln("\n" + formatKeyringUsageInstructions(awsProfile))
+		} else {
+			output.Statusln("\n" + formatUsageInstructions(awsProfile, shell))
+		}
+	}
+
+	if password != "" && opts.password == "" && !opts.skipPrompt && !kr.HasPassword(profileName) {
+		if savePassword, err := cc.Prompts(profileName).Confirm("Save password to keyring for future logins?", false); err == nil && savePassword {
+			if err := kr.SavePassword(profileName, password); err != nil {
+				output.Statusf("Warning: Failed to save password: %v\n", err)
+			} else {
+				output.Statusln("Password saved to keyring.")
+			}
+		}
+	}
+
+	return nil
+}
+
+// runLoginProfiles logs into several profiles in turn, sharing the Azure AD
+// sign-in between them. A failed profile does not stop the others.
+func runLoginProfiles(ctx context.Context, cc *CommandContext, opts loginOptions) error {
+	if opts.role != "" {
+		return fmt.Errorf("--role cannot be used with --all, --profiles or --group")
+	}
+	if opts.targetProfile != "" {
+		return fmt.Errorf("--target-profile cannot be used with --all, --profiles or --group")
+	}
+	if opts.traceFile != "" {
+		return fmt.Errorf("--trace-file cannot be used with --all, --profiles or --group")
+	}
+
+	names, err := selectProfiles(cc, opts.all, opts.profiles, opts.group)
+	if err != nil {
+		return err
+	}
+
+	opts.all, opts.profiles, opts.group = false, nil, ""
+	opts.noUsage = true
+	opts.session = newAzureSession()
+
+	var failed []string
+	for i, name := range names {
+		if i > 0 {
+			output.Statusln("")
+		}
+		output.Statusf("==> Profile '%s'\n", name)
+
+		profileCC := *cc
+		profileCC.Profile = name
+		if err := runLogin(ctx, &profileCC, opts); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			output.Statusf("Login for profile '%s' failed: %v\n", name, err)
+			failed = append(failed, name)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("login failed for %d of %d profiles: %s", len(failed), len(names), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// selectProfiles returns the profiles chosen with --all, --profiles or
+// --group, or nil if none of them was used
+func selectProfiles(cc *CommandContext, all bool, names []string, group string) ([]string, error) {
+	selectors := 0
+	for _, used := range []bool{all, len(names) > 0, group != ""} {
+		if used {
+			selectors++
+		}
+	}
+	if selectors == 0 {
+		return nil, nil
+	}
+	if selectors > 1 {
+		return nil, fmt.Errorf("use only one of --all, --profiles and --group")
+	}
+	if len(names) > 0 {
+		return names, nil
+	}
+
+	cfg, err := cc.LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if group != "" {
+		members, err := cfg.GroupProfiles(group)
+		if errors.Is(err, config.ErrGroupNotFound) {
+			if groups := cfg.ListGroups(); len(groups) > 0 {
+				return nil, fmt.Errorf("group '%s' not found (defined groups: %s)", group, strings.Join(groups, ", "))
+			}
+			return nil, fmt.Errorf("group '%s' not found\nDefine it in the groups section of %s", group, cc.ConfigFile)
+		}
+		return members, err
+	}
+
+	names = cfg.ListProfiles()
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no profiles configured\nRun 'azure2aws configure --profile <name>' to set up a profile")
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// azureSession carries an Azure AD sign-in from one profile to the next
+// during a multi-profile login. Entries are keyed by username, and SAML
+// assertions additionally by application. Profiles with require_mfa get
+// neither the assertions nor the Azure AD sessions of other profiles, which
+// would skip their MFA challenge.
+type azureSession struct {
+	passwords  map[string]string
+	cookies    map[string]string
+	assertions map[string]string
+}
+
+func newAzureSession() *azureSession {
+	return &azureSession{
+		passwords:  make(map[string]string),
+		cookies:    make(map[string]string),
+		assertions: make(map[string]string),
+	}
+}
+
+func sessionUser(profile *config.MergedProfile) string {
+	return strings.ToLower(profile.Username)
+}
+
+func sessionApp(profile *config.MergedProfile) string {
+	return profile.URL + "|" + profile.AppID + "|" + sessionUser(profile)
+}
+
+// assertion returns a SAML assertion obtained for the same application that
+// is still valid
+func (s *azureSession) assertion(profile *config.MergedProfile) (string, bool) {
+	if profile.RequireMFA {
+		return "", false
+	}
+	samlAssertion, ok := s.assertions[sessionApp(profile)]
+	if !ok {
+		return "", false
+	}
+	expiresAt, err := saml.ExtractExpiration(samlAssertion)
+	if err != nil || time.Until(expiresAt) < samlCacheMinValidity {
+		return "", false
+	}
+	return samlAssertion, true
+}
+
+// sessionCookies returns the Azure AD session cookies of an earlier sign-in
+// by the same user
+func (s *azureSession) sessionCookies(profile *config.MergedProfile) (string, bool) {
+	if profile.RequireMFA {
+		return "", false
+	}
+	cookies, ok := s.cookies[sessionUser(profile)]
+	return cookies, ok
+}
+
+// record keeps the results of a successful sign-in for later profiles;
+// client is nil for a browser sign-in
+func (s *azureSession) record(profile *config.MergedProfile, client *azuread.Client, samlAssertion, password string) {
+	user := sessionUser(profile)
+	if password != "" {
+		s.passwords[user] = password
+	}
+	if client != nil {
+		if cookies, err := client.SessionCookies(); err == nil {
+			s.cookies[user] = cookies
+		}
+	}
+	s.assertions[sessionApp(profile)] = samlAssertion
+}
+
+// authenticate returns a SAML assertion for the profile and the password used
+// to obtain it, reusing a cached assertion (with no password) or Azure AD
+// session when --cache-saml is set
+func authenticate(ctx context.Context, prompts *prompter.Scope, profileName string, profile *config.MergedProfile, backend keyring.Backend, opts loginOptions) (string, string, error) {
+	kr := keyring.New(backend)
+
+	if opts.browser {
+		if err := checkBrowserSignIn(profile); err != nil {
+			return "", "", err
+		}
+	}
+
+	if profile.AuthTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(profile.AuthTimeout)*time.Second)
+		defer cancel()
+	}
+
+	// A cached assertion or Azure AD session would skip the MFA challenge
+	// require_mfa asks for on every login
+	var artifacts *cache.Cache
+	if opts.cacheSAML && profile.RequireMFA {
+		output.Statusln("Not using the SAML cache: require_mfa asks for MFA on every login")
+	} else if opts.cacheSAML {
+		artifacts = cache.New(backend)
+		if samlAssertion, err := artifacts.Get(profileName, cache.KindSAMLAssertion, samlCacheMinValidity); err == nil {
+			output.Statusln("Using cached SAML assertion")
+			return samlAssertion, "", nil
+		}
+	}
+
+	if opts.session != nil {
+		if samlAssertion, ok := opts.session.assertion(profile); ok {
+			output.Statusln("Reusing the SAML assertion of a previous profile")
+			return samlAssertion, "", nil
+		}
+	}
+
+	if opts.browser {
+		samlAssertion, err := browserSignIn(ctx, profileName, profile, opts.callbackPort)
+		if err != nil {
+			return "", "", authTimeoutError(ctx, profile, err)
+		}
+		if artifacts != nil {
+			cacheArtifacts(artifacts, profileName, nil, samlAssertion)
+		}
+		if opts.session != nil {
+			opts.session.record(profile, nil, samlAssertion, "")
+		}
+		return samlAssertion, "", nil
+	}
+
+	// Get password, reusing the one entered for a previous profile. Windows
+	// Integrated Authentication signs in with the Kerberos ticket instead,
+	// and certificate-based authentication with the client certificate.
+	windowsAuth := profile.ADFSAuth == config.ADFSAuthWIA
+	password := opts.password
+	if password == "" && opts.session != nil {
+		password = opts.session.passwords[sessionUser(profile)]
+	}
+	if password == "" && profile.ClientCert != "" {
+		// Only needed if Azure AD does not offer certificate-based authentication
+		password, _ = kr.GetPassword(profileName)
+	}
+	storedPassword := false
+	if password == "" && !windowsAuth && profile.ClientCert == "" {
+		var err error
+		if password, storedPassword, err = getPassword(prompts, kr, profileName, profile.Username, opts.skipPrompt); err != nil {
+			return "", "", fmt.Errorf("failed to get password: %w", err)
+		}
+	}
+
+	httpOpts := httpClientOptions(profile)
+	if opts.userAgent != "" {
+		httpOpts.UserAgent = opts.userAgent
+	}
+
+	var spinner *output.Spinner
+	progress := func(event azuread.ProgressEvent) {
+		spinner.Update(event.Message)
+		showProgress(event)
+	}
+
+	// Create Azure AD client
+	client, err := azuread.NewClient(&azuread.ClientOptions{
+		URL:          profile.URL,
+		AppID:        profile.AppID,
+		RequireMFA:   profile.RequireMFA,
+		MFATimeout:   time.Duration(profile.MFATimeout) * time.Second,
+		MaxAuthSteps: profile.MaxAuthSteps,
+		CABundle:     profile.CABundle,
+		HTTP:         httpOpts,
+		Profile:      profileName,
+		Prompts:      prompts,
+
+		StaySignedIn:     profile.StaySignedIn,
+		Progress:         progress,
+		WindowsAuth:      windowsAuth,
+		HeadlessFallback: opts.authMode == authModeHeadless,
+		LoginURL:         azureLoginURL(profile),
+		TenantID:         profile.TenantID,
+		HomeTenant:       profile.HomeTenant,
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create Azure AD client: %w", err)
+	}
+
+	if artifacts != nil {
+		if cookies, err := artifacts.Get(profileName, cache.KindAzureCookies, 0); err == nil {
+			if err := client.RestoreSessionCookies(cookies); err != nil {
+				logging.Debug("Ignoring cached Azure AD session", "error", err)
+			}
+		}
+	}
+	if opts.session != nil {
+		if cookies, ok := opts.session.sessionCookies(profile); ok {
+			if err := client.RestoreSessionCookies(cookies); err != nil {
+				logging.Debug("Ignoring Azure AD session of a previous profile", "error", err)
+			}
+		}
+	}
+
+	// Authenticate
+	output.Statusf("Authenticating as %s...\n", profile.Username)
+	loginCreds := provider.NewLoginCredentials(profile.Username, password)
+	if seed, err := kr.GetTOTPSeed(profileName); err == nil {
+		loginCreds.TOTPSeed = seed
+	}
+	loginCreds.MFAToken = opts.mfaToken
+
+	if opts.traceFile != "" {
+		client.EnableTrace()
+	}
+
+	if !opts.quiet {
+		spinner = output.StartSpinner("Signing in")
+	}
+	samlAssertion, err := client.Authenticate(ctx, loginCreds)
+
+	// A rotated password makes the stored one fail; ask once and retry
+	if err != nil && storedPassword && !opts.skipPrompt && azuread.PasswordRejected(err) {
+		output.Statusf("The password stored in the keyring for '%s' was rejected.\n", profileName)
+		if retyped, promptErr := prompts.Password(fmt.Sprintf("Password for %s", profile.Username)); promptErr == nil && retyped != "" {
+			password = retyped
+			loginCreds.Password = password
+			if samlAssertion, err = client.Authenticate(ctx, loginCreds); err == nil {
+				updateStoredPassword(prompts, kr, profileName, password)
+			}
+		}
+	}
+	spinner.Stop()
+
+	if opts.traceFile != "" {
+		// Write the trace even (especially) when authentication failed
+		if traceErr := client.WriteTrace(opts.traceFile); traceErr != nil {
+			output.Statusf("Warning: %v\n", traceErr)
+		} else {
+			output.Statusf("Authentication trace written to %s\n", opts.traceFile)
+		}
+	}
+
+	if err != nil {
+		if artifacts != nil {
+			_ = artifacts.Delete(profileName, cache.KindAzureCookies)
+		}
+		return "", "", fmt.Errorf("authentication failed: %w", authTimeoutError(ctx, profile, err))
+	}
 
-	if !skipPrompt && !keyring.HasPassword(profileName) {
-		if savePassword, err := prompter.Confirm("Save password to keyring for future logins?", false); err == nil && savePassword {
-			if err := keyring.SavePassword(profileName, password); err != nil {
-				fmt.Printf("Warning: Failed to save password: %v\n", err)
+	// Keep the keyring in step with a password changed during sign-in
+	if changed := client.ChangedPassword(); changed != "" {
+		password = changed
+		if kr.HasPassword(profileName) {
+			if err := kr.SavePassword(profileName, password); err != nil {
+				output.Statusf("Warning: failed to update password in keyring: %v\n", err)
 			} else {
-				fmt.Println("Password saved to keyring.")
+				output.Statusln("Password updated in keyring.")
+			}
+		}
+	}
+
+	if artifacts != nil {
+		cacheArtifacts(artifacts, profileName, client, samlAssertion)
+	}
+	if opts.session != nil {
+		opts.session.record(profile, client, samlAssertion, password)
+	}
+
+	return samlAssertion, password, nil
+}
+
+// showProgress prints the sign-in stages the Azure AD client does not
+// already report (it announces MFA prompts and waits itself)
+func showProgress(event azuread.ProgressEvent) {
+	switch event.Stage {
+	case azuread.StagePasswordAccepted, azuread.StageMFACompleted, azuread.StageSAMLAssertion:
+		output.Statusln(event.Message)
+	}
+}
+
+// authTimeoutError replaces the error of a sign-in stopped by auth_timeout
+func authTimeoutError(ctx context.Context, profile *config.MergedProfile, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("sign-in did not complete within auth_timeout (%ds)", profile.AuthTimeout)
+	}
+	return err
+}
+
+// azureLoginURL returns the Azure AD sign-in host of the profile's cloud
+func azureLoginURL(profile *config.MergedProfile) string {
+	cloud, err := azurecloud.Lookup(profile.AzureCloud)
+	if err != nil {
+		// GetProfile has validated azure_cloud
+		return ""
+	}
+	return cloud.LoginURL
+}
+
+// httpClientOptions applies the profile's timeout, retry, User-Agent and
+// client certificate settings to the default HTTP client options
+func httpClientOptions(profile *config.MergedProfile) *provider.HTTPClientOptions {
+	opts := provider.DefaultHTTPClientOptions()
+	if profile.HTTPTimeout > 0 {
+		opts.Timeout = time.Duration(profile.HTTPTimeout) * time.Second
+	}
+	if profile.ConnectTimeout > 0 {
+		opts.ConnectTimeout = time.Duration(profile.ConnectTimeout) * time.Second
+	}
+	if profile.MaxRetries != nil {
+		opts.MaxRetries = *profile.MaxRetries
+	}
+	if profile.ThrottleRetries != nil {
+		opts.ThrottleRetries = *profile.ThrottleRetries
+	}
+	opts.UserAgent = profile.UserAgent
+	opts.ClientCert = profile.ClientCert
+	opts.ClientKey = profile.ClientKey
+	opts.ClientCertPassword = os.Getenv(config.EnvClientCertPassword)
+	return opts
+}
+
+// cacheArtifacts stores the SAML assertion and Azure AD session cookies
+// (none without a client, after a browser sign-in). Failures only cost a
+// re-authentication next time, so they are logged.
+func cacheArtifacts(artifacts *cache.Cache, profileName string, client *azuread.Client, samlAssertion string) {
+	if expiresAt, err := saml.ExtractExpiration(samlAssertion); err == nil {
+		if err := artifacts.Put(profileName, cache.KindSAMLAssertion, samlAssertion, expiresAt); err != nil {
+			logging.Debug("Failed to cache SAML assertion", "error", err)
+		}
+	} else {
+		logging.Debug("Not caching SAML assertion", "error", err)
+	}
+
+	if client == nil {
+		return
+	}
+	ttl := azureSessionCacheTTL
+	if client.StaySignedIn() {
+		ttl = persistentSessionCacheTTL
+	}
+	if cookies, err := client.SessionCookies(); err == nil {
+		if err := artifacts.Put(profileName, cache.KindAzureCookies, cookies, time.Now().Add(ttl)); err != nil {
+			logging.Debug("Failed to cache Azure AD session", "error", err)
+		}
+	}
+}
+
+// validateSAMLSignature checks the SAML response signature against the pinned
+// certificate or the tenant's federation metadata, according to the profile's
+// saml_validation mode
+func validateSAMLSignature(profile *config.MergedProfile, samlAssertion string) error {
+	mode := profile.SAMLValidation
+	switch mode {
+	case config.SAMLValidationOff:
+		return nil
+	case config.SAMLValidationWarn, config.SAMLValidationFail:
+	default:
+		return fmt.Errorf("unknown saml_validation %q (expected %s, %s, or %s)",
+			mode, config.SAMLValidationOff, config.SAMLValidationWarn, config.SAMLValidationFail)
+	}
+
+	err := verifySAMLSignature(profile, samlAssertion)
+	if err == nil {
+		logging.Debug("SAML signature verified")
+		return nil
+	}
+
+	if mode == config.SAMLValidationWarn {
+		output.Statusf("Warning: SAML signature validation failed: %v\n", err)
+		return nil
+	}
+
+	return fmt.Errorf("SAML signature validation failed: %w", err)
+}
+
+func verifySAMLSignature(profile *config.MergedProfile, samlAssertion string) error {
+	root, err := samlTrustRoot(profile, samlAssertion)
+	if err != nil {
+		return err
+	}
+	return saml.VerifyResponse(samlAssertion, root)
+}
+
+// samlTrustRoot returns what the profile's SAML responses must be signed and
+// issued by, from the config only: the pinned certificate, or the federation
+// metadata of federation_metadata_url or of the configured tenant_id in the
+// configured azure_cloud. A tenant ID also pins the Issuer.
+func samlTrustRoot(profile *config.MergedProfile, samlAssertion string) (*saml.TrustRoot, error) {
+	cloud, err := azurecloud.Lookup(profile.AzureCloud)
+	if err != nil {
+		return nil, err
+	}
+
+	root := &saml.TrustRoot{}
+	if azurecloud.IsTenantID(profile.TenantID) {
+		root.Issuer = cloud.Issuer(profile.TenantID)
+	}
+
+	if profile.SAMLSigningCert != "" {
+		if root.Certificates, err = saml.LoadCertificateFile(profile.SAMLSigningCert); err != nil {
+			return nil, err
+		}
+		return root, nil
+	}
+
+	metadataURL := profile.FederationMetadataURL
+	if metadataURL == "" {
+		if profile.TenantID == "" {
+			return nil, errNoSAMLTrustRoot(samlAssertion)
+		}
+		metadataURL = saml.FederationMetadataURL(cloud, profile.TenantID, profile.AppID)
+	}
+
+	var rootCAs *x509.CertPool
+	if profile.CABundle != "" {
+		if rootCAs, err = provider.LoadCABundle(profile.CABundle); err != nil {
+			return nil, err
+		}
+	}
+	logging.Debug("fetching federation metadata", "url", metadataURL)
+	metadata, err := saml.FetchFederationMetadata(metadataURL, rootCAs)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case root.Issuer == "":
+		root.Issuer = metadata.EntityID
+	case !strings.EqualFold(metadata.EntityID, root.Issuer):
+		return nil, fmt.Errorf("federation metadata %s is for %s, not tenant_id %s", metadataURL, metadata.EntityID, profile.TenantID)
+	}
+	if !strings.HasPrefix(strings.ToLower(root.Issuer), cloud.IssuerPrefix) {
+		return nil, fmt.Errorf("federation metadata issuer %s is not in azure_cloud %s", root.Issuer, cloud.ID)
+	}
+	root.Certificates = metadata.SigningCertificates
+	return root, nil
+}
+
+// errNoSAMLTrustRoot explains how to configure a trust root, naming the
+// tenant the response claims to come from as a hint only
+func errNoSAMLTrustRoot(samlAssertion string) error {
+	hint := ""
+	if issuer, err := saml.ExtractIssuer(samlAssertion); err == nil {
+		if _, tenant, err := azurecloud.ForIssuer(issuer); err == nil {
+			hint = fmt.Sprintf(" (the response claims tenant %s; check it is yours)", tenant)
+		}
+	}
+	return fmt.Errorf("no trust root to verify the SAML response: set tenant_id%s, saml_signing_cert or federation_metadata_url in the profile", hint)
+}
+
+// chainRoles sets up the chained roles configured for a profile, either by
+// assuming them directly or by writing source_profile entries for the SDK.
+// With cache_format cli, it does both and caches the credentials for the AWS
+// CLI.
+func chainRoles(ctx context.Context, cc *CommandContext, awsClient *aws.Client, profileName string, profile *config.MergedProfile, creds *aws.Credentials) error {
+	for _, chained := range profile.ChainedRoles {
+		region := chained.Region
+		if region == "" {
+			region = profile.Region
+		}
+
+		if profile.CacheFormat == config.CacheFormatCLI {
+			if err := cacheChainedRole(ctx, cc, awsClient, profileName, profile, chained, region, creds); err != nil {
+				return err
 			}
+			continue
+		}
+
+		switch profile.ChainMode {
+		case config.ChainModeSDK:
+			if err := aws.SaveChainedProfileConfig(chained.Profile, chained.RoleARN, profileName, region, profile.Output); err != nil {
+				return fmt.Errorf("failed to write chained profile %s: %w", chained.Profile, err)
+			}
+			output.Statusf("Configured chained profile %s (source: %s)\n", chained.Profile, profileName)
+
+		case config.ChainModeAzure2AWS:
+			output.Statusf("Assuming chained role %s...\n", chained.RoleARN)
+			chainedCreds, err := awsClient.AssumeRole(ctx, creds, chained.RoleARN, "azure2aws", aws.MaxChainedSessionDuration, region, profile.Output)
+			if err != nil {
+				return fmt.Errorf("failed to assume chained role %s: %w", chained.RoleARN, err)
+			}
+			if err := saveCredentials(cc, chained.Profile, profile, chainedCreds); err != nil {
+				return fmt.Errorf("failed to save credentials for chained profile %s: %w", chained.Profile, err)
+			}
+			output.Statusf("Credentials saved for chained profile %s\n", chained.Profile)
+
+		default:
+			return fmt.Errorf("unknown chain_mode %q (expected %s or %s)", profile.ChainMode, config.ChainModeAzure2AWS, config.ChainModeSDK)
 		}
 	}
 
 	return nil
 }
 
-func getPassword(profileName, username string, skipPrompt bool) (string, error) {
-	if password, err := keyring.GetPassword(profileName); err == nil && password != "" {
-		return password, nil
+// cacheChainedRole assumes a chained role and writes it as a role profile
+// whose credentials the AWS CLI finds in its cache, refreshing them itself
+// through source_profile once they expire
+func cacheChainedRole(ctx context.Context, cc *CommandContext, awsClient *aws.Client, profileName string, profile *config.MergedProfile, chained config.ChainedRole, region string, creds *aws.Credentials) error {
+	output.Statusf("Assuming chained role %s...\n", chained.RoleARN)
+	chainedCreds, err := awsClient.AssumeRole(ctx, creds, chained.RoleARN, "azure2aws", aws.MaxChainedSessionDuration, region, profile.Output)
+	if err != nil {
+		return fmt.Errorf("failed to assume chained role %s: %w", chained.RoleARN, err)
+	}
+
+	if err := aws.SaveChainedProfileConfig(chained.Profile, chained.RoleARN, profileName, region, profile.Output); err != nil {
+		return fmt.Errorf("failed to write chained profile %s: %w", chained.Profile, err)
+	}
+	dir, err := aws.DefaultCLICacheDir()
+	if err != nil {
+		return err
+	}
+	if err := aws.SaveCLICache(dir, chained.RoleARN, chainedCreds); err != nil {
+		return fmt.Errorf("failed to cache credentials for chained profile %s: %w", chained.Profile, err)
+	}
+
+	// Static credentials left by an earlier login would only confuse
+	selected, other, err := credentialStores(cc, profile)
+	if err != nil {
+		return err
+	}
+	for _, store := range []aws.CredentialStore{selected, other} {
+		if err := store.Delete(chained.Profile); err != nil && !errors.Is(err, aws.ErrCredentialsNotFound) {
+			return fmt.Errorf("failed to remove the previous credentials of chained profile %s: %w", chained.Profile, err)
+		}
+	}
+
+	output.Statusf("Credentials cached for chained profile %s (AWS CLI cache)\n", chained.Profile)
+	return nil
+}
+
+// validTargetProfile reports whether name can be an AWS profile (a section
+// name of the credentials and config files)
+func validTargetProfile(name string) bool {
+	return strings.TrimSpace(name) == name && name != "" && !strings.ContainsAny(name, "[]\r\n")
+}
+
+// readPasswordStdin reads a password from the first line of stdin. It reads
+// byte by byte so that later lines are left for prompts (such as an MFA code).
+func readPasswordStdin() (string, error) {
+	var line []byte
+	buf := make([]byte, 1)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if n > 0 {
+			if buf[0] == '\n' {
+				break
+			}
+			line = append(line, buf[0])
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read password from stdin: %w", err)
+		}
+	}
+	password := strings.TrimRight(string(line), "\r")
+	if password == "" {
+		return "", fmt.Errorf("no password on stdin")
+	}
+	return password, nil
+}
+
+// getPassword returns the password from the keyring (stored is true) or
+// prompts for it
+func getPassword(prompts *prompter.Scope, kr *keyring.Keyring, profileName, username string, skipPrompt bool) (password string, stored bool, err error) {
+	if password, err := kr.GetPassword(profileName); err == nil && password != "" {
+		return password, true, nil
 	}
 
 	// If skip-prompt is set and no password in keyring, fail
 	if skipPrompt {
-		return "", fmt.Errorf("no password found in keyring and --skip-prompt is set")
+		return "", false, fmt.Errorf("no password found in keyring and --skip-prompt is set")
 	}
 
 	// Prompt for password
-	return prompter.Password(fmt.Sprintf("Password for %s", username))
+	password, err = prompts.SharedPassword(username, fmt.Sprintf("Password for %s", username))
+	return password, false, err
 }
 
-// selectRole prompts user to select a role from multiple options
-func selectRole(roles []*saml.AWSRole) (*saml.AWSRole, error) {
+// updateStoredPassword offers to replace the password in the keyring after
+// the stored one was rejected
+func updateStoredPassword(prompts *prompter.Scope, kr *keyring.Keyring, profileName, password string) {
+	update, err := prompts.Confirm("Update the password stored in the keyring?", true)
+	if err != nil || !update {
+		return
+	}
+	if err := kr.SavePassword(profileName, password); err != nil {
+		output.Statusf("Warning: Failed to save password: %v\n", err)
+	} else {
+		output.Statusln("Password updated in keyring.")
+	}
+}
+
+// recordLogin remembers the assumed role, the available roles and the login
+// time in the profile's state file
+func recordLogin(profileName string, selected *saml.AWSRole, roles []*saml.AWSRole, accountAlias string) error {
+	st, err := state.Load(profileName)
+	if err != nil {
+		// Start over rather than fail on a corrupt state file
+		st = &state.State{}
+	}
+
+	st.LastRoleARN = selected.RoleARN
+	st.LastLogin = time.Now().UTC()
+	st.Roles = make([]state.Role, len(roles))
+	for i, role := range roles {
+		st.Roles[i] = state.Role{RoleARN: role.RoleARN, PrincipalARN: role.PrincipalARN}
+	}
+	if accountAlias != "" {
+		if st.AccountAliases == nil {
+			st.AccountAliases = make(map[string]string)
+		}
+		st.AccountAliases[selected.AccountID()] = accountAlias
+	}
+
+	return state.Save(profileName, st)
+}
+
+// matchRole finds the role given by full ARN or by (case-insensitive) role name
+func matchRole(roles []*saml.AWSRole, want string) (*saml.AWSRole, error) {
+	var matches []*saml.AWSRole
+	for _, role := range roles {
+		if role.RoleARN == want {
+			return role, nil
+		}
+		if strings.EqualFold(role.Name, want) {
+			matches = append(matches, role)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("role %s not found in SAML assertion", want)
+	case 1:
+		return matches[0], nil
+	}
+
+	arns := make([]string, len(matches))
+	for i, role := range matches {
+		arns[i] = role.RoleARN
+	}
+	return nil, fmt.Errorf("role name %q matches several roles, use the full ARN:\n  %s", want, strings.Join(arns, "\n  "))
+}
+
+// assumedRoleMatches reports whether stored credentials belong to the role
+// given by ARN or role name
+func assumedRoleMatches(want, assumedRoleARN string) bool {
+	if strings.HasPrefix(want, "arn:") {
+		return aws.SameRole(want, assumedRoleARN)
+	}
+	roleARN := aws.RoleARNFromAssumedRole(assumedRoleARN)
+	return roleARN != "" && strings.EqualFold(roleARN[strings.LastIndex(roleARN, "/")+1:], want)
+}
+
+// filterRoles narrows roles to those matching the role filter pattern (all
+// roles if it is empty)
+func filterRoles(roles []*saml.AWSRole, pattern string) ([]*saml.AWSRole, error) {
+	if pattern == "" {
+		return roles, nil
+	}
+
+	re, err := saml.CompileRoleFilter(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := saml.FilterRoles(roles, re)
+	if len(filtered) == 0 {
+		return nil, fmt.Errorf("no roles match role filter %q (%d role(s) in SAML assertion)", pattern, len(roles))
+	}
+	return filtered, nil
+}
+
+// findRole returns the role with the given ARN, or nil
+func findRole(roles []*saml.AWSRole, roleARN string) *saml.AWSRole {
+	if roleARN == "" {
+		return nil
+	}
+	for _, role := range roles {
+		if role.RoleARN == roleARN {
+			return role
+		}
+	}
+	return nil
+}
+
+// offerRememberRole asks whether later logins should use role without
+// prompting, and records the answer in the profile state
+func offerRememberRole(prompts *prompter.Scope, profileName string, role *saml.AWSRole) {
+	remember, err := prompts.Confirm(fmt.Sprintf("Always use %s for profile '%s'?", role.Name, profileName), false)
+	if err != nil || !remember {
+		return
+	}
+
+	st, err := state.Load(profileName)
+	if err != nil {
+		st = &state.State{}
+	}
+	st.RememberedRoleARN = role.RoleARN
+	if err := state.Save(profileName, st); err != nil {
+		output.Statusf("Warning: failed to remember role: %v\n", err)
+		return
+	}
+	output.Statusln("Role remembered. Use 'azure2aws login --choose-role' to pick another.")
+}
+
+// selectRole prompts user to select a role from multiple options, marking
+// lastRoleARN as the last used role
+func selectRole(prompts *prompter.Scope, roles []*saml.AWSRole, lastRoleARN string, labels, accounts map[string]string) (*saml.AWSRole, error) {
 	if len(roles) == 0 {
 		return nil, fmt.Errorf("no roles to select from")
 	}
 
 	options := make([]string, len(roles))
 	for i, role := range roles {
-		options[i] = fmt.Sprintf("%s (Account: %s)", role.Name, role.AccountID())
+		options[i] = describeRole(role, labels, accounts)
+		if role.RoleARN == lastRoleARN {
+			options[i] += " [last used]"
+		}
 	}
 
-	idx, err := prompter.Select("Select an AWS role:", options)
+	idx, err := prompts.Select("Select an AWS role:", options)
 	if err != nil {
 		return nil, err
 	}
@@ -180,6 +1211,85 @@ func selectRole(roles []*saml.AWSRole) (*saml.AWSRole, error) {
 	return roles[idx], nil
 }
 
+// describeRole formats a role for display, with its label and account name
+// when known
+func describeRole(role *saml.AWSRole, labels, accounts map[string]string) string {
+	desc := fmt.Sprintf("%s (Account: %s)", role.Name, role.AccountID())
+	if accounts[role.AccountID()] != "" {
+		desc = fmt.Sprintf("%s in %s", role.Name, formatAccount(role.AccountID(), accounts))
+	}
+	if label := labels[role.RoleARN]; label != "" {
+		desc = label + " - " + desc
+	}
+	return desc
+}
+
+// assumeRoleWithSAML assumes a role with the SAML assertion. If the requested
+// duration exceeds the role's MaxSessionDuration, it warns and retries with the
+// default duration, which every role allows (STS does not report the maximum).
+func assumeRoleWithSAML(ctx context.Context, awsClient *aws.Client, role *saml.AWSRole, samlAssertion string, duration int32, profile *config.MergedProfile) (*aws.Credentials, error) {
+	policy, err := loadSessionPolicy(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := awsClient.AssumeRoleWithSAML(ctx, role, samlAssertion, duration, profile.Region, profile.Output, policy)
+	if err == nil || duration <= aws.DefaultSessionDuration || !aws.IsDurationTooLong(err) {
+		return creds, err
+	}
+
+	requested := time.Duration(duration) * time.Second
+	fallback := time.Duration(aws.DefaultSessionDuration) * time.Second
+	output.Statusf("Warning: role %s does not allow %s sessions, retrying with %s\n", role.Name, requested, fallback)
+	output.Statusf("Set session_duration to at most the role's maximum session duration to avoid this\n")
+	return awsClient.AssumeRoleWithSAML(ctx, role, samlAssertion, aws.DefaultSessionDuration, profile.Region, profile.Output, policy)
+}
+
+// loadSessionPolicy reads the profile's session_policy file and policy_arns.
+// Returns nil if the profile has neither.
+func loadSessionPolicy(profile *config.MergedProfile) (*aws.SessionPolicy, error) {
+	if profile.SessionPolicy == "" && len(profile.PolicyARNs) == 0 {
+		return nil, nil
+	}
+
+	policy := &aws.SessionPolicy{PolicyARNs: profile.PolicyARNs}
+	if profile.SessionPolicy != "" {
+		data, err := os.ReadFile(profile.SessionPolicy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read session_policy: %w", err)
+		}
+		if !json.Valid(data) {
+			return nil, fmt.Errorf("session_policy %s is not valid JSON", profile.SessionPolicy)
+		}
+		policy.Policy = string(data)
+	}
+	return policy, nil
+}
+
+// formatAccount renders an account as "Name (123456789012)", or just the ID
+// when it has no name
+func formatAccount(accountID string, accounts map[string]string) string {
+	if name := accounts[accountID]; name != "" {
+		return fmt.Sprintf("%s (%s)", name, accountID)
+	}
+	return accountID
+}
+
+// accountNames returns the account names for a profile: aliases discovered
+// at login, overridden by the accounts section of the config
+func accountNames(profileName string, profile *config.MergedProfile) map[string]string {
+	names := make(map[string]string)
+	if st, err := state.Load(profileName); err == nil {
+		for id, alias := range st.AccountAliases {
+			names[id] = alias
+		}
+	}
+	for id, name := range profile.Accounts {
+		names[id] = name
+	}
+	return names
+}
+
 func formatCredentialsSummary(profileName string, creds *aws.Credentials) string {
 	var sb strings.Builder
 
@@ -198,14 +1308,14 @@ func formatCredentialsSummary(profileName string, creds *aws.Credentials) string
 	return sb.String()
 }
 
-func formatUsageInstructions(profileName string) string {
+func formatUsageInstructions(profileName, shell string) string {
 	var sb strings.Builder
 
 	sb.WriteString("╭─────────────────────────────────────────────────────────────╮\n")
 	sb.WriteString("│ Usage Instructions                                          │\n")
 	sb.WriteString("╞═════════════════════════════════════════════════════════════╡\n")
-	sb.WriteString("│ Set as default profile:                                     │\n")
-	sb.WriteString(fmt.Sprintf("│   export AWS_PROFILE=%-42s │\n", profileName))
+	sb.WriteString(fmt.Sprintf("│ Set as default profile (%s):%s│\n", shell, strings.Repeat(" ", max(0, 34-len(shell)))))
+	sb.WriteString(fmt.Sprintf("│   %-57s │\n", shellSetEnv(shell, "AWS_PROFILE", profileName)))
 	sb.WriteString("│                                                             │\n")
 	sb.WriteString("│ Or use directly:                                            │\n")
 	sb.WriteString(fmt.Sprintf("│   aws --profile %-19s sts get-caller-identity │\n", profileName))
@@ -213,3 +1323,18 @@ func formatUsageInstructions(profileName string) string {
 
 	return sb.String()
 }
+
+// formatKeyringUsageInstructions is formatUsageInstructions for credentials
+// kept in the keyring, which only azure2aws commands can read
+func formatKeyringUsageInstructions(profileName string) string {
+	var sb strings.Builder
+
+	sb.WriteString("╭─────────────────────────────────────────────────────────────╮\n")
+	sb.WriteString("│ Usage Instructions                                          │\n")
+	sb.WriteString("╞═════════════════════════════════════════════════════════════╡\n")
+	sb.WriteString("│ Credentials are kept in the keyring; run commands with:     │\n")
+	sb.WriteString(fmt.Sprintf("│   %-57s │\n", "azure2aws exec --profile "+profileName+" -- aws s3 ls"))
+	sb.WriteString("╰─────────────────────────────────────────────────────────────╯")
+
+	return sb.String()
+}
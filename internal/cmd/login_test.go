@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/user/azure2aws/internal/config"
+)
+
+func TestAzureSessionRequireMFA(t *testing.T) {
+	notOnOrAfter := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	samlAssertion := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf(
+		`<Response><Assertion><Conditions NotOnOrAfter="%s"/></Assertion></Response>`, notOnOrAfter)))
+
+	first := &config.MergedProfile{Name: "dev", URL: "https://example.com", AppID: "app", Username: "user@example.com"}
+	session := newAzureSession()
+	session.cookies[sessionUser(first)] = "cookies"
+	session.record(first, nil, samlAssertion, "password")
+
+	tests := []struct {
+		name       string
+		requireMFA bool
+		wantShared bool
+	}{
+		{name: "without require_mfa", requireMFA: false, wantShared: true},
+		{name: "require_mfa", requireMFA: true, wantShared: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile := *first
+			profile.Name = "prod"
+			profile.RequireMFA = tt.requireMFA
+
+			if _, ok := session.assertion(&profile); ok != tt.wantShared {
+				t.Errorf("assertion shared = %v, want %v", ok, tt.wantShared)
+			}
+			if _, ok := session.sessionCookies(&profile); ok != tt.wantShared {
+				t.Errorf("session cookies shared = %v, want %v", ok, tt.wantShared)
+			}
+			if session.passwords[sessionUser(&profile)] != "password" {
+				t.Error("expected the password to be shared")
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/user/azure2aws/internal/output"
+)
+
+// Prompt output formats
+const (
+	promptFormatPlain    = "plain"
+	promptFormatStarship = "starship"
+)
+
+// promptWarnWithin is when 'prompt --format starship' turns the segment
+// yellow; it turns red when the credentials are expiring (see status)
+const promptWarnWithin = 15 * time.Minute
+
+type promptOptions struct {
+	format string
+}
+
+func newPromptCmd(cc *CommandContext) *cobra.Command {
+	var opts promptOptions
+
+	cmd := &cobra.Command{
+		Use:   "prompt",
+		Short: "Print a profile's remaining credential time for a shell prompt",
+		Long: `Prints the profile name and the time left on its stored credentials, such
+as 'production 37m', or nothing when they are missing or expired. Only the
+credentials file (or the profile's state file, for credentials kept in the
+keyring) is read, so it is fast enough to run on every prompt.
+
+Formats:
+  plain     text, for PS1, PROMPT or tmux
+  starship  text styled by the time left (green, yellow within 15 minutes,
+            red within 5), for a starship custom module with
+            unsafe_no_escape = true
+
+Examples:
+  PS1='$(azure2aws prompt --profile production) \$ '
+
+  # ~/.config/starship.toml
+  [custom.azure2aws]
+  command = "azure2aws prompt --format starship"
+  when = true
+  unsafe_no_escape = true`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPrompt(cc, opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.format, "format", promptFormatPlain, "Output format (plain, starship)")
+
+	return cmd
+}
+
+func runPrompt(cc *CommandContext, opts promptOptions) error {
+	if opts.format != promptFormatPlain && opts.format != promptFormatStarship {
+		return fmt.Errorf("invalid format %q (must be plain or starship)", opts.format)
+	}
+
+	expiration, _, ok := storedCredentialsInfo(cc.Profile)
+	if !ok {
+		return nil
+	}
+
+	now := time.Now()
+	text, style := cc.Profile, "green"
+	if !expiration.IsZero() {
+		remaining := expiration.Sub(now)
+		if remaining <= 0 {
+			return nil
+		}
+		text += " " + formatRemaining(remaining)
+		switch {
+		case credentialsStatus(expiration, now) == "expiring":
+			style = "red"
+		case remaining < promptWarnWithin:
+			style = "yellow"
+		}
+	}
+
+	if opts.format == promptFormatStarship {
+		text = fmt.Sprintf("[%s](%s)", text, style)
+	}
+	output.Println(text)
+	return nil
+}
+
+// formatRemaining formats a duration compactly, as '1h12m' or '37m'
+func formatRemaining(d time.Duration) string {
+	minutes := int(d / time.Minute)
+	if minutes >= 60 {
+		return fmt.Sprintf("%dh%02dm", minutes/60, minutes%60)
+	}
+	return fmt.Sprintf("%dm", minutes)
+}
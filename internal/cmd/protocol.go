@@ -0,0 +1,411 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/user/azure2aws/internal/config"
+	"github.com/user/azure2aws/internal/output"
+	"github.com/user/azure2aws/internal/state"
+)
+
+const (
+	// protocolScheme is the URL scheme handled by azure2aws
+	protocolScheme = "azure2aws"
+
+	protocolDesktopFile = "azure2aws-handler.desktop"
+	protocolMacAppName  = "Azure2AWS Handler.app"
+)
+
+var (
+	validProfileName = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+	validServiceName = regexp.MustCompile(`^[a-z0-9-]*$`)
+	validRegionName  = regexp.MustCompile(`^([a-z]{2}(-[a-z]+)+-[0-9]+)?$`)
+)
+
+func newProtocolCmd(cc *CommandContext) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "protocol",
+		Short: "Manage the azure2aws:// URL handler",
+		Long: `Registers an azure2aws:// URL handler so dashboards and wikis can link
+directly into federated console sessions.
+
+Supported links:
+  azure2aws://console?profile=production
+  azure2aws://console?profile=production&service=ec2
+  azure2aws://console?profile=production&service=ec2&region=eu-west-1&path=%23Instances
+  azure2aws://console?profile=production&role_arn=arn:aws:iam::123456789012:role/ReadOnly
+
+A role_arn is only used when it is a chained role of the profile, or a role
+of its last SAML assertion that is also its configured, remembered or last
+used role; other roles must be confirmed at a terminal.
+
+Examples:
+  azure2aws protocol install
+  azure2aws protocol uninstall`,
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "install",
+		Short: "Register the azure2aws:// URL handler for the current user",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runProtocolInstall()
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "uninstall",
+		Short: "Remove the azure2aws:// URL handler",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runProtocolUninstall()
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:    "open <url>",
+		Short:  "Handle an azure2aws:// URL (invoked by the OS)",
+		Args:   cobra.ExactArgs(1),
+		Hidden: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runProtocolOpen(cmd.Context(), cc, args[0])
+		},
+	})
+
+	return cmd
+}
+
+func runProtocolOpen(ctx context.Context, cc *CommandContext, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+
+	if u.Scheme != protocolScheme {
+		return fmt.Errorf("unsupported URL scheme %q (expected %s://)", u.Scheme, protocolScheme)
+	}
+
+	// azure2aws://console?... parses with "console" as the host;
+	// azure2aws:console?... parses it as the opaque part
+	action := u.Host
+	if action == "" {
+		action = strings.Trim(u.Opaque+u.Path, "/")
+	}
+	if action != "console" {
+		return fmt.Errorf("unsupported action %q (expected console)", action)
+	}
+
+	query := u.Query()
+
+	profileName := query.Get("profile")
+	if profileName == "" {
+		profileName = cc.Profile
+	}
+	if !validProfileName.MatchString(profileName) {
+		return fmt.Errorf("invalid profile name %q", profileName)
+	}
+
+	service := query.Get("service")
+	if !validServiceName.MatchString(service) {
+		return fmt.Errorf("invalid service name %q", service)
+	}
+
+	region := query.Get("region")
+	if !validRegionName.MatchString(region) {
+		return fmt.Errorf("invalid region %q", region)
+	}
+
+	cc.Profile = profileName
+
+	roleARN := query.Get("role_arn")
+	if roleARN != "" {
+		if err := checkLinkedRole(cc, profileName, roleARN); err != nil {
+			return err
+		}
+	}
+
+	return runConsole(ctx, cc, consoleOptions{
+		service: service,
+		region:  region,
+		path:    query.Get("path"),
+		roleARN: roleARN,
+	})
+}
+
+// checkLinkedRole makes sure a link only opens the console as a role the
+// user already uses: one of the roles of the last SAML assertion that is the
+// configured, remembered or last used role of the profile, or one of its
+// chained roles. Other roles must be confirmed at a terminal.
+func checkLinkedRole(cc *CommandContext, profileName, roleARN string) error {
+	st, err := state.Load(profileName)
+	if err != nil {
+		return err
+	}
+
+	var configured []string
+	cfg, err := cc.LoadConfig()
+	if err != nil && !errors.Is(err, config.ErrConfigNotFound) {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err == nil {
+		if profile, ok := cfg.Profiles[profileName]; ok {
+			configured = append(configured, profile.RoleARN)
+			for _, chained := range profile.ChainedRoles {
+				if chained.RoleARN == roleARN {
+					return nil
+				}
+			}
+		}
+	}
+
+	offered := false
+	for _, r := range st.Roles {
+		offered = offered || r.RoleARN == roleARN
+	}
+	if offered {
+		for _, trusted := range append(configured, st.RememberedRoleARN, st.LastRoleARN) {
+			if trusted == roleARN {
+				return nil
+			}
+		}
+	}
+
+	output.Statusf("The link asks to open the console of profile '%s' as %s, which is not a role you use with it.\n", profileName, roleARN)
+	ok, err := cc.Prompts("").Confirm("Open the console as this role?", false)
+	if err != nil {
+		return fmt.Errorf("refusing role_arn %s from the link: %w", roleARN, err)
+	}
+	if !ok {
+		return fmt.Errorf("refusing role_arn %s from the link", roleARN)
+	}
+	return nil
+}
+
+func runProtocolInstall() error {
+	execPath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get current executable path: %w", err)
+	}
+
+	execPath, err = resolveSymlink(execPath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve executable path: %w", err)
+	}
+
+	switch runtime.GOOS {
+	case "linux", "freebsd", "openbsd", "netbsd":
+		err = installProtocolLinux(execPath)
+	case "darwin":
+		err = installProtocolDarwin(execPath)
+	case "windows":
+		err = installProtocolWindows(execPath)
+	default:
+		return fmt.Errorf("URL handler registration is not supported on %s", runtime.GOOS)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to register URL handler: %w", err)
+	}
+
+	output.Statusf("Registered %s:// URL handler (%s)\n", protocolScheme, execPath)
+	output.Statusf("Try it: %s://console?profile=default\n", protocolScheme)
+	return nil
+}
+
+func runProtocolUninstall() error {
+	var err error
+
+	switch runtime.GOOS {
+	case "linux", "freebsd", "openbsd", "netbsd":
+		err = uninstallProtocolLinux()
+	case "darwin":
+		err = uninstallProtocolDarwin()
+	case "windows":
+		err = uninstallProtocolWindows()
+	default:
+		return fmt.Errorf("URL handler registration is not supported on %s", runtime.GOOS)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to remove URL handler: %w", err)
+	}
+
+	output.Statusf("Removed %s:// URL handler\n", protocolScheme)
+	return nil
+}
+
+// Linux: XDG desktop entry registered as the x-scheme-handler
+
+func linuxApplicationsDir() (string, error) {
+	if dataHome := os.Getenv("XDG_DATA_HOME"); dataHome != "" {
+		return filepath.Join(dataHome, "applications"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".local", "share", "applications"), nil
+}
+
+func installProtocolLinux(execPath string) error {
+	appsDir, err := linuxApplicationsDir()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(appsDir, 0755); err != nil {
+		return fmt.Errorf("failed to create applications directory: %w", err)
+	}
+
+	entry := fmt.Sprintf(`[Desktop Entry]
+Type=Application
+Name=azure2aws URL Handler
+Exec="%s" protocol open %%u
+NoDisplay=true
+Terminal=false
+MimeType=x-scheme-handler/%s;
+`, execPath, protocolScheme)
+
+	desktopPath := filepath.Join(appsDir, protocolDesktopFile)
+	if err := os.WriteFile(desktopPath, []byte(entry), 0644); err != nil {
+		return fmt.Errorf("failed to write desktop entry: %w", err)
+	}
+
+	if err := exec.Command("xdg-mime", "default", protocolDesktopFile, "x-scheme-handler/"+protocolScheme).Run(); err != nil {
+		return fmt.Errorf("failed to run xdg-mime (desktop entry written to %s): %w", desktopPath, err)
+	}
+
+	// Refreshing the desktop database is best-effort
+	_ = exec.Command("update-desktop-database", appsDir).Run()
+
+	return nil
+}
+
+func uninstallProtocolLinux() error {
+	appsDir, err := linuxApplicationsDir()
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(filepath.Join(appsDir, protocolDesktopFile)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	_ = exec.Command("update-desktop-database", appsDir).Run()
+	return nil
+}
+
+// macOS: AppleScript applet declaring the URL scheme in its Info.plist
+
+func darwinHandlerAppPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, "Applications", protocolMacAppName), nil
+}
+
+func installProtocolDarwin(execPath string) error {
+	appPath, err := darwinHandlerAppPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(appPath), 0755); err != nil {
+		return fmt.Errorf("failed to create Applications directory: %w", err)
+	}
+
+	script := fmt.Sprintf(`on open location theURL
+	do shell script quoted form of %q & " protocol open " & quoted form of theURL
+end open location
+`, execPath)
+
+	scriptFile, err := os.CreateTemp("", "azure2aws-handler-*.applescript")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(scriptFile.Name())
+
+	if _, err := scriptFile.WriteString(script); err != nil {
+		scriptFile.Close()
+		return err
+	}
+	scriptFile.Close()
+
+	_ = os.RemoveAll(appPath)
+	if out, err := exec.Command("osacompile", "-o", appPath, scriptFile.Name()).CombinedOutput(); err != nil {
+		return fmt.Errorf("osacompile failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	plist := filepath.Join(appPath, "Contents", "Info.plist")
+	plistCommands := []string{
+		"Add :CFBundleIdentifier string com.github.rayselfs.azure2aws.handler",
+		"Add :CFBundleURLTypes array",
+		"Add :CFBundleURLTypes:0 dict",
+		"Add :CFBundleURLTypes:0:CFBundleURLName string azure2aws URL",
+		"Add :CFBundleURLTypes:0:CFBundleURLSchemes array",
+		"Add :CFBundleURLTypes:0:CFBundleURLSchemes:0 string " + protocolScheme,
+		"Add :LSBackgroundOnly bool true",
+	}
+	for _, c := range plistCommands {
+		if out, err := exec.Command("/usr/libexec/PlistBuddy", "-c", c, plist).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to update Info.plist: %w: %s", err, strings.TrimSpace(string(out)))
+		}
+	}
+
+	lsregister := "/System/Library/Frameworks/CoreServices.framework/Frameworks/LaunchServices.framework/Support/lsregister"
+	if err := exec.Command(lsregister, "-f", appPath).Run(); err != nil {
+		return fmt.Errorf("failed to register %s with LaunchServices: %w", appPath, err)
+	}
+
+	return nil
+}
+
+func uninstallProtocolDarwin() error {
+	appPath, err := darwinHandlerAppPath()
+	if err != nil {
+		return err
+	}
+
+	lsregister := "/System/Library/Frameworks/CoreServices.framework/Frameworks/LaunchServices.framework/Support/lsregister"
+	_ = exec.Command(lsregister, "-u", appPath).Run()
+
+	return os.RemoveAll(appPath)
+}
+
+// Windows: per-user registry keys under HKCU\Software\Classes
+
+const windowsProtocolKey = `HKCU\Software\Classes\` + protocolScheme
+
+func installProtocolWindows(execPath string) error {
+	commands := [][]string{
+		{"add", windowsProtocolKey, "/ve", "/d", "URL:azure2aws Protocol", "/f"},
+		{"add", windowsProtocolKey, "/v", "URL Protocol", "/d", "", "/f"},
+		{"add", windowsProtocolKey + `\shell\open\command`, "/ve", "/d", fmt.Sprintf(`"%s" protocol open "%%1"`, execPath), "/f"},
+	}
+
+	for _, args := range commands {
+		if out, err := exec.Command("reg", args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("reg %s failed: %w: %s", args[0], err, strings.TrimSpace(string(out)))
+		}
+	}
+
+	return nil
+}
+
+func uninstallProtocolWindows() error {
+	out, err := exec.Command("reg", "delete", windowsProtocolKey, "/f").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("reg delete failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/user/azure2aws/internal/aws"
+	"github.com/user/azure2aws/internal/output"
+)
+
+type pruneOptions struct {
+	dryRun bool
+}
+
+func newPruneCmd(cc *CommandContext) *cobra.Command {
+	var opts pruneOptions
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove expired credentials from the credentials file",
+		Long: `Removes the profiles of the AWS credentials file whose credentials have
+expired (x_security_token_expires, or another expiration key azure2aws
+writes, is in the past). Profiles without an expiration, such as long-term
+access keys, are never removed, and neither are comments or other profiles.
+
+Example:
+  azure2aws prune --dry-run
+  azure2aws prune`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPrune(opts)
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "List the expired profiles without removing them")
+
+	return cmd
+}
+
+func runPrune(opts pruneOptions) error {
+	store, err := aws.NewFileStore()
+	if err != nil {
+		return err
+	}
+
+	pruned, err := store.Prune(time.Now(), opts.dryRun)
+	if err != nil {
+		return err
+	}
+	if len(pruned) == 0 {
+		output.Statusf("No expired credentials in %s\n", store.CredentialsPath)
+		return nil
+	}
+
+	names := make([]string, 0, len(pruned))
+	for name := range pruned {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	verb := "Removed"
+	if opts.dryRun {
+		verb = "Would remove"
+	}
+	for _, name := range names {
+		output.Statusf("%s %s (expired %s)\n", verb, name, pruned[name].Local().Format("2006-01-02 15:04:05"))
+	}
+	output.Statusf("%s %d expired profiles from %s\n", verb, len(names), store.CredentialsPath)
+	return nil
+}
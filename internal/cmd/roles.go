@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/user/azure2aws/internal/config"
+	"github.com/user/azure2aws/internal/output"
+	"github.com/user/azure2aws/internal/saml"
+	"github.com/user/azure2aws/internal/state"
+)
+
+func newListRolesCmd(cc *CommandContext) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list-roles",
+		Short: "List the roles available to a profile",
+		Long: `Lists the AWS roles offered to a profile at its last login, with their
+account names (from the accounts config section or discovered aliases) and
+role labels. The role used last is marked with '*'.
+
+Example:
+  azure2aws list-roles --profile production`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runListRoles(cc)
+		},
+	}
+
+	return cmd
+}
+
+func runListRoles(cc *CommandContext) error {
+	profileName := cc.Profile
+
+	cfg, err := cc.LoadOrCreateConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	profile, err := cfg.GetProfile(profileName)
+	if errors.Is(err, config.ErrProfileNotFound) {
+		return fmt.Errorf("profile '%s' not found\nRun 'azure2aws configure --profile %s' to set up a profile", profileName, profileName)
+	}
+	if err != nil {
+		return err
+	}
+
+	st, err := state.Load(profileName)
+	if err != nil {
+		return err
+	}
+	if len(st.Roles) == 0 {
+		return fmt.Errorf("no roles recorded for profile '%s'\nRun 'azure2aws login --profile %s' first", profileName, profileName)
+	}
+
+	accounts := accountNames(profileName, profile)
+
+	w := tabwriter.NewWriter(output.Data(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\tACCOUNT\tROLE\tLABEL\tROLE ARN")
+	for _, r := range st.Roles {
+		role := &saml.AWSRole{RoleARN: r.RoleARN, PrincipalARN: r.PrincipalARN, Name: r.RoleARN[strings.LastIndex(r.RoleARN, "/")+1:]}
+
+		marker := ""
+		if r.RoleARN == st.LastRoleARN {
+			marker = "*"
+		}
+		label := profile.RoleLabel(r.RoleARN)
+		if label == "" {
+			label = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", marker, formatAccount(role.AccountID(), accounts), role.Name, label, role.RoleARN)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/user/azure2aws/internal/output"
+	"github.com/user/azure2aws/internal/prompter"
+)
+
+// backupMetadataFile describes the binary kept in the backups directory
+const backupMetadataFile = "backup.json"
+
+// binaryBackup is the binary replaced by the last update or rollback
+type binaryBackup struct {
+	Version     string    `json:"version"`
+	Binary      string    `json:"binary"`       // File name in the backups directory
+	InstallPath string    `json:"install_path"` // Where the binary was installed
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type rollbackOptions struct {
+	force bool
+}
+
+func newUpdateRollbackCmd(cc *CommandContext) *cobra.Command {
+	var opts rollbackOptions
+
+	cmd := &cobra.Command{
+		Use:   "rollback",
+		Short: "Restore the binary replaced by the last update",
+		Long: `Restores the azure2aws binary that the last update replaced, kept in
+~/.azure2aws/backups. The binary being rolled back from takes its place, so
+running rollback again returns to it.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runUpdateRollback(cc.Prompts(""), cc.Version, opts)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&opts.force, "force", "f", false, "Roll back without confirmation")
+
+	return cmd
+}
+
+func runUpdateRollback(prompts *prompter.Scope, currentVersion string, opts rollbackOptions) error {
+	execPath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get current executable path: %w", err)
+	}
+	execPath, err = resolveSymlink(execPath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve executable path: %w", err)
+	}
+
+	unlock, err := acquireLock(updateLockPath(execPath))
+	if err != nil {
+		return fmt.Errorf("another update is already in progress: %w", err)
+	}
+	defer unlock()
+
+	backup, backupPath, err := loadBackup()
+	if err != nil {
+		return err
+	}
+	if backup.InstallPath != execPath {
+		return fmt.Errorf("the backup was installed at %s, not %s", backup.InstallPath, execPath)
+	}
+
+	output.Statusf("Current version: %s\n", currentVersion)
+	output.Statusf("Backup version:  %s (replaced %s)\n", backup.Version, backup.CreatedAt.Local().Format("2006-01-02 15:04"))
+
+	if !opts.force {
+		ok, err := prompts.Confirm(fmt.Sprintf("Roll back to %s?", backup.Version), false)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			output.Statusln("Rollback cancelled.")
+			return nil
+		}
+	}
+
+	// Take the backup out of the way before the current binary replaces it
+	tmp, err := os.CreateTemp("", "azure2aws-rollback-*")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	binaryPath := tmp.Name()
+	if err := copyFileAtomic(backupPath, binaryPath, 0755); err != nil {
+		os.Remove(binaryPath)
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+	keepBinary := false
+	defer func() {
+		if !keepBinary {
+			os.Remove(binaryPath)
+		}
+	}()
+
+	if err := saveBackup(execPath, currentVersion); err != nil {
+		return fmt.Errorf("failed to back up the current binary: %w", err)
+	}
+
+	output.Statusln("Restoring backup...")
+	if err := replaceBinary(execPath, binaryPath); err != nil {
+		if !errors.Is(err, fs.ErrPermission) {
+			return fmt.Errorf("failed to restore backup: %w", err)
+		}
+
+		installed, err := installElevated(prompts, execPath, binaryPath)
+		if err != nil {
+			return fmt.Errorf("failed to restore backup: %w", err)
+		}
+		if !installed {
+			keepBinary = true
+			return nil
+		}
+	}
+
+	output.Statusf("Rolled back to %s\n", backup.Version)
+	return nil
+}
+
+// backupDir returns the directory keeping the binary replaced by an update
+func backupDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".azure2aws", "backups"), nil
+}
+
+// saveBackup copies the binary at execPath to the backups directory,
+// replacing any earlier backup
+func saveBackup(execPath, version string) error {
+	dir, err := backupDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	name := "azure2aws-" + version
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	previous, _, _ := loadBackup()
+
+	if err := copyFileAtomic(execPath, filepath.Join(dir, name), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(&binaryBackup{
+		Version:     version,
+		Binary:      name,
+		InstallPath: execPath,
+		CreatedAt:   time.Now(),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, backupMetadataFile), data, 0600); err != nil {
+		return err
+	}
+
+	if previous != nil && previous.Binary != name {
+		os.Remove(filepath.Join(dir, previous.Binary))
+	}
+	return nil
+}
+
+// loadBackup returns the backup metadata and the path of the backup binary
+func loadBackup() (*binaryBackup, string, error) {
+	dir, err := backupDir()
+	if err != nil {
+		return nil, "", err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, backupMetadataFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, "", fmt.Errorf("no backup found in %s; backups are kept from the next update on", dir)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read backup metadata: %w", err)
+	}
+
+	var backup binaryBackup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return nil, "", fmt.Errorf("failed to parse backup metadata: %w", err)
+	}
+
+	path := filepath.Join(dir, filepath.Base(backup.Binary))
+	if _, err := os.Stat(path); err != nil {
+		return nil, "", fmt.Errorf("backup binary missing: %w", err)
+	}
+	return &backup, path, nil
+}
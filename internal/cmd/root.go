@@ -1,22 +1,58 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
 
 	"github.com/spf13/cobra"
+	"github.com/user/azure2aws/internal/config"
 	"github.com/user/azure2aws/internal/logging"
+	"github.com/user/azure2aws/internal/prompter"
+	"github.com/user/azure2aws/internal/provider/azuread"
 )
 
-var (
-	cfgFile string
-	profile string
-	verbose bool
-	debug   bool
+// Exit statuses other than 1 (any other failure)
+const (
+	ExitNoInput            = 3 // A command needed input under --no-input
+	ExitInvalidCredentials = 4 // Azure AD rejected the username or password
+	ExitMFARequired        = 5 // MFA was required, failed or is not registered
+	ExitAccessBlocked      = 6 // Conditional Access or assignment denied sign-in
+	ExitAccountLocked      = 7 // The account is locked or disabled
+
+	ExitInterrupted = 130 // Cancelled by Ctrl+C or SIGTERM (128 + SIGINT)
 )
 
+// ExitCode returns the process exit status for an error returned by the root
+// command
+func ExitCode(err error) int {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ExitInterrupted
+	case errors.Is(err, prompter.ErrNoInput):
+		return ExitNoInput
+	case errors.Is(err, azuread.ErrInvalidCredentials):
+		return ExitInvalidCredentials
+	case errors.Is(err, azuread.ErrMFARequired):
+		return ExitMFARequired
+	case errors.Is(err, azuread.ErrAccessBlocked):
+		return ExitAccessBlocked
+	case errors.Is(err, azuread.ErrAccountLocked), errors.Is(err, azuread.ErrAccountDisabled):
+		return ExitAccountLocked
+	}
+	return 1
+}
+
 // NewRootCmd creates the root command
 func NewRootCmd(version, commit, date string) *cobra.Command {
+	return NewRootCmdWithContext(NewCommandContext(version, commit, date))
+}
+
+// NewRootCmdWithContext creates the root command bound to the given context
+func NewRootCmdWithContext(cc *CommandContext) *cobra.Command {
+	var check *updateCheck
+
 	rootCmd := &cobra.Command{
 		Use:   "azure2aws",
 		Short: "AWS credentials via Azure AD SAML authentication",
@@ -26,54 +62,68 @@ retrieves temporary AWS credentials using SAML.
 Simplified alternative to saml2aws, focused on Azure AD only.`,
 		SilenceUsage: true,
 		PersistentPreRun: func(cmd *cobra.Command, args []string) {
-			logging.InitLogger(verbose, debug)
+			logging.InitLogger(cc.Verbose, cc.Debug)
+			removeStaleBinary()
 
-			if cfgFile == "" {
+			if cc.ConfigFile == "" {
 				home, err := os.UserHomeDir()
 				if err == nil {
-					cfgFile = filepath.Join(home, ".azure2aws", "config.yaml")
+					cc.ConfigFile = filepath.Join(home, ".azure2aws", "config.yaml")
+				}
+			}
+			// AZURE2AWS_* overrides apply to this profile only, not to the
+			// other members of a group or the profiles a command lists
+			cc.configs.SelectProfile(cc.Profile)
+
+			// --no-input and AZURE2AWS_NO_INPUT take precedence over no_input
+			if !cmd.Flag("no-input").Changed && os.Getenv(config.EnvNoInput) == "" {
+				if defaults, ok := config.PeekDefaults(cc.ConfigFile); ok {
+					cc.NoInput = defaults.NoInput
 				}
 			}
+			cc.prompts.SetNoInput(cc.NoInput)
 
-			if cmd.Name() != "update" && cmd.Name() != "version" {
-				CheckForUpdateAsync(version)
+			switch cmd.Name() {
+			case "update", "version", "prompt", "credential-process", cobra.ShellCompRequestCmd:
+			default:
+				check = startUpdateCheck(cc.ConfigFile, cc.Version)
 			}
 		},
+		PersistentPostRun: func(cmd *cobra.Command, args []string) {
+			check.notify()
+		},
 	}
 
 	// Global flags
-	rootCmd.PersistentFlags().StringVarP(&profile, "profile", "p", "default", "AWS profile name")
-	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output")
-	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "Enable debug mode")
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "Config file (default: ~/.azure2aws/config.yaml)")
+	rootCmd.PersistentFlags().StringVarP(&cc.Profile, "profile", "p", cc.Profile, "AWS profile name")
+	rootCmd.PersistentFlags().BoolVarP(&cc.Verbose, "verbose", "v", cc.Verbose, "Enable verbose output")
+	rootCmd.PersistentFlags().BoolVar(&cc.Debug, "debug", cc.Debug, "Enable debug mode")
+	rootCmd.PersistentFlags().StringVar(&cc.ConfigFile, "config", cc.ConfigFile, "Config file (default: ~/.azure2aws/config.yaml)")
+	rootCmd.PersistentFlags().BoolVar(&cc.NoInput, "no-input", cc.NoInput, "Fail instead of prompting for input (exit status 3)")
 
 	// Add subcommands
-	rootCmd.AddCommand(newLoginCmd())
-	rootCmd.AddCommand(newConfigureCmd())
-	rootCmd.AddCommand(newExecCmd())
-	rootCmd.AddCommand(newConsoleCmd())
-	rootCmd.AddCommand(newVersionCmd(version, commit, date))
-	rootCmd.AddCommand(newUpdateCmd(version))
+	rootCmd.AddCommand(newLoginCmd(cc))
+	rootCmd.AddCommand(newConfigureCmd(cc))
+	rootCmd.AddCommand(newConfigCmd(cc))
+	rootCmd.AddCommand(newExecCmd(cc))
+	rootCmd.AddCommand(newCredentialProcessCmd(cc))
+	rootCmd.AddCommand(newStatusCmd(cc))
+	rootCmd.AddCommand(newPruneCmd(cc))
+	rootCmd.AddCommand(newUICmd(cc))
+	rootCmd.AddCommand(newPromptCmd(cc))
+	rootCmd.AddCommand(newDirenvCmd(cc))
+	rootCmd.AddCommand(newListRolesCmd(cc))
+	rootCmd.AddCommand(newAWSConfigGenerateCmd(cc))
+	rootCmd.AddCommand(newConsoleCmd(cc))
+	rootCmd.AddCommand(newWhoamiCmd(cc))
+	rootCmd.AddCommand(newDoctorCmd(cc))
+	rootCmd.AddCommand(newServerCmd(cc))
+	rootCmd.AddCommand(newDaemonCmd(cc))
+	rootCmd.AddCommand(newBenchCmd(cc))
+	rootCmd.AddCommand(newSAMLCmd(cc))
+	rootCmd.AddCommand(newProtocolCmd(cc))
+	rootCmd.AddCommand(newVersionCmd(cc))
+	rootCmd.AddCommand(newUpdateCmd(cc))
 
 	return rootCmd
 }
-
-// GetProfile returns the current profile name
-func GetProfile() string {
-	return profile
-}
-
-// GetConfigFile returns the config file path
-func GetConfigFile() string {
-	return cfgFile
-}
-
-// IsVerbose returns whether verbose mode is enabled
-func IsVerbose() bool {
-	return verbose
-}
-
-// IsDebug returns whether debug mode is enabled
-func IsDebug() bool {
-	return debug
-}
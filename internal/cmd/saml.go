@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/user/azure2aws/internal/cache"
+	"github.com/user/azure2aws/internal/output"
+	"github.com/user/azure2aws/internal/saml"
+)
+
+func newSAMLCmd(cc *CommandContext) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "saml",
+		Short: "Inspect SAML assertions",
+	}
+
+	cmd.AddCommand(newSAMLDecodeCmd(cc))
+
+	return cmd
+}
+
+type samlDecodeOptions struct {
+	file  string
+	last  bool
+	noXML bool
+}
+
+func newSAMLDecodeCmd(cc *CommandContext) *cobra.Command {
+	var opts samlDecodeOptions
+
+	cmd := &cobra.Command{
+		Use:   "decode",
+		Short: "Decode and summarize a SAML response",
+		Long: `Decodes a SAML response and prints a summary (issuer, subject, audience,
+validity window, session duration and AWS roles) followed by the
+pretty-printed XML.
+
+The response is read from a file (-f), the profile's cached assertion
+(--last, see 'login --cache-saml'), or stdin. Base64, URL-encoded base64
+(as copied from browser developer tools) and raw XML are accepted.
+
+Examples:
+  azure2aws saml decode -f response.txt
+  azure2aws saml decode --last --profile production
+  pbpaste | azure2aws saml decode --no-xml`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSAMLDecode(cc, opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.file, "file", "f", "", "Read the SAML response from a file ('-' for stdin)")
+	cmd.Flags().BoolVar(&opts.last, "last", false, "Use the profile's cached SAML assertion")
+	cmd.Flags().BoolVar(&opts.noXML, "no-xml", false, "Only print the summary")
+
+	return cmd
+}
+
+func runSAMLDecode(cc *CommandContext, opts samlDecodeOptions) error {
+	if opts.last && opts.file != "" {
+		return fmt.Errorf("--last and --file cannot be used together")
+	}
+
+	var raw string
+	switch {
+	case opts.last:
+		backend, err := cc.KeyringBackend()
+		if err != nil {
+			return err
+		}
+
+		raw, err = cache.New(backend).Get(cc.Profile, cache.KindSAMLAssertion, 0)
+		if errors.Is(err, cache.ErrMiss) {
+			return fmt.Errorf("no cached SAML assertion for profile '%s'\nRun 'azure2aws login --profile %s --cache-saml' first", cc.Profile, cc.Profile)
+		}
+		if err != nil {
+			return err
+		}
+
+	case opts.file != "" && opts.file != "-":
+		data, err := os.ReadFile(opts.file)
+		if err != nil {
+			return fmt.Errorf("failed to read SAML response: %w", err)
+		}
+		raw = string(data)
+
+	default:
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read SAML response: %w", err)
+		}
+		raw = string(data)
+	}
+
+	samlAssertion, err := normalizeSAMLInput(raw)
+	if err != nil {
+		return err
+	}
+
+	summary, err := saml.Summarize(samlAssertion)
+	if err != nil {
+		return err
+	}
+	output.Println(formatSAMLSummary(summary, time.Now()))
+
+	if !opts.noXML {
+		xml, err := saml.PrettyXML(samlAssertion)
+		if err != nil {
+			return err
+		}
+		output.Println()
+		output.Println(strings.TrimSpace(xml))
+	}
+
+	return nil
+}
+
+// normalizeSAMLInput converts a pasted SAML response (base64, possibly
+// line-wrapped or URL-encoded, or raw XML) to plain base64
+func normalizeSAMLInput(raw string) (string, error) {
+	raw = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(raw), "SAMLResponse="))
+	if raw == "" {
+		return "", fmt.Errorf("no SAML response provided")
+	}
+
+	if strings.HasPrefix(raw, "<") {
+		return base64.StdEncoding.EncodeToString([]byte(raw)), nil
+	}
+
+	if strings.Contains(raw, "%") {
+		unescaped, err := url.QueryUnescape(raw)
+		if err != nil {
+			return "", fmt.Errorf("failed to URL-decode SAML response: %w", err)
+		}
+		raw = unescaped
+	}
+
+	return strings.Join(strings.Fields(raw), ""), nil
+}
+
+// formatSAMLSummary renders a SAML summary, flagging an expired or not yet
+// valid assertion
+func formatSAMLSummary(s *saml.Summary, now time.Time) string {
+	var sb strings.Builder
+
+	field := func(name, value string) {
+		if value == "" {
+			value = "-"
+		}
+		fmt.Fprintf(&sb, "%-17s %s\n", name+":", value)
+	}
+
+	field("Issuer", s.Issuer)
+	field("Destination", s.Destination)
+	field("Subject", s.Subject)
+	field("Audience", strings.Join(s.Audiences, ", "))
+	field("Signed", fmt.Sprint(s.Signed))
+
+	validity := "-"
+	if !s.NotBefore.IsZero() || !s.NotOnOrAfter.IsZero() {
+		validity = fmt.Sprintf("%s → %s", formatSAMLTime(s.NotBefore), formatSAMLTime(s.NotOnOrAfter))
+		switch {
+		case !s.NotOnOrAfter.IsZero() && !now.Before(s.NotOnOrAfter):
+			validity += " (expired)"
+		case !s.NotBefore.IsZero() && now.Before(s.NotBefore):
+			validity += " (not yet valid)"
+		}
+	}
+	field("Valid", validity)
+
+	duration := "-"
+	if s.SessionDuration > 0 {
+		duration = fmt.Sprintf("%d seconds", s.SessionDuration)
+	}
+	field("Session duration", duration)
+
+	fmt.Fprintf(&sb, "Roles (%d):\n", len(s.Roles))
+	for _, role := range s.Roles {
+		fmt.Fprintf(&sb, "  %s\n    principal: %s\n", role.RoleARN, role.PrincipalARN)
+	}
+	if len(s.Roles) == 0 {
+		fmt.Fprintf(&sb, "  none (is the user assigned to the AWS app, and are role claims configured?)\n")
+	}
+	for _, invalid := range s.InvalidRoles {
+		fmt.Fprintf(&sb, "  invalid role value: %s\n", invalid)
+	}
+
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func formatSAMLTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/user/azure2aws/internal/aws"
+	"github.com/user/azure2aws/internal/config"
+	"github.com/user/azure2aws/internal/ecs"
+	"github.com/user/azure2aws/internal/imds"
+	"github.com/user/azure2aws/internal/output"
+)
+
+type serverOptions struct {
+	imds      bool
+	ecs       bool
+	addr      string
+	port      int
+	cacheSAML bool
+}
+
+func newServerCmd(cc *CommandContext) *cobra.Command {
+	var opts serverOptions
+
+	cmd := &cobra.Command{
+		Use:   "server",
+		Short: "Serve credentials to local tools",
+		Long: `Runs a local credential server for tools that cannot read
+~/.aws/credentials.
+
+With --imds, the EC2 instance metadata credential endpoints (IMDSv1 and
+IMDSv2) are served on --addr, a loopback address or 169.254.169.254. The
+profile's credentials are exposed as the instance profile role named after the
+profile. Only requests from this machine are answered, and on 169.254.169.254
+only with an IMDSv2 session token.
+
+Point SDKs at the server with:
+  export AWS_EC2_METADATA_SERVICE_ENDPOINT=http://127.0.0.1:9911
+
+With --ecs, the ECS container credentials endpoint is served on --port
+(127.0.0.1:9912 by default), protected by a random bearer token. The
+AWS_CONTAINER_CREDENTIALS_FULL_URI and AWS_CONTAINER_AUTHORIZATION_TOKEN
+variables for clients are printed to stdout, in a format suitable for
+'docker run --env-file'. The server only listens on loopback addresses, as
+the AWS SDKs refuse plain HTTP credential endpoints on other hosts (such as
+host.docker.internal), so containers must share the host network with
+'docker run --network host'.
+
+In both modes, credentials are refreshed through the SAML flow when they are
+about to expire. Refreshes use the stored password and the last used role, so
+no prompts are needed unless MFA requires one.
+
+Examples:
+  azure2aws server --imds --profile production
+  azure2aws server --imds --addr 169.254.169.254:80 --profile production
+  azure2aws server --ecs --port 9912 --profile production > ecs.env
+  docker run --network host --env-file ecs.env amazon/aws-cli sts get-caller-identity`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runServer(cmd.Context(), cc, opts)
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.imds, "imds", false, "Serve credentials through EC2 instance metadata endpoints")
+	cmd.Flags().BoolVar(&opts.ecs, "ecs", false, "Serve credentials through an ECS container credentials endpoint")
+	cmd.Flags().StringVar(&opts.addr, "addr", "", "Address to listen on (default 127.0.0.1:9911 for --imds)")
+	cmd.Flags().IntVar(&opts.port, "port", 0, "Port to listen on at 127.0.0.1 (default 9912 for --ecs)")
+	cmd.Flags().BoolVar(&opts.cacheSAML, "cache-saml", false, "Reuse a cached SAML assertion and Azure AD session when refreshing")
+
+	return cmd
+}
+
+func runServer(ctx context.Context, cc *CommandContext, opts serverOptions) error {
+	if opts.imds == opts.ecs {
+		return fmt.Errorf("select exactly one server mode (--imds or --ecs)")
+	}
+	if opts.addr != "" && opts.port != 0 {
+		return fmt.Errorf("--addr and --port cannot be used together")
+	}
+
+	addr := opts.addr
+	switch {
+	case addr != "":
+	case opts.port != 0:
+		addr = net.JoinHostPort("127.0.0.1", strconv.Itoa(opts.port))
+	case opts.ecs:
+		addr = net.JoinHostPort("127.0.0.1", strconv.Itoa(ecs.DefaultPort))
+	default:
+		addr = imds.DefaultAddr
+	}
+	if opts.imds {
+		if err := imds.CheckAddr(addr); err != nil {
+			return err
+		}
+	} else if err := ecs.CheckAddr(addr); err != nil {
+		return err
+	}
+
+	profileName := cc.Profile
+
+	cfg, err := cc.LoadOrCreateConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	profile, err := cfg.GetProfile(profileName)
+	if errors.Is(err, config.ErrProfileNotFound) {
+		return fmt.Errorf("profile '%s' not found\nRun 'azure2aws configure --profile %s' to set up a profile", profileName, profileName)
+	}
+	if err != nil {
+		return err
+	}
+
+	source := &refreshingCredentials{ctx: ctx, cc: cc, profileName: profileName, cacheSAML: opts.cacheSAML}
+
+	// Log in up front, while prompts can still be answered
+	if _, err := source.get(false); err != nil {
+		return err
+	}
+
+	credentials := func() (*aws.Credentials, error) {
+		return source.get(true)
+	}
+
+	if opts.ecs {
+		server, err := ecs.NewServer(credentials)
+		if err != nil {
+			return err
+		}
+		output.Println(formatECSEnv(addr, server.Token()))
+		output.Statusf("Serving ECS credentials for profile '%s' on http://%s (press Ctrl+C to stop)\n", profileName, addr)
+		return server.ListenAndServe(ctx, addr)
+	}
+
+	server := imds.NewServer(profileName, profile.Region, credentials)
+	output.Statusf("Serving credentials for profile '%s' on http://%s (press Ctrl+C to stop)\n", profileName, addr)
+	return server.ListenAndServe(ctx, addr)
+}
+
+// formatECSEnv renders the client environment for an ECS credentials server
+// listening on a loopback address, which containers reach with --network
+// host
+func formatECSEnv(addr, token string) string {
+	uri := "http://" + addr + ecs.CredentialsPath
+	return fmt.Sprintf("AWS_CONTAINER_CREDENTIALS_FULL_URI=%s\nAWS_CONTAINER_AUTHORIZATION_TOKEN=%s", uri, token)
+}
+
+// refreshingCredentials returns a profile's stored credentials, logging in
+// again when they are missing or about to expire
+type refreshingCredentials struct {
+	ctx         context.Context // Ends with the server
+	cc          *CommandContext
+	profileName string
+	cacheSAML   bool
+
+	mu sync.Mutex
+}
+
+func (r *refreshingCredentials) get(unattended bool) (*aws.Credentials, error) {
+	return r.getValidFor(0, unattended)
+}
+
+// getValidFor is get, also logging in again when the credentials expire
+// within margin
+func (r *refreshingCredentials) getValidFor(margin time.Duration, unattended bool) (*aws.Credentials, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if creds, err := loadStoredCredentials(r.cc, r.profileName); err == nil && !aws.IsExpired(creds.Expiration) && time.Until(creds.Expiration) >= margin {
+		return creds, nil
+	}
+
+	output.Statusf("Refreshing credentials for profile '%s'...\n", r.profileName)
+	err := runLogin(r.ctx, r.cc, loginOptions{
+		force:          true,
+		skipPrompt:     unattended,
+		noUsage:        true,
+		cacheSAML:      r.cacheSAML,
+		preferLastRole: true,
+	})
+	if err != nil {
+		output.Statusf("Warning: failed to refresh credentials: %v\n", err)
+		return nil, err
+	}
+
+	return loadStoredCredentials(r.cc, r.profileName)
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Shells with distinct environment variable syntax
+const (
+	shellPOSIX      = "bash"
+	shellZsh        = "zsh"
+	shellFish       = "fish"
+	shellPowerShell = "powershell"
+	shellCmd        = "cmd"
+)
+
+// supportedShells lists the values accepted by --shell
+var supportedShells = []string{shellPOSIX, shellZsh, shellFish, shellPowerShell, shellCmd}
+
+// detectShell guesses the user's interactive shell from the environment
+func detectShell() string {
+	if shell := os.Getenv("SHELL"); shell != "" {
+		switch name := strings.TrimSuffix(filepath.Base(shell), ".exe"); name {
+		case "fish":
+			return shellFish
+		case "zsh":
+			return shellZsh
+		case "pwsh", "powershell":
+			return shellPowerShell
+		default:
+			return shellPOSIX
+		}
+	}
+
+	if runtime.GOOS == "windows" {
+		// PSModulePath is set in PowerShell sessions but not in cmd.exe
+		if os.Getenv("PSModulePath") != "" {
+			return shellPowerShell
+		}
+		return shellCmd
+	}
+
+	return shellPOSIX
+}
+
+// normalizeShell validates a --shell value
+func normalizeShell(shell string) (string, error) {
+	switch strings.ToLower(shell) {
+	case "":
+		return detectShell(), nil
+	case "sh", "bash", "ksh":
+		return shellPOSIX, nil
+	case "pwsh", "powershell":
+		return shellPowerShell, nil
+	case shellZsh, shellFish, shellCmd:
+		return strings.ToLower(shell), nil
+	}
+	return "", fmt.Errorf("unsupported shell %q (supported: %s)", shell, strings.Join(supportedShells, ", "))
+}
+
+// shellSetEnv returns the shell statement that sets an environment variable
+func shellSetEnv(shell, name, value string) string {
+	switch shell {
+	case shellFish:
+		return fmt.Sprintf("set -gx %s %s", name, value)
+	case shellPowerShell:
+		return fmt.Sprintf("$env:%s = \"%s\"", name, value)
+	case shellCmd:
+		return fmt.Sprintf("set %s=%s", name, value)
+	default:
+		return fmt.Sprintf("export %s=%s", name, value)
+	}
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/user/azure2aws/internal/aws"
+	"github.com/user/azure2aws/internal/output"
+)
+
+type statusOptions struct {
+	all   bool
+	group string
+}
+
+func newStatusCmd(cc *CommandContext) *cobra.Command {
+	var opts statusOptions
+
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the state of stored credentials",
+		Long: `Shows whether the stored credentials of profiles are valid, when they
+expire and which role they are for. Credentials expiring within 5 minutes are
+reported as expiring, as 'login' would refresh them.
+
+Examples:
+  azure2aws status --profile production
+  azure2aws status --group prod
+  azure2aws status --all`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runStatus(cc, opts)
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.all, "all", false, "Show all configured profiles")
+	cmd.Flags().StringVar(&opts.group, "group", "", "Show the profiles of this group")
+
+	return cmd
+}
+
+func runStatus(cc *CommandContext, opts statusOptions) error {
+	names, err := selectProfiles(cc, opts.all, nil, opts.group)
+	if err != nil {
+		return err
+	}
+	if names == nil {
+		names = []string{cc.Profile}
+	}
+
+	w := tabwriter.NewWriter(output.Data(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROFILE\tSTATUS\tEXPIRES\tROLE")
+	for _, name := range names {
+		status, expires, role := "missing", "-", "-"
+		if expiration, assumedRoleARN, ok := storedCredentialsInfo(name); ok {
+			status = credentialsStatus(expiration, time.Now())
+			if !expiration.IsZero() {
+				expires = expiration.Local().Format("2006-01-02 15:04:05")
+			}
+			if arn := aws.RoleARNFromAssumedRole(assumedRoleARN); arn != "" {
+				role = arn
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, status, expires, role)
+	}
+	return w.Flush()
+}
+
+// credentialsStatus describes credentials expiring at expiration
+func credentialsStatus(expiration, now time.Time) string {
+	switch {
+	case expiration.IsZero():
+		return "unknown"
+	case !now.Before(expiration):
+		return "expired"
+	case expiration.Sub(now) < 5*time.Minute:
+		return "expiring"
+	default:
+		return "valid"
+	}
+}
@@ -0,0 +1,296 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/user/azure2aws/internal/aws"
+	"github.com/user/azure2aws/internal/output"
+	"golang.org/x/term"
+)
+
+// uiReloadInterval is how often the dashboard rereads the stored credentials;
+// countdowns tick every second in between
+const uiReloadInterval = 10 * time.Second
+
+// Keys read by the dashboard in raw terminal mode
+const (
+	uiKeyCtrlC  = 3
+	uiKeyEnter  = 13
+	uiKeyEscape = 27
+)
+
+func newUICmd(cc *CommandContext) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ui",
+		Short: "Interactive dashboard of all profiles",
+		Long: `Shows every configured profile with the state of its credentials and a
+countdown to their expiry, refreshed every second.
+
+Keys:
+  ↑/↓ (or k/j)  move between profiles
+  l, enter      log in (skipped while the credentials are valid)
+  r             log in again, even if the credentials are valid
+  c             open the AWS console
+  e             copy export commands for the credentials to the clipboard
+  q             quit
+
+The clipboard is set with an OSC 52 terminal sequence, which most terminals
+(iTerm2, kitty, WezTerm, Windows Terminal, tmux with set-clipboard) support.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runUI(cmd.Context(), cc)
+		},
+	}
+
+	return cmd
+}
+
+// uiRow is a profile shown on the dashboard
+type uiRow struct {
+	name       string
+	expiration time.Time
+	role       string
+	missing    bool
+}
+
+// dashboard is the state of 'azure2aws ui'
+type dashboard struct {
+	cc      *CommandContext
+	rows    []uiRow
+	cursor  int
+	message string // Result of the last action
+	drawn   int    // Lines of the last frame
+}
+
+func runUI(ctx context.Context, cc *CommandContext) error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) || !term.IsTerminal(int(os.Stderr.Fd())) {
+		return fmt.Errorf("ui needs a terminal; use 'azure2aws status --all' in scripts")
+	}
+
+	names, err := selectProfiles(cc, true, nil, "")
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("no profiles configured\nRun 'azure2aws configure --profile <name>' to set one up")
+	}
+
+	d := &dashboard{cc: cc}
+	d.reload(names)
+
+	// Keys are read one at a time on request, so that stdin is left to the
+	// prompts of a login while it runs
+	reader := bufio.NewReader(os.Stdin)
+	want := make(chan struct{})
+	keys := make(chan rune)
+	go func() {
+		for range want {
+			keys <- readUIKey(reader)
+		}
+	}()
+	defer close(want)
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("failed to enable raw terminal mode: %w", err)
+	}
+	defer func() { term.Restore(fd, state) }()
+
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	lastReload := time.Now()
+
+	want <- struct{}{}
+	for {
+		d.render()
+
+		select {
+		case <-ctx.Done():
+			d.clear()
+			return ctx.Err()
+
+		case <-tick.C:
+			if time.Since(lastReload) >= uiReloadInterval {
+				d.reload(names)
+				lastReload = time.Now()
+			}
+			continue
+
+		case key := <-keys:
+			switch key {
+			case 'q', uiKeyCtrlC, uiKeyEscape:
+				d.clear()
+				return nil
+			case 'k', 'A':
+				if d.cursor > 0 {
+					d.cursor--
+				}
+			case 'j', 'B':
+				if d.cursor < len(d.rows)-1 {
+					d.cursor++
+				}
+			case 'e':
+				d.copyExports()
+			case 'l', uiKeyEnter, 'r', 'c':
+				// Run the action in cooked mode below the dashboard, then
+				// draw the dashboard again under its output
+				d.clear()
+				term.Restore(fd, state)
+				d.runAction(ctx, key)
+				if state, err = term.MakeRaw(fd); err != nil {
+					return fmt.Errorf("failed to enable raw terminal mode: %w", err)
+				}
+				d.reload(names)
+				lastReload = time.Now()
+			}
+			want <- struct{}{}
+		}
+	}
+}
+
+// readUIKey reads a key, mapping the arrow keys to 'A' (up) and 'B' (down)
+func readUIKey(reader *bufio.Reader) rune {
+	r, _, err := reader.ReadRune()
+	if err != nil {
+		return 'q'
+	}
+	if r != uiKeyEscape || reader.Buffered() == 0 {
+		return r
+	}
+	// Arrow keys arrive as ESC [ A/B (or ESC O A/B in application mode)
+	if next, _, err := reader.ReadRune(); err != nil || (next != '[' && next != 'O') {
+		return 0
+	}
+	code, _, _ := reader.ReadRune()
+	return code
+}
+
+// reload reads the stored credentials of the profiles
+func (d *dashboard) reload(names []string) {
+	d.rows = d.rows[:0]
+	for _, name := range names {
+		row := uiRow{name: name, role: "-", missing: true}
+		if expiration, assumedRoleARN, ok := storedCredentialsInfo(name); ok {
+			row.missing = false
+			row.expiration = expiration
+			if arn := aws.RoleARNFromAssumedRole(assumedRoleARN); arn != "" {
+				row.role = arn
+			}
+		}
+		d.rows = append(d.rows, row)
+	}
+	if d.cursor >= len(d.rows) {
+		d.cursor = len(d.rows) - 1
+	}
+}
+
+// render redraws the dashboard over its previous frame
+func (d *dashboard) render() {
+	width := 80
+	if w, _, err := term.GetSize(int(os.Stderr.Fd())); err == nil && w > 0 {
+		width = w
+	}
+
+	now := time.Now()
+	var table strings.Builder
+	tw := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  PROFILE\tSTATUS\tEXPIRES IN\tROLE")
+	for i, row := range d.rows {
+		marker := "  "
+		if i == d.cursor {
+			marker = "> "
+		}
+		status, remaining := "missing", "-"
+		if !row.missing {
+			status = credentialsStatus(row.expiration, now)
+			if !row.expiration.IsZero() && now.Before(row.expiration) {
+				remaining = row.expiration.Sub(now).Truncate(time.Second).String()
+			}
+		}
+		fmt.Fprintf(tw, "%s%s\t%s\t%s\t%s\n", marker, row.name, status, remaining, row.role)
+	}
+	tw.Flush()
+
+	lines := strings.Split(strings.TrimSuffix(table.String(), "\n"), "\n")
+	lines = append(lines, "")
+	if d.message != "" {
+		lines = append(lines, "  "+d.message)
+	}
+	lines = append(lines, "  ↑/↓ move, l login, r refresh, c console, e copy exports, q quit")
+
+	d.clear()
+	var sb strings.Builder
+	for _, line := range lines {
+		if runes := []rune(line); len(runes) >= width {
+			line = string(runes[:width-1])
+		}
+		sb.WriteString(line)
+		sb.WriteString("\r\n")
+	}
+	output.Statusf("%s", sb.String())
+	d.drawn = len(lines)
+}
+
+// clear erases the last frame (the cursor sits below its last line)
+func (d *dashboard) clear() {
+	if d.drawn > 0 {
+		output.Statusf("\x1b[%dA\r\x1b[J", d.drawn)
+		d.drawn = 0
+	}
+}
+
+// runAction logs into the highlighted profile or opens its console
+func (d *dashboard) runAction(ctx context.Context, key rune) {
+	name := d.rows[d.cursor].name
+	profileCC := *d.cc
+	profileCC.Profile = name
+
+	var err error
+	switch key {
+	case 'c':
+		output.Statusf("==> Console for profile '%s'\n", name)
+		err = runConsole(ctx, &profileCC, consoleOptions{})
+	default:
+		output.Statusf("==> Login for profile '%s'\n", name)
+		err = runLogin(ctx, &profileCC, loginOptions{force: key == 'r', noUsage: true})
+	}
+
+	if err != nil {
+		d.message = fmt.Sprintf("%s: %v", name, strings.SplitN(err.Error(), "\n", 2)[0])
+	} else {
+		d.message = ""
+	}
+	output.Statusln("")
+}
+
+// copyExports puts the shell commands exporting the highlighted profile's
+// credentials on the clipboard
+func (d *dashboard) copyExports() {
+	name := d.rows[d.cursor].name
+	creds, err := loadValidCredentials(d.cc, name)
+	if err != nil {
+		d.message = fmt.Sprintf("%s: %v", name, strings.SplitN(err.Error(), "\n", 2)[0])
+		return
+	}
+
+	shell := detectShell()
+	var sb strings.Builder
+	for _, v := range buildEnvVars(creds, name) {
+		key, value, _ := strings.Cut(v, "=")
+		sb.WriteString(shellSetEnv(shell, key, value))
+		sb.WriteString("\n")
+	}
+
+	// OSC 52 asks the terminal to set the clipboard
+	output.Statusf("\x1b]52;c;%s\x07", base64.StdEncoding.EncodeToString([]byte(sb.String())))
+	d.message = fmt.Sprintf("Copied %s export commands for '%s' to the clipboard", shell, name)
+}
@@ -2,59 +2,185 @@ package cmd
 
 import (
 	"archive/tar"
+	"archive/zip"
 	"compress/gzip"
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/sha256"
+	"crypto/x509"
+	_ "embed"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"net/http"
 	"os"
+	"os/exec"
+	"os/user"
 	"path/filepath"
+	"reflect"
 	"runtime"
+	"strconv"
 	"strings"
-	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/user/azure2aws/internal/config"
+	"github.com/user/azure2aws/internal/logging"
+	"github.com/user/azure2aws/internal/output"
+	"github.com/user/azure2aws/internal/prompter"
+	"github.com/user/azure2aws/internal/provider"
 )
 
+// releasePublicKey verifies the signature over the release checksums: the
+// PEM-encoded public key of the cosign key releases are signed with. The
+// release workflow verifies each release's signature against the same file.
+//
+//go:embed cosign.pub
+var releasePublicKey []byte
+
+const (
+	githubAPIURL         = "https://api.github.com/repos/rayselfs/azure2aws/releases/latest"
+	githubReleasesAPIURL = "https://api.github.com/repos/rayselfs/azure2aws/releases?per_page=30"
+	updateRepoName       = "rayselfs/azure2aws"
+
+	// githubTokenEnv authenticates GitHub API requests, raising the rate limit
+	githubTokenEnv = "GITHUB_TOKEN"
+
+	// updateCheckTimeout bounds the background check for a new version
+	updateCheckTimeout = 3 * time.Second
+	// updateTimeout bounds each request of 'update', including downloads
+	updateTimeout = 5 * time.Minute
+)
+
+// Update channels
 const (
-	githubAPIURL   = "https://api.github.com/repos/rayselfs/azure2aws/releases/latest"
-	updateRepoName = "rayselfs/azure2aws"
+	// updateChannelStable follows the latest release
+	updateChannelStable = "stable"
+	// updateChannelBeta follows the newest release, including pre-releases
+	updateChannelBeta = "beta"
 )
 
 type GitHubRelease struct {
-	TagName string        `json:"tag_name"`
-	Assets  []GitHubAsset `json:"assets"`
+	TagName    string        `json:"tag_name"`
+	Draft      bool          `json:"draft"`
+	Prerelease bool          `json:"prerelease"`
+	Assets     []GitHubAsset `json:"assets"`
 }
 
 type GitHubAsset struct {
 	Name               string `json:"name"`
 	BrowserDownloadURL string `json:"browser_download_url"`
+	Size               int64  `json:"size"`
+}
+
+// Install methods detected from the executable location
+const (
+	installMethodHomebrew  = "homebrew"
+	installMethodGoInstall = "go install"
+	installMethodScoop     = "scoop"
+	installMethodApt       = "apt"
+	installMethodManual    = "manual"
+)
+
+// packageManager names the package manager of a packaged build, set by
+// packagers with
+// -ldflags "-X github.com/user/azure2aws/internal/cmd.packageManager=<name>".
+// It takes precedence over detecting the install method from the path.
+var packageManager = ""
+
+// dpkgListPath lists the files installed by the azure2aws Debian package
+const dpkgListPath = "/var/lib/dpkg/info/azure2aws.list"
+
+type updateOptions struct {
+	force         bool
+	dryRun        bool
+	check         bool
+	verbose       bool
+	channel       string
+	skipSignature bool
+	caBundle      string
+
+	forceSelfUpdate bool
+
+	// installBinary is set when re-executed via sudo to perform only the
+	// privileged replacement step
+	installBinary string
+}
+
+// updatePlan describes what an update would do
+type updatePlan struct {
+	currentVersion string
+	latestVersion  string
+	asset          *GitHubAsset
+	checksumAsset  *GitHubAsset
+	signatureAsset *GitHubAsset
+	skipSignature  bool
+	installPath    string
+	installMethod  string
+	needsElevation bool
 }
 
-func newUpdateCmd(currentVersion string) *cobra.Command {
-	var force bool
+func newUpdateCmd(cc *CommandContext) *cobra.Command {
+	var opts updateOptions
 
 	cmd := &cobra.Command{
 		Use:   "update",
 		Short: "Update azure2aws to the latest version",
 		Long: `Checks for updates and downloads the latest version from GitHub.
 
-The binary is verified using SHA256 checksum before installation.`,
+The binary is verified using SHA256 checksum before installation. The
+checksums file itself must carry a valid signature by the release key built
+into azure2aws, so a tampered release is rejected. --skip-signature disables
+this check; only use it for releases published before signing was introduced.
+
+The replaced binary is kept in ~/.azure2aws/backups; 'azure2aws update
+rollback' restores it.
+
+Use --check in scripts and CI to fail when a newer version is available,
+without installing it.
+
+Use --dry-run to preview the update: the asset that would be downloaded, its
+size, the install path, whether elevated permissions are needed, and how
+azure2aws appears to have been installed.
+
+With --channel beta (or update_channel: beta in the config defaults),
+pre-releases such as release candidates are offered as well.
+
+Requests go through HTTPS_PROXY and trust ca_bundle from the config defaults
+(or AZURE2AWS_CA_BUNDLE). Set GITHUB_TOKEN to authenticate GitHub API
+requests when the anonymous rate limit is exhausted, as on shared CI hosts.`,
+		Args: cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runUpdate(currentVersion, force)
+			opts.verbose = cc.Verbose
+			channel, caBundle := updateSettings(cc)
+			if opts.channel == "" {
+				opts.channel = channel
+			}
+			opts.caBundle = caBundle
+			return runUpdate(cc.Prompts(""), cc.Version, opts)
 		},
 	}
 
-	cmd.Flags().BoolVarP(&force, "force", "f", false, "Force update even if current version is latest")
+	cmd.Flags().BoolVarP(&opts.force, "force", "f", false, "Force update even if current version is latest")
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "Print the update plan without downloading or installing anything")
+	cmd.Flags().BoolVar(&opts.check, "check", false, "Only check for a new version; exit non-zero when outdated")
+	cmd.Flags().StringVar(&opts.channel, "channel", "", "Release channel: stable or beta (default: update_channel or stable)")
+	cmd.Flags().BoolVar(&opts.skipSignature, "skip-signature", false, "Do not require a valid signature over the release checksums")
+	cmd.Flags().StringVar(&opts.installBinary, "install-binary", "", "Install the given binary over this executable (used for elevation)")
+	cmd.Flags().BoolVar(&opts.forceSelfUpdate, "force-self-update", false, "Replace the binary even if a package manager installed it")
+	_ = cmd.Flags().MarkHidden("install-binary")
+
+	cmd.AddCommand(newUpdateRollbackCmd(cc))
 
 	return cmd
 }
 
-func runUpdate(currentVersion string, force bool) error {
+func runUpdate(prompts *prompter.Scope, currentVersion string, opts updateOptions) error {
 	execPath, err := os.Executable()
 	if err != nil {
 		return fmt.Errorf("failed to get current executable path: %w", err)
@@ -65,112 +191,446 @@ func runUpdate(currentVersion string, force bool) error {
 		return fmt.Errorf("failed to resolve executable path: %w", err)
 	}
 
-	lockFile := execPath + ".lock"
-	unlock, err := acquireLock(lockFile)
+	// Elevated re-exec: the parent process holds the lock and did all checks
+	if opts.installBinary != "" {
+		if err := replaceBinary(execPath, opts.installBinary); err != nil {
+			return fmt.Errorf("failed to install update: %w", err)
+		}
+		return nil
+	}
+
+	// Overwriting a managed binary breaks the package manager's own upgrades
+	installMethod := detectInstallMethod(execPath)
+	if hint := managedUpgradeHint(installMethod); hint != "" && !opts.dryRun && !opts.check && !opts.forceSelfUpdate {
+		return fmt.Errorf("azure2aws was installed with %s; run '%s' to update it\nUse --force-self-update to replace the binary anyway", installMethod, hint)
+	}
+
+	if !opts.dryRun && !opts.check {
+		unlock, err := acquireLock(updateLockPath(execPath))
+		if err != nil {
+			return fmt.Errorf("another update is already in progress: %w", err)
+		}
+		defer unlock()
+	}
+
+	client, err := newUpdateClient(opts.caBundle, updateTimeout)
 	if err != nil {
-		return fmt.Errorf("another update is already in progress: %w", err)
+		return err
 	}
-	defer unlock()
 
-	fmt.Println("Checking for updates...")
-	release, err := getLatestRelease()
+	output.Statusln("Checking for updates...")
+	release, err := getChannelRelease(client, opts.channel)
 	if err != nil {
 		return fmt.Errorf("failed to check for updates: %w", err)
 	}
 
-	if !force && release.TagName == currentVersion {
-		fmt.Printf("Already running the latest version: %s\n", currentVersion)
+	if opts.check {
+		if release.TagName == currentVersion {
+			output.Statusf("azure2aws %s is up to date\n", currentVersion)
+			return nil
+		}
+		return fmt.Errorf("azure2aws %s is outdated: %s is available\nRun 'azure2aws update' to upgrade", currentVersion, release.TagName)
+	}
+
+	if !opts.force && release.TagName == currentVersion {
+		output.Statusf("Already running the latest version: %s\n", currentVersion)
 		return nil
 	}
 
-	fmt.Printf("Current version: %s\n", currentVersion)
-	fmt.Printf("Latest version:  %s\n", release.TagName)
+	output.Statusf("Current version: %s\n", currentVersion)
+	if release.Prerelease {
+		output.Statusf("Latest version:  %s (pre-release)\n", release.TagName)
+	} else {
+		output.Statusf("Latest version:  %s\n", release.TagName)
+	}
 
-	asset, checksumAsset := findAssets(release, runtime.GOOS, runtime.GOARCH)
+	asset, checksumAsset, signatureAsset := findAssets(release, runtime.GOOS, runtime.GOARCH)
 	if asset == nil {
 		return fmt.Errorf("no release found for %s/%s", runtime.GOOS, runtime.GOARCH)
 	}
 
-	if !force {
-		fmt.Printf("\nDo you want to update to %s? [y/N]: ", release.TagName)
-		var response string
-		fmt.Scanln(&response)
-		if response != "y" && response != "Y" {
-			fmt.Println("Update cancelled.")
+	if opts.dryRun || opts.verbose {
+		plan := &updatePlan{
+			currentVersion: currentVersion,
+			latestVersion:  release.TagName,
+			asset:          asset,
+			checksumAsset:  checksumAsset,
+			signatureAsset: signatureAsset,
+			skipSignature:  opts.skipSignature,
+			installPath:    execPath,
+			installMethod:  installMethod,
+			needsElevation: !isWritableDir(filepath.Dir(execPath)),
+		}
+		output.Statusf("%s", plan)
+	}
+
+	if opts.dryRun {
+		output.Statusln("Dry run: nothing was downloaded or installed.")
+		return nil
+	}
+
+	if !opts.skipSignature {
+		if checksumAsset == nil || signatureAsset == nil {
+			return fmt.Errorf("release %s is not signed\nRe-run with --skip-signature to install it without signature verification", release.TagName)
+		}
+	}
+
+	if !opts.force {
+		output.Statusln()
+		ok, err := prompts.Confirm(fmt.Sprintf("Do you want to update to %s?", release.TagName), false)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			output.Statusln("Update cancelled.")
 			return nil
 		}
 	}
 
-	fmt.Printf("Downloading %s...\n", asset.Name)
-	tmpFile, err := downloadFile(asset.BrowserDownloadURL)
+	output.Statusf("Downloading %s...\n", asset.Name)
+	tmpFile, err := downloadFile(client, asset.BrowserDownloadURL)
 	if err != nil {
 		return fmt.Errorf("failed to download update: %w", err)
 	}
 	defer os.Remove(tmpFile)
 
 	if checksumAsset != nil {
-		fmt.Println("Verifying checksum...")
-		if err := verifyChecksum(tmpFile, asset.Name, checksumAsset.BrowserDownloadURL); err != nil {
+		checksums, err := fetchAsset(client, checksumAsset.BrowserDownloadURL)
+		if err != nil {
+			return fmt.Errorf("failed to download checksums: %w", err)
+		}
+
+		if opts.skipSignature {
+			output.Statusln("Warning: skipping signature verification (--skip-signature)")
+		} else {
+			output.Statusln("Verifying signature...")
+			signature, err := fetchAsset(client, signatureAsset.BrowserDownloadURL)
+			if err != nil {
+				return fmt.Errorf("failed to download signature: %w", err)
+			}
+			if err := verifySignature(checksums, signature, releasePublicKey); err != nil {
+				return fmt.Errorf("signature verification failed: %w", err)
+			}
+		}
+
+		output.Statusln("Verifying checksum...")
+		if err := verifyChecksum(tmpFile, asset.Name, checksums); err != nil {
 			return fmt.Errorf("checksum verification failed: %w", err)
 		}
 	}
 
-	fmt.Println("Extracting binary...")
-	binaryPath, err := extractBinary(tmpFile)
+	output.Statusln("Extracting binary...")
+	binaryPath, err := extractBinary(tmpFile, asset.Name)
 	if err != nil {
 		return fmt.Errorf("failed to extract binary: %w", err)
 	}
-	defer os.Remove(binaryPath)
+	keepBinary := false
+	defer func() {
+		if !keepBinary {
+			os.Remove(binaryPath)
+		}
+	}()
 
-	fmt.Println("Installing update...")
+	if err := saveBackup(execPath, currentVersion); err != nil {
+		return fmt.Errorf("failed to back up the current binary: %w", err)
+	}
+
+	output.Statusln("Installing update...")
 	if err := replaceBinary(execPath, binaryPath); err != nil {
-		return fmt.Errorf("failed to install update: %w", err)
+		if !errors.Is(err, fs.ErrPermission) {
+			return fmt.Errorf("failed to install update: %w", err)
+		}
+
+		installed, err := installElevated(prompts, execPath, binaryPath)
+		if err != nil {
+			return fmt.Errorf("failed to install update: %w", err)
+		}
+		if !installed {
+			// The printed command still needs the downloaded binary
+			keepBinary = true
+			return nil
+		}
 	}
 
-	fmt.Printf("Successfully updated to %s\n", release.TagName)
+	output.Statusf("Successfully updated to %s\n", release.TagName)
+	output.Statusf("Run 'azure2aws update rollback' to return to %s.\n", currentVersion)
 	return nil
 }
 
-func getLatestRelease() (*GitHubRelease, error) {
-	client := &http.Client{
-		Timeout: 3 * time.Second,
+// String renders the plan for display
+func (p *updatePlan) String() string {
+	var sb strings.Builder
+
+	sb.WriteString("\nUpdate plan:\n")
+	fmt.Fprintf(&sb, "  Version:        %s → %s\n", p.currentVersion, p.latestVersion)
+	fmt.Fprintf(&sb, "  Asset:          %s (%s)\n", p.asset.Name, formatSize(p.asset.Size))
+	fmt.Fprintf(&sb, "  Download URL:   %s\n", p.asset.BrowserDownloadURL)
+	if p.checksumAsset != nil {
+		fmt.Fprintf(&sb, "  Checksum:       %s\n", p.checksumAsset.Name)
+	} else {
+		sb.WriteString("  Checksum:       not published, download will not be verified\n")
+	}
+	switch {
+	case p.skipSignature:
+		sb.WriteString("  Signature:      skipped (--skip-signature)\n")
+	case p.signatureAsset != nil:
+		fmt.Fprintf(&sb, "  Signature:      %s\n", p.signatureAsset.Name)
+	default:
+		sb.WriteString("  Signature:      not published, update requires --skip-signature\n")
+	}
+	fmt.Fprintf(&sb, "  Install path:   %s\n", p.installPath)
+	fmt.Fprintf(&sb, "  Install method: %s\n", p.installMethod)
+	if dir, err := backupDir(); err == nil {
+		fmt.Fprintf(&sb, "  Backup:         %s (restore with 'azure2aws update rollback')\n", dir)
+	}
+	if p.needsElevation {
+		fmt.Fprintf(&sb, "  Elevation:      required (no write access to %s)\n", filepath.Dir(p.installPath))
+	} else {
+		sb.WriteString("  Elevation:      not required\n")
+	}
+
+	if hint := managedUpgradeHint(p.installMethod); hint != "" {
+		fmt.Fprintf(&sb, "\nThis binary is managed by %s; use '%s' instead (or --force-self-update).\n", p.installMethod, hint)
+	}
+	sb.WriteString("\n")
+
+	return sb.String()
+}
+
+// detectInstallMethod guesses how the binary at execPath was installed
+func detectInstallMethod(execPath string) string {
+	if packageManager != "" {
+		return packageManager
+	}
+
+	path := filepath.ToSlash(execPath)
+
+	switch {
+	case strings.Contains(path, "/Cellar/") || strings.Contains(path, "/homebrew/") || strings.Contains(path, "/.linuxbrew/"):
+		return installMethodHomebrew
+	case strings.Contains(strings.ToLower(path), "/scoop/"):
+		return installMethodScoop
 	}
-	resp, err := client.Get(githubAPIURL)
+
+	for _, dir := range goBinDirs() {
+		if filepath.Dir(execPath) == dir {
+			return installMethodGoInstall
+		}
+	}
+
+	if ownedByDpkg(execPath) {
+		return installMethodApt
+	}
+
+	return installMethodManual
+}
+
+// ownedByDpkg reports whether the azure2aws Debian package installed the
+// binary at execPath
+func ownedByDpkg(execPath string) bool {
+	data, err := os.ReadFile(dpkgListPath)
 	if err != nil {
-		return nil, err
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == execPath {
+			return true
+		}
+	}
+	return false
+}
+
+// managedUpgradeHint returns the command updating a binary installed by a
+// package manager, or "" if the binary is not managed
+func managedUpgradeHint(installMethod string) string {
+	switch installMethod {
+	case installMethodHomebrew:
+		return "brew upgrade azure2aws"
+	case installMethodScoop:
+		return "scoop update azure2aws"
+	case installMethodApt:
+		return "sudo apt-get install --only-upgrade azure2aws"
+	case installMethodGoInstall, installMethodManual:
+		return ""
+	default:
+		// Set by a packager at build time
+		return fmt.Sprintf("%s upgrade azure2aws", installMethod)
+	}
+}
+
+// goBinDirs returns the directories 'go install' places binaries in
+func goBinDirs() []string {
+	var dirs []string
+
+	if gobin := os.Getenv("GOBIN"); gobin != "" {
+		dirs = append(dirs, filepath.Clean(gobin))
+	}
+
+	gopath := os.Getenv("GOPATH")
+	if gopath == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			gopath = filepath.Join(home, "go")
+		}
+	}
+	for _, p := range filepath.SplitList(gopath) {
+		dirs = append(dirs, filepath.Join(p, "bin"))
+	}
+
+	return dirs
+}
+
+// isWritableDir reports whether the current user can create files in dir
+func isWritableDir(dir string) bool {
+	f, err := os.CreateTemp(dir, ".azure2aws-write-test-*")
+	if err != nil {
+		return false
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return true
+}
+
+// formatSize renders a byte count in human-readable units
+func formatSize(size int64) string {
+	const unit = 1024
+	if size <= 0 {
+		return "unknown size"
+	}
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// newUpdateClient creates the HTTP client of the updater. It honors the proxy
+// environment variables and trusts caBundle in addition to the system roots.
+func newUpdateClient(caBundle string, timeout time.Duration) (*provider.HTTPClient, error) {
+	opts := provider.DefaultHTTPClientOptions()
+	opts.CABundle = caBundle
+	opts.Timeout = timeout
+	// A rate limit is reported rather than waited out
+	opts.ThrottleRetries = 0
+	return provider.NewHTTPClient(opts)
+}
+
+// getGitHubAPI sends a GitHub API request, authenticated with GITHUB_TOKEN
+// when set, and decodes the JSON response into v
+func getGitHubAPI(client *provider.HTTPClient, url string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if token := os.Getenv(githubTokenEnv); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
 	}
 	defer resp.Body.Close()
 
+	if err := gitHubRateLimitError(resp); err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("GitHub API rejected the token in %s", githubTokenEnv)
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("GitHub API returned status %d", resp.StatusCode)
+		return fmt.Errorf("GitHub API returned status %d", resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// gitHubRateLimitError describes a response rejected by the GitHub API rate
+// limit, or returns nil for any other response
+func gitHubRateLimitError(resp *http.Response) error {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" && resp.Header.Get("Retry-After") == "" {
+		return nil
+	}
+
+	msg := "GitHub API rate limit exceeded"
+	if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		msg += fmt.Sprintf(" (resets at %s)", time.Unix(reset, 0).Local().Format("15:04:05"))
+	} else if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+		msg += fmt.Sprintf(" (retry after %ss)", retryAfter)
+	}
+	if os.Getenv(githubTokenEnv) == "" {
+		msg += fmt.Sprintf("\nSet %s to a GitHub token to raise the limit", githubTokenEnv)
 	}
+	return errors.New(msg)
+}
 
+func getLatestRelease(client *provider.HTTPClient) (*GitHubRelease, error) {
 	var release GitHubRelease
-	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+	if err := getGitHubAPI(client, githubAPIURL, &release); err != nil {
 		return nil, err
 	}
-
 	return &release, nil
 }
 
-func CheckForUpdateAsync(currentVersion string) {
-	go func() {
-		release, err := getLatestRelease()
-		if err != nil {
-			return
+// getChannelRelease returns the release an update channel points to. On the
+// beta channel, that is the newest published release, pre-release or not.
+func getChannelRelease(client *provider.HTTPClient, channel string) (*GitHubRelease, error) {
+	switch channel {
+	case "", updateChannelStable:
+		return getLatestRelease(client)
+	case updateChannelBeta:
+	default:
+		return nil, fmt.Errorf("unknown update channel %q (expected %s or %s)", channel, updateChannelStable, updateChannelBeta)
+	}
+
+	var releases []GitHubRelease
+	if err := getGitHubAPI(client, githubReleasesAPIURL, &releases); err != nil {
+		return nil, err
+	}
+
+	// Releases are listed newest first
+	for i := range releases {
+		if !releases[i].Draft {
+			return &releases[i], nil
 		}
+	}
+	return nil, fmt.Errorf("no releases found")
+}
 
-		if release.TagName != currentVersion && release.TagName != "" {
-			fmt.Fprintf(os.Stderr, "\n\033[33m💡 A new version of azure2aws is available: %s → %s\033[0m\n", currentVersion, release.TagName)
-			fmt.Fprintf(os.Stderr, "\033[33m   Run 'azure2aws update' to upgrade.\033[0m\n\n")
+// updateSettings returns the update channel (stable if not set) and the CA
+// bundle for the updater, from the config defaults and the environment. An
+// unreadable config is ignored.
+func updateSettings(cc *CommandContext) (channel, caBundle string) {
+	channel = updateChannelStable
+	if cfg, err := cc.LoadConfig(); err == nil {
+		if cfg.Defaults.UpdateChannel != "" {
+			channel = cfg.Defaults.UpdateChannel
 		}
-	}()
+		caBundle = cfg.Defaults.CABundle
+	}
+	if v := os.Getenv(config.EnvCABundle); v != "" {
+		caBundle = v
+	}
+	return channel, caBundle
 }
 
-func findAssets(release *GitHubRelease, goos, goarch string) (*GitHubAsset, *GitHubAsset) {
-	var asset, checksumAsset *GitHubAsset
+func findAssets(release *GitHubRelease, goos, goarch string) (*GitHubAsset, *GitHubAsset, *GitHubAsset) {
+	var asset, checksumAsset, signatureAsset *GitHubAsset
 
-	archiveName := fmt.Sprintf("azure2aws_%s_%s_%s.tar.gz", strings.TrimPrefix(release.TagName, "v"), goos, goarch)
+	// Windows releases are zip archives
+	ext := ".tar.gz"
+	if goos == "windows" {
+		ext = ".zip"
+	}
+	archiveName := fmt.Sprintf("azure2aws_%s_%s_%s%s", strings.TrimPrefix(release.TagName, "v"), goos, goarch, ext)
 	checksumName := "azure2aws_checksums.txt"
 
 	for i := range release.Assets {
@@ -180,13 +640,16 @@ func findAssets(release *GitHubRelease, goos, goarch string) (*GitHubAsset, *Git
 		if release.Assets[i].Name == checksumName {
 			checksumAsset = &release.Assets[i]
 		}
+		if release.Assets[i].Name == checksumName+".sig" {
+			signatureAsset = &release.Assets[i]
+		}
 	}
 
-	return asset, checksumAsset
+	return asset, checksumAsset, signatureAsset
 }
 
-func downloadFile(url string) (string, error) {
-	resp, err := http.Get(url)
+func downloadFile(client *provider.HTTPClient, url string) (string, error) {
+	resp, err := client.Get(url)
 	if err != nil {
 		return "", err
 	}
@@ -210,18 +673,55 @@ func downloadFile(url string) (string, error) {
 	return tmpFile.Name(), nil
 }
 
-func verifyChecksum(archivePath, archiveName, checksumURL string) error {
-	resp, err := http.Get(checksumURL)
+// fetchAsset downloads a small release asset into memory
+func fetchAsset(client *provider.HTTPClient, url string) ([]byte, error) {
+	resp, err := client.Get(url)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	checksumData, err := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download failed with status %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// verifySignature checks a base64-encoded signature over data, as written by
+// 'cosign sign-blob', against a PEM-encoded public key such as cosign.pub
+func verifySignature(data, signature, publicKey []byte) error {
+	block, _ := pem.Decode(publicKey)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return fmt.Errorf("invalid release signing key: no PEM public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("invalid release signing key: %w", err)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
 	if err != nil {
-		return err
+		return fmt.Errorf("invalid signature encoding: %w", err)
 	}
 
+	var valid bool
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(data)
+		valid = ecdsa.VerifyASN1(key, digest[:], sig)
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(key, data, sig)
+	default:
+		return fmt.Errorf("unsupported release signing key type %T", key)
+	}
+	if !valid {
+		return fmt.Errorf("checksums are not signed by the release key")
+	}
+	return nil
+}
+
+func verifyChecksum(archivePath, archiveName string, checksumData []byte) error {
 	var expectedChecksum string
 	for _, line := range strings.Split(string(checksumData), "\n") {
 		parts := strings.Fields(line)
@@ -254,7 +754,13 @@ func verifyChecksum(archivePath, archiveName, checksumURL string) error {
 	return nil
 }
 
-func extractBinary(archivePath string) (string, error) {
+// extractBinary extracts the azure2aws binary from a tar.gz or zip archive
+// into a temporary file
+func extractBinary(archivePath, archiveName string) (string, error) {
+	if strings.HasSuffix(archiveName, ".zip") {
+		return extractZipBinary(archivePath)
+	}
+
 	f, err := os.Open(archivePath)
 	if err != nil {
 		return "", err
@@ -278,30 +784,150 @@ func extractBinary(archivePath string) (string, error) {
 			return "", err
 		}
 
-		if header.Name == "azure2aws" || header.Name == "azure2aws.exe" {
-			tmpFile, err := os.CreateTemp("", "azure2aws-new-*")
-			if err != nil {
-				return "", err
-			}
-			defer tmpFile.Close()
+		if isBinaryName(header.Name) {
+			return writeBinary(tr)
+		}
+	}
 
-			if _, err := io.Copy(tmpFile, tr); err != nil {
-				os.Remove(tmpFile.Name())
-				return "", err
-			}
+	return "", fmt.Errorf("azure2aws binary not found in archive")
+}
 
-			if err := os.Chmod(tmpFile.Name(), 0755); err != nil {
-				os.Remove(tmpFile.Name())
-				return "", err
-			}
+func extractZipBinary(archivePath string) (string, error) {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return "", err
+	}
+	defer zr.Close()
 
-			return tmpFile.Name(), nil
+	for _, f := range zr.File {
+		if !isBinaryName(f.Name) {
+			continue
 		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return "", err
+		}
+		defer rc.Close()
+		return writeBinary(rc)
 	}
 
 	return "", fmt.Errorf("azure2aws binary not found in archive")
 }
 
+// isBinaryName reports whether an archive entry is the azure2aws binary
+func isBinaryName(name string) bool {
+	return name == "azure2aws" || name == "azure2aws.exe"
+}
+
+// writeBinary writes an extracted binary to an executable temporary file
+func writeBinary(r io.Reader) (string, error) {
+	tmpFile, err := os.CreateTemp("", "azure2aws-new-*")
+	if err != nil {
+		return "", err
+	}
+	defer tmpFile.Close()
+
+	if _, err := io.Copy(tmpFile, r); err != nil {
+		os.Remove(tmpFile.Name())
+		return "", err
+	}
+
+	if err := os.Chmod(tmpFile.Name(), 0755); err != nil {
+		os.Remove(tmpFile.Name())
+		return "", err
+	}
+
+	return tmpFile.Name(), nil
+}
+
+// installElevated handles a permission error from replaceBinary, typically a
+// binary installed in a root-owned directory. With consent, the replacement
+// step is re-executed via sudo; otherwise the exact command to run is printed.
+// It reports whether the update was installed.
+func installElevated(prompts *prompter.Scope, execPath, binaryPath string) (bool, error) {
+	dir := filepath.Dir(execPath)
+	output.Statusf("\n%s is not writable by the current user", dir)
+	if owner := fileOwner(execPath); owner != "" {
+		output.Statusf(" (owned by %s)", owner)
+	}
+	output.Statusln(".")
+
+	if runtime.GOOS == "windows" {
+		output.Statusln("Re-run 'azure2aws update' from an elevated (Run as administrator) terminal.")
+		return false, nil
+	}
+
+	sudoArgs := []string{execPath, "update", "--install-binary", binaryPath}
+	manualCmd := fmt.Sprintf("sudo %q update --install-binary %q", execPath, binaryPath)
+
+	if _, err := exec.LookPath("sudo"); err == nil {
+		ok, err := prompts.Confirm("Install the update with sudo?", false)
+		if err != nil {
+			return false, err
+		}
+
+		if ok {
+			cmd := exec.Command("sudo", sudoArgs...)
+			cmd.Stdin = os.Stdin
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			if err := cmd.Run(); err != nil {
+				return false, fmt.Errorf("sudo install failed: %w", err)
+			}
+			return true, nil
+		}
+	}
+
+	output.Statusln("To finish the update, run:")
+	output.Statusf("  %s\n", manualCmd)
+	return false, nil
+}
+
+// fileOwner returns the owning user of path when it differs from the current
+// user, or "" if unknown. Ownership is only available on Unix-like systems.
+func fileOwner(path string) string {
+	info, err := os.Stat(path)
+	if err != nil || info.Sys() == nil {
+		return ""
+	}
+
+	// syscall.Stat_t is platform specific, so read its Uid field generically
+	sys := reflect.Indirect(reflect.ValueOf(info.Sys()))
+	if sys.Kind() != reflect.Struct {
+		return ""
+	}
+	uidField := sys.FieldByName("Uid")
+	if !uidField.IsValid() || !uidField.CanUint() {
+		return ""
+	}
+
+	uid := strconv.FormatUint(uidField.Uint(), 10)
+	if uid == strconv.Itoa(os.Getuid()) {
+		return ""
+	}
+
+	if u, err := user.LookupId(uid); err == nil {
+		return u.Username
+	}
+	return "uid " + uid
+}
+
+// updateLockPath returns the update lock file for execPath, falling back to
+// the temp directory when the install directory is not writable
+func updateLockPath(execPath string) string {
+	if isWritableDir(filepath.Dir(execPath)) {
+		return execPath + ".lock"
+	}
+
+	sum := sha256.Sum256([]byte(execPath))
+	return filepath.Join(os.TempDir(), fmt.Sprintf("azure2aws-update-%x.lock", sum[:8]))
+}
+
+// replaceBinary installs the binary at newPath over oldPath. The old binary is
+// renamed out of the way first. Windows cannot delete a running executable,
+// so there it is left behind as <name>.old and removed by the next run (see
+// removeStaleBinary).
 func replaceBinary(oldPath, newPath string) error {
 	oldInfo, err := os.Stat(oldPath)
 	if err != nil {
@@ -315,19 +941,56 @@ func replaceBinary(oldPath, newPath string) error {
 	defer os.Remove(tmpPath)
 
 	backupPath := oldPath + ".backup"
+	if runtime.GOOS == "windows" {
+		backupPath = staleBinaryPath(oldPath)
+		// Left by an earlier update; it is only in use while that binary
+		// still runs
+		if err := os.Remove(backupPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("a previous version is still running from %s; close it and retry: %w", backupPath, err)
+		}
+	}
 	if err := os.Rename(oldPath, backupPath); err != nil {
 		return fmt.Errorf("failed to backup old binary: %w", err)
 	}
 
 	if err := os.Rename(tmpPath, oldPath); err != nil {
-		os.Rename(backupPath, oldPath)
+		if restoreErr := os.Rename(backupPath, oldPath); restoreErr != nil {
+			return fmt.Errorf("failed to install new binary: %w (the previous binary is at %s)", err, backupPath)
+		}
 		return fmt.Errorf("failed to install new binary: %w", err)
 	}
 
-	os.Remove(backupPath)
+	if err := os.Remove(backupPath); err != nil && runtime.GOOS == "windows" {
+		logging.Debug("Old binary still in use, removing it on the next run", "path", backupPath)
+	}
 	return nil
 }
 
+// staleBinaryPath returns where a replaced, still running binary is left on
+// Windows
+func staleBinaryPath(execPath string) string {
+	return execPath + ".old"
+}
+
+// removeStaleBinary deletes the binary left behind by a Windows update once
+// it no longer runs
+func removeStaleBinary() {
+	if runtime.GOOS != "windows" {
+		return
+	}
+
+	execPath, err := os.Executable()
+	if err != nil {
+		return
+	}
+	if execPath, err = resolveSymlink(execPath); err != nil {
+		return
+	}
+	if err := os.Remove(staleBinaryPath(execPath)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		logging.Debug("Failed to remove old binary", "error", err)
+	}
+}
+
 func copyFileAtomic(src, dst string, mode fs.FileMode) error {
 	in, err := os.Open(src)
 	if err != nil {
@@ -410,13 +1073,13 @@ func acquireLockUnix(lockFile string) (func(), error) {
 		return nil, err
 	}
 
-	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+	if err := flock(f); err != nil {
 		f.Close()
 		return nil, fmt.Errorf("failed to acquire lock: %w", err)
 	}
 
 	unlock := func() {
-		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		funlock(f)
 		f.Close()
 		os.Remove(lockFile)
 	}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+)
+
+func TestVerifySignature(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	data := []byte("abc  azure2aws_linux_amd64.tar.gz\n")
+	digest := sha256.Sum256(data)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := []byte(base64.StdEncoding.EncodeToString(sig) + "\n")
+
+	tests := []struct {
+		name      string
+		data      []byte
+		signature []byte
+		publicKey []byte
+		wantErr   bool
+	}{
+		{name: "valid", data: data, signature: signature, publicKey: publicKey},
+		{name: "tampered data", data: []byte("def  azure2aws_linux_amd64.tar.gz\n"), signature: signature, publicKey: publicKey, wantErr: true},
+		{name: "not base64", data: data, signature: []byte("not a signature!"), publicKey: publicKey, wantErr: true},
+		{name: "other key", data: data, signature: signature, publicKey: releasePublicKey, wantErr: true},
+		{name: "no PEM key", data: data, signature: signature, publicKey: []byte(base64.StdEncoding.EncodeToString(der)), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifySignature(tt.data, tt.signature, tt.publicKey)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verifySignature() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
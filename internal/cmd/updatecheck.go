@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/azure2aws/internal/config"
+	"github.com/user/azure2aws/internal/logging"
+	"github.com/user/azure2aws/internal/output"
+)
+
+const (
+	// updateCheckInterval is how often the passive check queries GitHub
+	updateCheckInterval = 24 * time.Hour
+
+	// updateCheckWait bounds how long a finished command waits for a
+	// pending check before exiting without a notice
+	updateCheckWait = 500 * time.Millisecond
+)
+
+// updateCheckState is the result of the last passive check, kept in
+// ~/.azure2aws/update-check.json
+type updateCheckState struct {
+	CheckedAt     time.Time `json:"checked_at"`
+	Channel       string    `json:"channel"`
+	LatestVersion string    `json:"latest_version,omitempty"`
+}
+
+// updateCheck is a passive check for a new version, started before a
+// command runs and reported after it finishes
+type updateCheck struct {
+	currentVersion string
+	latestVersion  string
+	done           chan struct{}
+}
+
+// startUpdateCheck starts the passive check when update_check (or
+// AZURE2AWS_UPDATE_CHECK) enables it. GitHub is queried at most once per
+// updateCheckInterval; in between, the cached result is reported. Returns nil
+// when the check is disabled.
+func startUpdateCheck(configPath, currentVersion string) *updateCheck {
+	// The config is only peeked at: an encrypted config would prompt
+	defaults, _ := config.PeekDefaults(configPath)
+
+	enabled := defaults.UpdateCheck != nil && *defaults.UpdateCheck
+	if v := os.Getenv(config.EnvUpdateCheck); v != "" {
+		enabled, _ = strconv.ParseBool(v)
+	}
+	if !enabled || currentVersion == "" || currentVersion == "dev" {
+		return nil
+	}
+	channel, caBundle := passiveUpdateSettings(defaults, currentVersion)
+
+	check := &updateCheck{currentVersion: currentVersion, done: make(chan struct{})}
+
+	previous := loadUpdateCheckState()
+	if previous != nil && previous.Channel == channel && time.Since(previous.CheckedAt) < updateCheckInterval {
+		check.latestVersion = previous.LatestVersion
+		close(check.done)
+		return check
+	}
+
+	go func() {
+		defer close(check.done)
+
+		st := &updateCheckState{CheckedAt: time.Now(), Channel: channel}
+		if previous != nil && previous.Channel == channel {
+			st.LatestVersion = previous.LatestVersion
+		}
+
+		client, err := newUpdateClient(caBundle, updateCheckTimeout)
+		if err == nil {
+			var release *GitHubRelease
+			if release, err = getChannelRelease(client, channel); err == nil {
+				st.LatestVersion = release.TagName
+			}
+		}
+		if err != nil {
+			// Failures are remembered too, so that an offline machine does
+			// not retry on every command
+			logging.Debug("Update check failed", "error", err)
+		}
+
+		check.latestVersion = st.LatestVersion
+		if err := saveUpdateCheckState(st); err != nil {
+			logging.Debug("Failed to save update check", "error", err)
+		}
+	}()
+
+	return check
+}
+
+// passiveUpdateSettings returns the update channel and CA bundle for checks
+// that do not update anything. Without update_channel, pre-release builds
+// follow the beta channel.
+func passiveUpdateSettings(defaults config.Defaults, currentVersion string) (channel, caBundle string) {
+	channel = defaults.UpdateChannel
+	if channel == "" {
+		channel = updateChannelStable
+		if strings.Contains(currentVersion, "-") {
+			channel = updateChannelBeta
+		}
+	}
+	caBundle = defaults.CABundle
+	if v := os.Getenv(config.EnvCABundle); v != "" {
+		caBundle = v
+	}
+	return channel, caBundle
+}
+
+// notify prints a one-line notice when a newer version is available
+func (c *updateCheck) notify() {
+	if c == nil {
+		return
+	}
+
+	select {
+	case <-c.done:
+	case <-time.After(updateCheckWait):
+		return
+	}
+
+	if c.latestVersion != "" && c.latestVersion != c.currentVersion {
+		output.Statusf("A new version of azure2aws is available: %s → %s (run 'azure2aws update')\n", c.currentVersion, c.latestVersion)
+	}
+}
+
+// updateCheckPath returns the file caching the last passive check
+func updateCheckPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".azure2aws", "update-check.json"), nil
+}
+
+// loadUpdateCheckState returns the last passive check, or nil if there is
+// none
+func loadUpdateCheckState() *updateCheckState {
+	path, err := updateCheckPath()
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	var st updateCheckState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil
+	}
+	return &st
+}
+
+func saveUpdateCheckState(st *updateCheckState) error {
+	path, err := updateCheckPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
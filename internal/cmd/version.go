@@ -1,17 +1,165 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"runtime"
+	"runtime/debug"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
+	"github.com/user/azure2aws/internal/config"
+	"github.com/user/azure2aws/internal/output"
 )
 
-func newVersionCmd(version, commit, date string) *cobra.Command {
-	return &cobra.Command{
+type versionOptions struct {
+	json bool
+}
+
+// versionInfo is the build metadata reported by 'version --json'
+type versionInfo struct {
+	Version      string         `json:"version"`
+	Commit       string         `json:"commit"`
+	BuildDate    string         `json:"build_date"`
+	GoVersion    string         `json:"go_version"`
+	OS           string         `json:"os"`
+	Arch         string         `json:"arch"`
+	Latest       *latestVersion `json:"latest,omitempty"`
+	Dependencies []dependency   `json:"dependencies,omitempty"`
+}
+
+// latestVersion compares the build with the newest release of its channel
+type latestVersion struct {
+	Channel  string `json:"channel"`
+	Version  string `json:"version,omitempty"`
+	UpToDate bool   `json:"up_to_date"`
+	Error    string `json:"error,omitempty"`
+}
+
+// dependency is a module compiled into the binary
+type dependency struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+}
+
+func newVersionCmd(cc *CommandContext) *cobra.Command {
+	var opts versionOptions
+
+	cmd := &cobra.Command{
 		Use:   "version",
 		Short: "Print version information",
-		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Println(version)
+		Long: `Prints the version of azure2aws.
+
+With --verbose or --json, the full build metadata is printed as well: commit,
+build date, Go version, platform, the modules compiled in, and how the build
+compares to the latest release (looked up on GitHub). Include it in support
+tickets; --json suits fleet inventories.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runVersion(cc, opts)
 		},
 	}
+
+	cmd.Flags().BoolVar(&opts.json, "json", false, "Print build metadata as JSON")
+
+	return cmd
+}
+
+func runVersion(cc *CommandContext, opts versionOptions) error {
+	if !opts.json && !cc.Verbose {
+		output.Println(cc.Version)
+		return nil
+	}
+
+	info := buildVersionInfo(cc)
+	info.Latest = lookupLatestVersion(cc.ConfigFile, cc.Version)
+
+	if opts.json {
+		enc := json.NewEncoder(output.Data())
+		enc.SetIndent("", "  ")
+		return enc.Encode(info)
+	}
+
+	w := tabwriter.NewWriter(output.Data(), 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Version:\t%s\n", info.Version)
+	fmt.Fprintf(w, "Commit:\t%s\n", info.Commit)
+	fmt.Fprintf(w, "Build date:\t%s\n", info.BuildDate)
+	fmt.Fprintf(w, "Go version:\t%s\n", info.GoVersion)
+	fmt.Fprintf(w, "Platform:\t%s/%s\n", info.OS, info.Arch)
+	switch latest := info.Latest; {
+	case latest.Error != "":
+		fmt.Fprintf(w, "Latest (%s):\tunknown (%s)\n", latest.Channel, latest.Error)
+	case latest.UpToDate:
+		fmt.Fprintf(w, "Latest (%s):\t%s (up to date)\n", latest.Channel, latest.Version)
+	default:
+		fmt.Fprintf(w, "Latest (%s):\t%s (run 'azure2aws update')\n", latest.Channel, latest.Version)
+	}
+	if len(info.Dependencies) > 0 {
+		fmt.Fprintln(w, "Dependencies:")
+		for _, dep := range info.Dependencies {
+			fmt.Fprintf(w, "  %s\t%s\n", dep.Path, dep.Version)
+		}
+	}
+	return w.Flush()
+}
+
+// buildVersionInfo collects the build metadata. Builds without -ldflags
+// (such as 'go install') fall back to the VCS data Go records.
+func buildVersionInfo(cc *CommandContext) *versionInfo {
+	info := &versionInfo{
+		Version:   cc.Version,
+		Commit:    cc.Commit,
+		BuildDate: cc.BuildDate,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	}
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	if info.Version == "dev" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		info.Version = bi.Main.Version
+	}
+	for _, s := range bi.Settings {
+		switch {
+		case s.Key == "vcs.revision" && info.Commit == "none":
+			info.Commit = s.Value
+		case s.Key == "vcs.time" && info.BuildDate == "unknown":
+			info.BuildDate = s.Value
+		}
+	}
+	for _, dep := range bi.Deps {
+		if dep.Replace != nil {
+			dep = dep.Replace
+		}
+		info.Dependencies = append(info.Dependencies, dependency{Path: dep.Path, Version: dep.Version})
+	}
+
+	return info
+}
+
+// lookupLatestVersion compares currentVersion with the latest release of the
+// configured update channel
+func lookupLatestVersion(configPath, currentVersion string) *latestVersion {
+	defaults, _ := config.PeekDefaults(configPath)
+	channel, caBundle := passiveUpdateSettings(defaults, currentVersion)
+
+	latest := &latestVersion{Channel: channel}
+	client, err := newUpdateClient(caBundle, updateCheckTimeout)
+	if err != nil {
+		latest.Error = err.Error()
+		return latest
+	}
+	release, err := getChannelRelease(client, channel)
+	if err != nil {
+		latest.Error = err.Error()
+		return latest
+	}
+
+	latest.Version = release.TagName
+	latest.UpToDate = release.TagName == currentVersion
+	return latest
 }
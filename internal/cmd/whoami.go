@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/user/azure2aws/internal/output"
+)
+
+func newWhoamiCmd(cc *CommandContext) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "whoami",
+		Short: "Show the AWS identity for a profile",
+		Long: `Calls sts:GetCallerIdentity with the stored credentials for a profile
+and prints the caller ARN, account, and user ID.
+
+If credentials are expired, an error is returned (use 'azure2aws login' first).
+
+Example:
+  azure2aws whoami --profile production`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runWhoami(cmd.Context(), cc)
+		},
+	}
+
+	return cmd
+}
+
+func runWhoami(ctx context.Context, cc *CommandContext) error {
+	profileName := cc.Profile
+
+	creds, err := loadValidCredentials(cc, profileName)
+	if err != nil {
+		return err
+	}
+
+	awsClient, err := profileAWSClient(cc, profileName)
+	if err != nil {
+		return err
+	}
+
+	identity, err := awsClient.GetCallerIdentity(ctx, creds)
+	if err != nil {
+		return fmt.Errorf("%w\nRun 'azure2aws login --profile %s --force' to refresh", err, profileName)
+	}
+
+	output.Printf("Profile: %s\n", profileName)
+	output.Printf("ARN:     %s\n", identity.ARN)
+	output.Printf("Account: %s\n", identity.Account)
+	output.Printf("UserID:  %s\n", identity.UserID)
+	if !creds.Expiration.IsZero() {
+		output.Printf("Expires: %s\n", creds.Expiration.Local().Format("2006-01-02 15:04:05"))
+	}
+
+	return nil
+}
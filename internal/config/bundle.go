@@ -0,0 +1,128 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BundleVersion is the current profile bundle format version
+const BundleVersion = 1
+
+// Bundle formats
+const (
+	BundleFormatYAML = "yaml"
+	BundleFormatJSON = "json"
+)
+
+// Bundle is a shareable set of profiles, used to distribute team configuration.
+// Bundles never contain secrets; usernames are removed when redacted.
+type Bundle struct {
+	Version  int                `yaml:"version"`
+	Defaults *Defaults          `yaml:"defaults,omitempty"`
+	Profiles map[string]Profile `yaml:"profiles"`
+
+	RoleLabels map[string]string `yaml:"role_labels,omitempty"`
+	Accounts   map[string]string `yaml:"accounts,omitempty"`
+}
+
+// NewBundle creates a bundle from the named profiles, or all profiles if names is empty.
+// When redact is set, personal fields such as usernames are removed.
+func NewBundle(cfg *Config, names []string, redact bool) (*Bundle, error) {
+	if len(names) == 0 {
+		names = cfg.ListProfiles()
+		sort.Strings(names)
+	}
+
+	defaults := cfg.Defaults
+	bundle := &Bundle{
+		Version:  BundleVersion,
+		Defaults: &defaults,
+		Profiles: make(map[string]Profile, len(names)),
+
+		RoleLabels: cfg.RoleLabels,
+		Accounts:   cfg.Accounts,
+	}
+
+	for _, name := range names {
+		profile, exists := cfg.Profiles[name]
+		if !exists {
+			return nil, fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+		}
+
+		if redact {
+			profile.Username = ""
+		}
+
+		bundle.Profiles[name] = profile
+	}
+
+	return bundle, nil
+}
+
+// Marshal encodes the bundle in the given format (yaml or json)
+func (b *Bundle) Marshal(format string) ([]byte, error) {
+	data, err := yaml.Marshal(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal bundle: %w", err)
+	}
+
+	switch format {
+	case "", BundleFormatYAML:
+		return data, nil
+	case BundleFormatJSON:
+		// Round-trip through a generic map so the YAML field names are reused
+		var generic map[string]interface{}
+		if err := yaml.Unmarshal(data, &generic); err != nil {
+			return nil, fmt.Errorf("failed to convert bundle: %w", err)
+		}
+		out, err := json.MarshalIndent(generic, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal bundle: %w", err)
+		}
+		return append(out, '\n'), nil
+	default:
+		return nil, fmt.Errorf("unsupported bundle format %q (expected %s or %s)", format, BundleFormatYAML, BundleFormatJSON)
+	}
+}
+
+// ParseBundle decodes a YAML or JSON bundle
+func ParseBundle(data []byte) (*Bundle, error) {
+	// JSON is valid YAML, so a single decoder handles both formats
+	var bundle Bundle
+	if err := yaml.Unmarshal(data, &bundle); err != nil {
+		return nil, fmt.Errorf("failed to parse bundle: %w", err)
+	}
+
+	if bundle.Version > BundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d (max %d)", bundle.Version, BundleVersion)
+	}
+
+	if len(bundle.Profiles) == 0 {
+		return nil, fmt.Errorf("bundle contains no profiles")
+	}
+
+	for name, profile := range bundle.Profiles {
+		output, err := NormalizeOutput(profile.Output)
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: %w", name, err)
+		}
+		profile.Output = output
+		bundle.Profiles[name] = profile
+	}
+
+	return &bundle, nil
+}
+
+// LoadBundle reads and decodes a bundle file
+func LoadBundle(path string) (*Bundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle: %w", err)
+	}
+
+	return ParseBundle(data)
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/user/azure2aws/internal/prompter"
+)
+
+// Cache loads a config file once for a command and the code it calls.
+// Decrypting an encrypted config derives its key from the passphrase, which
+// is slow by design and may prompt, so the file is only read again when it
+// changes, reusing the passphrase. Loaded configs are shared: changes made to
+// one are seen by later loads until the file is saved.
+type Cache struct {
+	// Prompts asks for the passphrase of an encrypted config; the default
+	// scope of the prompter package when nil
+	Prompts *prompter.Scope
+
+	mu         sync.Mutex
+	loaded     bool
+	path       string
+	modTime    time.Time
+	size       int64
+	cfg        *Config
+	err        error
+	passphrase string
+	selected   string
+}
+
+// SelectProfile sets the profile AZURE2AWS_* overrides apply to in the
+// configs loaded, see Config.SelectProfile
+func (c *Cache) SelectProfile(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.selected = name
+	if c.cfg != nil {
+		c.cfg.SelectProfile(name)
+	}
+}
+
+// Load returns the config at path, as LoadConfig would
+func (c *Cache) Load(path string) (*Config, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var (
+		modTime time.Time
+		size    int64 = -1
+	)
+	if info, err := os.Stat(path); err == nil {
+		modTime, size = info.ModTime(), info.Size()
+	}
+	if c.loaded && c.path == path && c.modTime.Equal(modTime) && c.size == size {
+		return c.cfg, c.err
+	}
+
+	known := ""
+	if c.path == path {
+		known = c.passphrase
+	}
+	prompts := c.Prompts
+	if prompts == nil {
+		prompts = prompter.For("")
+	}
+	cfg, err := loadConfig(path, known, prompts)
+	if cfg != nil {
+		cfg.SelectProfile(c.selected)
+	}
+
+	c.loaded, c.path, c.modTime, c.size = true, path, modTime, size
+	c.cfg, c.err = cfg, err
+	if cfg != nil {
+		c.passphrase = cfg.passphrase
+	}
+	return cfg, err
+}
+
+// LoadOrCreate returns the config at path, or a new one if it does not
+// exist, as LoadOrCreateConfig would
+func (c *Cache) LoadOrCreate(path string) (*Config, error) {
+	cfg, err := c.Load(path)
+	if errors.Is(err, ErrConfigNotFound) {
+		cfg = NewConfig()
+		c.mu.Lock()
+		cfg.SelectProfile(c.selected)
+		c.mu.Unlock()
+		return cfg, nil
+	}
+	return cfg, err
+}
@@ -3,9 +3,17 @@ package config
 import (
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 
+	"github.com/user/azure2aws/internal/azurecloud"
+	"github.com/user/azure2aws/internal/partition"
+	"github.com/user/azure2aws/internal/prompter"
+	"github.com/user/azure2aws/internal/secretbox"
 	"gopkg.in/yaml.v3"
 )
 
@@ -14,6 +22,8 @@ var (
 	ErrProfileNotFound = errors.New("profile not found")
 	// ErrConfigNotFound is returned when config file doesn't exist
 	ErrConfigNotFound = errors.New("config file not found")
+	// ErrGroupNotFound is returned when a profile group doesn't exist
+	ErrGroupNotFound = errors.New("group not found")
 )
 
 // DefaultConfigPath returns the default config file path
@@ -34,8 +44,15 @@ func EnsureConfigDir(configPath string) error {
 	return nil
 }
 
-// LoadConfig loads configuration from the specified path
+// LoadConfig loads configuration from the specified path, decrypting it
+// transparently when it is encrypted at rest
 func LoadConfig(path string) (*Config, error) {
+	return loadConfig(path, "", prompter.For(""))
+}
+
+// loadConfig is LoadConfig, trying the passphrase known (if any) before
+// resolving one, which may prompt through prompts
+func loadConfig(path, known string, prompts *prompter.Scope) (*Config, error) {
 	// Check if file exists
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		return nil, ErrConfigNotFound
@@ -46,7 +63,26 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
+	var passphrase string
+	if IsEncrypted(data) {
+		sealed := data
+		if known != "" {
+			passphrase = known
+			data, err = secretbox.Open(encryptedHeader, sealed, passphrase)
+		}
+		if known == "" || errors.Is(err, secretbox.ErrWrongPassphrase) {
+			if passphrase, err = resolvePassphrase(path, prompts); err != nil {
+				return nil, err
+			}
+			data, err = secretbox.Open(encryptedHeader, sealed, passphrase)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt config file: %w", err)
+		}
+	}
+
 	cfg := NewConfig()
+	cfg.passphrase = passphrase
 	if err := yaml.Unmarshal(data, cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
@@ -59,6 +95,22 @@ func LoadConfig(path string) (*Config, error) {
 	return cfg, nil
 }
 
+// PeekDefaults returns the defaults of the config at path without prompting
+// for anything. It reports false when the config is missing, unreadable or
+// encrypted.
+func PeekDefaults(path string) (Defaults, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil || IsEncrypted(data) {
+		return Defaults{}, false
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return Defaults{}, false
+	}
+	return cfg.Defaults, true
+}
+
 // LoadOrCreateConfig loads config or creates a new one if it doesn't exist
 func LoadOrCreateConfig(path string) (*Config, error) {
 	cfg, err := LoadConfig(path)
@@ -71,7 +123,8 @@ func LoadOrCreateConfig(path string) (*Config, error) {
 	return cfg, nil
 }
 
-// SaveConfig saves configuration to the specified path
+// SaveConfig saves configuration to the specified path, encrypted if the
+// config has a passphrase
 func SaveConfig(cfg *Config, path string) error {
 	// Ensure directory exists
 	if err := EnsureConfigDir(path); err != nil {
@@ -83,6 +136,13 @@ func SaveConfig(cfg *Config, path string) error {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
+	if cfg.Encrypted() {
+		data, err = secretbox.Seal(encryptedHeader, data, cfg.passphrase)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt config: %w", err)
+		}
+	}
+
 	// Write with secure permissions (0600)
 	if err := os.WriteFile(path, data, 0600); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
@@ -91,20 +151,81 @@ func SaveConfig(cfg *Config, path string) error {
 	return nil
 }
 
-// GetProfile returns a merged profile (with defaults applied)
+// SelectProfile names the profile the command works on, the only one
+// AZURE2AWS_* environment overrides apply to. It defaults to AZURE2AWS_PROFILE.
+func (c *Config) SelectProfile(name string) {
+	c.selected = name
+}
+
+// GetProfile returns a merged profile, with defaults applied and, for the
+// selected profile, AZURE2AWS_* environment overrides. If the profile is not
+// in the config but is named by AZURE2AWS_PROFILE and AZURE2AWS_URL and
+// AZURE2AWS_APP_ID are set, it is built from the environment.
 func (c *Config) GetProfile(name string) (*MergedProfile, error) {
+	selected := c.selected
+	if selected == "" {
+		selected = os.Getenv(EnvProfile)
+	}
 	profile, exists := c.Profiles[name]
-	if !exists {
+	if !exists && (name != selected || name != os.Getenv(EnvProfile) || !hasEnvProfile()) {
 		return nil, fmt.Errorf("%w: %s", ErrProfileNotFound, name)
 	}
 
 	merged := &MergedProfile{
-		Name:     name,
-		URL:      profile.URL,
-		AppID:    profile.AppID,
-		Username: profile.Username,
-		RoleARN:  profile.RoleARN,
-		Output:   profile.Output,
+		Name:       name,
+		URL:        profile.URL,
+		AppID:      profile.AppID,
+		Username:   profile.Username,
+		RoleARN:    profile.RoleARN,
+		RoleFilter: profile.RoleFilter,
+		Partition:  profile.Partition,
+		Output:     profile.Output,
+		RequireMFA: profile.RequireMFA,
+		ADFSAuth:   profile.ADFSAuth,
+		HomeTenant: profile.HomeTenant,
+		TenantID:   profile.TenantID,
+		AzureCloud: profile.AzureCloud,
+
+		StaySignedIn: profile.StaySignedIn,
+
+		SessionPolicy: profile.SessionPolicy,
+		PolicyARNs:    profile.PolicyARNs,
+
+		ChainedRoles: profile.ChainedRoles,
+		ChainMode:    profile.ChainMode,
+
+		SAMLValidation:        profile.SAMLValidation,
+		SAMLSigningCert:       profile.SAMLSigningCert,
+		FederationMetadataURL: profile.FederationMetadataURL,
+		ClientCert:            profile.ClientCert,
+		ClientKey:             profile.ClientKey,
+
+		RoleLabels: c.RoleLabels,
+		Accounts:   c.Accounts,
+
+		DiscoverAccountAliases: c.Defaults.DiscoverAccountAliases,
+	}
+
+	if profile.CABundle != "" {
+		merged.CABundle = profile.CABundle
+	} else {
+		merged.CABundle = c.Defaults.CABundle
+	}
+	merged.UserAgent = c.Defaults.UserAgent
+	if profile.UserAgent != "" {
+		merged.UserAgent = profile.UserAgent
+	}
+
+	merged.STSEndpoint = profile.STSEndpoint
+	merged.STSRegion = c.Defaults.STSRegion
+	if profile.STSRegion != "" {
+		merged.STSRegion = profile.STSRegion
+	}
+	if c.Defaults.UseFIPSEndpoint != nil {
+		merged.UseFIPSEndpoint = *c.Defaults.UseFIPSEndpoint
+	}
+	if profile.UseFIPSEndpoint != nil {
+		merged.UseFIPSEndpoint = *profile.UseFIPSEndpoint
 	}
 
 	if profile.Region != "" {
@@ -119,9 +240,237 @@ func (c *Config) GetProfile(name string) (*MergedProfile, error) {
 		merged.SessionDuration = c.Defaults.SessionDuration
 	}
 
+	merged.HTTPTimeout = c.Defaults.HTTPTimeout
+	if profile.HTTPTimeout > 0 {
+		merged.HTTPTimeout = profile.HTTPTimeout
+	}
+	merged.ConnectTimeout = c.Defaults.ConnectTimeout
+	if profile.ConnectTimeout > 0 {
+		merged.ConnectTimeout = profile.ConnectTimeout
+	}
+	merged.MaxRetries = c.Defaults.MaxRetries
+	if profile.MaxRetries != nil {
+		merged.MaxRetries = profile.MaxRetries
+	}
+	merged.ThrottleRetries = c.Defaults.ThrottleRetries
+	if profile.ThrottleRetries != nil {
+		merged.ThrottleRetries = profile.ThrottleRetries
+	}
+	merged.MFATimeout = c.Defaults.MFATimeout
+	if profile.MFATimeout > 0 {
+		merged.MFATimeout = profile.MFATimeout
+	}
+	merged.MaxAuthSteps = c.Defaults.MaxAuthSteps
+	if profile.MaxAuthSteps > 0 {
+		merged.MaxAuthSteps = profile.MaxAuthSteps
+	}
+	merged.AuthTimeout = c.Defaults.AuthTimeout
+	if profile.AuthTimeout > 0 {
+		merged.AuthTimeout = profile.AuthTimeout
+	}
+	merged.CredentialStorage = c.Defaults.CredentialStorage
+	if profile.CredentialStorage != "" {
+		merged.CredentialStorage = profile.CredentialStorage
+	}
+	merged.CacheFormat = c.Defaults.CacheFormat
+	if profile.CacheFormat != "" {
+		merged.CacheFormat = profile.CacheFormat
+	}
+	merged.ExpirationKeys = c.Defaults.ExpirationKeys
+	if len(profile.ExpirationKeys) > 0 {
+		merged.ExpirationKeys = profile.ExpirationKeys
+	}
+
+	if name == selected {
+		if err := applyEnvOverrides(merged); err != nil {
+			return nil, err
+		}
+	}
+
+	if merged.HTTPTimeout < 0 || merged.ConnectTimeout < 0 ||
+		(merged.MaxRetries != nil && *merged.MaxRetries < 0) ||
+		(merged.ThrottleRetries != nil && *merged.ThrottleRetries < 0) {
+		return nil, fmt.Errorf("profile %s: http_timeout, connect_timeout, max_retries and throttle_retries must not be negative", name)
+	}
+	if merged.MFATimeout < 0 || merged.MaxAuthSteps < 0 || merged.AuthTimeout < 0 {
+		return nil, fmt.Errorf("profile %s: mfa_timeout, max_auth_steps and auth_timeout must not be negative", name)
+	}
+
+	if merged.RoleFilter != "" {
+		if _, err := regexp.Compile(merged.RoleFilter); err != nil {
+			return nil, fmt.Errorf("profile %s: invalid role_filter: %w", name, err)
+		}
+	}
+
+	if len(merged.PolicyARNs) > MaxPolicyARNs {
+		return nil, fmt.Errorf("profile %s: at most %d policy_arns are allowed", name, MaxPolicyARNs)
+	}
+	for _, arn := range merged.PolicyARNs {
+		if !strings.HasPrefix(arn, "arn:") || !strings.Contains(arn, ":policy/") {
+			return nil, fmt.Errorf("profile %s: invalid policy ARN %q", name, arn)
+		}
+	}
+
+	if merged.STSEndpoint != "" {
+		if u, err := url.Parse(merged.STSEndpoint); err != nil || u.Scheme != "https" || u.Host == "" {
+			return nil, fmt.Errorf("profile %s: sts_endpoint must be an https:// URL, got %q", name, merged.STSEndpoint)
+		}
+	}
+
+	cloud, err := azurecloud.Lookup(merged.AzureCloud)
+	if err != nil {
+		return nil, fmt.Errorf("profile %s: %w", name, err)
+	}
+	merged.AzureCloud = cloud.ID
+	if merged.URL == "" {
+		merged.URL = cloud.AppsURL
+	}
+
+	// Sovereign clouds pair with their AWS partition unless told otherwise
+	explicitRegion := profile.Region != "" || os.Getenv(EnvRegion) != ""
+	if merged.Partition == "" && !explicitRegion {
+		merged.Partition = cloud.Partition
+	}
+	if err := resolvePartition(merged, explicitRegion); err != nil {
+		return nil, fmt.Errorf("profile %s: %w", name, err)
+	}
+
+	output, err := NormalizeOutput(merged.Output)
+	if err != nil {
+		return nil, fmt.Errorf("profile %s: %w", name, err)
+	}
+	merged.Output = output
+
+	if merged.ChainMode == "" {
+		merged.ChainMode = ChainModeAzure2AWS
+	}
+
+	// Responses can only be verified against a configured trust root;
+	// profiles without one only warn until one is added
+	if merged.SAMLValidation == "" {
+		merged.SAMLValidation = SAMLValidationWarn
+		if merged.TenantID != "" || merged.SAMLSigningCert != "" || merged.FederationMetadataURL != "" {
+			merged.SAMLValidation = SAMLValidationFail
+		}
+	}
+
+	if strings.ContainsAny(merged.TenantID, "/?# ") {
+		return nil, fmt.Errorf("profile %s: tenant_id must be a tenant ID or domain, got %q", name, merged.TenantID)
+	}
+
+	switch merged.ADFSAuth {
+	case "":
+		merged.ADFSAuth = ADFSAuthForms
+	case ADFSAuthForms, ADFSAuthWIA:
+	default:
+		return nil, fmt.Errorf("profile %s: unknown adfs_auth %q (expected %s or %s)", name, merged.ADFSAuth, ADFSAuthForms, ADFSAuthWIA)
+	}
+
+	switch merged.CredentialStorage {
+	case "":
+		merged.CredentialStorage = CredentialStorageFile
+	case CredentialStorageFile, CredentialStorageKeyring:
+	default:
+		return nil, fmt.Errorf("profile %s: unknown credential_storage %q (expected %s or %s)", name, merged.CredentialStorage, CredentialStorageFile, CredentialStorageKeyring)
+	}
+
+	switch merged.CacheFormat {
+	case "":
+		merged.CacheFormat = CacheFormatINI
+	case CacheFormatINI:
+	case CacheFormatCLI:
+		// The CLI resolves source_profile before it reads the cache, so the
+		// source profile's credentials must be in the credentials file
+		if merged.CredentialStorage == CredentialStorageKeyring {
+			return nil, fmt.Errorf("profile %s: cache_format %s requires credential_storage %s", name, CacheFormatCLI, CredentialStorageFile)
+		}
+	default:
+		return nil, fmt.Errorf("profile %s: unknown cache_format %q (expected %s or %s)", name, merged.CacheFormat, CacheFormatINI, CacheFormatCLI)
+	}
+
+	if len(merged.ExpirationKeys) == 0 {
+		merged.ExpirationKeys = ExpirationKeys[:1]
+	}
+	for _, key := range merged.ExpirationKeys {
+		known := false
+		for _, k := range ExpirationKeys {
+			known = known || k == key
+		}
+		if !known {
+			return nil, fmt.Errorf("profile %s: unknown expiration key %q (supported: %s)", name, key, strings.Join(ExpirationKeys, ", "))
+		}
+	}
+
 	return merged, nil
 }
 
+// resolvePartition checks that the profile's region is in its partition. A
+// region inherited from the defaults is replaced by the partition's default
+// region; without a partition, it is inferred from the region.
+func resolvePartition(p *MergedProfile, explicitRegion bool) error {
+	inferred := p.Partition == ""
+	if inferred {
+		p.Partition = partition.ForRegion(p.Region).ID
+	}
+
+	part, err := partition.Lookup(p.Partition)
+	if err != nil {
+		return err
+	}
+	if !inferred && (p.Region == "" || (!explicitRegion && partition.ForRegion(p.Region) != part)) {
+		p.Region = part.DefaultRegion
+	}
+	if p.Region != "" && partition.ForRegion(p.Region) != part {
+		return fmt.Errorf("region %s is not in partition %s", p.Region, part.ID)
+	}
+	if p.STSRegion != "" && partition.ForRegion(p.STSRegion) != part {
+		return fmt.Errorf("sts_region %s is not in partition %s", p.STSRegion, part.ID)
+	}
+	return nil
+}
+
+// RoleLabel returns the label configured for a role ARN, or "" if it has none
+func (p *MergedProfile) RoleLabel(roleARN string) string {
+	return p.RoleLabels[roleARN]
+}
+
+// ResolveRoleLabel returns the role ARN labelled role (case-insensitive), or
+// role unchanged if it is not a label
+func (p *MergedProfile) ResolveRoleLabel(role string) (string, error) {
+	var matches []string
+	for arn, label := range p.RoleLabels {
+		if strings.EqualFold(label, role) {
+			matches = append(matches, arn)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return role, nil
+	case 1:
+		return matches[0], nil
+	}
+	sort.Strings(matches)
+	return "", fmt.Errorf("role label %q is used for several roles: %s", role, strings.Join(matches, ", "))
+}
+
+// NormalizeOutput lowercases an AWS CLI output format and checks that the AWS
+// CLI accepts it. An empty value is allowed and means the CLI default.
+func NormalizeOutput(output string) (string, error) {
+	output = strings.ToLower(strings.TrimSpace(output))
+	if output == "" {
+		return "", nil
+	}
+
+	for _, format := range OutputFormats {
+		if output == format {
+			return output, nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid output format %q (supported: %s)", output, strings.Join(OutputFormats, ", "))
+}
+
 // SetProfile adds or updates a profile
 func (c *Config) SetProfile(name string, profile Profile) {
 	if c.Profiles == nil {
@@ -136,6 +485,16 @@ func (c *Config) DeleteProfile(name string) error {
 		return fmt.Errorf("%w: %s", ErrProfileNotFound, name)
 	}
 	delete(c.Profiles, name)
+
+	for group, members := range c.Groups {
+		kept := members[:0]
+		for _, member := range members {
+			if member != name {
+				kept = append(kept, member)
+			}
+		}
+		c.Groups[group] = kept
+	}
 	return nil
 }
 
@@ -148,6 +507,33 @@ func (c *Config) ListProfiles() []string {
 	return names
 }
 
+// GroupProfiles returns the profiles of a group, checking that they exist
+func (c *Config) GroupProfiles(group string) ([]string, error) {
+	members, ok := c.Groups[group]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrGroupNotFound, group)
+	}
+	if len(members) == 0 {
+		return nil, fmt.Errorf("group %s has no profiles", group)
+	}
+	for _, member := range members {
+		if !c.HasProfile(member) {
+			return nil, fmt.Errorf("group %s: %w: %s", group, ErrProfileNotFound, member)
+		}
+	}
+	return members, nil
+}
+
+// ListGroups returns all group names, sorted
+func (c *Config) ListGroups() []string {
+	names := make([]string, 0, len(c.Groups))
+	for name := range c.Groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // HasProfile checks if a profile exists
 func (c *Config) HasProfile(name string) bool {
 	_, exists := c.Profiles[name]
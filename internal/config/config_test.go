@@ -1,8 +1,12 @@
 package config
 
 import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -125,3 +129,686 @@ func TestProfileOverridesDefaults(t *testing.T) {
 		t.Errorf("expected session duration 7200, got %d", merged.SessionDuration)
 	}
 }
+
+func TestGetProfileRequireMFA(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetProfile("prod", Profile{
+		URL:        "https://example.com",
+		RequireMFA: true,
+	})
+
+	merged, err := cfg.GetProfile("prod")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !merged.RequireMFA {
+		t.Error("expected RequireMFA to be true")
+	}
+}
+
+func TestGetProfileChainModeDefault(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetProfile("prod", Profile{
+		URL: "https://example.com",
+		ChainedRoles: []ChainedRole{
+			{Profile: "prod-admin", RoleARN: "arn:aws:iam::123456789012:role/Admin"},
+		},
+	})
+
+	merged, err := cfg.GetProfile("prod")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if merged.ChainMode != ChainModeAzure2AWS {
+		t.Errorf("expected chain mode %s, got %s", ChainModeAzure2AWS, merged.ChainMode)
+	}
+
+	if len(merged.ChainedRoles) != 1 {
+		t.Errorf("expected 1 chained role, got %d", len(merged.ChainedRoles))
+	}
+}
+
+func TestGetProfileSAMLValidationDefault(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile Profile
+		want    string
+	}{
+		{name: "no trust root", profile: Profile{URL: "https://example.com"}, want: SAMLValidationWarn},
+		{name: "tenant_id", profile: Profile{URL: "https://example.com", TenantID: "contoso.onmicrosoft.com"}, want: SAMLValidationFail},
+		{name: "saml_signing_cert", profile: Profile{URL: "https://example.com", SAMLSigningCert: "/etc/azure2aws/signing.pem"}, want: SAMLValidationFail},
+		{name: "federation_metadata_url", profile: Profile{URL: "https://example.com", FederationMetadataURL: "https://example.com/metadata.xml"}, want: SAMLValidationFail},
+		{name: "explicit", profile: Profile{URL: "https://example.com", TenantID: "contoso.onmicrosoft.com", SAMLValidation: SAMLValidationOff}, want: SAMLValidationOff},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewConfig()
+			cfg.SetProfile("prod", tt.profile)
+
+			merged, err := cfg.GetProfile("prod")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if merged.SAMLValidation != tt.want {
+				t.Errorf("expected saml_validation %s, got %s", tt.want, merged.SAMLValidation)
+			}
+		})
+	}
+}
+
+func TestBundleRedactAndParse(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetProfile("prod", Profile{
+		URL:      "https://example.com",
+		AppID:    "app-123",
+		Username: "user@example.com",
+	})
+
+	bundle, err := NewBundle(cfg, nil, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if bundle.Profiles["prod"].Username != "" {
+		t.Error("expected username to be redacted")
+	}
+
+	for _, format := range []string{BundleFormatYAML, BundleFormatJSON} {
+		data, err := bundle.Marshal(format)
+		if err != nil {
+			t.Fatalf("failed to marshal %s bundle: %v", format, err)
+		}
+
+		parsed, err := ParseBundle(data)
+		if err != nil {
+			t.Fatalf("failed to parse %s bundle: %v", format, err)
+		}
+
+		if parsed.Profiles["prod"].AppID != "app-123" {
+			t.Errorf("expected app ID app-123 from %s bundle, got %s", format, parsed.Profiles["prod"].AppID)
+		}
+	}
+}
+
+func TestGetProfileEnvOverrides(t *testing.T) {
+	t.Setenv(EnvRoleARN, "arn:aws:iam::123456789012:role/FromEnv")
+	t.Setenv(EnvSessionDuration, "7200")
+
+	cfg := NewConfig()
+	cfg.SetProfile("prod", Profile{
+		URL:     "https://example.com",
+		RoleARN: "arn:aws:iam::123456789012:role/FromConfig",
+	})
+	cfg.SetProfile("dev", Profile{
+		URL:     "https://example.com",
+		RoleARN: "arn:aws:iam::123456789012:role/Dev",
+	})
+	cfg.SelectProfile("prod")
+
+	dev, err := cfg.GetProfile("dev")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if dev.RoleARN != "arn:aws:iam::123456789012:role/Dev" {
+		t.Errorf("expected env overrides to skip unselected profile, got %s", dev.RoleARN)
+	}
+
+	merged, err := cfg.GetProfile("prod")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if merged.RoleARN != "arn:aws:iam::123456789012:role/FromEnv" {
+		t.Errorf("expected role ARN from env, got %s", merged.RoleARN)
+	}
+
+	if merged.SessionDuration != 7200 {
+		t.Errorf("expected session duration 7200, got %d", merged.SessionDuration)
+	}
+}
+
+func TestGetProfileFromEnvOnly(t *testing.T) {
+	t.Setenv(EnvURL, "https://example.com")
+	t.Setenv(EnvAppID, "app-123")
+	t.Setenv(EnvUsername, "ci@example.com")
+	t.Setenv(EnvProfile, "ci")
+
+	cfg := NewConfig()
+
+	merged, err := cfg.GetProfile("ci")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if merged.Username != "ci@example.com" {
+		t.Errorf("expected username from env, got %s", merged.Username)
+	}
+
+	if merged.Region != cfg.Defaults.Region {
+		t.Errorf("expected default region %s, got %s", cfg.Defaults.Region, merged.Region)
+	}
+}
+
+func TestGetProfileFromEnvNotSelected(t *testing.T) {
+	t.Setenv(EnvURL, "https://example.com")
+	t.Setenv(EnvAppID, "app-123")
+
+	cfg := NewConfig()
+	if _, err := cfg.GetProfile("ci"); !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("expected ErrProfileNotFound without AZURE2AWS_PROFILE, got %v", err)
+	}
+
+	t.Setenv(EnvProfile, "ci")
+	cfg.SelectProfile("other")
+	if _, err := cfg.GetProfile("ci"); !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("expected ErrProfileNotFound when another profile is selected, got %v", err)
+	}
+}
+
+func TestGetProfileInvalidEnv(t *testing.T) {
+	t.Setenv(EnvSessionDuration, "forever")
+
+	cfg := NewConfig()
+	cfg.SetProfile("prod", Profile{URL: "https://example.com"})
+	cfg.SelectProfile("prod")
+
+	if _, err := cfg.GetProfile("prod"); err == nil {
+		t.Error("expected error for invalid session duration")
+	}
+}
+
+func TestSaveAndLoadEncryptedConfig(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+
+	cfg := NewConfig()
+	cfg.SetProfile("test", Profile{
+		URL:   "https://test.example.com",
+		AppID: "app-123",
+	})
+	cfg.SetPassphrase("correct horse")
+
+	if err := SaveConfig(cfg, configPath); err != nil {
+		t.Fatalf("failed to save config: %v", err)
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+	if !IsEncrypted(data) {
+		t.Fatal("expected config file to be encrypted")
+	}
+	if strings.Contains(string(data), "test.example.com") {
+		t.Error("expected profile data not to be stored in plaintext")
+	}
+
+	t.Setenv(EnvConfigPassphrase, "correct horse")
+	loaded, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if !loaded.HasProfile("test") {
+		t.Error("expected profile 'test' to exist")
+	}
+	if !loaded.Encrypted() {
+		t.Error("expected loaded config to stay encrypted")
+	}
+
+	t.Setenv(EnvConfigPassphrase, "wrong")
+	if _, err := LoadConfig(configPath); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("expected ErrWrongPassphrase, got %v", err)
+	}
+}
+
+func TestCacheEncryptedConfig(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+
+	cfg := NewConfig()
+	cfg.SetProfile("test", Profile{URL: "https://test.example.com", AppID: "app-123"})
+	cfg.SetPassphrase("correct horse")
+	if err := SaveConfig(cfg, configPath); err != nil {
+		t.Fatalf("failed to save config: %v", err)
+	}
+
+	var cache Cache
+	t.Setenv(EnvConfigPassphrase, "correct horse")
+	first, err := cache.Load(configPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	// An unchanged file is not decrypted again
+	t.Setenv(EnvConfigPassphrase, "wrong")
+	if again, err := cache.Load(configPath); err != nil || again != first {
+		t.Fatalf("expected the cached config, got %p, %v", again, err)
+	}
+
+	// A changed file is read again with the passphrase that decrypted it
+	first.SetProfile("other", Profile{URL: "https://other.example.com", AppID: "app-456"})
+	if err := SaveConfig(first, configPath); err != nil {
+		t.Fatalf("failed to save config: %v", err)
+	}
+	reloaded, err := cache.Load(configPath)
+	if err != nil {
+		t.Fatalf("failed to reload config: %v", err)
+	}
+	if reloaded == first || !reloaded.HasProfile("other") {
+		t.Error("expected the changed config to be read again")
+	}
+}
+
+func TestPeekDefaults(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+
+	if _, ok := PeekDefaults(configPath); ok {
+		t.Error("expected no defaults for a missing config")
+	}
+
+	cfg := NewConfig()
+	cfg.Defaults.UpdateChannel = "beta"
+	if err := SaveConfig(cfg, configPath); err != nil {
+		t.Fatalf("failed to save config: %v", err)
+	}
+	defaults, ok := PeekDefaults(configPath)
+	if !ok || defaults.UpdateChannel != "beta" {
+		t.Errorf("expected update channel 'beta', got %q (ok=%v)", defaults.UpdateChannel, ok)
+	}
+
+	// Encrypted configs are not decrypted, so nothing prompts
+	cfg.SetPassphrase("correct horse")
+	if err := SaveConfig(cfg, configPath); err != nil {
+		t.Fatalf("failed to save config: %v", err)
+	}
+	if _, ok := PeekDefaults(configPath); ok {
+		t.Error("expected no defaults for an encrypted config")
+	}
+}
+
+func TestGetProfileADFSAuth(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetProfile("default", Profile{URL: "https://example.com", AppID: "app"})
+	cfg.SetProfile("wia", Profile{URL: "https://example.com", AppID: "app", ADFSAuth: "wia"})
+	cfg.SetProfile("bad", Profile{URL: "https://example.com", AppID: "app", ADFSAuth: "ntlm"})
+
+	profile, err := cfg.GetProfile("default")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if profile.ADFSAuth != ADFSAuthForms {
+		t.Errorf("expected adfs_auth %s, got %s", ADFSAuthForms, profile.ADFSAuth)
+	}
+
+	profile, err = cfg.GetProfile("wia")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if profile.ADFSAuth != ADFSAuthWIA {
+		t.Errorf("expected adfs_auth %s, got %s", ADFSAuthWIA, profile.ADFSAuth)
+	}
+
+	if _, err := cfg.GetProfile("bad"); err == nil {
+		t.Error("expected error for unknown adfs_auth")
+	}
+}
+
+func TestGetProfileCredentialStorage(t *testing.T) {
+	cfg := NewConfig()
+	cfg.Defaults.CredentialStorage = CredentialStorageKeyring
+	cfg.SetProfile("default", Profile{URL: "https://example.com", AppID: "app"})
+	cfg.SetProfile("file", Profile{URL: "https://example.com", AppID: "app", CredentialStorage: "file"})
+	cfg.SetProfile("bad", Profile{URL: "https://example.com", AppID: "app", CredentialStorage: "vault"})
+
+	profile, err := cfg.GetProfile("default")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if profile.CredentialStorage != CredentialStorageKeyring {
+		t.Errorf("expected credential_storage %s, got %s", CredentialStorageKeyring, profile.CredentialStorage)
+	}
+
+	profile, err = cfg.GetProfile("file")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if profile.CredentialStorage != CredentialStorageFile {
+		t.Errorf("expected credential_storage %s, got %s", CredentialStorageFile, profile.CredentialStorage)
+	}
+
+	if _, err := cfg.GetProfile("bad"); err == nil {
+		t.Error("expected error for unknown credential_storage")
+	}
+}
+
+func TestGetProfileCacheFormat(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetProfile("default", Profile{URL: "https://example.com", AppID: "app"})
+	cfg.SetProfile("cli", Profile{URL: "https://example.com", AppID: "app", CacheFormat: "cli"})
+	cfg.SetProfile("keyring", Profile{URL: "https://example.com", AppID: "app", CacheFormat: "cli", CredentialStorage: "keyring"})
+	cfg.SetProfile("bad", Profile{URL: "https://example.com", AppID: "app", CacheFormat: "json"})
+
+	profile, err := cfg.GetProfile("default")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if profile.CacheFormat != CacheFormatINI {
+		t.Errorf("expected cache_format %s, got %s", CacheFormatINI, profile.CacheFormat)
+	}
+
+	profile, err = cfg.GetProfile("cli")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if profile.CacheFormat != CacheFormatCLI {
+		t.Errorf("expected cache_format %s, got %s", CacheFormatCLI, profile.CacheFormat)
+	}
+
+	if _, err := cfg.GetProfile("keyring"); err == nil {
+		t.Error("expected error for cache_format cli with credential_storage keyring")
+	}
+	if _, err := cfg.GetProfile("bad"); err == nil {
+		t.Error("expected error for unknown cache_format")
+	}
+}
+
+func TestGetProfileExpirationKeys(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetProfile("default", Profile{URL: "https://example.com", AppID: "app"})
+	cfg.SetProfile("awsume", Profile{URL: "https://example.com", AppID: "app", ExpirationKeys: []string{"x_security_token_expires", "expiration"}})
+	cfg.SetProfile("bad", Profile{URL: "https://example.com", AppID: "app", ExpirationKeys: []string{"expires_at"}})
+
+	profile, err := cfg.GetProfile("default")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(profile.ExpirationKeys) != 1 || profile.ExpirationKeys[0] != "x_security_token_expires" {
+		t.Errorf("expected expiration keys [x_security_token_expires], got %v", profile.ExpirationKeys)
+	}
+
+	profile, err = cfg.GetProfile("awsume")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(profile.ExpirationKeys) != 2 || profile.ExpirationKeys[1] != "expiration" {
+		t.Errorf("expected the profile's expiration keys, got %v", profile.ExpirationKeys)
+	}
+
+	if _, err := cfg.GetProfile("bad"); err == nil {
+		t.Error("expected error for unknown expiration key")
+	}
+}
+
+func TestGetProfileTenantID(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetProfile("domain", Profile{URL: "https://example.com", AppID: "app", TenantID: "contoso.onmicrosoft.com"})
+	cfg.SetProfile("bad", Profile{URL: "https://example.com", AppID: "app", TenantID: "contoso.com/saml2"})
+
+	profile, err := cfg.GetProfile("domain")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if profile.TenantID != "contoso.onmicrosoft.com" {
+		t.Errorf("expected tenant_id contoso.onmicrosoft.com, got %s", profile.TenantID)
+	}
+
+	if _, err := cfg.GetProfile("bad"); err == nil {
+		t.Error("expected error for tenant_id with a path")
+	}
+}
+
+func TestGetProfileAzureCloud(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetProfile("gov", Profile{URL: "https://example.com", AppID: "app", AzureCloud: "USGovernment"})
+	cfg.SetProfile("gov-commercial", Profile{URL: "https://example.com", AppID: "app", AzureCloud: "usgovernment", Region: "us-east-1"})
+	cfg.SetProfile("bad", Profile{URL: "https://example.com", AppID: "app", AzureCloud: "germany"})
+
+	profile, err := cfg.GetProfile("gov")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if profile.AzureCloud != "usgovernment" || profile.Partition != "aws-us-gov" {
+		t.Errorf("expected usgovernment in aws-us-gov, got %s in %s", profile.AzureCloud, profile.Partition)
+	}
+
+	profile, err = cfg.GetProfile("gov-commercial")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if profile.Partition != "aws" {
+		t.Errorf("expected the region's partition aws, got %s", profile.Partition)
+	}
+
+	if _, err := cfg.GetProfile("bad"); err == nil {
+		t.Error("expected error for unknown azure_cloud")
+	}
+}
+
+func TestGetProfileOutputValidation(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetProfile("yaml", Profile{URL: "https://example.com", AppID: "app", Output: "YAML-Stream"})
+	cfg.SetProfile("bad", Profile{URL: "https://example.com", AppID: "app", Output: "xml"})
+
+	profile, err := cfg.GetProfile("yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if profile.Output != "yaml-stream" {
+		t.Errorf("expected output yaml-stream, got %s", profile.Output)
+	}
+
+	if _, err := cfg.GetProfile("bad"); err == nil {
+		t.Error("expected error for invalid output format")
+	}
+}
+
+func TestResolveRoleLabel(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetProfile("prod", Profile{URL: "https://example.com"})
+	cfg.RoleLabels = map[string]string{
+		"arn:aws:iam::111111111111:role/Admin": "staging-admin",
+		"arn:aws:iam::222222222222:role/Admin": "prod-admin",
+		"arn:aws:iam::333333333333:role/Admin": "dup",
+		"arn:aws:iam::444444444444:role/Admin": "Dup",
+	}
+
+	profile, err := cfg.GetProfile("prod")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, err := profile.ResolveRoleLabel("Staging-Admin"); err != nil || got != "arn:aws:iam::111111111111:role/Admin" {
+		t.Errorf("expected staging-admin ARN, got %q (%v)", got, err)
+	}
+	if got, err := profile.ResolveRoleLabel("ReadOnly"); err != nil || got != "ReadOnly" {
+		t.Errorf("expected non-label to be returned unchanged, got %q (%v)", got, err)
+	}
+	if _, err := profile.ResolveRoleLabel("dup"); err == nil {
+		t.Error("expected error for ambiguous label")
+	}
+}
+
+func TestGetProfilePartition(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetProfile("gov", Profile{URL: "https://example.com", Partition: "aws-us-gov"})
+	cfg.SetProfile("cn", Profile{URL: "https://example.com", Region: "cn-northwest-1"})
+	cfg.SetProfile("bad", Profile{URL: "https://example.com", Partition: "aws-cn", Region: "eu-west-1"})
+	cfg.SetProfile("unknown", Profile{URL: "https://example.com", Partition: "aws-iso"})
+
+	gov, err := cfg.GetProfile("gov")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gov.Region != "us-gov-west-1" {
+		t.Errorf("expected default region to be replaced by us-gov-west-1, got %s", gov.Region)
+	}
+
+	cn, err := cfg.GetProfile("cn")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cn.Partition != "aws-cn" {
+		t.Errorf("expected partition inferred from region, got %s", cn.Partition)
+	}
+
+	if _, err := cfg.GetProfile("bad"); err == nil {
+		t.Error("expected error for region outside partition")
+	}
+	if _, err := cfg.GetProfile("unknown"); err == nil {
+		t.Error("expected error for unknown partition")
+	}
+}
+
+func TestGetProfileSTSEndpoint(t *testing.T) {
+	fips := true
+	cfg := NewConfig()
+	cfg.Defaults.UseFIPSEndpoint = &fips
+	cfg.SetProfile("regional", Profile{URL: "https://example.com", STSRegion: "eu-west-1"})
+	cfg.SetProfile("bad", Profile{URL: "https://example.com", Region: "us-gov-west-1", STSRegion: "us-east-1"})
+
+	regional, err := cfg.GetProfile("regional")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if regional.STSRegion != "eu-west-1" {
+		t.Errorf("expected sts_region eu-west-1, got %s", regional.STSRegion)
+	}
+	if !regional.UseFIPSEndpoint {
+		t.Error("expected use_fips_endpoint inherited from defaults")
+	}
+
+	if _, err := cfg.GetProfile("bad"); err == nil {
+		t.Error("expected error for sts_region outside partition")
+	}
+
+	cfg.SetProfile("custom", Profile{URL: "https://example.com", STSEndpoint: "https://sts.internal.example.com"})
+	if _, err := cfg.GetProfile("custom"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	cfg.SetProfile("plain", Profile{URL: "https://example.com", STSEndpoint: "http://sts.internal.example.com"})
+	if _, err := cfg.GetProfile("plain"); err == nil {
+		t.Error("expected error for non-https sts_endpoint")
+	}
+}
+
+func TestGetProfilePolicyARNs(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetProfile("scoped", Profile{URL: "https://example.com", PolicyARNs: []string{"arn:aws:iam::aws:policy/ReadOnlyAccess"}})
+	cfg.SetProfile("bad", Profile{URL: "https://example.com", PolicyARNs: []string{"ReadOnlyAccess"}})
+
+	if _, err := cfg.GetProfile("scoped"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := cfg.GetProfile("bad"); err == nil {
+		t.Error("expected error for invalid policy ARN")
+	}
+
+	t.Setenv(EnvPolicyARNs, "arn:aws:iam::aws:policy/ViewOnlyAccess, arn:aws:iam::123456789012:policy/Extra")
+	cfg.SelectProfile("scoped")
+	scoped, err := cfg.GetProfile("scoped")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(scoped.PolicyARNs) != 2 || scoped.PolicyARNs[1] != "arn:aws:iam::123456789012:policy/Extra" {
+		t.Errorf("expected policy ARNs from environment, got %v", scoped.PolicyARNs)
+	}
+}
+
+func TestGroupProfiles(t *testing.T) {
+	cfg := NewConfig()
+	cfg.SetProfile("a", Profile{URL: "https://example.com"})
+	cfg.SetProfile("b", Profile{URL: "https://example.com"})
+	cfg.Groups = map[string][]string{"prod": {"a", "b"}, "broken": {"a", "missing"}}
+
+	members, err := cfg.GroupProfiles("prod")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(members) != 2 {
+		t.Errorf("expected 2 members, got %v", members)
+	}
+
+	if _, err := cfg.GroupProfiles("nope"); !errors.Is(err, ErrGroupNotFound) {
+		t.Errorf("expected ErrGroupNotFound, got %v", err)
+	}
+	if _, err := cfg.GroupProfiles("broken"); !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("expected ErrProfileNotFound, got %v", err)
+	}
+
+	if err := cfg.DeleteProfile("b"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if members := cfg.Groups["prod"]; len(members) != 1 || members[0] != "a" {
+		t.Errorf("expected deleted profile removed from group, got %v", members)
+	}
+}
+
+func TestParsePreset(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	preset := []byte("version: 1\nprofiles:\n  team:\n    url: https://example.com\n    app_id: app\n")
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, preset))
+
+	bundle, err := ParsePreset(preset, []byte(signature+"\n"), pub)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := bundle.Profiles["team"]; !ok {
+		t.Error("expected profile 'team' in preset")
+	}
+
+	tampered := []byte(strings.Replace(string(preset), "app_id: app", "app_id: evil", 1))
+	if _, err := ParsePreset(tampered, []byte(signature), pub); !errors.Is(err, ErrPresetSignature) {
+		t.Errorf("expected ErrPresetSignature, got %v", err)
+	}
+}
+
+func TestMergePresetDefaults(t *testing.T) {
+	dst := Defaults{Region: "eu-west-1", KeyringBackend: "system"}
+	src := Defaults{
+		Region:          "us-east-1",
+		SessionDuration: 7200,
+		KeyringBackend:  "file",
+		CABundle:        "/tmp/evil.pem",
+		VaultPath:       "secret/evil",
+		PresetPublicKey: "/tmp/evil.pub",
+	}
+
+	changed := MergePresetDefaults(&dst, src)
+
+	if dst.Region != "eu-west-1" {
+		t.Errorf("expected local region kept, got %s", dst.Region)
+	}
+	if dst.SessionDuration != 7200 {
+		t.Errorf("expected unset session_duration filled, got %d", dst.SessionDuration)
+	}
+	if dst.KeyringBackend != "system" || dst.CABundle != "" || dst.VaultPath != "" || dst.PresetPublicKey != "" {
+		t.Errorf("expected trust settings untouched, got %+v", dst)
+	}
+	if len(changed) != 1 || changed[0] != "session_duration" {
+		t.Errorf("expected only session_duration changed, got %v", changed)
+	}
+}
+
+func TestMergeDefaults(t *testing.T) {
+	dst := Defaults{Region: "eu-west-1", KeyringBackend: "system"}
+	src := Defaults{Region: "us-east-1", CABundle: "/etc/ca.pem"}
+
+	if changed := MergeDefaults(&dst, src, false); len(changed) != 1 || changed[0] != "ca_bundle" {
+		t.Errorf("expected only ca_bundle filled, got %v", changed)
+	}
+	if dst.Region != "eu-west-1" {
+		t.Errorf("expected local region kept, got %s", dst.Region)
+	}
+
+	if changed := MergeDefaults(&dst, src, true); len(changed) != 1 || changed[0] != "region" {
+		t.Errorf("expected region overwritten, got %v", changed)
+	}
+	if dst.Region != "us-east-1" || dst.KeyringBackend != "system" {
+		t.Errorf("unexpected defaults after overwrite: %+v", dst)
+	}
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/user/azure2aws/internal/keyring"
+	"github.com/user/azure2aws/internal/prompter"
+	"github.com/user/azure2aws/internal/secretbox"
+)
+
+const (
+	// encryptedHeader marks a config file encrypted with a passphrase
+	encryptedHeader = "azure2aws-encrypted-v1"
+
+	// PassphraseKeyringService is the keyring service storing config passphrases,
+	// keyed by the absolute config file path
+	PassphraseKeyringService = "azure2aws-config"
+)
+
+// ErrWrongPassphrase is returned when an encrypted config cannot be decrypted
+var ErrWrongPassphrase = secretbox.ErrWrongPassphrase
+
+// IsEncrypted reports whether config file contents are encrypted
+func IsEncrypted(data []byte) bool {
+	return secretbox.IsSealed(encryptedHeader, data)
+}
+
+// Encrypted reports whether the config is written encrypted
+func (c *Config) Encrypted() bool {
+	return c.passphrase != ""
+}
+
+// SetPassphrase enables encryption at rest with the given passphrase.
+// An empty passphrase disables encryption.
+func (c *Config) SetPassphrase(passphrase string) {
+	c.passphrase = passphrase
+}
+
+// resolvePassphrase returns the passphrase for an encrypted config file, from
+// AZURE2AWS_CONFIG_PASSPHRASE, the keyring, or a prompt of prompts
+func resolvePassphrase(path string, prompts *prompter.Scope) (string, error) {
+	if passphrase := os.Getenv(EnvConfigPassphrase); passphrase != "" {
+		return passphrase, nil
+	}
+
+	if kr, err := passphraseKeyring(prompts); err == nil {
+		if passphrase, err := kr.GetPassword(passphraseKey(path)); err == nil {
+			return passphrase, nil
+		}
+	}
+
+	passphrase, err := prompts.Password(fmt.Sprintf("Passphrase for %s", path))
+	if err != nil {
+		return "", fmt.Errorf("failed to read config passphrase: %w", err)
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("a passphrase is required to decrypt %s", path)
+	}
+
+	return passphrase, nil
+}
+
+// SavePassphrase stores the passphrase for a config file in the keyring,
+// which may prompt through prompts
+func SavePassphrase(path, passphrase string, prompts *prompter.Scope) error {
+	kr, err := passphraseKeyring(prompts)
+	if err != nil {
+		return err
+	}
+	return kr.SavePassword(passphraseKey(path), passphrase)
+}
+
+// DeletePassphrase removes a stored config passphrase from the keyring
+func DeletePassphrase(path string, prompts *prompter.Scope) error {
+	kr, err := passphraseKeyring(prompts)
+	if err != nil {
+		return err
+	}
+	return kr.DeletePassword(passphraseKey(path))
+}
+
+// passphraseKeyring returns the keyring holding config passphrases: the
+// backend named by AZURE2AWS_KEYRING_BACKEND, or else the OS keyring. The
+// keyring_backend of the config cannot be used, as it is only known once the
+// config is decrypted.
+func passphraseKeyring(prompts *prompter.Scope) (*keyring.Keyring, error) {
+	name := os.Getenv(keyring.EnvBackend)
+	if name == "" {
+		name = keyring.BackendSystem
+	}
+	backend, err := keyring.NewBackend(name, keyring.Options{Prompts: prompts})
+	if err != nil {
+		return nil, err
+	}
+	return keyring.NewWithBackend(PassphraseKeyringService, backend), nil
+}
+
+// passphraseKey is the keyring account for a config file
+func passphraseKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
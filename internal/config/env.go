@@ -0,0 +1,176 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Environment variables that override profile fields
+const (
+	EnvURL             = "AZURE2AWS_URL"
+	EnvAppID           = "AZURE2AWS_APP_ID"
+	EnvUsername        = "AZURE2AWS_USERNAME"
+	EnvRoleARN         = "AZURE2AWS_ROLE_ARN"
+	EnvRoleFilter      = "AZURE2AWS_ROLE_FILTER"
+	EnvRegion          = "AZURE2AWS_REGION"
+	EnvPartition       = "AZURE2AWS_PARTITION"
+	EnvOutput          = "AZURE2AWS_OUTPUT"
+	EnvSessionDuration = "AZURE2AWS_SESSION_DURATION"
+	EnvSessionPolicy   = "AZURE2AWS_SESSION_POLICY"
+	EnvPolicyARNs      = "AZURE2AWS_POLICY_ARNS"
+	EnvRequireMFA      = "AZURE2AWS_REQUIRE_MFA"
+	EnvStaySignedIn    = "AZURE2AWS_STAY_SIGNED_IN"
+	EnvChainMode       = "AZURE2AWS_CHAIN_MODE"
+	EnvADFSAuth        = "AZURE2AWS_ADFS_AUTH"
+	EnvHomeTenant      = "AZURE2AWS_HOME_TENANT"
+	EnvTenantID        = "AZURE2AWS_TENANT_ID"
+	EnvAzureCloud      = "AZURE2AWS_AZURE_CLOUD"
+
+	EnvSAMLValidation        = "AZURE2AWS_SAML_VALIDATION"
+	EnvSAMLSigningCert       = "AZURE2AWS_SAML_SIGNING_CERT"
+	EnvFederationMetadataURL = "AZURE2AWS_FEDERATION_METADATA_URL"
+	EnvCABundle              = "AZURE2AWS_CA_BUNDLE"
+	EnvUserAgent             = "AZURE2AWS_USER_AGENT"
+	EnvClientCert            = "AZURE2AWS_CLIENT_CERT"
+	EnvClientKey             = "AZURE2AWS_CLIENT_KEY"
+	EnvSTSRegion             = "AZURE2AWS_STS_REGION"
+	EnvSTSEndpoint           = "AZURE2AWS_STS_ENDPOINT"
+	EnvUseFIPSEndpoint       = "AZURE2AWS_USE_FIPS_ENDPOINT"
+
+	EnvHTTPTimeout    = "AZURE2AWS_HTTP_TIMEOUT"
+	EnvConnectTimeout = "AZURE2AWS_CONNECT_TIMEOUT"
+	EnvMaxRetries     = "AZURE2AWS_MAX_RETRIES"
+
+	EnvThrottleRetries = "AZURE2AWS_THROTTLE_RETRIES"
+	EnvMFATimeout      = "AZURE2AWS_MFA_TIMEOUT"
+	EnvMaxAuthSteps    = "AZURE2AWS_MAX_AUTH_STEPS"
+	EnvAuthTimeout     = "AZURE2AWS_AUTH_TIMEOUT"
+
+	EnvCredentialStorage = "AZURE2AWS_CREDENTIAL_STORAGE"
+	EnvCacheFormat       = "AZURE2AWS_CACHE_FORMAT"
+)
+
+// Environment variables for global settings
+const (
+	EnvProfile = "AZURE2AWS_PROFILE"
+	EnvConfig  = "AZURE2AWS_CONFIG"
+
+	EnvConfigPassphrase = "AZURE2AWS_CONFIG_PASSPHRASE"
+	EnvUpdateCheck      = "AZURE2AWS_UPDATE_CHECK"
+	EnvMFAToken         = "AZURE2AWS_MFA_TOKEN"
+	EnvPassword         = "AZURE2AWS_PASSWORD"
+	EnvNoInput          = "AZURE2AWS_NO_INPUT"
+
+	EnvClientCertPassword = "AZURE2AWS_CLIENT_CERT_PASSWORD"
+
+	EnvDaemonSocket = "AZURE2AWS_DAEMON_SOCKET"
+)
+
+// hasEnvProfile reports whether the environment defines enough to build a
+// profile without a config file entry
+func hasEnvProfile() bool {
+	return os.Getenv(EnvURL) != "" && os.Getenv(EnvAppID) != ""
+}
+
+// applyEnvOverrides layers AZURE2AWS_* environment variables over a merged profile
+func applyEnvOverrides(p *MergedProfile) error {
+	stringOverrides := map[string]*string{
+		EnvURL:        &p.URL,
+		EnvAppID:      &p.AppID,
+		EnvUsername:   &p.Username,
+		EnvRoleARN:    &p.RoleARN,
+		EnvRoleFilter: &p.RoleFilter,
+		EnvRegion:     &p.Region,
+		EnvPartition:  &p.Partition,
+		EnvOutput:     &p.Output,
+		EnvChainMode:  &p.ChainMode,
+		EnvADFSAuth:   &p.ADFSAuth,
+		EnvHomeTenant: &p.HomeTenant,
+		EnvTenantID:   &p.TenantID,
+		EnvAzureCloud: &p.AzureCloud,
+
+		EnvSessionPolicy: &p.SessionPolicy,
+
+		EnvSAMLValidation:        &p.SAMLValidation,
+		EnvSAMLSigningCert:       &p.SAMLSigningCert,
+		EnvFederationMetadataURL: &p.FederationMetadataURL,
+		EnvCABundle:              &p.CABundle,
+		EnvUserAgent:             &p.UserAgent,
+		EnvClientCert:            &p.ClientCert,
+		EnvClientKey:             &p.ClientKey,
+		EnvSTSRegion:             &p.STSRegion,
+		EnvSTSEndpoint:           &p.STSEndpoint,
+
+		EnvCredentialStorage: &p.CredentialStorage,
+		EnvCacheFormat:       &p.CacheFormat,
+	}
+
+	for name, field := range stringOverrides {
+		if v := os.Getenv(name); v != "" {
+			*field = v
+		}
+	}
+
+	if v := os.Getenv(EnvPolicyARNs); v != "" {
+		p.PolicyARNs = nil
+		for _, arn := range strings.Split(v, ",") {
+			if arn = strings.TrimSpace(arn); arn != "" {
+				p.PolicyARNs = append(p.PolicyARNs, arn)
+			}
+		}
+	}
+
+	intOverrides := map[string]*int{
+		EnvSessionDuration: &p.SessionDuration,
+		EnvHTTPTimeout:     &p.HTTPTimeout,
+		EnvConnectTimeout:  &p.ConnectTimeout,
+		EnvMFATimeout:      &p.MFATimeout,
+		EnvMaxAuthSteps:    &p.MaxAuthSteps,
+		EnvAuthTimeout:     &p.AuthTimeout,
+	}
+
+	for name, field := range intOverrides {
+		if v := os.Getenv(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: %w", name, v, err)
+			}
+			*field = n
+		}
+	}
+
+	optionalIntOverrides := map[string]**int{
+		EnvMaxRetries:      &p.MaxRetries,
+		EnvThrottleRetries: &p.ThrottleRetries,
+	}
+
+	for name, field := range optionalIntOverrides {
+		if v := os.Getenv(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: %w", name, v, err)
+			}
+			*field = &n
+		}
+	}
+
+	boolOverrides := map[string]*bool{
+		EnvRequireMFA:      &p.RequireMFA,
+		EnvStaySignedIn:    &p.StaySignedIn,
+		EnvUseFIPSEndpoint: &p.UseFIPSEndpoint,
+	}
+
+	for name, field := range boolOverrides {
+		if v := os.Getenv(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: %w", name, v, err)
+			}
+			*field = b
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// ErrPresetSignature is returned when a preset does not match its signature
+var ErrPresetSignature = errors.New("preset signature verification failed")
+
+// LoadPresetPublicKey reads the Ed25519 public key (PEM "PUBLIC KEY") used to
+// verify presets
+func LoadPresetPublicKey(path string) (ed25519.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read preset public key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", path)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse preset public key: %w", err)
+	}
+
+	edKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("preset public key in %s is not an Ed25519 key", path)
+	}
+
+	return edKey, nil
+}
+
+// ParsePreset verifies a preset against its detached Ed25519 signature (raw
+// or base64-encoded) and decodes it as a bundle
+func ParsePreset(data, signature []byte, key ed25519.PublicKey) (*Bundle, error) {
+	sig := signature
+	if len(sig) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+		if err != nil {
+			return nil, fmt.Errorf("%w: malformed signature", ErrPresetSignature)
+		}
+		sig = decoded
+	}
+
+	if !ed25519.Verify(key, data, sig) {
+		return nil, ErrPresetSignature
+	}
+
+	return ParseBundle(data)
+}
+
+// presetDefaults are the defaults (by YAML key) a preset may set. Settings
+// that decide where secrets are kept, which CAs are trusted or which key
+// verifies presets are never taken from one.
+var presetDefaults = map[string]bool{
+	"region":                   true,
+	"session_duration":         true,
+	"user_agent":               true,
+	"http_timeout":             true,
+	"connect_timeout":          true,
+	"max_retries":              true,
+	"throttle_retries":         true,
+	"mfa_timeout":              true,
+	"max_auth_steps":           true,
+	"auth_timeout":             true,
+	"sts_region":               true,
+	"use_fips_endpoint":        true,
+	"console_duration":         true,
+	"update_check":             true,
+	"discover_account_aliases": true,
+	"credential_storage":       true,
+	"expiration_keys":          true,
+	"cache_format":             true,
+}
+
+// MergeDefaults copies the fields set in src to dst, over the unset fields of
+// dst only unless overwrite is set, and returns the YAML keys it changed
+func MergeDefaults(dst *Defaults, src Defaults, overwrite bool) []string {
+	return mergeDefaults(dst, src, overwrite, nil)
+}
+
+// MergePresetDefaults copies the fields a preset may set from its defaults
+// to the unset fields of dst, and returns the YAML keys it changed
+func MergePresetDefaults(dst *Defaults, src Defaults) []string {
+	return mergeDefaults(dst, src, false, presetDefaults)
+}
+
+// mergeDefaults merges the fields of src whose keys are allowed (all when
+// allowed is nil) into dst
+func mergeDefaults(dst *Defaults, src Defaults, overwrite bool, allowed map[string]bool) []string {
+	dv := reflect.ValueOf(dst).Elem()
+	sv := reflect.ValueOf(src)
+
+	var changed []string
+	for i := 0; i < sv.NumField(); i++ {
+		key, _, _ := strings.Cut(sv.Type().Field(i).Tag.Get("yaml"), ",")
+		if allowed != nil && !allowed[key] {
+			continue
+		}
+		if sv.Field(i).IsZero() || (!dv.Field(i).IsZero() && !overwrite) {
+			continue
+		}
+		if reflect.DeepEqual(dv.Field(i).Interface(), sv.Field(i).Interface()) {
+			continue
+		}
+		dv.Field(i).Set(sv.Field(i))
+		changed = append(changed, key)
+	}
+	return changed
+}
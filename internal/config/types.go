@@ -4,12 +4,67 @@ package config
 type Config struct {
 	Defaults Defaults           `yaml:"defaults"`
 	Profiles map[string]Profile `yaml:"profiles"`
+
+	// RoleLabels maps role ARNs to human-readable labels shown during role
+	// selection and accepted by 'login --role'
+	RoleLabels map[string]string `yaml:"role_labels,omitempty"`
+
+	// Accounts maps AWS account IDs to names shown next to roles
+	Accounts map[string]string `yaml:"accounts,omitempty"`
+
+	// Groups maps group names to profile names, for commands accepting --group
+	Groups map[string][]string `yaml:"groups,omitempty"`
+
+	// passphrase encrypts the file at rest when set
+	passphrase string
+
+	// selected is the profile AZURE2AWS_* overrides apply to, see SelectProfile
+	selected string
 }
 
 // Defaults contains default settings applied to all profiles
 type Defaults struct {
 	Region          string `yaml:"region"`
 	SessionDuration int    `yaml:"session_duration"`
+	KeyringBackend  string `yaml:"keyring_backend,omitempty"` // Password store backend (system, pass, file, 1password, vault)
+
+	OnePasswordVault string `yaml:"onepassword_vault,omitempty"` // Vault for 1Password items without an explicit reference
+	VaultPath        string `yaml:"vault_path,omitempty"`        // HashiCorp Vault KV base path for secrets without an explicit path
+
+	CABundle  string `yaml:"ca_bundle,omitempty"`  // PEM file of extra CA certificates to trust (TLS-inspecting proxies)
+	UserAgent string `yaml:"user_agent,omitempty"` // User-Agent of Azure AD requests ("browser" for a browser's)
+
+	HTTPTimeout    int  `yaml:"http_timeout,omitempty"`    // Azure AD request timeout in seconds
+	ConnectTimeout int  `yaml:"connect_timeout,omitempty"` // Azure AD connect timeout in seconds
+	MaxRetries     *int `yaml:"max_retries,omitempty"`     // Retries of idempotent requests on transient network errors
+
+	ThrottleRetries *int `yaml:"throttle_retries,omitempty"` // Retries when Azure AD throttles sign-ins (HTTP 429, AADSTS90033)
+
+	MFATimeout int `yaml:"mfa_timeout,omitempty"` // Seconds to wait for a push or call to be approved
+
+	MaxAuthSteps int `yaml:"max_auth_steps,omitempty"` // Sign-in pages handled before giving up
+
+	AuthTimeout int `yaml:"auth_timeout,omitempty"` // Seconds a whole sign-in may take (0: no limit)
+
+	STSRegion       string `yaml:"sts_region,omitempty"`        // Region of the STS endpoint (defaults to the profile region)
+	UseFIPSEndpoint *bool  `yaml:"use_fips_endpoint,omitempty"` // Send STS and IAM requests to FIPS endpoints
+
+	ConsoleDuration int `yaml:"console_duration,omitempty"` // Console session length in seconds (900-43200) for 'console'
+
+	UpdateChannel string `yaml:"update_channel,omitempty"` // Release channel for 'update' (stable, beta)
+	UpdateCheck   *bool  `yaml:"update_check,omitempty"`   // Notify about new versions after commands (checked at most daily)
+
+	NoInput bool `yaml:"no_input,omitempty"` // Fail instead of prompting, like --no-input
+
+	PresetPublicKey string `yaml:"preset_public_key,omitempty"` // Ed25519 PEM public key verifying 'configure --from-url' presets
+
+	DiscoverAccountAliases bool `yaml:"discover_account_aliases,omitempty"` // Look up the account alias (iam:ListAccountAliases) after each login
+
+	CredentialStorage string `yaml:"credential_storage,omitempty"` // Where AWS credentials are stored (file, keyring)
+
+	ExpirationKeys []string `yaml:"expiration_keys,omitempty"` // Keys the credentials file records the expiration under
+
+	CacheFormat string `yaml:"cache_format,omitempty"` // Where chained role credentials are written (ini, cli)
 }
 
 // Profile represents an Azure AD SAML profile configuration
@@ -20,14 +75,128 @@ type Profile struct {
 	Username string `yaml:"username"` // Username/email
 
 	// AWS configuration
-	RoleARN string `yaml:"role_arn,omitempty"` // Preferred AWS role ARN
-	Region  string `yaml:"region,omitempty"`   // Override default region
-	Output  string `yaml:"output,omitempty"`   // AWS CLI output format (json, text, table)
+	RoleARN    string `yaml:"role_arn,omitempty"`    // Preferred AWS role ARN
+	RoleFilter string `yaml:"role_filter,omitempty"` // Regex a role ARN or name must match to be offered
+	Region     string `yaml:"region,omitempty"`      // Override default region
+	Partition  string `yaml:"partition,omitempty"`   // AWS partition (aws, aws-us-gov, aws-cn); inferred from region if empty
+	Output     string `yaml:"output,omitempty"`      // AWS CLI output format (json, yaml, yaml-stream, text, table)
 
 	// Optional overrides
 	SessionDuration int `yaml:"session_duration,omitempty"` // Override default session duration
+	ConsoleDuration int `yaml:"console_duration,omitempty"` // Override default console session length
+
+	// Session policies, limiting the session below the role's permissions
+	SessionPolicy string   `yaml:"session_policy,omitempty"` // JSON file with an inline session policy
+	PolicyARNs    []string `yaml:"policy_arns,omitempty"`    // ARNs of managed session policies
+
+	// Security
+	RequireMFA            bool   `yaml:"require_mfa,omitempty"`             // Fail login unless Azure AD challenged for MFA; no SAML cache
+	StaySignedIn          bool   `yaml:"stay_signed_in,omitempty"`          // Answer "Stay signed in?" with yes (persistent Azure AD session)
+	ADFSAuth              string `yaml:"adfs_auth,omitempty"`               // ADFS sign-in method (forms, wia)
+	HomeTenant            string `yaml:"home_tenant,omitempty"`             // Home tenant (domain or ID) of a B2B guest account
+	TenantID              string `yaml:"tenant_id,omitempty"`               // Tenant (ID or domain) of the application, scoping the sign-in
+	AzureCloud            string `yaml:"azure_cloud,omitempty"`             // Azure cloud (public, usgovernment, china)
+	SAMLValidation        string `yaml:"saml_validation,omitempty"`         // SAML signature validation mode (off, warn, fail)
+	SAMLSigningCert       string `yaml:"saml_signing_cert,omitempty"`       // Pinned PEM signing certificate file
+	FederationMetadataURL string `yaml:"federation_metadata_url,omitempty"` // Override tenant federation metadata URL
+	OnePasswordRef        string `yaml:"onepassword_ref,omitempty"`         // op:// reference to the password (1password keyring backend)
+	VaultPath             string `yaml:"vault_path,omitempty"`              // Vault KV path holding password and totp_seed (vault keyring backend)
+	CABundle              string `yaml:"ca_bundle,omitempty"`               // Override default CA bundle file
+	UserAgent             string `yaml:"user_agent,omitempty"`              // Override default User-Agent
+	ClientCert            string `yaml:"client_cert,omitempty"`             // Client certificate file (PEM, or .p12/.pfx) for certificate-based auth and mTLS
+	ClientKey             string `yaml:"client_key,omitempty"`              // PEM private key of client_cert
+	STSRegion             string `yaml:"sts_region,omitempty"`              // Override default STS endpoint region
+	UseFIPSEndpoint       *bool  `yaml:"use_fips_endpoint,omitempty"`       // Override default FIPS endpoint setting
+	STSEndpoint           string `yaml:"sts_endpoint,omitempty"`            // Custom STS endpoint URL (VPC endpoint, internal proxy)
+
+	// Network
+	HTTPTimeout    int  `yaml:"http_timeout,omitempty"`    // Override default request timeout (seconds)
+	ConnectTimeout int  `yaml:"connect_timeout,omitempty"` // Override default connect timeout (seconds)
+	MaxRetries     *int `yaml:"max_retries,omitempty"`     // Override default retry count
+
+	ThrottleRetries *int `yaml:"throttle_retries,omitempty"` // Override default throttling retry count
+
+	MFATimeout int `yaml:"mfa_timeout,omitempty"` // Override default MFA approval timeout (seconds)
+
+	MaxAuthSteps int `yaml:"max_auth_steps,omitempty"` // Override default sign-in step limit
+
+	AuthTimeout int `yaml:"auth_timeout,omitempty"` // Override default sign-in timeout (seconds)
+
+	CredentialStorage string `yaml:"credential_storage,omitempty"` // Override default credential storage
+
+	ExpirationKeys []string `yaml:"expiration_keys,omitempty"` // Override default expiration keys
+
+	CacheFormat string `yaml:"cache_format,omitempty"` // Override default cache format
+
+	// Role chaining
+	ChainedRoles []ChainedRole `yaml:"chained_roles,omitempty"` // Roles assumed from this profile's credentials
+	ChainMode    string        `yaml:"chain_mode,omitempty"`    // How chained roles are assumed (azure2aws, sdk)
 }
 
+// ChainedRole is a second-hop role assumed using a profile's SAML credentials
+type ChainedRole struct {
+	Profile string `yaml:"profile"`          // AWS profile name for the chained role
+	RoleARN string `yaml:"role_arn"`         // ARN of the role to assume
+	Region  string `yaml:"region,omitempty"` // Override region for the chained profile
+}
+
+// Chain modes
+const (
+	// ChainModeAzure2AWS assumes chained roles in azure2aws and writes their credentials
+	ChainModeAzure2AWS = "azure2aws"
+	// ChainModeSDK writes role_arn/source_profile entries so the AWS CLI/SDK assumes them
+	ChainModeSDK = "sdk"
+)
+
+// OutputFormats are the output formats accepted by the AWS CLI
+var OutputFormats = []string{"json", "yaml", "yaml-stream", "text", "table"}
+
+// SAML signature validation modes
+const (
+	// SAMLValidationOff skips signature validation
+	SAMLValidationOff = "off"
+	// SAMLValidationWarn prints a warning when validation fails
+	SAMLValidationWarn = "warn"
+	// SAMLValidationFail aborts login when validation fails
+	SAMLValidationFail = "fail"
+)
+
+// ADFS sign-in methods
+const (
+	// ADFSAuthForms submits the username and password to the ADFS form
+	ADFSAuthForms = "forms"
+	// ADFSAuthWIA signs in with the current Kerberos ticket (Windows
+	// Integrated Authentication)
+	ADFSAuthWIA = "wia"
+)
+
+// Credential storage locations
+const (
+	// CredentialStorageFile writes AWS credentials to ~/.aws/credentials
+	CredentialStorageFile = "file"
+	// CredentialStorageKeyring keeps AWS credentials in the keyring, where
+	// only azure2aws commands read them
+	CredentialStorageKeyring = "keyring"
+)
+
+// Cache formats of chained role credentials
+const (
+	// CacheFormatINI writes them to the credential storage like any profile
+	CacheFormatINI = "ini"
+	// CacheFormatCLI writes role_arn/source_profile entries and puts the
+	// credentials in the AWS CLI cache (~/.aws/cli/cache), where the CLI
+	// finds them for those role profiles
+	CacheFormatCLI = "cli"
+)
+
+// ExpirationKeys are the credentials file keys the expiration can be written
+// under: azure2aws's own, and those other tools (awsume, SDK credential
+// helpers, IDE plugins) read
+var ExpirationKeys = []string{"x_security_token_expires", "aws_expiration", "expiration"}
+
+// MaxPolicyARNs is the number of managed session policies STS accepts
+const MaxPolicyARNs = 10
+
 // MergedProfile returns a profile with defaults applied
 type MergedProfile struct {
 	Name            string
@@ -35,9 +204,53 @@ type MergedProfile struct {
 	AppID           string
 	Username        string
 	RoleARN         string
+	RoleFilter      string
 	Region          string
+	Partition       string
 	Output          string
 	SessionDuration int
+	SessionPolicy   string
+	PolicyARNs      []string
+	RequireMFA      bool
+	StaySignedIn    bool
+	ADFSAuth        string
+	HomeTenant      string
+	TenantID        string
+	AzureCloud      string
+	ChainedRoles    []ChainedRole
+	ChainMode       string
+
+	SAMLValidation        string
+	SAMLSigningCert       string
+	FederationMetadataURL string
+	CABundle              string
+	UserAgent             string
+	ClientCert            string
+	ClientKey             string
+	STSRegion             string
+	UseFIPSEndpoint       bool
+	STSEndpoint           string
+
+	HTTPTimeout    int  // Seconds; 0 uses the client default
+	ConnectTimeout int  // Seconds; 0 uses the client default
+	MaxRetries     *int // nil uses the client default
+
+	ThrottleRetries *int // nil uses the client default
+
+	MFATimeout int // Seconds; 0 uses the client default
+
+	MaxAuthSteps int // 0 uses the client default
+
+	AuthTimeout int // Seconds; 0 means no limit
+
+	CredentialStorage string
+	ExpirationKeys    []string
+	CacheFormat       string
+
+	RoleLabels map[string]string // Role ARN to label
+	Accounts   map[string]string // Account ID to name
+
+	DiscoverAccountAliases bool
 }
 
 // NewConfig creates a new configuration with sensible defaults
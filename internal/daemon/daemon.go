@@ -0,0 +1,190 @@
+// Package daemon serves credentials held in memory to other azure2aws
+// invocations over a unix socket, so that concurrent tools do not each read
+// the credentials file or the keyring.
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/user/azure2aws/internal/aws"
+	"github.com/user/azure2aws/internal/config"
+	"github.com/user/azure2aws/internal/logging"
+)
+
+// credentialsPath is the endpoint returning a profile's credentials
+const credentialsPath = "/credentials"
+
+// requestTimeout bounds a request to the daemon, which may log in again
+const requestTimeout = 2 * time.Minute
+
+// ErrNotRunning is returned by Fetch when no daemon listens on the socket
+var ErrNotRunning = errors.New("credential daemon is not running")
+
+// SocketPath returns the daemon socket: AZURE2AWS_DAEMON_SOCKET, or
+// ~/.azure2aws/daemon.sock. Windows 10 and later support unix sockets too.
+func SocketPath() (string, error) {
+	if path := os.Getenv(config.EnvDaemonSocket); path != "" {
+		return path, nil
+	}
+	dir, err := defaultDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "daemon.sock"), nil
+}
+
+// defaultDir returns ~/.azure2aws, the directory of the default socket
+func defaultDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".azure2aws"), nil
+}
+
+// CredentialsFunc returns valid credentials for a profile, refreshing them if
+// needed
+type CredentialsFunc func(profile string) (*aws.Credentials, error)
+
+// Server holds credentials in memory and serves them on a unix socket
+type Server struct {
+	credentials CredentialsFunc
+	store       *aws.MemoryStore
+}
+
+// NewServer creates a server obtaining credentials from fn
+func NewServer(fn CredentialsFunc) *Server {
+	return &Server{credentials: fn, store: aws.NewMemoryStore()}
+}
+
+// ListenAndServe serves on the socket at path until ctx is cancelled. The
+// socket's directory must only be writable by the current user. A stale
+// socket left by a daemon that did not shut down is replaced.
+func (s *Server) ListenAndServe(ctx context.Context, path string) error {
+	if err := prepareSocketDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	if _, err := Fetch(path, ""); !errors.Is(err, ErrNotRunning) {
+		return fmt.Errorf("a credential daemon is already running on %s", path)
+	}
+	_ = os.Remove(path)
+
+	listener, err := listen(path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	defer os.Remove(path)
+
+	srv := &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// get returns a profile's credentials from memory, asking the
+// CredentialsFunc when they are missing or about to expire
+func (s *Server) get(profile string) (*aws.Credentials, error) {
+	if !aws.Expired(s.store, profile) {
+		return s.store.Load(profile)
+	}
+
+	creds, err := s.credentials(profile)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.Save(profile, creds); err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	profile := r.URL.Query().Get("profile")
+	logging.Debug("Daemon credentials request", "method", r.Method, "path", r.URL.Path, "profile", profile)
+
+	if r.Method != http.MethodGet || r.URL.Path != credentialsPath {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if profile == "" {
+		// A liveness check
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	creds, err := s.get(profile)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(creds)
+}
+
+// Fetch asks the daemon listening on the socket at path for a profile's
+// credentials. It returns ErrNotRunning when there is none.
+func Fetch(path, profile string) (*aws.Credentials, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, ErrNotRunning
+	}
+
+	client := &http.Client{
+		Timeout: requestTimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		},
+	}
+
+	res, err := client.Get("http://daemon" + credentialsPath + "?profile=" + url.QueryEscape(profile))
+	if err != nil {
+		if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ENOENT) {
+			return nil, ErrNotRunning
+		}
+		return nil, fmt.Errorf("credential daemon request failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNoContent:
+		return nil, nil
+	default:
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+		return nil, fmt.Errorf("credential daemon: %s", strings.TrimSpace(string(body)))
+	}
+
+	var creds aws.Credentials
+	if err := json.NewDecoder(res.Body).Decode(&creds); err != nil {
+		return nil, fmt.Errorf("failed to decode daemon response: %w", err)
+	}
+	return &creds, nil
+}
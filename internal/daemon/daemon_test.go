@@ -0,0 +1,125 @@
+//go:build !windows
+
+package daemon
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/user/azure2aws/internal/aws"
+)
+
+// serve starts a server on the socket at path and waits until it answers
+func serve(t *testing.T, path string) {
+	t.Helper()
+
+	server := NewServer(func(profile string) (*aws.Credentials, error) {
+		return &aws.Credentials{AccessKeyID: "AKIA" + profile, SecretAccessKey: "secret", Expiration: time.Now().Add(time.Hour)}, nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- server.ListenAndServe(ctx, path) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Error("expected the socket to be removed on shutdown")
+		}
+	})
+
+	for i := 0; ; i++ {
+		if _, err := Fetch(path, ""); err == nil {
+			return
+		}
+		if i == 100 {
+			t.Fatal("daemon did not start")
+		}
+		select {
+		case err := <-done:
+			t.Fatalf("daemon stopped: %v", err)
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}
+
+func TestListenAndServeSocketPermissions(t *testing.T) {
+	// The socket must not depend on the umask of the caller
+	umask := syscall.Umask(0)
+	defer syscall.Umask(umask)
+
+	path := filepath.Join(t.TempDir(), "run", "daemon.sock")
+	serve(t, path)
+
+	info, err := os.Stat(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0700 {
+		t.Errorf("expected socket directory mode 0700, got %04o", perm)
+	}
+	info, err = os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("expected socket mode 0600, got %04o", perm)
+	}
+
+	creds, err := Fetch(path, "dev")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if creds.AccessKeyID != "AKIAdev" {
+		t.Errorf("expected the credentials of dev, got %s", creds.AccessKeyID)
+	}
+}
+
+func TestListenAndServeRejectsSharedDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "shared")
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	err := NewServer(nil).ListenAndServe(context.Background(), filepath.Join(dir, "daemon.sock"))
+	if err == nil {
+		t.Fatal("expected error for a directory writable by other users")
+	}
+	if _, statErr := os.Stat(filepath.Join(dir, "daemon.sock")); statErr == nil {
+		t.Error("expected no socket to be created")
+	}
+}
+
+func TestListenAndServeTightensDefaultDirectory(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := filepath.Join(home, ".azure2aws")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	serve(t, filepath.Join(dir, "daemon.sock"))
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0700 {
+		t.Errorf("expected ~/.azure2aws to be tightened to 0700, got %04o", perm)
+	}
+}
+
+func TestFetchNotRunning(t *testing.T) {
+	if _, err := Fetch(filepath.Join(t.TempDir(), "daemon.sock"), "dev"); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("expected ErrNotRunning, got %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+//go:build !windows
+
+package daemon
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// prepareSocketDir creates the socket directory with mode 0700, or checks
+// that an existing one belongs to the current user and that no one else can
+// write to it, so the socket cannot be replaced. ~/.azure2aws is tightened
+// to 0700.
+func prepareSocketDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create socket directory: %w", err)
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("failed to check socket directory: %w", err)
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && int(stat.Uid) != os.Getuid() {
+		return fmt.Errorf("socket directory %s belongs to another user", dir)
+	}
+
+	if info.Mode().Perm()&0077 == 0 {
+		return nil
+	}
+	if defaultDir, err := defaultDir(); err == nil && dir == defaultDir {
+		if err := os.Chmod(dir, 0700); err != nil {
+			return fmt.Errorf("failed to restrict socket directory permissions: %w", err)
+		}
+		return nil
+	}
+	if info.Mode().Perm()&0022 != 0 {
+		return fmt.Errorf("socket directory %s is writable by other users (mode %04o); use a directory only you can write to", dir, info.Mode().Perm())
+	}
+	return nil
+}
+
+// listen creates the unix socket at path with mode 0600 from the start,
+// rather than restricting it once other users could already connect
+func listen(path string) (net.Listener, error) {
+	umask := syscall.Umask(0177)
+	defer syscall.Umask(umask)
+	return net.Listen("unix", path)
+}
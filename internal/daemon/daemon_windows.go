@@ -0,0 +1,25 @@
+//go:build windows
+
+package daemon
+
+import (
+	"fmt"
+	"net"
+	"os"
+)
+
+// prepareSocketDir creates the socket directory. Windows has no file modes:
+// the socket gets the ACL of its directory, which in the user profile (such
+// as ~/.azure2aws) only grants access to the user, SYSTEM and administrators.
+func prepareSocketDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	return nil
+}
+
+// listen creates the unix socket at path (AF_UNIX, Windows 10 1803 and
+// later); named pipes are not used
+func listen(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
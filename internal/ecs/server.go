@@ -0,0 +1,147 @@
+package ecs
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/user/azure2aws/internal/aws"
+	"github.com/user/azure2aws/internal/logging"
+)
+
+const (
+	// DefaultPort is the default port of the credentials endpoint
+	DefaultPort = 9912
+
+	// CredentialsPath is the path credentials are served on
+	CredentialsPath = "/"
+)
+
+// CredentialsFunc returns valid credentials, refreshing them if needed
+type CredentialsFunc func() (*aws.Credentials, error)
+
+// Server serves credentials in the format of the ECS container credentials
+// endpoint (AWS_CONTAINER_CREDENTIALS_FULL_URI), guarded by a bearer token
+// (AWS_CONTAINER_AUTHORIZATION_TOKEN)
+type Server struct {
+	token       string
+	credentials CredentialsFunc
+}
+
+// NewServer creates a server with a random authorization token
+func NewServer(credentials CredentialsFunc) (*Server, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("failed to generate authorization token: %w", err)
+	}
+
+	return &Server{token: hex.EncodeToString(buf), credentials: credentials}, nil
+}
+
+// Token returns the value clients must send in the Authorization header
+func (s *Server) Token() string {
+	return s.token
+}
+
+// credentialsResponse is the document the AWS SDKs expect from the endpoint
+type credentialsResponse struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	Token           string `json:"Token"`
+	Expiration      string `json:"Expiration"`
+}
+
+// CheckAddr returns an error unless addr is a loopback address, the only
+// plain HTTP credentials endpoint the AWS SDKs accept
+func CheckAddr(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid listen address %q: %w", addr, err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("refusing to serve ECS credentials on %s: listen on a loopback address and run containers with --network host", addr)
+}
+
+// ListenAndServe serves on addr until ctx is cancelled
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	if err := CheckAddr(addr); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	srv := &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logging.Debug("ECS credentials request", "method", r.Method, "path", r.URL.Path, "remote", r.RemoteAddr)
+
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(s.token)) != 1 {
+		writeError(w, http.StatusForbidden, "invalid authorization token")
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if r.URL.Path != CredentialsPath {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	creds, err := s.credentials()
+	if err != nil {
+		logging.Debug("ECS credentials unavailable", "error", err)
+		writeError(w, http.StatusInternalServerError, "credentials unavailable")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(credentialsResponse{
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		Token:           creds.SessionToken,
+		Expiration:      creds.Expiration.UTC().Format(time.RFC3339),
+	})
+}
+
+// writeError writes an error in the shape the SDKs log ({"code","message"})
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"code":    http.StatusText(status),
+		"message": message,
+	})
+}
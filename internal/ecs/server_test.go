@@ -0,0 +1,69 @@
+package ecs
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/user/azure2aws/internal/aws"
+)
+
+func TestServeHTTP(t *testing.T) {
+	s, err := NewServer(func() (*aws.Credentials, error) {
+		return &aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "token", Expiration: time.Now().Add(time.Hour)}, nil
+	})
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		token    string
+		wantCode int
+	}{
+		{name: "credentials", method: http.MethodGet, path: CredentialsPath, token: s.Token(), wantCode: http.StatusOK},
+		{name: "no token", method: http.MethodGet, path: CredentialsPath, wantCode: http.StatusForbidden},
+		{name: "wrong token", method: http.MethodGet, path: CredentialsPath, token: "forged", wantCode: http.StatusForbidden},
+		{name: "wrong method", method: http.MethodPost, path: CredentialsPath, token: s.Token(), wantCode: http.StatusMethodNotAllowed},
+		{name: "wrong path", method: http.MethodGet, path: "/other", token: s.Token(), wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "http://127.0.0.1:9912"+tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", tt.token)
+			}
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected status %d, got %d", tt.wantCode, rec.Code)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var resp credentialsResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || resp.AccessKeyID != "AKID" {
+				t.Errorf("unexpected response %+v, %v", resp, err)
+			}
+		})
+	}
+}
+
+func TestCheckAddr(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1:9912", "localhost:9912", "[::1]:9912"} {
+		if err := CheckAddr(addr); err != nil {
+			t.Errorf("CheckAddr(%q) error = %v", addr, err)
+		}
+	}
+	for _, addr := range []string{":9912", "0.0.0.0:9912", "172.17.0.1:9912", "169.254.170.2:80"} {
+		if err := CheckAddr(addr); err == nil {
+			t.Errorf("CheckAddr(%q) expected error", addr)
+		}
+	}
+}
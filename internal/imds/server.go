@@ -0,0 +1,270 @@
+package imds
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/azure2aws/internal/aws"
+	"github.com/user/azure2aws/internal/logging"
+)
+
+const (
+	// DefaultAddr is the default listen address. SDKs are pointed at it with
+	// AWS_EC2_METADATA_SERVICE_ENDPOINT.
+	DefaultAddr = "127.0.0.1:9911"
+
+	// MetadataHost is the address of the real EC2 instance metadata service
+	MetadataHost = "169.254.169.254"
+
+	credentialsPath = "/latest/meta-data/iam/security-credentials/"
+	tokenPath       = "/latest/api/token"
+	regionPath      = "/latest/meta-data/placement/region"
+
+	tokenTTLHeader = "X-Aws-Ec2-Metadata-Token-Ttl-Seconds"
+	tokenHeader    = "X-Aws-Ec2-Metadata-Token"
+
+	// maxTokenTTL is the longest IMDSv2 session token lifetime EC2 accepts
+	maxTokenTTL = 6 * time.Hour
+)
+
+// CredentialsFunc returns valid credentials, refreshing them if needed
+type CredentialsFunc func() (*aws.Credentials, error)
+
+// Server serves credentials through the EC2 instance metadata endpoints used
+// by the AWS SDKs (IMDSv1 and IMDSv2). Only this machine may connect: it
+// listens on loopback or the metadata service address, and rejects requests
+// from other peers.
+type Server struct {
+	roleName    string
+	region      string
+	credentials CredentialsFunc
+	// requireToken rejects IMDSv1 requests, as on the metadata service
+	// address other hosts may route to the server
+	requireToken bool
+
+	mu     sync.Mutex
+	tokens map[string]time.Time
+}
+
+// NewServer creates a server that exposes credentials under roleName
+func NewServer(roleName, region string, credentials CredentialsFunc) *Server {
+	return &Server{
+		roleName:    roleName,
+		region:      region,
+		credentials: credentials,
+		tokens:      make(map[string]time.Time),
+	}
+}
+
+// credentialsResponse is the document returned for an instance profile role
+type credentialsResponse struct {
+	Code            string `json:"Code"`
+	LastUpdated     string `json:"LastUpdated"`
+	Type            string `json:"Type"`
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	Token           string `json:"Token"`
+	Expiration      string `json:"Expiration"`
+}
+
+// CheckAddr returns an error unless addr is a loopback address or the
+// metadata service address, the only ones the server may listen on
+func CheckAddr(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid listen address %q: %w", addr, err)
+	}
+	if host == MetadataHost || host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("refusing to serve instance metadata on %s: listen on a loopback address or %s", addr, MetadataHost)
+}
+
+// ListenAndServe serves on addr until ctx is cancelled. On the metadata
+// service address, IMDSv2 session tokens are required.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	if err := CheckAddr(addr); err != nil {
+		return err
+	}
+	if host, _, _ := net.SplitHostPort(addr); host == MetadataHost {
+		s.requireToken = true
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	srv := &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logging.Debug("IMDS request", "method", r.Method, "path", r.URL.Path, "remote", r.RemoteAddr)
+
+	// A browser page must not be able to read credentials through DNS rebinding
+	if !allowedHost(r.Host) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	// Nor may another host that routes to the address the server listens on
+	if !allowedRemote(r.RemoteAddr) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	if r.URL.Path == tokenPath {
+		s.handleToken(w, r)
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := r.Header.Get(tokenHeader)
+	if (token != "" || s.requireToken) && !s.validToken(token) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.URL.Path == credentialsPath || r.URL.Path == strings.TrimSuffix(credentialsPath, "/"):
+		writeText(w, s.roleName)
+	case r.URL.Path == credentialsPath+s.roleName:
+		s.handleCredentials(w)
+	case r.URL.Path == regionPath && s.region != "":
+		writeText(w, s.region)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleToken issues an IMDSv2 session token
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// Like EC2, refuse tokens to requests that went through a proxy
+	if r.Header.Get("X-Forwarded-For") != "" {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	seconds, err := strconv.Atoi(r.Header.Get(tokenTTLHeader))
+	ttl := time.Duration(seconds) * time.Second
+	if err != nil || ttl < time.Second || ttl > maxTokenTTL {
+		http.Error(w, "invalid token TTL", http.StatusBadRequest)
+		return
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	token := hex.EncodeToString(buf)
+
+	s.mu.Lock()
+	now := time.Now()
+	for t, expiresAt := range s.tokens {
+		if now.After(expiresAt) {
+			delete(s.tokens, t)
+		}
+	}
+	s.tokens[token] = now.Add(ttl)
+	s.mu.Unlock()
+
+	w.Header().Set(tokenTTLHeader, strconv.Itoa(seconds))
+	writeText(w, token)
+}
+
+func (s *Server) validToken(token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiresAt, ok := s.tokens[token]
+	return ok && time.Now().Before(expiresAt)
+}
+
+func (s *Server) handleCredentials(w http.ResponseWriter) {
+	creds, err := s.credentials()
+	if err != nil {
+		logging.Debug("IMDS credentials unavailable", "error", err)
+		http.Error(w, "credentials unavailable", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(credentialsResponse{
+		Code:            "Success",
+		LastUpdated:     time.Now().UTC().Format(time.RFC3339),
+		Type:            "AWS-HMAC",
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		Token:           creds.SessionToken,
+		Expiration:      creds.Expiration.UTC().Format(time.RFC3339),
+	})
+}
+
+// allowedHost accepts loopback and metadata service host names only
+func allowedHost(hostport string) bool {
+	host := hostport
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		host = h
+	}
+	if host == "localhost" || host == MetadataHost {
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
+
+// allowedRemote accepts peers on this machine only: loopback addresses, and
+// the metadata service address when it is a local alias
+func allowedRemote(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	if host == MetadataHost {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func writeText(w http.ResponseWriter, body string) {
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = w.Write([]byte(body))
+}
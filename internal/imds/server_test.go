@@ -0,0 +1,101 @@
+package imds
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/user/azure2aws/internal/aws"
+)
+
+func newTestServer() *Server {
+	return NewServer("production", "eu-west-1", func() (*aws.Credentials, error) {
+		return &aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "token", Expiration: time.Now().Add(time.Hour)}, nil
+	})
+}
+
+// serve sends a request to s from this machine unless remote is set
+func serve(s *Server, method, host, path, remote string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "http://"+host+path, nil)
+	req.RemoteAddr = "127.0.0.1:50000"
+	if remote != "" {
+		req.RemoteAddr = remote
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCheckAddr(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1:9911", "localhost:9911", "[::1]:9911", "169.254.169.254:80"} {
+		if err := CheckAddr(addr); err != nil {
+			t.Errorf("CheckAddr(%q) error = %v", addr, err)
+		}
+	}
+	for _, addr := range []string{":9911", "0.0.0.0:9911", "192.168.1.10:9911", "[::]:9911", "127.0.0.1"} {
+		if err := CheckAddr(addr); err == nil {
+			t.Errorf("CheckAddr(%q) expected error", addr)
+		}
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	const host = "127.0.0.1:9911"
+	s := newTestServer()
+
+	tokenResp := serve(s, http.MethodPut, host, tokenPath, "", http.Header{tokenTTLHeader: {"60"}})
+	if tokenResp.Code != http.StatusOK {
+		t.Fatalf("token request: expected 200, got %d", tokenResp.Code)
+	}
+	token := tokenResp.Body.String()
+
+	tests := []struct {
+		name     string
+		method   string
+		host     string
+		path     string
+		remote   string
+		header   http.Header
+		wantCode int
+	}{
+		{name: "IMDSv1", method: http.MethodGet, host: host, path: credentialsPath + "production", wantCode: http.StatusOK},
+		{name: "IMDSv2", method: http.MethodGet, host: host, path: credentialsPath + "production", header: http.Header{tokenHeader: {token}}, wantCode: http.StatusOK},
+		{name: "role list", method: http.MethodGet, host: host, path: credentialsPath, wantCode: http.StatusOK},
+		{name: "region", method: http.MethodGet, host: host, path: regionPath, wantCode: http.StatusOK},
+		{name: "invalid token", method: http.MethodGet, host: host, path: credentialsPath + "production", header: http.Header{tokenHeader: {"forged"}}, wantCode: http.StatusUnauthorized},
+		{name: "other role", method: http.MethodGet, host: host, path: credentialsPath + "admin", wantCode: http.StatusNotFound},
+		{name: "wrong method", method: http.MethodPost, host: host, path: credentialsPath + "production", wantCode: http.StatusMethodNotAllowed},
+		{name: "token by GET", method: http.MethodGet, host: host, path: tokenPath, header: http.Header{tokenTTLHeader: {"60"}}, wantCode: http.StatusMethodNotAllowed},
+		{name: "token through proxy", method: http.MethodPut, host: host, path: tokenPath, header: http.Header{tokenTTLHeader: {"60"}, "X-Forwarded-For": {"10.0.0.1"}}, wantCode: http.StatusForbidden},
+		{name: "rebound host", method: http.MethodGet, host: "evil.example:9911", path: credentialsPath + "production", wantCode: http.StatusForbidden},
+		{name: "remote peer", method: http.MethodGet, host: host, path: credentialsPath + "production", remote: "10.0.0.5:50000", wantCode: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serve(s, tt.method, tt.host, tt.path, tt.remote, tt.header); rec.Code != tt.wantCode {
+				t.Errorf("expected status %d, got %d", tt.wantCode, rec.Code)
+			}
+		})
+	}
+}
+
+func TestServeHTTPRequiresToken(t *testing.T) {
+	const host = MetadataHost
+	s := newTestServer()
+	s.requireToken = true
+
+	path := credentialsPath + "production"
+	if rec := serve(s, http.MethodGet, host, path, "", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("IMDSv1: expected status 401, got %d", rec.Code)
+	}
+
+	token := serve(s, http.MethodPut, host, tokenPath, "", http.Header{tokenTTLHeader: {"60"}}).Body.String()
+	if rec := serve(s, http.MethodGet, host, path, "", http.Header{tokenHeader: {token}}); rec.Code != http.StatusOK {
+		t.Errorf("IMDSv2: expected status 200, got %d", rec.Code)
+	}
+}
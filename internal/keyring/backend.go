@@ -0,0 +1,154 @@
+package keyring
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/user/azure2aws/internal/logging"
+	"github.com/user/azure2aws/internal/prompter"
+	"github.com/zalando/go-keyring"
+)
+
+// Backend names
+const (
+	// BackendSystem uses the OS keyring (macOS Keychain, Secret Service, Windows Credential Manager)
+	BackendSystem = "system"
+	// BackendPass uses the pass/GPG password store
+	BackendPass = "pass"
+	// BackendFile stores secrets in a passphrase-encrypted file
+	BackendFile = "file"
+	// BackendOnePassword uses the 1Password CLI (op)
+	BackendOnePassword = "1password"
+	// BackendVault uses a HashiCorp Vault KV secrets engine
+	BackendVault = "vault"
+)
+
+// EnvBackend selects the keyring backend, overriding keyring_backend in the config
+const EnvBackend = "AZURE2AWS_KEYRING_BACKEND"
+
+// Backend stores secrets by service and account
+type Backend interface {
+	// Name returns the backend name
+	Name() string
+	// Get returns the secret, or ErrPasswordNotFound if it does not exist
+	Get(service, account string) (string, error)
+	// Set stores the secret, replacing any existing value
+	Set(service, account, secret string) error
+	// Delete removes the secret, or returns ErrPasswordNotFound if it does not exist
+	Delete(service, account string) error
+}
+
+// totpSource is implemented by backends that can store an authenticator
+// (TOTP) seed alongside a password
+type totpSource interface {
+	TOTPSeed(service, account string) (string, error)
+}
+
+// availabilityChecker is implemented by backends that can report availability
+// without writing a test secret
+type availabilityChecker interface {
+	Available() bool
+}
+
+// Options configures backend-specific settings
+type Options struct {
+	// OnePasswordVault is the vault for items without an explicit reference
+	OnePasswordVault string
+	// OnePasswordRefs maps profile names to op:// secret references
+	OnePasswordRefs map[string]string
+
+	// VaultPath is the Vault KV base path for secrets without an explicit path
+	VaultPath string
+	// VaultPaths maps profile names to Vault KV paths
+	VaultPaths map[string]string
+
+	// Prompts asks for the master passphrase of the file backend; the
+	// default scope of the prompter package when nil
+	Prompts *prompter.Scope
+}
+
+var backendFactories = map[string]func(Options) (Backend, error){
+	BackendSystem:      func(Options) (Backend, error) { return systemBackend{}, nil },
+	BackendPass:        func(Options) (Backend, error) { return newPassBackend() },
+	BackendFile:        newFileBackend,
+	BackendOnePassword: newOnePasswordBackend,
+	BackendVault:       newVaultBackend,
+}
+
+// Backends returns the names of the supported backends
+func Backends() []string {
+	names := make([]string, 0, len(backendFactories))
+	for name := range backendFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewBackend creates the named backend. An empty name selects the system
+// keyring, falling back to the encrypted file backend when it is unavailable.
+// The system keyring is only probed with a read.
+func NewBackend(name string, opts Options) (Backend, error) {
+	if name == "" {
+		if (systemBackend{}).Available() {
+			return systemBackend{}, nil
+		}
+		logging.Info("System keyring unavailable; using the encrypted file backend")
+		path, err := DefaultSecretsPath()
+		if err != nil {
+			return nil, err
+		}
+		return &fileBackend{path: path, fallback: true, prompts: opts.Prompts}, nil
+	}
+
+	factory, ok := backendFactories[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown keyring backend %q (supported: %s)", name, strings.Join(Backends(), ", "))
+	}
+
+	return factory(opts)
+}
+
+// SelectBackend creates the named backend. AZURE2AWS_KEYRING_BACKEND, when
+// set, takes precedence over name.
+func SelectBackend(name string, opts Options) (Backend, error) {
+	if env := os.Getenv(EnvBackend); env != "" {
+		name = env
+	}
+	return NewBackend(name, opts)
+}
+
+// systemBackend stores secrets in the OS keyring via go-keyring
+type systemBackend struct{}
+
+func (systemBackend) Name() string { return BackendSystem }
+
+// Available reports whether the OS keyring answers a lookup, without
+// writing to it
+func (systemBackend) Available() bool {
+	_, err := keyring.Get(ServiceName, "__azure2aws_keyring_probe__")
+	return err == nil || errors.Is(err, keyring.ErrNotFound)
+}
+
+func (systemBackend) Get(service, account string) (string, error) {
+	secret, err := keyring.Get(service, account)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", ErrPasswordNotFound
+	}
+	return secret, err
+}
+
+func (systemBackend) Set(service, account, secret string) error {
+	return keyring.Set(service, account, secret)
+}
+
+func (systemBackend) Delete(service, account string) error {
+	err := keyring.Delete(service, account)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return ErrPasswordNotFound
+	}
+	return err
+}
@@ -0,0 +1,237 @@
+package keyring
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/user/azure2aws/internal/logging"
+	"github.com/user/azure2aws/internal/prompter"
+	"github.com/user/azure2aws/internal/secretbox"
+)
+
+const (
+	// EnvFilePassphrase supplies the master passphrase for the file backend
+	EnvFilePassphrase = "AZURE2AWS_KEYRING_PASSPHRASE"
+
+	secretsFileHeader = "azure2aws-secrets-v1"
+)
+
+// fileBackend stores secrets in an AES-encrypted file protected by a master
+// passphrase, for systems without an OS keyring
+type fileBackend struct {
+	path string
+	// fallback is set when the backend was chosen because the system
+	// keyring is unavailable
+	fallback bool
+	// prompts asks for the master passphrase; prompter.For("") when nil
+	prompts *prompter.Scope
+
+	mu         sync.Mutex
+	passphrase string
+}
+
+func newFileBackend(opts Options) (Backend, error) {
+	path, err := DefaultSecretsPath()
+	if err != nil {
+		return nil, err
+	}
+	return &fileBackend{path: path, prompts: opts.Prompts}, nil
+}
+
+// DefaultSecretsPath returns the encrypted secrets file used by the file backend
+func DefaultSecretsPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".azure2aws", "secrets.enc"), nil
+}
+
+func (f *fileBackend) Name() string { return BackendFile }
+
+// Available reports whether the secrets file can be created. It does not
+// prompt for the passphrase.
+func (f *fileBackend) Available() bool {
+	dir := filepath.Dir(f.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return false
+	}
+
+	tmp, err := os.CreateTemp(dir, ".secrets-test-*")
+	if err != nil {
+		return false
+	}
+	tmp.Close()
+	os.Remove(tmp.Name())
+	return true
+}
+
+func (f *fileBackend) Get(service, account string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	secrets, err := f.load()
+	if err != nil {
+		return "", err
+	}
+
+	secret, ok := secrets[service][account]
+	if !ok {
+		return "", ErrPasswordNotFound
+	}
+	return secret, nil
+}
+
+func (f *fileBackend) Set(service, account, secret string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.fallback {
+		logging.Warn("The system keyring is unavailable; storing the secret in " + f.path + " (set keyring_backend to choose a backend)")
+	}
+
+	secrets, err := f.load()
+	if err != nil {
+		return err
+	}
+
+	if secrets[service] == nil {
+		secrets[service] = make(map[string]string)
+	}
+	secrets[service][account] = secret
+
+	return f.save(secrets)
+}
+
+func (f *fileBackend) Delete(service, account string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	secrets, err := f.load()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := secrets[service][account]; !ok {
+		return ErrPasswordNotFound
+	}
+	delete(secrets[service], account)
+	if len(secrets[service]) == 0 {
+		delete(secrets, service)
+	}
+
+	return f.save(secrets)
+}
+
+// load decrypts the secrets file. A missing file is an empty store and does
+// not require the passphrase.
+func (f *fileBackend) load() (map[string]map[string]string, error) {
+	secrets := make(map[string]map[string]string)
+
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return secrets, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets file: %w", err)
+	}
+
+	passphrase, err := f.masterPassphrase(false)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := secretbox.Open(secretsFileHeader, data, passphrase)
+	if err != nil {
+		// Don't keep a wrong passphrase for the rest of the process
+		f.passphrase = ""
+		return nil, fmt.Errorf("failed to decrypt %s: %w", f.path, err)
+	}
+
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse secrets file: %w", err)
+	}
+	return secrets, nil
+}
+
+func (f *fileBackend) save(secrets map[string]map[string]string) error {
+	_, statErr := os.Stat(f.path)
+	passphrase, err := f.masterPassphrase(errors.Is(statErr, os.ErrNotExist))
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return fmt.Errorf("failed to marshal secrets: %w", err)
+	}
+
+	data, err := secretbox.Seal(secretsFileHeader, plaintext, passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt secrets: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.path), 0700); err != nil {
+		return fmt.Errorf("failed to create secrets directory: %w", err)
+	}
+
+	// Write to a temp file and rename so a failed write never truncates the store
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write secrets file: %w", err)
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write secrets file: %w", err)
+	}
+
+	return nil
+}
+
+// masterPassphrase returns the passphrase from AZURE2AWS_KEYRING_PASSPHRASE or
+// a prompt, cached for the process. When creating the file, the prompted
+// passphrase is confirmed.
+func (f *fileBackend) masterPassphrase(create bool) (string, error) {
+	if f.passphrase != "" {
+		return f.passphrase, nil
+	}
+
+	if passphrase := os.Getenv(EnvFilePassphrase); passphrase != "" {
+		f.passphrase = passphrase
+		return passphrase, nil
+	}
+
+	prompt := fmt.Sprintf("Master passphrase for %s", f.path)
+	if create {
+		prompt = fmt.Sprintf("New master passphrase for %s", f.path)
+	}
+
+	prompts := f.prompts
+	if prompts == nil {
+		prompts = prompter.For("")
+	}
+	passphrase, err := prompts.Password(prompt)
+	if err != nil {
+		return "", fmt.Errorf("failed to read master passphrase: %w", err)
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("a master passphrase is required for the file keyring")
+	}
+
+	if create {
+		confirm, err := prompts.Password("Confirm master passphrase")
+		if err != nil {
+			return "", fmt.Errorf("failed to read master passphrase: %w", err)
+		}
+		if confirm != passphrase {
+			return "", fmt.Errorf("master passphrases do not match")
+		}
+	}
+
+	f.passphrase = passphrase
+	return passphrase, nil
+}
@@ -0,0 +1,109 @@
+package keyring
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/user/azure2aws/internal/secretbox"
+)
+
+func TestFileBackend(t *testing.T) {
+	t.Setenv(EnvFilePassphrase, "passphrase")
+	path := filepath.Join(t.TempDir(), "azure2aws", "secrets.enc")
+	f := &fileBackend{path: path}
+
+	if err := f.Set("azure2aws", "dev", "s3cr3t-value"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := f.Set("azure2aws", "prod", "other"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 && os.PathSeparator == '/' {
+		t.Errorf("expected mode 0600, got %o", perm)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !secretbox.IsSealed(secretsFileHeader, data) || strings.Contains(string(data), "s3cr3t-value") {
+		t.Errorf("expected an encrypted secrets file, got %q", data)
+	}
+
+	// A new backend reads the file back
+	f = &fileBackend{path: path}
+	tests := []struct {
+		account string
+		want    string
+		wantErr error
+	}{
+		{account: "dev", want: "s3cr3t-value"},
+		{account: "prod", want: "other"},
+		{account: "missing", wantErr: ErrPasswordNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.account, func(t *testing.T) {
+			got, err := f.Get("azure2aws", tt.account)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Get: expected %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if err := f.Delete("azure2aws", "dev"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := f.Get("azure2aws", "dev"); !errors.Is(err, ErrPasswordNotFound) {
+		t.Errorf("expected the deleted secret to be gone, got %v", err)
+	}
+	if err := f.Delete("azure2aws", "dev"); !errors.Is(err, ErrPasswordNotFound) {
+		t.Errorf("expected ErrPasswordNotFound deleting it again, got %v", err)
+	}
+	if got, err := f.Get("azure2aws", "prod"); err != nil || got != "other" {
+		t.Errorf("expected the other secret to be kept, got %q, %v", got, err)
+	}
+}
+
+func TestFileBackendWrongPassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.enc")
+	t.Setenv(EnvFilePassphrase, "passphrase")
+	if err := (&fileBackend{path: path}).Set("azure2aws", "dev", "s3cr3t-value"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(EnvFilePassphrase, "wrong")
+	f := &fileBackend{path: path}
+	if _, err := f.Get("azure2aws", "dev"); !errors.Is(err, secretbox.ErrWrongPassphrase) {
+		t.Errorf("expected ErrWrongPassphrase, got %v", err)
+	}
+	if f.passphrase != "" {
+		t.Error("expected the wrong passphrase not to be kept")
+	}
+}
+
+func TestFileBackendMissingFile(t *testing.T) {
+	// Without the file no passphrase is needed: none is set, and a prompt
+	// would fail without a terminal
+	t.Setenv(EnvFilePassphrase, "")
+	f := &fileBackend{path: filepath.Join(t.TempDir(), "secrets.enc")}
+
+	if _, err := f.Get("azure2aws", "dev"); !errors.Is(err, ErrPasswordNotFound) {
+		t.Errorf("Get: expected ErrPasswordNotFound, got %v", err)
+	}
+	if err := f.Delete("azure2aws", "dev"); !errors.Is(err, ErrPasswordNotFound) {
+		t.Errorf("Delete: expected ErrPasswordNotFound, got %v", err)
+	}
+	if f.passphrase != "" {
+		t.Error("expected no passphrase to be asked for")
+	}
+}
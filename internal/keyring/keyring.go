@@ -3,8 +3,6 @@ package keyring
 import (
 	"errors"
 	"fmt"
-
-	"github.com/zalando/go-keyring"
 )
 
 const (
@@ -22,25 +20,37 @@ var (
 // Keyring provides password storage operations
 type Keyring struct {
 	serviceName string
+	backend     Backend
 }
 
-// New creates a new Keyring instance
-func New() *Keyring {
-	return &Keyring{
-		serviceName: ServiceName,
-	}
+// New creates a Keyring storing passwords in backend
+func New(backend Backend) *Keyring {
+	return NewWithBackend(ServiceName, backend)
 }
 
-// NewWithService creates a new Keyring with a custom service name (useful for testing)
-func NewWithService(serviceName string) *Keyring {
+// NewWithBackend creates a Keyring storing the secrets of a service in backend
+func NewWithBackend(serviceName string, backend Backend) *Keyring {
 	return &Keyring{
 		serviceName: serviceName,
+		backend:     backend,
 	}
 }
 
+// Backend returns the name of the backend storing passwords
+func (k *Keyring) Backend() string {
+	return k.backend.Name()
+}
+
+// Fallback reports whether the backend is the encrypted file the automatic
+// choice fell back to because the system keyring is unavailable
+func (k *Keyring) Fallback() bool {
+	file, ok := k.backend.(*fileBackend)
+	return ok && file.fallback
+}
+
 // SavePassword stores a password for the given profile
 func (k *Keyring) SavePassword(profile, password string) error {
-	if err := keyring.Set(k.serviceName, profile, password); err != nil {
+	if err := k.backend.Set(k.serviceName, profile, password); err != nil {
 		return fmt.Errorf("failed to save password: %w", err)
 	}
 	return nil
@@ -48,9 +58,9 @@ func (k *Keyring) SavePassword(profile, password string) error {
 
 // GetPassword retrieves a password for the given profile
 func (k *Keyring) GetPassword(profile string) (string, error) {
-	password, err := keyring.Get(k.serviceName, profile)
+	password, err := k.backend.Get(k.serviceName, profile)
 	if err != nil {
-		if errors.Is(err, keyring.ErrNotFound) {
+		if errors.Is(err, ErrPasswordNotFound) {
 			return "", ErrPasswordNotFound
 		}
 		return "", fmt.Errorf("failed to get password: %w", err)
@@ -60,8 +70,8 @@ func (k *Keyring) GetPassword(profile string) (string, error) {
 
 // DeletePassword removes a password for the given profile
 func (k *Keyring) DeletePassword(profile string) error {
-	if err := keyring.Delete(k.serviceName, profile); err != nil {
-		if errors.Is(err, keyring.ErrNotFound) {
+	if err := k.backend.Delete(k.serviceName, profile); err != nil {
+		if errors.Is(err, ErrPasswordNotFound) {
 			return ErrPasswordNotFound
 		}
 		return fmt.Errorf("failed to delete password: %w", err)
@@ -69,6 +79,16 @@ func (k *Keyring) DeletePassword(profile string) error {
 	return nil
 }
 
+// GetTOTPSeed retrieves the authenticator (TOTP) seed for the given profile,
+// or ErrPasswordNotFound if the backend has none
+func (k *Keyring) GetTOTPSeed(profile string) (string, error) {
+	source, ok := k.backend.(totpSource)
+	if !ok {
+		return "", ErrPasswordNotFound
+	}
+	return source.TOTPSeed(k.serviceName, profile)
+}
+
 // HasPassword checks if a password exists for the given profile
 func (k *Keyring) HasPassword(profile string) bool {
 	_, err := k.GetPassword(profile)
@@ -77,44 +97,11 @@ func (k *Keyring) HasPassword(profile string) bool {
 
 // IsAvailable checks if the keyring is available on this system
 func (k *Keyring) IsAvailable() bool {
-	// Try to perform a no-op operation to check availability
-	// We use a test key that we immediately clean up
-	testKey := "__azure2aws_keyring_test__"
-	testValue := "test"
-
-	err := keyring.Set(k.serviceName, testKey, testValue)
-	if err != nil {
-		return false
+	if checker, ok := k.backend.(availabilityChecker); ok {
+		return checker.Available()
 	}
 
-	// Clean up test key
-	_ = keyring.Delete(k.serviceName, testKey)
-	return true
-}
-
-// Package-level convenience functions
-
-// SavePassword stores a password using the default service name
-func SavePassword(profile, password string) error {
-	return New().SavePassword(profile, password)
-}
-
-// GetPassword retrieves a password using the default service name
-func GetPassword(profile string) (string, error) {
-	return New().GetPassword(profile)
-}
-
-// DeletePassword removes a password using the default service name
-func DeletePassword(profile string) error {
-	return New().DeletePassword(profile)
-}
-
-// HasPassword checks if a password exists using the default service name
-func HasPassword(profile string) bool {
-	return New().HasPassword(profile)
-}
-
-// IsAvailable checks if keyring is available using the default service name
-func IsAvailable() bool {
-	return New().IsAvailable()
+	// Look up a key that does not exist, so nothing is written
+	_, err := k.backend.Get(k.serviceName, "__azure2aws_keyring_probe__")
+	return err == nil || errors.Is(err, ErrPasswordNotFound)
 }
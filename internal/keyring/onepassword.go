@@ -0,0 +1,223 @@
+package keyring
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// onePasswordBackend stores secrets in 1Password via the op CLI. Profiles
+// with a configured op:// reference read and write that field; everything
+// else uses a Password item titled "<service>/<account>".
+type onePasswordBackend struct {
+	path  string
+	vault string
+	refs  map[string]string
+}
+
+// onePasswordRef is a parsed op://vault/item[/section]/field reference
+type onePasswordRef struct {
+	vault   string
+	item    string
+	section string
+	field   string
+}
+
+func newOnePasswordBackend(opts Options) (Backend, error) {
+	path, err := exec.LookPath("op")
+	if err != nil {
+		return nil, fmt.Errorf("1password keyring backend requires the 1Password CLI ('op'): %w", err)
+	}
+
+	for profile, ref := range opts.OnePasswordRefs {
+		if _, err := parseOnePasswordRef(ref); err != nil {
+			return nil, fmt.Errorf("invalid onepassword_ref for profile %s: %w", profile, err)
+		}
+	}
+
+	return &onePasswordBackend{
+		path:  path,
+		vault: opts.OnePasswordVault,
+		refs:  opts.OnePasswordRefs,
+	}, nil
+}
+
+func (o *onePasswordBackend) Name() string { return BackendOnePassword }
+
+// Available reports whether op is signed in
+func (o *onePasswordBackend) Available() bool {
+	_, err := o.run(nil, "whoami")
+	return err == nil
+}
+
+func (o *onePasswordBackend) Get(service, account string) (string, error) {
+	ref := o.resolve(service, account)
+
+	args := []string{"item", "get", ref.item, "--fields", "label=" + ref.fieldPath(), "--reveal"}
+	args = append(args, o.vaultArgs(ref)...)
+
+	out, err := o.run(nil, args...)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
+
+func (o *onePasswordBackend) Set(service, account, secret string) error {
+	ref := o.resolve(service, account)
+
+	current, err := o.run(nil, append([]string{"item", "get", ref.item, "--format", "json"}, o.vaultArgs(ref)...)...)
+	if err == nil {
+		// Edit the item from its JSON on stdin so the secret stays out of argv
+		var item map[string]interface{}
+		if err := json.Unmarshal(current, &item); err != nil {
+			return fmt.Errorf("failed to parse 1Password item: %w", err)
+		}
+		setOnePasswordField(item, ref, secret)
+		data, err := json.Marshal(item)
+		if err != nil {
+			return fmt.Errorf("failed to build 1Password item: %w", err)
+		}
+		_, err = o.run(bytes.NewReader(data), append([]string{"item", "edit", ref.item}, o.vaultArgs(ref)...)...)
+		return err
+	} else if err != ErrPasswordNotFound {
+		return err
+	}
+
+	// Create the item from a template on stdin so the secret stays out of argv
+	template := map[string]interface{}{
+		"title":    ref.item,
+		"category": "PASSWORD",
+		"fields": []map[string]string{{
+			"id":      "password",
+			"type":    "CONCEALED",
+			"purpose": "PASSWORD",
+			"label":   ref.field,
+			"value":   secret,
+		}},
+	}
+	data, err := json.Marshal(template)
+	if err != nil {
+		return fmt.Errorf("failed to build 1Password item: %w", err)
+	}
+
+	args := append([]string{"item", "create"}, o.vaultArgs(ref)...)
+	_, err = o.run(bytes.NewReader(data), append(args, "-")...)
+	return err
+}
+
+func (o *onePasswordBackend) Delete(service, account string) error {
+	if service == ServiceName && o.refs[account] != "" {
+		return fmt.Errorf("the password for profile %s is managed in 1Password (%s); remove it there", account, o.refs[account])
+	}
+
+	ref := o.resolve(service, account)
+	_, err := o.run(nil, append([]string{"item", "delete", ref.item}, o.vaultArgs(ref)...)...)
+	return err
+}
+
+// setOnePasswordField sets the value of the referenced field in an item as
+// printed by 'op item get --format json', adding a concealed field if the
+// item has none
+func setOnePasswordField(item map[string]interface{}, ref onePasswordRef, secret string) {
+	fields, _ := item["fields"].([]interface{})
+	for _, f := range fields {
+		field, ok := f.(map[string]interface{})
+		if !ok || field["label"] != ref.field {
+			continue
+		}
+		section, _ := field["section"].(map[string]interface{})
+		if ref.section != "" && (section == nil || section["label"] != ref.section) {
+			continue
+		}
+		field["value"] = secret
+		return
+	}
+
+	field := map[string]interface{}{"type": "CONCEALED", "label": ref.field, "value": secret}
+	if ref.section != "" {
+		field["section"] = map[string]interface{}{"label": ref.section}
+	}
+	item["fields"] = append(fields, field)
+}
+
+// resolve returns the item reference for a secret
+func (o *onePasswordBackend) resolve(service, account string) onePasswordRef {
+	if service == ServiceName {
+		if raw := o.refs[account]; raw != "" {
+			ref, _ := parseOnePasswordRef(raw)
+			return ref
+		}
+	}
+
+	return onePasswordRef{
+		vault: o.vault,
+		item:  service + "/" + account,
+		field: "password",
+	}
+}
+
+// fieldPath returns the field in op's [section.]field notation
+func (r onePasswordRef) fieldPath() string {
+	if r.section != "" {
+		return r.section + "." + r.field
+	}
+	return r.field
+}
+
+func (o *onePasswordBackend) vaultArgs(ref onePasswordRef) []string {
+	if ref.vault == "" {
+		return nil
+	}
+	return []string{"--vault", ref.vault}
+}
+
+func (o *onePasswordBackend) run(stdin io.Reader, args ...string) ([]byte, error) {
+	cmd := exec.Command(o.path, args...)
+	cmd.Stdin = stdin
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if strings.Contains(msg, "isn't an item") || strings.Contains(msg, "isn't a field") {
+			return nil, ErrPasswordNotFound
+		}
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("op %s failed: %s", strings.Join(args[:min(2, len(args))], " "), msg)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// parseOnePasswordRef parses an op://vault/item[/section]/field secret reference
+func parseOnePasswordRef(raw string) (onePasswordRef, error) {
+	rest, ok := strings.CutPrefix(raw, "op://")
+	if !ok {
+		return onePasswordRef{}, fmt.Errorf("%q is not an op:// reference", raw)
+	}
+
+	parts := strings.Split(rest, "/")
+	for _, part := range parts {
+		if part == "" {
+			return onePasswordRef{}, fmt.Errorf("%q has an empty path segment", raw)
+		}
+	}
+
+	switch len(parts) {
+	case 3:
+		return onePasswordRef{vault: parts[0], item: parts[1], field: parts[2]}, nil
+	case 4:
+		return onePasswordRef{vault: parts[0], item: parts[1], section: parts[2], field: parts[3]}, nil
+	default:
+		return onePasswordRef{}, fmt.Errorf("%q must be op://vault/item/field or op://vault/item/section/field", raw)
+	}
+}
@@ -0,0 +1,82 @@
+package keyring
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strings"
+)
+
+// passBackend stores secrets in the pass/GPG password store as
+// <service>/<account> entries
+type passBackend struct {
+	path string
+}
+
+func newPassBackend() (Backend, error) {
+	path, err := exec.LookPath("pass")
+	if err != nil {
+		return nil, fmt.Errorf("pass keyring backend requires the 'pass' command: %w", err)
+	}
+	return &passBackend{path: path}, nil
+}
+
+func (p *passBackend) Name() string { return BackendPass }
+
+// Available reports whether the password store is initialised
+func (p *passBackend) Available() bool {
+	_, err := p.run(nil, "ls")
+	return err == nil
+}
+
+func (p *passBackend) Get(service, account string) (string, error) {
+	out, err := p.run(nil, "show", passEntry(service, account))
+	if err != nil {
+		return "", err
+	}
+
+	// pass convention: the secret is the first line of the entry
+	secret, _, _ := strings.Cut(string(out), "\n")
+	return secret, nil
+}
+
+func (p *passBackend) Set(service, account, secret string) error {
+	_, err := p.run(strings.NewReader(secret+"\n"), "insert", "--multiline", "--force", passEntry(service, account))
+	return err
+}
+
+func (p *passBackend) Delete(service, account string) error {
+	_, err := p.run(nil, "rm", "--force", passEntry(service, account))
+	return err
+}
+
+func (p *passBackend) run(stdin *strings.Reader, args ...string) ([]byte, error) {
+	cmd := exec.Command(p.path, args...)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if strings.Contains(msg, "is not in the password store") {
+			return nil, ErrPasswordNotFound
+		}
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("pass %s failed: %s", args[0], msg)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// passEntry returns the password store path for a secret. Accounts may contain
+// slashes (e.g. file paths), so they are escaped into a single path segment.
+func passEntry(service, account string) string {
+	return service + "/" + url.PathEscape(account)
+}
@@ -0,0 +1,316 @@
+package keyring
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Vault KV fields read and written by the vault backend
+const (
+	vaultPasswordField = "password"
+	vaultTOTPSeedField = "totp_seed"
+)
+
+// vaultBackend stores secrets in a HashiCorp Vault KV (v1 or v2) secrets
+// engine using VAULT_ADDR and VAULT_TOKEN. Profiles with a configured path
+// keep their password (and optional TOTP seed) there; other secrets are stored
+// under the default base path.
+type vaultBackend struct {
+	addr      string
+	token     string
+	namespace string
+	basePath  string
+	paths     map[string]string
+	client    *http.Client
+}
+
+// vaultMount describes the secrets engine a path belongs to
+type vaultMount struct {
+	Path    string `json:"path"`
+	Options struct {
+		Version string `json:"version"`
+	} `json:"options"`
+}
+
+func newVaultBackend(opts Options) (Backend, error) {
+	addr := strings.TrimRight(os.Getenv("VAULT_ADDR"), "/")
+	if addr == "" {
+		return nil, fmt.Errorf("vault keyring backend requires VAULT_ADDR")
+	}
+
+	token := os.Getenv("VAULT_TOKEN")
+	if token == "" {
+		// Token helper file written by 'vault login'
+		if home, err := os.UserHomeDir(); err == nil {
+			if data, err := os.ReadFile(filepath.Join(home, ".vault-token")); err == nil {
+				token = strings.TrimSpace(string(data))
+			}
+		}
+	}
+	if token == "" {
+		return nil, fmt.Errorf("vault keyring backend requires VAULT_TOKEN or 'vault login'")
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if caCert := os.Getenv("VAULT_CACERT"); caCert != "" {
+		pem, err := os.ReadFile(caCert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read VAULT_CACERT: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in VAULT_CACERT %s", caCert)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &vaultBackend{
+		addr:      addr,
+		token:     token,
+		namespace: os.Getenv("VAULT_NAMESPACE"),
+		basePath:  strings.Trim(opts.VaultPath, "/"),
+		paths:     opts.VaultPaths,
+		client: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: transport,
+		},
+	}, nil
+}
+
+func (v *vaultBackend) Name() string { return BackendVault }
+
+// Available reports whether the token is valid
+func (v *vaultBackend) Available() bool {
+	_, err := v.request(http.MethodGet, "auth/token/lookup-self", nil)
+	return err == nil
+}
+
+func (v *vaultBackend) Get(service, account string) (string, error) {
+	path, err := v.secretPath(service, account)
+	if err != nil {
+		return "", ErrPasswordNotFound
+	}
+
+	data, err := v.read(path)
+	if err != nil {
+		return "", err
+	}
+
+	secret, ok := data[vaultPasswordField].(string)
+	if !ok || secret == "" {
+		return "", ErrPasswordNotFound
+	}
+	return secret, nil
+}
+
+// TOTPSeed returns the authenticator seed stored next to a profile's password
+func (v *vaultBackend) TOTPSeed(service, account string) (string, error) {
+	path, err := v.secretPath(service, account)
+	if err != nil {
+		return "", ErrPasswordNotFound
+	}
+
+	data, err := v.read(path)
+	if err != nil {
+		return "", err
+	}
+
+	seed, ok := data[vaultTOTPSeedField].(string)
+	if !ok || seed == "" {
+		return "", ErrPasswordNotFound
+	}
+	return seed, nil
+}
+
+func (v *vaultBackend) Set(service, account, secret string) error {
+	path, err := v.secretPath(service, account)
+	if err != nil {
+		return err
+	}
+
+	// Keep other fields (e.g. totp_seed) stored at the same path
+	data, err := v.read(path)
+	if err != nil && err != ErrPasswordNotFound {
+		return err
+	}
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	data[vaultPasswordField] = secret
+
+	return v.write(path, data)
+}
+
+func (v *vaultBackend) Delete(service, account string) error {
+	path, err := v.secretPath(service, account)
+	if err != nil {
+		return ErrPasswordNotFound
+	}
+
+	data, err := v.read(path)
+	if err != nil {
+		return err
+	}
+	if _, ok := data[vaultPasswordField]; !ok {
+		return ErrPasswordNotFound
+	}
+	delete(data, vaultPasswordField)
+
+	if len(data) > 0 {
+		return v.write(path, data)
+	}
+
+	apiPath, _, err := v.apiPath(path)
+	if err != nil {
+		return err
+	}
+	_, err = v.request(http.MethodDelete, apiPath, nil)
+	return err
+}
+
+// secretPath returns the KV path holding a secret
+func (v *vaultBackend) secretPath(service, account string) (string, error) {
+	if service == ServiceName {
+		if path := v.paths[account]; path != "" {
+			return strings.Trim(path, "/"), nil
+		}
+	}
+
+	if v.basePath == "" {
+		return "", fmt.Errorf("no vault_path configured for %s/%s", service, account)
+	}
+
+	// Accounts may be file paths; keep them a single path segment
+	return v.basePath + "/" + service + "/" + strings.ReplaceAll(strings.Trim(account, "/"), "/", "_"), nil
+}
+
+// read returns the fields stored at a KV path
+func (v *vaultBackend) read(path string) (map[string]interface{}, error) {
+	apiPath, kvV2, err := v.apiPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := v.request(http.MethodGet, apiPath, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse vault response: %w", err)
+	}
+
+	if !kvV2 {
+		return resp.Data, nil
+	}
+
+	// KV v2 nests the secret under data.data; deleted versions have no data
+	data, ok := resp.Data["data"].(map[string]interface{})
+	if !ok {
+		return nil, ErrPasswordNotFound
+	}
+	return data, nil
+}
+
+// write replaces the fields stored at a KV path
+func (v *vaultBackend) write(path string, data map[string]interface{}) error {
+	apiPath, kvV2, err := v.apiPath(path)
+	if err != nil {
+		return err
+	}
+
+	var payload interface{} = data
+	if kvV2 {
+		payload = map[string]interface{}{"data": data}
+	}
+
+	_, err = v.request(http.MethodPost, apiPath, payload)
+	return err
+}
+
+// apiPath maps a CLI-style KV path (as used with 'vault kv get') to its HTTP
+// API path, inserting "data/" for KV v2 mounts
+func (v *vaultBackend) apiPath(path string) (string, bool, error) {
+	body, err := v.request(http.MethodGet, "sys/internal/ui/mounts/"+path, nil)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to look up vault mount for %s: %w", path, err)
+	}
+
+	var resp struct {
+		Data vaultMount `json:"data"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", false, fmt.Errorf("failed to parse vault mount: %w", err)
+	}
+
+	mount := resp.Data.Path
+	if resp.Data.Options.Version != "2" || mount == "" {
+		return path, false, nil
+	}
+
+	rest := strings.TrimPrefix(path, strings.TrimSuffix(mount, "/"))
+	return mount + "data/" + strings.TrimPrefix(rest, "/"), true, nil
+}
+
+// request performs a Vault API call and returns the response body
+func (v *vaultBackend) request(method, path string, payload interface{}) ([]byte, error) {
+	var reqBody io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal vault request: %w", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, v.addr+"/v1/"+path, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vault request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	req.Header.Set("X-Vault-Request", "true")
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault response: %w", err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, ErrPasswordNotFound
+	case resp.StatusCode >= 300:
+		var errResp struct {
+			Errors []string `json:"errors"`
+		}
+		if json.Unmarshal(body, &errResp) == nil && len(errResp.Errors) > 0 {
+			return nil, fmt.Errorf("vault returned status %d: %s", resp.StatusCode, strings.Join(errResp.Errors, "; "))
+		}
+		return nil, fmt.Errorf("vault returned status %d", resp.StatusCode)
+	}
+
+	return body, nil
+}
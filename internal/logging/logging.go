@@ -3,14 +3,15 @@ package logging
 import (
 	"io"
 	"log/slog"
-	"os"
 	"strings"
+
+	"github.com/user/azure2aws/internal/output"
 )
 
 var defaultLogger *slog.Logger
 
 func init() {
-	defaultLogger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+	defaultLogger = slog.New(slog.NewTextHandler(output.Status(), &slog.HandlerOptions{
 		Level: slog.LevelInfo,
 	}))
 }
@@ -25,7 +26,7 @@ func InitLogger(verbose, debug bool) {
 		level = slog.LevelWarn
 	}
 
-	handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+	handler := slog.NewTextHandler(output.Status(), &slog.HandlerOptions{
 		Level:     level,
 		AddSource: debug,
 		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
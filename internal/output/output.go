@@ -0,0 +1,81 @@
+// Package output separates human-facing status text from machine output.
+//
+// Status text (progress, prompts, warnings, summaries) goes to stderr and
+// machine output (JSON, URLs, values meant for eval or pipes) goes to stdout,
+// so `eval "$(azure2aws ...)"` and `azure2aws ... | jq` only ever see the
+// latter. Writes are serialized so that output from concurrent goroutines is
+// never interleaved within a line.
+package output
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// lockedWriter serializes writes to an underlying writer
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	beforeWrite()
+	n, err := l.w.Write(p)
+	afterWrite(p)
+	return n, err
+}
+
+var (
+	// mu is shared by both writers so a terminal showing both streams
+	// never gets a status line spliced into a data line
+	mu     sync.Mutex
+	status = &lockedWriter{mu: &mu, w: os.Stderr}
+	data   = &lockedWriter{mu: &mu, w: os.Stdout}
+)
+
+// SetWriters redirects status and machine output, e.g. when embedding
+// commands or in tests. A nil writer leaves that stream unchanged.
+func SetWriters(statusW, dataW io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	if statusW != nil {
+		status.w = statusW
+	}
+	if dataW != nil {
+		data.w = dataW
+	}
+}
+
+// Status returns the writer for human-facing status text (stderr)
+func Status() io.Writer {
+	return status
+}
+
+// Data returns the writer for machine output (stdout)
+func Data() io.Writer {
+	return data
+}
+
+// Statusf writes formatted status text
+func Statusf(format string, args ...any) {
+	fmt.Fprint(status, fmt.Sprintf(format, args...))
+}
+
+// Statusln writes a line of status text
+func Statusln(args ...any) {
+	fmt.Fprint(status, fmt.Sprintln(args...))
+}
+
+// Printf writes formatted machine output
+func Printf(format string, args ...any) {
+	fmt.Fprint(data, fmt.Sprintf(format, args...))
+}
+
+// Println writes a line of machine output
+func Println(args ...any) {
+	fmt.Fprint(data, fmt.Sprintln(args...))
+}
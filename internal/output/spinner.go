@@ -0,0 +1,160 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// spinnerInterval is the time between two frames of the spinner
+const spinnerInterval = 100 * time.Millisecond
+
+// spinnerFrames animate the spinner; Windows consoles get plain ASCII
+func spinnerFrames() []string {
+	if runtime.GOOS == "windows" {
+		return []string{"|", "/", "-", "\\"}
+	}
+	return []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+}
+
+// spin is the running spinner, guarded by mu
+var spin *Spinner
+
+// Spinner shows the current phase of a long operation and the elapsed time
+// on the last line of the terminal. Status text written while it runs is
+// printed above it, and it is hidden while a prompt owns the terminal.
+type Spinner struct {
+	message string
+	start   time.Time
+	frame   int
+	drawn   bool // The spinner line is on screen
+	paused  int  // Nested Pause calls
+	atStart bool // The cursor is at the start of an empty line
+	done    chan struct{}
+}
+
+// StartSpinner shows a spinner with the given message. It returns nil, which
+// is safe to use, when stderr is not a terminal or another spinner runs.
+func StartSpinner(message string) *Spinner {
+	mu.Lock()
+	defer mu.Unlock()
+
+	f, ok := status.w.(*os.File)
+	if spin != nil || !ok || !term.IsTerminal(int(f.Fd())) {
+		return nil
+	}
+
+	s := &Spinner{message: message, start: time.Now(), atStart: true, done: make(chan struct{})}
+	spin = s
+	go s.run()
+	return s
+}
+
+// Update replaces the message of the spinner
+func (s *Spinner) Update(message string) {
+	if s == nil {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	s.message = message
+}
+
+// Stop removes the spinner from the terminal
+func (s *Spinner) Stop() {
+	if s == nil {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if spin != s {
+		return
+	}
+	s.clear()
+	spin = nil
+	close(s.done)
+}
+
+// PauseSpinner hides the running spinner, if any, until the returned function
+// is called; prompts use it to keep the terminal to themselves
+func PauseSpinner() (resume func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	s := spin
+	if s == nil {
+		return func() {}
+	}
+	s.clear()
+	s.paused++
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		s.paused--
+		// The answer to a prompt ends with Enter
+		s.atStart = true
+	}
+}
+
+// run redraws the spinner until it is stopped
+func (s *Spinner) run() {
+	ticker := time.NewTicker(spinnerInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			mu.Lock()
+			if s.paused == 0 && s.atStart {
+				s.draw()
+			}
+			mu.Unlock()
+		}
+	}
+}
+
+// draw writes the spinner line; mu must be held
+func (s *Spinner) draw() {
+	frames := spinnerFrames()
+	s.frame = (s.frame + 1) % len(frames)
+	line := fmt.Sprintf("%s %s (%ds)", frames[s.frame], s.message, int(time.Since(s.start).Seconds()))
+
+	// A wrapped line could not be cleared with \r
+	if f, ok := status.w.(*os.File); ok {
+		if width, _, err := term.GetSize(int(f.Fd())); err == nil && width > 1 {
+			if runes := []rune(line); len(runes) >= width {
+				line = string(runes[:width-1])
+			}
+		}
+	}
+
+	fmt.Fprint(status.w, "\r\x1b[K"+line)
+	s.drawn = true
+}
+
+// clear removes the spinner line; mu must be held
+func (s *Spinner) clear() {
+	if s.drawn {
+		fmt.Fprint(status.w, "\r\x1b[K")
+		s.drawn = false
+	}
+}
+
+// beforeWrite makes room for other output; mu must be held
+func beforeWrite() {
+	if spin != nil {
+		spin.clear()
+	}
+}
+
+// afterWrite records whether status text left the cursor on an empty line;
+// mu must be held
+func afterWrite(p []byte) {
+	if spin != nil && len(p) > 0 {
+		spin.atStart = strings.HasSuffix(string(p), "\n")
+	}
+}
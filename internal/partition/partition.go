@@ -0,0 +1,178 @@
+// Package partition describes the AWS partitions azure2aws can sign in to:
+// the commercial partition, GovCloud (US) and China.
+package partition
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Partition IDs, as used in ARNs
+const (
+	AWS      = "aws"
+	GovCloud = "aws-us-gov"
+	China    = "aws-cn"
+)
+
+// Partition holds the endpoints that differ between partitions. STS endpoints
+// follow from the region.
+type Partition struct {
+	ID            string
+	DefaultRegion string
+	// FederationURL is the console federation (getSigninToken) endpoint
+	FederationURL string
+	// LogoutURL signs out of the console, then follows redirect_uri
+	LogoutURL string
+	// ConsoleURL is the console home page
+	ConsoleURL string
+	// serviceConsole formats the console URL of a service
+	serviceConsole string
+	// regionalConsole formats the console URL of a region; empty if the
+	// partition has a single console host
+	regionalConsole string
+	// dnsSuffix is the domain of regional service endpoints
+	dnsSuffix string
+}
+
+var partitions = map[string]*Partition{
+	AWS: {
+		ID:              AWS,
+		DefaultRegion:   "us-east-1",
+		FederationURL:   "https://signin.aws.amazon.com/federation",
+		LogoutURL:       "https://signin.aws.amazon.com/oauth?Action=logout",
+		ConsoleURL:      "https://console.aws.amazon.com/",
+		serviceConsole:  "https://%s.console.aws.amazon.com/",
+		regionalConsole: "https://%s.console.aws.amazon.com/",
+		dnsSuffix:       "amazonaws.com",
+	},
+	GovCloud: {
+		ID:             GovCloud,
+		DefaultRegion:  "us-gov-west-1",
+		FederationURL:  "https://signin.amazonaws-us-gov.com/federation",
+		LogoutURL:      "https://signin.amazonaws-us-gov.com/oauth?Action=logout",
+		ConsoleURL:     "https://console.amazonaws-us-gov.com/",
+		serviceConsole: "https://console.amazonaws-us-gov.com/%s/home",
+		dnsSuffix:      "amazonaws.com",
+	},
+	China: {
+		ID:             China,
+		DefaultRegion:  "cn-north-1",
+		FederationURL:  "https://signin.amazonaws.cn/federation",
+		LogoutURL:      "https://signin.amazonaws.cn/oauth?Action=logout",
+		ConsoleURL:     "https://console.amazonaws.cn/",
+		serviceConsole: "https://console.amazonaws.cn/%s/home",
+		dnsSuffix:      "amazonaws.com.cn",
+	},
+}
+
+// IDs lists the supported partition IDs
+var IDs = []string{AWS, GovCloud, China}
+
+// Lookup returns the partition with the given ID
+func Lookup(id string) (*Partition, error) {
+	p, ok := partitions[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown partition %q (supported: %s)", id, strings.Join(IDs, ", "))
+	}
+	return p, nil
+}
+
+// ForRegion returns the partition a region belongs to. Unknown regions are
+// assumed to be commercial.
+func ForRegion(region string) *Partition {
+	switch {
+	case strings.HasPrefix(region, "us-gov-"):
+		return partitions[GovCloud]
+	case strings.HasPrefix(region, "cn-"):
+		return partitions[China]
+	default:
+		return partitions[AWS]
+	}
+}
+
+// ForARN returns the partition of an ARN, or nil if the ARN is malformed or
+// in an unsupported partition
+func ForARN(arn string) *Partition {
+	parts := strings.SplitN(arn, ":", 3)
+	if len(parts) < 3 || parts[0] != "arn" {
+		return nil
+	}
+	return partitions[parts[1]]
+}
+
+// IsSignInOrigin reports whether origin (as in an Origin header) is the AWS
+// sign-in site of a partition, or one of its regional hosts
+func IsSignInOrigin(origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme != "https" || u.Path != "" {
+		return false
+	}
+	for _, p := range partitions {
+		federation, _ := url.Parse(p.FederationURL)
+		if u.Host == federation.Host || strings.HasSuffix(u.Host, "."+federation.Host) {
+			return true
+		}
+	}
+	return false
+}
+
+// ServiceConsoleURL returns the console URL of a service (e.g. "s3")
+func (p *Partition) ServiceConsoleURL(service string) string {
+	return fmt.Sprintf(p.serviceConsole, service)
+}
+
+// ConsolePageURL returns the console URL of a page of a service in a region.
+// path is appended below the service ("/buckets") and may end with a fragment
+// ("#Instances"); without it, the service's home page is used. Without a
+// region or path, this is the same as ServiceConsoleURL (or ConsoleURL when
+// service is empty as well).
+func (p *Partition) ConsolePageURL(service, region, path string) (string, error) {
+	if region == "" && path == "" {
+		if service == "" {
+			return p.ConsoleURL, nil
+		}
+		return p.ServiceConsoleURL(service), nil
+	}
+	if service == "" {
+		if path != "" {
+			return "", fmt.Errorf("a console path requires a service")
+		}
+		service = "console"
+	}
+
+	page := "/home"
+	switch {
+	case path == "":
+	case strings.HasPrefix(path, "/"):
+		page = path
+	case strings.HasPrefix(path, "#"):
+		page += path
+	default:
+		return "", fmt.Errorf("console path %q must start with / or #", path)
+	}
+
+	base := p.ConsoleURL
+	if region != "" && p.regionalConsole != "" {
+		base = fmt.Sprintf(p.regionalConsole, region)
+	}
+
+	u, err := url.Parse(strings.TrimSuffix(base, "/") + "/" + service + page)
+	if err != nil {
+		return "", fmt.Errorf("invalid console path %q: %w", path, err)
+	}
+	if region != "" {
+		q := u.Query()
+		q.Set("region", region)
+		u.RawQuery = q.Encode()
+	}
+	return u.String(), nil
+}
+
+// STSURL returns the regional STS endpoint, or its FIPS variant
+func (p *Partition) STSURL(region string, fips bool) string {
+	if fips {
+		return fmt.Sprintf("https://sts-fips.%s.%s/", region, p.dnsSuffix)
+	}
+	return fmt.Sprintf("https://sts.%s.%s/", region, p.dnsSuffix)
+}
@@ -0,0 +1,224 @@
+package prompter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/user/azure2aws/internal/output"
+)
+
+// DefaultPromptInterval is the minimum gap between two consecutive prompts
+const DefaultPromptInterval = 100 * time.Millisecond
+
+// Broker serializes prompts coming from concurrent operations so the terminal
+// never shows interleaved prompts. Identical in-flight requests (same key) are
+// deduplicated and share a single answer.
+type Broker struct {
+	prompter *Prompter
+	interval time.Duration
+
+	// mu is held while a prompt owns the terminal
+	mu   sync.Mutex
+	last time.Time
+
+	flightMu sync.Mutex
+	inflight map[string]*flight
+
+	// running counts the operations of each label between Begin and end
+	runningMu sync.Mutex
+	running   map[string]int
+}
+
+// flight is a prompt whose answer is shared by all callers with the same key
+type flight struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// NewBroker creates a Broker that prompts through the given Prompter
+func NewBroker(p *Prompter) *Broker {
+	return &Broker{
+		prompter: p,
+		interval: DefaultPromptInterval,
+		inflight: make(map[string]*flight),
+		running:  make(map[string]int),
+	}
+}
+
+// SetNoInput makes every prompt of the Broker fail with ErrNoInput, see
+// Prompter.SetNoInput
+func (b *Broker) SetNoInput(disabled bool) {
+	b.prompter.SetNoInput(disabled)
+}
+
+// SetInterval sets the minimum gap between consecutive prompts
+func (b *Broker) SetInterval(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.interval = d
+}
+
+// do runs fn with exclusive access to the terminal. If key is non-empty and
+// another caller is already prompting with the same key, the caller waits and
+// receives that answer instead of prompting again.
+func (b *Broker) do(label, key string, fn func(p *Prompter) (interface{}, error)) (interface{}, error) {
+	if key != "" {
+		b.flightMu.Lock()
+		if f, ok := b.inflight[key]; ok {
+			b.flightMu.Unlock()
+			<-f.done
+			return f.value, f.err
+		}
+		f := &flight{done: make(chan struct{})}
+		b.inflight[key] = f
+		b.flightMu.Unlock()
+
+		f.value, f.err = b.run(label, fn)
+
+		b.flightMu.Lock()
+		delete(b.inflight, key)
+		b.flightMu.Unlock()
+		close(f.done)
+
+		return f.value, f.err
+	}
+
+	return b.run(label, fn)
+}
+
+// run waits for the terminal, applies rate limiting, and executes the prompt
+func (b *Broker) run(label string, fn func(p *Prompter) (interface{}, error)) (interface{}, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if wait := b.interval - time.Since(b.last); wait > 0 && !b.last.IsZero() {
+		time.Sleep(wait)
+	}
+
+	resume := output.PauseSpinner()
+	defer resume()
+
+	if label != "" && !b.prompter.noInput.Load() && b.concurrent() {
+		output.Statusf("[%s]\n", label)
+	}
+
+	value, err := fn(b.prompter)
+	b.last = time.Now()
+
+	return value, err
+}
+
+// readsKeys reports whether key waits read the terminal: not while input is
+// disabled, not from a pipe (whose lines are meant for later prompts), and
+// not while operations of other labels run, when a key press could not be
+// told apart
+func (b *Broker) readsKeys() bool {
+	return keysSupported && !b.prompter.noInput.Load() && isInteractive() && !b.concurrent()
+}
+
+// key waits up to d for a key press, with exclusive access to the terminal
+func (b *Broker) key(ctx context.Context, d time.Duration) (rune, error) {
+	if !b.readsKeys() {
+		return 0, sleepContext(ctx, d)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.prompter.readKey(ctx, d)
+}
+
+// Begin marks an operation with the given label (typically the profile name)
+// as running until end is called. Prompts name their label only while
+// operations of more than one label run.
+func (b *Broker) Begin(label string) (end func()) {
+	b.runningMu.Lock()
+	b.running[label]++
+	b.runningMu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.runningMu.Lock()
+			defer b.runningMu.Unlock()
+			if b.running[label]--; b.running[label] <= 0 {
+				delete(b.running, label)
+			}
+		})
+	}
+}
+
+// concurrent reports whether operations of more than one label are running
+func (b *Broker) concurrent() bool {
+	b.runningMu.Lock()
+	defer b.runningMu.Unlock()
+	return len(b.running) > 1
+}
+
+// Scope returns a prompt scope that identifies itself with the given label
+// (typically the profile name) in a context line before each prompt, when
+// operations of other labels are running too
+func (b *Broker) Scope(label string) *Scope {
+	return &Scope{broker: b, label: label}
+}
+
+// Scope issues prompts through a Broker on behalf of a labelled operation
+type Scope struct {
+	broker *Broker
+	label  string
+}
+
+// String prompts for a string input
+func (s *Scope) String(prompt, defaultValue string) (string, error) {
+	v, err := s.broker.do(s.label, "", func(p *Prompter) (interface{}, error) {
+		return p.PromptString(prompt, defaultValue)
+	})
+	return v.(string), err
+}
+
+// Password prompts for a password
+func (s *Scope) Password(prompt string) (string, error) {
+	v, err := s.broker.do(s.label, "", func(p *Prompter) (interface{}, error) {
+		return p.PromptPassword(prompt)
+	})
+	return v.(string), err
+}
+
+// SharedPassword prompts for a password, sharing the answer with any
+// concurrent caller using the same key (e.g., the same username)
+func (s *Scope) SharedPassword(key, prompt string) (string, error) {
+	v, err := s.broker.do(s.label, "password:"+key, func(p *Prompter) (interface{}, error) {
+		return p.PromptPassword(prompt)
+	})
+	return v.(string), err
+}
+
+// Select prompts for selection from options
+func (s *Scope) Select(prompt string, options []string) (int, error) {
+	v, err := s.broker.do(s.label, "", func(p *Prompter) (interface{}, error) {
+		return p.PromptSelect(prompt, options)
+	})
+	return v.(int), err
+}
+
+// ReadsKeys reports whether Key can return a key press rather than only
+// waiting
+func (s *Scope) ReadsKeys() bool {
+	return s.broker.readsKeys()
+}
+
+// Key waits up to d for a single key press, without Enter, and returns it,
+// or 0 when none was pressed or keys cannot be read (see ReadsKeys). Returns
+// ctx's error when it is cancelled.
+func (s *Scope) Key(ctx context.Context, d time.Duration) (rune, error) {
+	return s.broker.key(ctx, d)
+}
+
+// Confirm prompts for yes/no confirmation
+func (s *Scope) Confirm(prompt string, defaultYes bool) (bool, error) {
+	v, err := s.broker.do(s.label, "", func(p *Prompter) (interface{}, error) {
+		return p.PromptConfirm(prompt, defaultYes)
+	})
+	return v.(bool), err
+}
@@ -0,0 +1,79 @@
+package prompter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBrokerConcurrent(t *testing.T) {
+	b := NewBroker(New())
+	if b.concurrent() {
+		t.Error("expected no concurrent operations")
+	}
+
+	endDev := b.Begin("dev")
+	endDevAgain := b.Begin("dev")
+	if b.concurrent() {
+		t.Error("expected operations of one profile not to be concurrent")
+	}
+
+	endProd := b.Begin("prod")
+	if !b.concurrent() {
+		t.Error("expected operations of two profiles to be concurrent")
+	}
+
+	endDev()
+	endDev()
+	if !b.concurrent() {
+		t.Error("expected dev to keep running until its second operation ends")
+	}
+
+	endDevAgain()
+	if b.concurrent() {
+		t.Error("expected only prod to be running")
+	}
+	endProd()
+}
+
+func TestScopeKeyWithoutTerminal(t *testing.T) {
+	// Tests run without a terminal on stdin, so Key only waits
+	s := NewBroker(New()).Scope("dev")
+	if s.ReadsKeys() {
+		t.Fatal("expected keys not to be read without a terminal")
+	}
+
+	key, err := s.Key(context.Background(), 10*time.Millisecond)
+	if err != nil || key != 0 {
+		t.Errorf("got %q, %v; want no key and no error", key, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	if _, err := s.Key(ctx, time.Minute); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("expected a cancelled wait to return at once")
+	}
+}
+
+func TestBrokerNoInput(t *testing.T) {
+	disabled := NewBroker(New())
+	disabled.SetNoInput(true)
+	other := NewBroker(New())
+
+	if _, err := disabled.Scope("dev").String("Username", ""); !errors.Is(err, ErrNoInput) {
+		t.Errorf("expected ErrNoInput, got %v", err)
+	}
+	if _, err := disabled.Scope("dev").Select("Select an AWS role:", []string{"a", "b"}); !errors.Is(err, ErrNoInput) {
+		t.Errorf("expected ErrNoInput, got %v", err)
+	}
+
+	// Each Broker keeps its own setting
+	if other.prompter.noInput.Load() || defaultBroker.prompter.noInput.Load() {
+		t.Error("expected input to stay enabled for other Brokers")
+	}
+}
@@ -0,0 +1,242 @@
+package prompter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/user/azure2aws/internal/output"
+	"golang.org/x/term"
+)
+
+// maxVisibleOptions is how many options the fuzzy selector shows at once
+const maxVisibleOptions = 10
+
+// errSelectCancelled is returned when the user aborts the selector
+var errSelectCancelled = errors.New("selection cancelled")
+
+// Keys understood by the fuzzy selector
+const (
+	keyCtrlC     = 3
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlU     = 21
+	keyBackspace = 8
+	keyEnter     = 13
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+// isInteractive reports whether the arrow-key selector can be used
+func isInteractive() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stderr.Fd()))
+}
+
+// fuzzySelect lets the user narrow options by typing and pick one with the
+// arrow keys. Returns the index of the selected option.
+func (p *Prompter) fuzzySelect(prompt string, options []string) (int, error) {
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return -1, fmt.Errorf("failed to enable raw terminal mode: %w", err)
+	}
+	defer term.Restore(fd, state)
+
+	width := 80
+	if w, _, err := term.GetSize(int(os.Stderr.Fd())); err == nil && w > 0 {
+		width = w
+	}
+
+	var (
+		query   []rune
+		cursor  int
+		offset  int
+		drawn   int
+		matches = filterOptions(options, "")
+	)
+
+	for {
+		// Keep the cursor inside the visible window
+		if cursor >= len(matches) {
+			cursor = len(matches) - 1
+		}
+		if cursor < 0 {
+			cursor = 0
+		}
+		if cursor < offset {
+			offset = cursor
+		}
+		if cursor >= offset+maxVisibleOptions {
+			offset = cursor - maxVisibleOptions + 1
+		}
+
+		drawn = renderSelect(prompt, string(query), options, matches, cursor, offset, drawn, width)
+
+		r, _, err := p.reader.ReadRune()
+		if err != nil {
+			clearLines(drawn)
+			return -1, fmt.Errorf("failed to read input: %w", err)
+		}
+
+		switch r {
+		case keyCtrlC:
+			clearLines(drawn)
+			return -1, errSelectCancelled
+
+		case keyEnter, '\n':
+			if len(matches) == 0 {
+				continue
+			}
+			clearLines(drawn)
+			idx := matches[cursor]
+			output.Statusf("%s %s\r\n", prompt, options[idx])
+			return idx, nil
+
+		case keyEscape:
+			// Arrow keys arrive as ESC [ A/B (or ESC O A/B in application mode)
+			next, _, err := p.reader.ReadRune()
+			if err != nil || (next != '[' && next != 'O') {
+				continue
+			}
+			switch code, _, _ := p.reader.ReadRune(); code {
+			case 'A':
+				cursor--
+			case 'B':
+				cursor++
+			}
+
+		case keyCtrlP:
+			cursor--
+
+		case keyCtrlN:
+			cursor++
+
+		case keyBackspace, keyDelete:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				matches = filterOptions(options, string(query))
+				cursor, offset = 0, 0
+			}
+
+		case keyCtrlU:
+			query = query[:0]
+			matches = filterOptions(options, "")
+			cursor, offset = 0, 0
+
+		default:
+			if unicode.IsPrint(r) {
+				query = append(query, r)
+				matches = filterOptions(options, string(query))
+				cursor, offset = 0, 0
+			}
+		}
+	}
+}
+
+// renderSelect redraws the selector over the previous frame of drawn lines
+// and returns the number of lines written
+func renderSelect(prompt, query string, options []string, matches []int, cursor, offset, drawn, width int) int {
+	clearLines(drawn)
+
+	var sb strings.Builder
+	lines := 0
+	line := func(s string) {
+		sb.WriteString(truncate(s, width-1))
+		sb.WriteString("\r\n")
+		lines++
+	}
+
+	line(fmt.Sprintf("%s %s", prompt, query))
+	end := offset + maxVisibleOptions
+	if end > len(matches) {
+		end = len(matches)
+	}
+	for i := offset; i < end; i++ {
+		marker := "  "
+		if i == cursor {
+			marker = "> "
+		}
+		line(marker + options[matches[i]])
+	}
+	if len(matches) == 0 {
+		line("  (no matches)")
+	}
+	line(fmt.Sprintf("  [%d/%d] type to filter, ↑/↓ to move, enter to select", len(matches), len(options)))
+
+	output.Statusf("%s", sb.String())
+	return lines
+}
+
+// clearLines erases the previous frame (the cursor sits below its last line)
+func clearLines(n int) {
+	if n > 0 {
+		output.Statusf("\x1b[%dA\r\x1b[J", n)
+	}
+}
+
+func truncate(s string, width int) string {
+	if width < 1 || utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:width-1]) + "…"
+}
+
+// filterOptions returns the indexes of the options matching every
+// whitespace-separated term of query (case-insensitive). Options containing
+// all terms verbatim rank before those only matching them as subsequences;
+// otherwise the original order is kept.
+func filterOptions(options []string, query string) []int {
+	terms := strings.Fields(strings.ToLower(query))
+
+	type match struct {
+		index int
+		exact bool
+	}
+	var matches []match
+	for i, opt := range options {
+		lower := strings.ToLower(opt)
+		exact, ok := true, true
+		for _, t := range terms {
+			if strings.Contains(lower, t) {
+				continue
+			}
+			exact = false
+			if !isSubsequence(t, lower) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			matches = append(matches, match{index: i, exact: exact})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].exact && !matches[j].exact
+	})
+
+	indexes := make([]int, len(matches))
+	for i, m := range matches {
+		indexes[i] = m.index
+	}
+	return indexes
+}
+
+// isSubsequence reports whether the runes of sub appear in s in order
+func isSubsequence(sub, s string) bool {
+	rest := []rune(sub)
+	for _, r := range s {
+		if len(rest) == 0 {
+			break
+		}
+		if r == rest[0] {
+			rest = rest[1:]
+		}
+	}
+	return len(rest) == 0
+}
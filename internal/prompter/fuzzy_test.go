@@ -0,0 +1,35 @@
+package prompter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterOptions(t *testing.T) {
+	options := []string{
+		"Admin (Account: 111111111111)",
+		"ReadOnly (Account: 222222222222)",
+		"prod-admin (Account: 333333333333)",
+		"ProdDeployer (Account: 444444444444)",
+	}
+
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{"", []int{0, 1, 2, 3}},
+		{"prod admin", []int{2}},
+		{"ADMIN", []int{0, 2}},
+		{"prdply", []int{3}},
+		{"2222", []int{1}},
+		{"deploy", []int{3}},
+		{"nothing", []int{}},
+	}
+
+	for _, tt := range tests {
+		got := filterOptions(options, tt.query)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("filterOptions(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
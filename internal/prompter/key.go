@@ -0,0 +1,69 @@
+package prompter
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// keyPollInterval is how often a key wait checks for cancellation
+const keyPollInterval = 100 * time.Millisecond
+
+// readKey waits up to d for a key press on the terminal and returns it, or 0
+// when none was pressed. Ctrl+C interrupts the process as it would outside
+// raw mode.
+func (p *Prompter) readKey(ctx context.Context, d time.Duration) (rune, error) {
+	deadline := time.Now().Add(d)
+
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return 0, sleepContext(ctx, d)
+	}
+	defer term.Restore(fd, state)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		left := time.Until(deadline)
+		if left <= 0 {
+			return 0, nil
+		}
+
+		if p.reader.Buffered() == 0 {
+			ready, err := waitReadable(fd, min(left, keyPollInterval))
+			if err != nil {
+				return 0, sleepContext(ctx, left)
+			}
+			if !ready {
+				continue
+			}
+		}
+
+		r, _, err := p.reader.ReadRune()
+		if err != nil {
+			return 0, sleepContext(ctx, time.Until(deadline))
+		}
+		if r == keyCtrlC {
+			interrupt()
+			continue
+		}
+		return r, nil
+	}
+}
+
+// sleepContext waits for d, returning early when ctx is cancelled
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
@@ -0,0 +1,30 @@
+//go:build !windows
+
+package prompter
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// keysSupported reports whether readKey can wait for a key press
+const keysSupported = true
+
+// waitReadable waits up to d for fd to have input
+func waitReadable(fd int, d time.Duration) (bool, error) {
+	fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+	n, err := unix.Poll(fds, int(d.Milliseconds()))
+	if err == unix.EINTR {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// interrupt delivers the Ctrl+C that raw mode read as a key
+func interrupt() {
+	unix.Kill(unix.Getpid(), unix.SIGINT)
+}
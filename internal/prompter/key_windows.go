@@ -0,0 +1,21 @@
+//go:build windows
+
+package prompter
+
+import (
+	"errors"
+	"time"
+)
+
+// keysSupported reports whether readKey can wait for a key press. The
+// console cannot be polled for key presses alone, so waits on Windows only
+// time out.
+const keysSupported = false
+
+// waitReadable is not supported on Windows
+func waitReadable(fd int, d time.Duration) (bool, error) {
+	return false, errors.New("waiting for a key press is not supported on Windows")
+}
+
+// interrupt is not needed on Windows, where keys are never read in raw mode
+func interrupt() {}
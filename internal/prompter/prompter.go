@@ -2,17 +2,27 @@ package prompter
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
+	"sync/atomic"
 
+	"github.com/user/azure2aws/internal/output"
 	"golang.org/x/term"
 )
 
+// ErrNoInput is returned by every prompt of a Prompter once its input is
+// disabled with SetNoInput
+var ErrNoInput = errors.New("input required")
+
 // Prompter handles interactive user input
 type Prompter struct {
 	reader *bufio.Reader
+
+	// noInput makes every prompt fail with ErrNoInput
+	noInput atomic.Bool
 }
 
 // New creates a new Prompter
@@ -22,12 +32,30 @@ func New() *Prompter {
 	}
 }
 
+// SetNoInput makes every prompt fail with ErrNoInput instead of waiting for
+// the terminal, so that pipelines fail fast rather than hang
+func (p *Prompter) SetNoInput(disabled bool) {
+	p.noInput.Store(disabled)
+}
+
+// checkInput fails when prompting is disabled
+func (p *Prompter) checkInput(prompt string) error {
+	if p.noInput.Load() {
+		return fmt.Errorf("%w: %q (prompting is disabled by --no-input)", ErrNoInput, prompt)
+	}
+	return nil
+}
+
 // PromptString prompts for a string input with an optional default value
 func (p *Prompter) PromptString(prompt, defaultValue string) (string, error) {
+	if err := p.checkInput(prompt); err != nil {
+		return "", err
+	}
+
 	if defaultValue != "" {
-		fmt.Printf("%s [%s]: ", prompt, defaultValue)
+		output.Statusf("%s [%s]: ", prompt, defaultValue)
 	} else {
-		fmt.Printf("%s: ", prompt)
+		output.Statusf("%s: ", prompt)
 	}
 
 	input, err := p.reader.ReadString('\n')
@@ -44,11 +72,15 @@ func (p *Prompter) PromptString(prompt, defaultValue string) (string, error) {
 
 // PromptPassword prompts for a password (hidden input)
 func (p *Prompter) PromptPassword(prompt string) (string, error) {
-	fmt.Printf("%s: ", prompt)
+	if err := p.checkInput(prompt); err != nil {
+		return "", err
+	}
+
+	output.Statusf("%s: ", prompt)
 
 	// Read password without echoing
 	passwordBytes, err := term.ReadPassword(int(os.Stdin.Fd()))
-	fmt.Println() // Print newline after password input
+	output.Statusln() // Print newline after password input
 
 	if err != nil {
 		return "", fmt.Errorf("failed to read password: %w", err)
@@ -58,13 +90,23 @@ func (p *Prompter) PromptPassword(prompt string) (string, error) {
 }
 
 // PromptSelect prompts the user to select from a list of options
-// Returns the index of the selected option
+// Returns the index of the selected option. On a terminal the options can be
+// filtered by typing and chosen with the arrow keys; otherwise a numbered
+// list is read from stdin.
 func (p *Prompter) PromptSelect(prompt string, options []string) (int, error) {
-	fmt.Println(prompt)
+	if err := p.checkInput(prompt); err != nil {
+		return -1, err
+	}
+
+	if isInteractive() {
+		return p.fuzzySelect(prompt, options)
+	}
+
+	output.Statusln(prompt)
 	for i, opt := range options {
-		fmt.Printf("  [%d] %s\n", i+1, opt)
+		output.Statusf("  [%d] %s\n", i+1, opt)
 	}
-	fmt.Print("Selection: ")
+	output.Statusf("Selection: ")
 
 	input, err := p.reader.ReadString('\n')
 	if err != nil {
@@ -86,6 +128,10 @@ func (p *Prompter) PromptSelect(prompt string, options []string) (int, error) {
 
 // PromptConfirm prompts for a yes/no confirmation
 func (p *Prompter) PromptConfirm(prompt string, defaultYes bool) (bool, error) {
+	if err := p.checkInput(prompt); err != nil {
+		return false, err
+	}
+
 	var hint string
 	if defaultYes {
 		hint = "[Y/n]"
@@ -93,7 +139,7 @@ func (p *Prompter) PromptConfirm(prompt string, defaultYes bool) (bool, error) {
 		hint = "[y/N]"
 	}
 
-	fmt.Printf("%s %s: ", prompt, hint)
+	output.Statusf("%s %s: ", prompt, hint)
 
 	input, err := p.reader.ReadString('\n')
 	if err != nil {
@@ -115,26 +161,12 @@ func (p *Prompter) PromptConfirm(prompt string, defaultYes bool) (bool, error) {
 	}
 }
 
-// Package-level convenience functions using a default Prompter
-
-var defaultPrompter = New()
-
-// String prompts for a string input
-func String(prompt, defaultValue string) (string, error) {
-	return defaultPrompter.PromptString(prompt, defaultValue)
-}
-
-// Password prompts for a password
-func Password(prompt string) (string, error) {
-	return defaultPrompter.PromptPassword(prompt)
-}
-
-// Select prompts for selection from options
-func Select(prompt string, options []string) (int, error) {
-	return defaultPrompter.PromptSelect(prompt, options)
-}
+// defaultBroker serves the prompts of code given no Scope. Commands prompt
+// through a Broker of their own, which carries their --no-input setting.
+var defaultBroker = NewBroker(New())
 
-// Confirm prompts for yes/no confirmation
-func Confirm(prompt string, defaultYes bool) (bool, error) {
-	return defaultPrompter.PromptConfirm(prompt, defaultYes)
+// For returns a prompt scope of the default Broker labelled with the given
+// context (e.g., profile name)
+func For(label string) *Scope {
+	return defaultBroker.Scope(label)
 }
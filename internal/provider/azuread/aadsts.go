@@ -0,0 +1,123 @@
+package azuread
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of sign-in errors reported by Azure AD, for callers that act on them
+// (exit codes, retries)
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrMFARequired        = errors.New("multi-factor authentication required")
+	ErrAccessBlocked      = errors.New("sign-in blocked by policy")
+	ErrAccountLocked      = errors.New("account locked")
+	ErrAccountDisabled    = errors.New("account disabled")
+)
+
+// aadstsCode describes a known AADSTS error code
+type aadstsCode struct {
+	kind    error
+	summary string // defaults to the kind's message
+	hint    string
+}
+
+// aadstsCodes maps the AADSTS codes users commonly run into to guidance.
+// See https://learn.microsoft.com/entra/identity-platform/reference-error-codes
+var aadstsCodes = map[string]aadstsCode{
+	"50126": {kind: ErrInvalidCredentials,
+		hint: "check the username and password; if the password changed, update the stored one with 'azure2aws configure'"},
+	"50056": {kind: ErrInvalidCredentials, summary: "no password",
+		hint: "enter the password, or store it with 'azure2aws configure'"},
+	"50034": {kind: ErrInvalidCredentials, summary: "user not found",
+		hint: "the account does not exist in this directory; check the username"},
+	"50055": {kind: ErrInvalidCredentials, summary: "password expired",
+		hint: "change it at " + passwordChangeURL + ", then log in again"},
+	"50053": {kind: ErrAccountLocked,
+		hint: "too many failed sign-ins, or sign-ins from a blocked IP address; wait a few minutes before retrying, or ask your administrator to unlock the account"},
+	"50057": {kind: ErrAccountDisabled,
+		hint: "contact your administrator"},
+	"50076": {kind: ErrMFARequired,
+		hint: "run 'login' interactively to approve the MFA request, or pass --mfa-token"},
+	"50074": {kind: ErrMFARequired,
+		hint: "run 'login' interactively to approve the MFA request, or pass --mfa-token"},
+	"50079": {kind: ErrMFARequired, summary: "MFA registration required",
+		hint: "register an authentication method at https://aka.ms/mfasetup, then log in again"},
+	"500121": {kind: ErrMFARequired, summary: "MFA challenge failed",
+		hint: "the request was denied, timed out or the code was wrong; log in again and approve the request"},
+	"53000": {kind: ErrAccessBlocked, summary: "compliant device required",
+		hint: "Conditional Access only allows managed devices; sign in on such a device, with 'login --browser' there"},
+	"53001": {kind: ErrAccessBlocked, summary: "domain-joined device required",
+		hint: "Conditional Access only allows domain-joined devices; sign in on such a device, with 'login --browser' there"},
+	"53003": {kind: ErrAccessBlocked, summary: "blocked by Conditional Access",
+		hint: "a Conditional Access policy (location, device or client app) denied the sign-in; try 'login --browser', or ask your administrator which policy applies"},
+	"530032": {kind: ErrAccessBlocked, summary: "blocked by security policy",
+		hint: "ask your administrator why the account is blocked"},
+	"50105": {kind: ErrAccessBlocked, summary: "not assigned to the application",
+		hint: "ask your administrator to assign you (or one of your groups) to the AWS application"},
+	"700016": {summary: "application not found",
+		hint: "check app_id and url in the profile"},
+	"90072": {summary: "account not in this tenant",
+		hint: "the account is not a member or guest of the application's tenant; check url and the username"},
+}
+
+// AADSTSError is a sign-in error reported by Azure AD. Known codes carry a
+// kind (one of the Err* values above, matched with errors.Is) and a hint.
+type AADSTSError struct {
+	Code    string // Numeric part, e.g. "50126"
+	Message string // Azure AD's own text
+}
+
+// newAADSTSError returns the error for an sErrorCode and its text
+func newAADSTSError(code, message string) *AADSTSError {
+	return &AADSTSError{Code: code, Message: message}
+}
+
+// Error implements error
+func (e *AADSTSError) Error() string {
+	known, ok := aadstsCodes[e.Code]
+	if !ok {
+		return fmt.Sprintf("AADSTS%s: %s", e.Code, e.Message)
+	}
+	summary := known.summary
+	if summary == "" {
+		summary = known.kind.Error()
+	}
+	return fmt.Sprintf("%s (AADSTS%s): %s", summary, e.Code, known.hint)
+}
+
+// Unwrap returns the kind of a known code
+func (e *AADSTSError) Unwrap() error {
+	return aadstsCodes[e.Code].kind
+}
+
+// signInError is a sign-in error of a known kind detected by the client
+// itself, with its own message
+type signInError struct {
+	kind    error
+	message string
+
+	// passwordRejected is set when the password was checked and rejected
+	passwordRejected bool
+}
+
+// Error implements error
+func (e *signInError) Error() string {
+	return e.message
+}
+
+// Unwrap returns the kind
+func (e *signInError) Unwrap() error {
+	return e.kind
+}
+
+// PasswordRejected reports whether err means the password was checked and
+// rejected, so that asking for it again may help
+func PasswordRejected(err error) bool {
+	var aadsts *AADSTSError
+	if errors.As(err, &aadsts) {
+		return aadsts.Code == "50126"
+	}
+	var signIn *signInError
+	return errors.As(err, &signIn) && signIn.passwordRejected
+}
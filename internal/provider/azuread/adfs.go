@@ -0,0 +1,260 @@
+package azuread
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/user/azure2aws/internal/output"
+	"github.com/user/azure2aws/internal/provider"
+)
+
+// maxADFSChallenges bounds the ADFS pages handled in one sign-in, so a page
+// that keeps coming back fails instead of looping
+const maxADFSChallenges = 5
+
+// adfsAuthMethodPattern finds the methods offered on the ADFS method
+// selection page
+var adfsAuthMethodPattern = regexp.MustCompile(`selectAuthMethod\('([^']+)'\)`)
+
+// isADFSChallenge reports whether the page is an ADFS sign-in page: the
+// additional authentication (MFA) shown after the primary sign-in, or the
+// forms page shown again after a failed sign-in
+func isADFSChallenge(res *http.Response, html string) bool {
+	if res == nil || res.Request == nil || !strings.Contains(strings.ToLower(res.Request.URL.Path), "/adfs/") {
+		return false
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return false
+	}
+	return doc.Find("form input[name='AuthMethod']").Length() > 0
+}
+
+// processADFSChallenge answers an ADFS additional authentication page: the
+// method selection, a verification code (Azure MFA, TOTP adapters), the Duo
+// iframe, or a phone approval that ADFS waits for when the form is submitted
+func (c *Client) processADFSChallenge(res *http.Response, html string, creds *provider.LoginCredentials) (*http.Response, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ADFS page: %w", err)
+	}
+
+	form := doc.Find("form:has(input[name='AuthMethod'])").First()
+	values := url.Values{}
+	form.Find("input").Each(func(_ int, s *goquery.Selection) {
+		if name := s.AttrOr("name", ""); name != "" {
+			values.Set(name, s.AttrOr("value", ""))
+		}
+	})
+	submitURL := c.fullURL(res, form.AttrOr("action", res.Request.URL.String()))
+	errorText := strings.TrimSpace(doc.Find("#errorText").Text())
+
+	switch {
+	case form.Find("input[name='Password']").Length() > 0:
+		if errorText == "" {
+			errorText = "the username or password was not accepted"
+		}
+		return nil, &signInError{kind: ErrInvalidCredentials, message: errorText, passwordRejected: true}
+
+	case doc.Find("#duo_iframe").Length() > 0:
+		frame := doc.Find("#duo_iframe")
+		sigResponse, err := c.processDuo(res.Request.URL.String(), frame.AttrOr("data-host", ""), frame.AttrOr("data-sig-request", ""), creds)
+		if err != nil {
+			return nil, fmt.Errorf("Duo authentication failed: %w", err)
+		}
+		values.Set(frame.AttrOr("data-post-argument", "sig_response"), sigResponse)
+
+	case form.Find("input[name='VerificationCode']").Length() > 0:
+		if errorText != "" {
+			if creds.MFAToken != "" || creds.TOTPSeed != "" {
+				return nil, fmt.Errorf("verification code rejected: %s", errorText)
+			}
+			output.Statusf("ADFS: %s\n", errorText)
+		}
+		code, err := c.adfsVerificationCode(creds)
+		if err != nil {
+			return nil, err
+		}
+		values.Set("VerificationCode", code)
+
+	case values.Get("AuthMethod") == "":
+		method, err := c.selectADFSAuthMethod(doc, html)
+		if err != nil {
+			return nil, err
+		}
+		values.Set("AuthMethod", method)
+		return c.postADFSForm(submitURL, values)
+
+	default:
+		if errorText != "" {
+			return nil, fmt.Errorf("%s", errorText)
+		}
+		output.Statusln("ADFS requires additional authentication; approve the sign-in request on your phone.")
+		c.progress(StageMFAWaiting, "Waiting for MFA approval (ADFS)")
+	}
+
+	c.mfaCompleted = true
+	c.progress(StageMFACompleted, "MFA completed")
+	return c.postADFSForm(submitURL, values)
+}
+
+// adfsVerificationCode returns the one-time code for an ADFS challenge: the
+// --mfa-token code, one generated from the TOTP seed, or a prompted one
+func (c *Client) adfsVerificationCode(creds *provider.LoginCredentials) (string, error) {
+	if creds.MFAToken != "" {
+		return creds.MFAToken, nil
+	}
+	if creds.TOTPSeed != "" {
+		return provider.GenerateTOTP(creds.TOTPSeed, time.Now())
+	}
+	code, err := c.prompts.String("Enter ADFS verification code", "")
+	if err != nil {
+		return "", fmt.Errorf("failed to read verification code: %w", err)
+	}
+	return code, nil
+}
+
+// selectADFSAuthMethod picks the method on the ADFS method selection page,
+// asking when several are offered
+func (c *Client) selectADFSAuthMethod(doc *goquery.Document, html string) (string, error) {
+	var methods, labels []string
+	for _, m := range adfsAuthMethodPattern.FindAllStringSubmatch(html, -1) {
+		label := strings.TrimSpace(doc.Find("#" + m[1]).Text())
+		if label == "" {
+			label = m[1]
+		}
+		methods = append(methods, m[1])
+		labels = append(labels, label)
+	}
+
+	switch len(methods) {
+	case 0:
+		return "", fmt.Errorf("ADFS offered no authentication method")
+	case 1:
+		return methods[0], nil
+	}
+
+	idx, err := c.prompts.Select("Select ADFS authentication method", labels)
+	if err != nil {
+		return "", fmt.Errorf("failed to select authentication method: %w", err)
+	}
+	return methods[idx], nil
+}
+
+// postADFSForm submits form values to an ADFS (or Duo) endpoint
+func (c *Client) postADFSForm(submitURL string, values url.Values) (*http.Response, error) {
+	req, err := c.newRequest("POST", submitURL, strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ADFS request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.httpClient.Do(req)
+}
+
+// duoResponse is the envelope of the Duo frame API
+type duoResponse struct {
+	Stat     string `json:"stat"`
+	Message  string `json:"message"`
+	Response struct {
+		TxID      string `json:"txid"`
+		Result    string `json:"result"`
+		Status    string `json:"status"`
+		ResultURL string `json:"result_url"`
+		Cookie    string `json:"cookie"`
+	} `json:"response"`
+}
+
+// processDuo completes the Duo Web iframe flow of the ADFS Duo adapter with
+// a push, or with the --mfa-token passcode, and returns the signed response
+// for the ADFS form
+func (c *Client) processDuo(parentURL, host, sigRequest string, creds *provider.LoginCredentials) (string, error) {
+	tx, app, ok := strings.Cut(sigRequest, ":")
+	if host == "" || !ok {
+		return "", fmt.Errorf("Duo iframe is missing its host or signature request")
+	}
+	frameURL := "https://" + host + "/frame"
+
+	// Start a Duo session
+	authURL := fmt.Sprintf("%s/web/v1/auth?tx=%s&parent=%s&v=2.6", frameURL, url.QueryEscape(tx), url.QueryEscape(parentURL))
+	res, err := c.postADFSForm(authURL, url.Values{"parent": {parentURL}})
+	if err != nil {
+		return "", err
+	}
+	doc, err := goquery.NewDocumentFromReader(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return "", fmt.Errorf("failed to parse Duo page: %w", err)
+	}
+	sid := doc.Find("input[name='sid']").AttrOr("value", "")
+	if sid == "" {
+		return "", fmt.Errorf("Duo did not start a session")
+	}
+
+	prompt := url.Values{"sid": {sid}, "device": {"phone1"}, "factor": {"Duo Push"}, "out_of_date": {"False"}}
+	if creds.MFAToken != "" {
+		prompt.Set("factor", "Passcode")
+		prompt.Set("passcode", creds.MFAToken)
+	}
+	var promptResp duoResponse
+	if err := c.postDuo(frameURL+"/prompt", prompt, &promptResp); err != nil {
+		return "", err
+	}
+	if creds.MFAToken == "" {
+		output.Statusln("Duo push sent; approve the sign-in request on your phone.")
+		c.progress(StageMFAWaiting, "Waiting for MFA approval (Duo push)")
+	}
+
+	deadline := time.Now().Add(c.mfaTimeout)
+	for {
+		var status duoResponse
+		if err := c.postDuo(frameURL+"/status", url.Values{"sid": {sid}, "txid": {promptResp.Response.TxID}}, &status); err != nil {
+			return "", err
+		}
+
+		switch status.Response.Result {
+		case "SUCCESS":
+			result := status
+			if status.Response.ResultURL != "" {
+				if err := c.postDuo("https://"+host+status.Response.ResultURL, url.Values{"sid": {sid}}, &result); err != nil {
+					return "", err
+				}
+			}
+			if result.Response.Cookie == "" {
+				return "", fmt.Errorf("Duo returned no signed response")
+			}
+			return result.Response.Cookie + ":" + app, nil
+		case "FAILURE":
+			return "", fmt.Errorf("%s", status.Response.Status)
+		}
+
+		if time.Now().After(deadline) {
+			return "", fmt.Errorf("timed out waiting for Duo approval")
+		}
+		if err := c.sleep(time.Second); err != nil {
+			return "", err
+		}
+	}
+}
+
+// postDuo posts a form to the Duo frame API and decodes its JSON response
+func (c *Client) postDuo(endpoint string, values url.Values, v *duoResponse) error {
+	res, err := c.postADFSForm(endpoint, values)
+	if err != nil {
+		return fmt.Errorf("Duo request failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode Duo response: %w", err)
+	}
+	if v.Stat != "OK" {
+		return fmt.Errorf("Duo error: %s", v.Message)
+	}
+	return nil
+}
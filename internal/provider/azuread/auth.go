@@ -2,6 +2,7 @@ package azuread
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,21 +12,24 @@ import (
 	"strings"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/user/azure2aws/internal/output"
 	"github.com/user/azure2aws/internal/provider"
 )
 
 // authenticate is the main authentication state machine
 func (c *Client) authenticate(creds *provider.LoginCredentials) (string, error) {
 	// Start the SAML flow
-	startURL := fmt.Sprintf("%s/applications/redirecttofederatedapplication.aspx?Operation=LinkedSignIn&applicationId=%s",
-		c.baseURL, c.appID)
-
-	res, err := c.httpClient.Get(startURL)
+	c.progress(StageStarted, "Connecting to Azure AD")
+	res, err := c.get(SignInURL(c.baseURL, c.appID, c.tenantID))
 	if err != nil {
 		return "", fmt.Errorf("failed to start authentication: %w", err)
 	}
 
 	// Main authentication loop - state machine
+	steps := &authSteps{max: c.maxAuthSteps}
+	adfsChallenges := 0
+	signInPages, realm, scoped := 0, "", false
+	passwordAccepted, retrieving := false, false
 	for {
 		resBody, err := io.ReadAll(res.Body)
 		if err != nil {
@@ -37,32 +41,91 @@ func (c *Client) authenticate(creds *provider.LoginCredentials) (string, error)
 		// Reset body for potential re-reading
 		res.Body = io.NopCloser(bytes.NewBuffer(resBody))
 
-		switch {
-		case strings.Contains(resBodyStr, "ConvergedSignIn"):
+		state := c.pageState(res, resBodyStr)
+		if err := steps.visit(state, res); err != nil {
+			return "", err
+		}
+
+		// The password is accepted once the sign-in moves past the sign-in
+		// page; ADFS reports a rejected password on its own page
+		if signInPages > 0 && !passwordAccepted && state != stateConvergedSignIn && state != stateADFSChallenge && state != stateUnknown {
+			passwordAccepted = true
+			c.progress(StagePasswordAccepted, "Password accepted")
+		}
+		if (state == stateSAMLRequest || state == stateHiddenForm) && !retrieving {
+			retrieving = true
+			c.progress(StageSAMLAssertion, "Retrieving SAML assertion")
+		}
+
+		switch state {
+		case stateConvergedSignIn:
+			if scopedURL := c.scopedSignInURL(res); scopedURL != "" && !scoped {
+				scoped = true
+				res, err = c.get(scopedURL)
+				if err != nil {
+					return "", fmt.Errorf("failed to restart sign-in in the tenant: %w", err)
+				}
+				continue
+			}
+
+			// A guest is sent on to their home tenant's sign-in page
+			signInPages++
+			if signInPages > maxHomeRealmRedirects+1 {
+				return "", fmt.Errorf("sign-in did not complete after %d home-realm discovery redirects", maxHomeRealmRedirects)
+			}
+			if pageRealm := signInRealm(res); pageRealm != realm {
+				if realm != "" {
+					output.Statusf("Following home-realm discovery to %s\n", pageRealm)
+				}
+				realm = pageRealm
+			}
+
+			c.progress(StageCredentials, "Signing in as %s", creds.Username)
 			res, err = c.processConvergedSignIn(res, resBodyStr, creds)
 			if err != nil {
 				return "", fmt.Errorf("ConvergedSignIn failed: %w", err)
 			}
 
-		case strings.Contains(resBodyStr, "ConvergedTFA"):
+		case stateConvergedTFA:
 			res, err = c.processConvergedTFA(res, resBodyStr, creds)
 			if err != nil {
 				return "", fmt.Errorf("ConvergedTFA failed: %w", err)
 			}
 
-		case strings.Contains(resBodyStr, "KmsiInterrupt"):
+		case stateChangePassword:
+			res, err = c.processChangePassword(resBodyStr, creds)
+			if err != nil {
+				return "", fmt.Errorf("ConvergedChangePassword failed: %w", err)
+			}
+
+		case stateKmsiInterrupt:
 			res, err = c.processKmsiInterrupt(res, resBodyStr)
 			if err != nil {
 				return "", fmt.Errorf("KmsiInterrupt failed: %w", err)
 			}
 
-		case strings.Contains(resBodyStr, "SAMLRequest"):
+		case stateConsent:
+			res, err = c.processConsent(res, resBodyStr)
+			if err != nil {
+				return "", fmt.Errorf("ConvergedConsent failed: %w", err)
+			}
+
+		case stateSAMLRequest:
 			res, err = c.processSAMLRequest(res, resBodyStr)
 			if err != nil {
 				return "", fmt.Errorf("SAMLRequest failed: %w", err)
 			}
 
-		case c.isHiddenForm(resBodyStr):
+		case stateADFSChallenge:
+			if adfsChallenges++; adfsChallenges > maxADFSChallenges {
+				return "", fmt.Errorf("ADFS kept asking for additional authentication")
+			}
+			res, err = c.processADFSChallenge(res, resBodyStr, creds)
+			if err != nil {
+				return "", fmt.Errorf("ADFS authentication failed: %w", err)
+			}
+
+		case stateHiddenForm:
 			if samlAssertion := c.getSAMLAssertion(resBodyStr); samlAssertion != "" {
 				return samlAssertion, nil
 			}
@@ -76,12 +139,18 @@ func (c *Client) authenticate(creds *provider.LoginCredentials) (string, error)
 			if strings.Contains(resBodyStr, "sErrorCode") {
 				var convergedResp ConvergedResponse
 				if err := c.unmarshalEmbeddedJSON(resBodyStr, &convergedResp); err == nil {
+					if err := checkThrottled(convergedResp.SErrorCode, 0); err != nil {
+						return "", err
+					}
 					if convergedResp.SErrorCode != "" && convergedResp.SErrorCode != "50058" {
-						return "", fmt.Errorf("authentication error: %s - %s", convergedResp.SErrorCode, convergedResp.SErrTxt)
+						return "", newAADSTSError(convergedResp.SErrorCode, convergedResp.SErrTxt)
 					}
 				}
 			}
-			return "", fmt.Errorf("reached unknown authentication state")
+			if c.headlessFallback {
+				return c.authenticateHeadless(res)
+			}
+			return "", c.unknownStateError(res, resBodyStr)
 		}
 
 		if err != nil {
@@ -90,6 +159,17 @@ func (c *Client) authenticate(creds *provider.LoginCredentials) (string, error)
 	}
 }
 
+// SignInURL is the URL starting the SAML sign-in to an application, in the
+// given tenant if not empty
+func SignInURL(baseURL, appID, tenantID string) string {
+	signInURL := fmt.Sprintf("%s/applications/redirecttofederatedapplication.aspx?Operation=LinkedSignIn&applicationId=%s",
+		baseURL, appID)
+	if tenantID != "" {
+		signInURL += "&tenantId=" + url.QueryEscape(tenantID)
+	}
+	return signInURL
+}
+
 // processConvergedSignIn handles the converged sign-in page
 func (c *Client) processConvergedSignIn(res *http.Response, resBodyStr string, creds *provider.LoginCredentials) (*http.Response, error) {
 	var convergedResp ConvergedResponse
@@ -105,16 +185,78 @@ func (c *Client) processConvergedSignIn(res *http.Response, resBodyStr string, c
 	if err != nil {
 		return nil, fmt.Errorf("failed to get credential type: %w", err)
 	}
+	if err := checkUserExists(credTypeResp, creds.Username); err != nil {
+		return nil, err
+	}
 
 	// Check if federated authentication is needed
 	if credTypeResp.Credentials.FederationRedirectURL != "" {
 		return c.processFederatedAuth(credTypeResp.Credentials.FederationRedirectURL, creds)
 	}
+	if c.windowsAuth {
+		return nil, fmt.Errorf("adfs_auth is wia, but %s is not a federated (ADFS) account", creds.Username)
+	}
+
+	if c.certAuth {
+		if params := credTypeResp.Credentials.CertAuthParams; params != nil && params.CertAuthURL != "" {
+			return c.processCertAuth(params.CertAuthURL, credTypeResp.FlowToken, &convergedResp)
+		}
+		// The certificate only serves mTLS; sign in with a password
+		if creds.Password == "" {
+			password, err := c.prompts.Password(fmt.Sprintf("Azure AD does not offer certificate-based authentication for %s; password", creds.Username))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read password: %w", err)
+			}
+			creds.Password = password
+		}
+	}
 
 	// Process normal authentication
 	return c.processAuthentication(loginURL, refererURL, creds, &convergedResp)
 }
 
+// checkUserExists fails early when Azure AD does not know the username, which
+// would otherwise surface as a confusing error later in the flow
+func checkUserExists(credTypeResp *GetCredentialTypeResponse, username string) error {
+	tenant := "this tenant"
+	if _, domain, ok := strings.Cut(username, "@"); ok {
+		tenant = domain
+	}
+
+	switch credTypeResp.IfExistsResult {
+	case ifExistsUserNotFound:
+		return &signInError{kind: ErrInvalidCredentials, message: fmt.Sprintf("user %s not found in tenant %s; check the username in your profile (guest accounts may need home_tenant)", username, tenant)}
+	case ifExistsPersonalOnly:
+		return &signInError{kind: ErrInvalidCredentials, message: fmt.Sprintf("%s is a personal Microsoft account, not a work or school account in %s; check the username in your profile", username, tenant)}
+	}
+	return nil
+}
+
+// processCertAuth signs in with the client certificate: the certauth
+// endpoint requests it in the TLS handshake and answers with a form posting
+// the result back to Azure AD
+func (c *Client) processCertAuth(certAuthURL, flowToken string, convergedResp *ConvergedResponse) (*http.Response, error) {
+	if flowToken == "" {
+		flowToken = convergedResp.SFT
+	}
+
+	formValues := url.Values{}
+	formValues.Set("ctx", convergedResp.SCtx)
+	formValues.Set("flowToken", flowToken)
+
+	req, err := c.newRequest("POST", certAuthURL, strings.NewReader(formValues.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate authentication request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("certificate authentication failed: %w", err)
+	}
+	return res, nil
+}
+
 // requestGetCredentialType checks what type of credential the user needs
 func (c *Client) requestGetCredentialType(refererURL string, creds *provider.LoginCredentials, convergedResp *ConvergedResponse) (*GetCredentialTypeResponse, *http.Response, error) {
 	reqBody := GetCredentialTypeRequest{
@@ -129,7 +271,7 @@ func (c *Client) requestGetCredentialType(refererURL string, creds *provider.Log
 		return nil, nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", convergedResp.URLGetCredentialType, strings.NewReader(string(reqBodyJSON)))
+	req, err := c.newRequest("POST", convergedResp.URLGetCredentialType, strings.NewReader(string(reqBodyJSON)))
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -152,14 +294,22 @@ func (c *Client) requestGetCredentialType(refererURL string, creds *provider.Log
 		return nil, res, fmt.Errorf("failed to decode response: %w", err)
 	}
 
+	if err := checkThrottled("", credTypeResp.ThrottleStatus); err != nil {
+		return nil, res, err
+	}
+
 	return &credTypeResp, res, nil
 }
 
 // processAuthentication handles password authentication
 func (c *Client) processAuthentication(loginURL, refererURL string, creds *provider.LoginCredentials, convergedResp *ConvergedResponse) (*http.Response, error) {
+	if err := checkThrottled(convergedResp.SErrorCode, 0); err != nil {
+		return nil, err
+	}
+
 	// Check for login errors (50058 = user not signed in yet, which is expected)
 	if convergedResp.SErrorCode != "" && convergedResp.SErrorCode != "50058" {
-		return nil, fmt.Errorf("login error: %s - %s", convergedResp.SErrorCode, convergedResp.SErrTxt)
+		return nil, newAADSTSError(convergedResp.SErrorCode, convergedResp.SErrTxt)
 	}
 
 	formValues := url.Values{}
@@ -171,7 +321,7 @@ func (c *Client) processAuthentication(loginURL, refererURL string, creds *provi
 	formValues.Set("loginfmt", creds.Username)
 	formValues.Set("passwd", creds.Password)
 
-	req, err := http.NewRequest("POST", loginURL, strings.NewReader(formValues.Encode()))
+	req, err := c.newRequest("POST", loginURL, strings.NewReader(formValues.Encode()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create login request: %w", err)
 	}
@@ -189,7 +339,7 @@ func (c *Client) processAuthentication(loginURL, refererURL string, creds *provi
 
 // processFederatedAuth handles ADFS federation
 func (c *Client) processFederatedAuth(federationURL string, creds *provider.LoginCredentials) (*http.Response, error) {
-	res, err := c.httpClient.Get(federationURL)
+	res, err := c.get(federationURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get federation URL: %w", err)
 	}
@@ -210,11 +360,15 @@ func (c *Client) processFederatedAuth(federationURL string, creds *provider.Logi
 		return nil, fmt.Errorf("ADFS form submit URL not found")
 	}
 
+	if c.windowsAuth {
+		return c.processWindowsAuth(c.fullURL(res, formSubmitURL), formValues)
+	}
+
 	formValues.Set("UserName", creds.Username)
 	formValues.Set("Password", creds.Password)
 	formValues.Set("AuthMethod", "FormsAuthentication")
 
-	req, err := http.NewRequest("POST", c.fullURL(res, formSubmitURL), strings.NewReader(formValues.Encode()))
+	req, err := c.newRequest("POST", c.fullURL(res, formSubmitURL), strings.NewReader(formValues.Encode()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create ADFS login request: %w", err)
 	}
@@ -224,6 +378,84 @@ func (c *Client) processFederatedAuth(federationURL string, creds *provider.Logi
 	return c.httpClient.Do(req)
 }
 
+// maxNegotiateLegs bounds the Negotiate round trips (Kerberos needs one,
+// NTLM two)
+const maxNegotiateLegs = 3
+
+// processWindowsAuth signs in to ADFS with Windows Integrated
+// Authentication: the form is submitted asking for Windows authentication,
+// and the Negotiate challenge of the endpoint it leads to is answered with
+// the signed-in user's Kerberos ticket
+func (c *Client) processWindowsAuth(submitURL string, formValues url.Values) (*http.Response, error) {
+	formValues.Set("AuthMethod", "WindowsAuthentication")
+
+	req, err := c.newRequest("POST", submitURL, strings.NewReader(formValues.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ADFS login request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, ok := provider.NegotiateChallenge(res.Header)
+	if res.StatusCode != http.StatusUnauthorized {
+		return res, nil
+	}
+	if !ok {
+		res.Body.Close()
+		return nil, fmt.Errorf("ADFS does not offer Windows Integrated Authentication at %s; set adfs_auth to forms", res.Request.URL.Host)
+	}
+
+	// Replay the challenged request (after redirects, usually the GET of
+	// the ADFS wia endpoint) with Negotiate tokens
+	challenged := res.Request
+	negotiator, err := provider.NewNegotiator(challenged.URL.Hostname())
+	if err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+	defer negotiator.Close()
+
+	for leg := 1; ; leg++ {
+		res.Body.Close()
+
+		token, err := negotiator.Step(challenge)
+		if err != nil {
+			return nil, err
+		}
+
+		var body io.Reader
+		if challenged.Method == http.MethodPost {
+			body = strings.NewReader(formValues.Encode())
+		}
+		req, err := c.newRequest(challenged.Method, challenged.URL.String(), body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create ADFS login request: %w", err)
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		req.Header.Set("Authorization", "Negotiate "+base64.StdEncoding.EncodeToString(token))
+
+		res, err = c.httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode != http.StatusUnauthorized {
+			return res, nil
+		}
+
+		challenge, ok = provider.NegotiateChallenge(res.Header)
+		if !ok || challenge == nil || leg == maxNegotiateLegs {
+			res.Body.Close()
+			return nil, fmt.Errorf("ADFS rejected Windows Integrated Authentication (check your Kerberos ticket with klist)")
+		}
+	}
+}
+
 // processKmsiInterrupt handles the "Keep Me Signed In" page
 func (c *Client) processKmsiInterrupt(res *http.Response, resBodyStr string) (*http.Response, error) {
 	var convergedResp ConvergedResponse
@@ -234,9 +466,13 @@ func (c *Client) processKmsiInterrupt(res *http.Response, resBodyStr string) (*h
 	formValues := url.Values{}
 	formValues.Set(convergedResp.SFTName, convergedResp.SFT)
 	formValues.Set("ctx", convergedResp.SCtx)
-	formValues.Set("LoginOptions", "1") // Don't stay signed in
+	if c.staySignedIn {
+		formValues.Set("LoginOptions", kmsiStaySignedIn)
+	} else {
+		formValues.Set("LoginOptions", kmsiDontStaySignedIn)
+	}
 
-	req, err := http.NewRequest("POST", c.fullURL(res, convergedResp.URLPost), strings.NewReader(formValues.Encode()))
+	req, err := c.newRequest("POST", c.fullURL(res, convergedResp.URLPost), strings.NewReader(formValues.Encode()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create KMSI request: %w", err)
 	}
@@ -254,6 +490,60 @@ func (c *Client) processKmsiInterrupt(res *http.Response, resBodyStr string) (*h
 	return newRes, nil
 }
 
+// LoginOptions answers of the "Stay signed in?" (KMSI) page
+const (
+	kmsiStaySignedIn     = "1" // Yes
+	kmsiDontStaySignedIn = "3" // No
+)
+
+// processConsent handles the application consent ("review permissions")
+// interstitial shown on first access to an app, after explicit user confirmation
+func (c *Client) processConsent(res *http.Response, resBodyStr string) (*http.Response, error) {
+	var convergedResp ConvergedResponse
+	if err := c.unmarshalEmbeddedJSON(resBodyStr, &convergedResp); err != nil {
+		return nil, fmt.Errorf("failed to parse consent response: %w", err)
+	}
+
+	if convergedResp.URLPost == "" {
+		return nil, fmt.Errorf("consent form URL not found")
+	}
+
+	appName := convergedResp.SAppName
+	if appName == "" {
+		appName = "this application"
+	}
+
+	output.Statusf("Azure AD is asking you to grant %s access to your account.\n", appName)
+	for _, scope := range convergedResp.ArrScopes {
+		if scope.Label != "" {
+			output.Statusf("  - %s\n", scope.Label)
+		}
+	}
+
+	accept, err := c.prompts.Confirm("Accept the requested permissions?", false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read consent confirmation: %w", err)
+	}
+	if !accept {
+		return nil, fmt.Errorf("consent was declined; grant access to the application in a browser or ask an administrator for tenant-wide consent")
+	}
+
+	formValues := url.Values{}
+	formValues.Set(convergedResp.SFTName, convergedResp.SFT)
+	formValues.Set("ctx", convergedResp.SCtx)
+	formValues.Set("canary", convergedResp.Canary)
+	formValues.Set("acceptConsent", "true")
+
+	req, err := c.newRequest("POST", c.fullURL(res, convergedResp.URLPost), strings.NewReader(formValues.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consent request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return c.httpClient.Do(req)
+}
+
 // processSAMLRequest handles SAML request forms
 func (c *Client) processSAMLRequest(res *http.Response, resBodyStr string) (*http.Response, error) {
 	formValues, formSubmitURL, err := c.parseFormData(resBodyStr)
@@ -265,7 +555,7 @@ func (c *Client) processSAMLRequest(res *http.Response, resBodyStr string) (*htt
 		return nil, fmt.Errorf("SAML request form URL not found")
 	}
 
-	req, err := http.NewRequest("POST", c.fullURL(res, formSubmitURL), strings.NewReader(formValues.Encode()))
+	req, err := c.newRequest("POST", c.fullURL(res, formSubmitURL), strings.NewReader(formValues.Encode()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create SAML request: %w", err)
 	}
@@ -286,7 +576,7 @@ func (c *Client) reProcessForm(resBodyStr string) (*http.Response, error) {
 		return nil, fmt.Errorf("form URL not found")
 	}
 
-	req, err := http.NewRequest("POST", formSubmitURL, strings.NewReader(formValues.Encode()))
+	req, err := c.newRequest("POST", formSubmitURL, strings.NewReader(formValues.Encode()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create form request: %w", err)
 	}
@@ -342,12 +632,14 @@ func (c *Client) getSAMLAssertion(html string) string {
 		return ""
 	}
 
-	samlResponse, exists := doc.Find("input[name='SAMLResponse']").Attr("value")
-	if exists {
-		return samlResponse
-	}
+	// Interstitial pages may render several forms; use the first non-empty SAMLResponse
+	var samlResponse string
+	doc.Find("input[name='SAMLResponse']").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		samlResponse = strings.TrimSpace(s.AttrOr("value", ""))
+		return samlResponse == ""
+	})
 
-	return ""
+	return samlResponse
 }
 
 // parseFormData extracts form fields and action URL from HTML
@@ -357,7 +649,11 @@ func (c *Client) parseFormData(html string) (url.Values, string, error) {
 		return nil, "", fmt.Errorf("failed to parse HTML: %w", err)
 	}
 
-	form := doc.Find("form").First()
+	// Prefer the form carrying the SAML payload when a page renders several
+	form := doc.Find("form:has(input[name='SAMLResponse']), form:has(input[name='SAMLRequest'])").First()
+	if form.Length() == 0 {
+		form = doc.Find("form").First()
+	}
 	if form.Length() == 0 {
 		return nil, "", fmt.Errorf("form not found")
 	}
@@ -1,23 +1,120 @@
 package azuread
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"time"
 
+	"github.com/user/azure2aws/internal/output"
+	"github.com/user/azure2aws/internal/prompter"
 	"github.com/user/azure2aws/internal/provider"
 )
 
+// defaultMFATimeout is how long a push or call waits for approval
+const defaultMFATimeout = 2 * time.Minute
+
+// defaultLoginURL is the Azure AD sign-in host of the global cloud
+const defaultLoginURL = "https://login.microsoftonline.com/"
+
 // Client handles Azure AD SAML authentication
 type Client struct {
 	httpClient *provider.HTTPClient
+	// ctx is the context of the running Authenticate; requests and waits
+	// end with it
+	ctx        context.Context
 	baseURL    string
 	appID      string
+	requireMFA bool
+	prompts    *prompter.Scope
+
+	// windowsAuth signs in to ADFS with Windows Integrated Authentication
+	windowsAuth bool
+
+	// headlessFallback continues unrecognised flows in a headless browser
+	headlessFallback bool
+
+	// loginURL is the Azure AD sign-in host
+	loginURL string
+
+	// tenantID scopes the sign-in to the application's tenant
+	tenantID string
+
+	// homeTenant is the home-realm hint for B2B guest accounts
+	homeTenant string
+
+	// certAuth signs in with the client certificate when Azure AD offers
+	// certificate-based authentication
+	certAuth bool
+
+	// staySignedIn answers the "Stay signed in?" prompt with yes
+	staySignedIn bool
+
+	// maxAuthSteps bounds the pages handled in one sign-in
+	maxAuthSteps int
+
+	// mfaTimeout is how long a push or call waits for approval
+	mfaTimeout time.Duration
+
+	// throttleRetries is how often the sign-in flow is restarted when Azure
+	// AD throttles it
+	throttleRetries int
+
+	// changedPassword is the new password set during a forced password
+	// change
+	changedPassword string
+
+	// onProgress receives sign-in progress events; may be nil
+	onProgress ProgressFunc
+
+	// mfaCompleted records whether an MFA challenge was satisfied during
+	// the current authentication flow
+	mfaCompleted bool
 }
 
 // ClientOptions contains configuration for the Azure AD client
 type ClientOptions struct {
-	URL        string // Azure AD base URL (e.g., https://account.activedirectory.windowsazure.com)
-	AppID      string // Azure AD application ID
-	SkipVerify bool   // Skip TLS certificate verification
+	URL          string                      // Azure AD base URL (e.g., https://account.activedirectory.windowsazure.com)
+	AppID        string                      // Azure AD application ID
+	SkipVerify   bool                        // Skip TLS certificate verification
+	CABundle     string                      // PEM file of extra CA certificates to trust
+	HTTP         *provider.HTTPClientOptions // Timeouts and retries (nil uses the defaults)
+	RequireMFA   bool                        // Fail if Azure AD does not challenge for MFA
+	MFATimeout   time.Duration               // Wait for a push or call to be approved (0 uses the default)
+	MaxAuthSteps int                         // Pages handled in one sign-in before giving up (0 uses the default)
+	Profile      string                      // Profile name shown as context for interactive prompts
+	Prompts      *prompter.Scope             // Interactive prompts (nil uses prompter.For(Profile))
+
+	// WindowsAuth signs in to ADFS with the current Kerberos ticket
+	// (Windows Integrated Authentication) instead of the password
+	WindowsAuth bool
+
+	// HeadlessFallback continues in a headless browser when the sign-in
+	// reaches a page the client does not recognise
+	HeadlessFallback bool
+
+	// StaySignedIn answers "Stay signed in?" with yes, so Azure AD issues a
+	// persistent session
+	StaySignedIn bool
+
+	// Progress is called as the sign-in moves through its stages, so that
+	// long waits can be shown; may be nil
+	Progress ProgressFunc
+
+	// LoginURL is the Azure AD sign-in host of the cloud (default
+	// https://login.microsoftonline.com/)
+	LoginURL string
+
+	// TenantID is the application's tenant (ID or domain); the sign-in
+	// starts there instead of at a multi-tenant endpoint
+	TenantID string
+
+	// HomeTenant is the home tenant (domain or ID) of a B2B guest account,
+	// sent as a home-realm hint
+	HomeTenant string
 }
 
 // NewClient creates a new Azure AD authentication client
@@ -34,8 +131,30 @@ func NewClient(opts *ClientOptions) (*Client, error) {
 		return nil, fmt.Errorf("AppID is required")
 	}
 
+	loginURL := opts.LoginURL
+	if loginURL == "" {
+		loginURL = defaultLoginURL
+	}
+	prompts := opts.Prompts
+	if prompts == nil {
+		prompts = prompter.For(opts.Profile)
+	}
+
+	mfaTimeout := opts.MFATimeout
+	if mfaTimeout <= 0 {
+		mfaTimeout = defaultMFATimeout
+	}
+	maxAuthSteps := opts.MaxAuthSteps
+	if maxAuthSteps <= 0 {
+		maxAuthSteps = defaultMaxAuthSteps
+	}
+
 	httpOpts := provider.DefaultHTTPClientOptions()
+	if opts.HTTP != nil {
+		httpOpts = opts.HTTP
+	}
 	httpOpts.SkipVerify = opts.SkipVerify
+	httpOpts.CABundle = opts.CABundle
 
 	httpClient, err := provider.NewHTTPClient(httpOpts)
 	if err != nil {
@@ -44,14 +163,115 @@ func NewClient(opts *ClientOptions) (*Client, error) {
 
 	return &Client{
 		httpClient: httpClient,
+		ctx:        context.Background(),
 		baseURL:    opts.URL,
 		appID:      opts.AppID,
+		requireMFA: opts.RequireMFA,
+		prompts:    prompts,
+
+		windowsAuth:      opts.WindowsAuth,
+		headlessFallback: opts.HeadlessFallback,
+		loginURL:         loginURL,
+		tenantID:         opts.TenantID,
+		homeTenant:       opts.HomeTenant,
+		certAuth:         httpOpts.ClientCert != "",
+
+		staySignedIn:    opts.StaySignedIn,
+		onProgress:      opts.Progress,
+		mfaTimeout:      mfaTimeout,
+		maxAuthSteps:    maxAuthSteps,
+		throttleRetries: httpOpts.ThrottleRetries,
 	}, nil
 }
 
+// sessionURLs are the Azure AD endpoints whose cookies carry the SSO session
+func (c *Client) sessionURLs() []string {
+	return []string{c.loginURL, c.baseURL}
+}
+
+// SessionCookies returns the Azure AD session cookies as JSON, for caching
+func (c *Client) SessionCookies() (string, error) {
+	data, err := json.Marshal(c.httpClient.ExportCookies(c.sessionURLs()))
+	if err != nil {
+		return "", fmt.Errorf("failed to encode session cookies: %w", err)
+	}
+	return string(data), nil
+}
+
+// RestoreSessionCookies loads cookies previously returned by SessionCookies,
+// letting Azure AD reuse the existing sign-in session
+func (c *Client) RestoreSessionCookies(data string) error {
+	var cookies map[string][]*http.Cookie
+	if err := json.Unmarshal([]byte(data), &cookies); err != nil {
+		return fmt.Errorf("failed to decode session cookies: %w", err)
+	}
+	c.httpClient.ImportCookies(cookies)
+	return nil
+}
+
+// EnableHTTPStats starts recording where request time is spent
+func (c *Client) EnableHTTPStats() {
+	c.httpClient.EnableStats()
+}
+
+// HTTPStats returns the request timings recorded since EnableHTTPStats
+func (c *Client) HTTPStats() provider.RequestStats {
+	return c.httpClient.Stats()
+}
+
+// EnableTrace starts recording the authentication flow's requests and
+// responses, with secrets redacted
+func (c *Client) EnableTrace() {
+	c.httpClient.EnableTrace()
+}
+
+// WriteTrace writes the recorded flow to path as a HAR file
+func (c *Client) WriteTrace(path string) error {
+	return c.httpClient.WriteTrace(path)
+}
+
+// StaySignedIn reports whether the client asks Azure AD for a persistent
+// session
+func (c *Client) StaySignedIn() bool {
+	return c.staySignedIn
+}
+
+// ChangedPassword returns the new password if Azure AD required a password
+// change during Authenticate, or ""
+func (c *Client) ChangedPassword() string {
+	return c.changedPassword
+}
+
+// newRequest creates a request bound to the running Authenticate
+func (c *Client) newRequest(method, url string, body io.Reader) (*http.Request, error) {
+	return http.NewRequestWithContext(c.ctx, method, url, body)
+}
+
+// get fetches a URL within the running Authenticate
+func (c *Client) get(url string) (*http.Response, error) {
+	req, err := c.newRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.httpClient.Do(req)
+}
+
+// sleep waits between polls, returning early when the sign-in is cancelled
+func (c *Client) sleep(d time.Duration) error {
+	select {
+	case <-c.ctx.Done():
+		return c.ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
 // Authenticate performs Azure AD SAML authentication
 // Returns the base64-encoded SAML assertion
-func (c *Client) Authenticate(creds *provider.LoginCredentials) (string, error) {
+func (c *Client) Authenticate(ctx context.Context, creds *provider.LoginCredentials) (string, error) {
+	c.ctx = ctx
+	defer func() { c.ctx = context.Background() }()
+
 	if creds == nil {
 		return "", fmt.Errorf("credentials cannot be nil")
 	}
@@ -60,9 +280,38 @@ func (c *Client) Authenticate(creds *provider.LoginCredentials) (string, error)
 		return "", fmt.Errorf("username is required")
 	}
 
-	if creds.Password == "" {
+	if creds.Password == "" && !c.windowsAuth && !c.certAuth {
 		return "", fmt.Errorf("password is required")
 	}
 
-	return c.authenticate(creds)
+	if name := guestSignInName(creds.Username); name != creds.Username {
+		output.Statusf("Signing in to guest account %s as %s\n", creds.Username, name)
+		creds.Username = name
+	}
+
+	var samlAssertion string
+	for attempt := 1; ; attempt++ {
+		c.mfaCompleted = false
+
+		var err error
+		samlAssertion, err = c.authenticate(creds)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errThrottled) || attempt > c.throttleRetries {
+			return "", err
+		}
+
+		wait := provider.ThrottleBackoff(attempt)
+		output.Statusf("Azure AD is throttling sign-ins; retrying in %s (%d/%d)...\n", wait, attempt, c.throttleRetries)
+		if err := c.sleep(wait); err != nil {
+			return "", err
+		}
+	}
+
+	if c.requireMFA && !c.mfaCompleted {
+		return "", fmt.Errorf("MFA is required for this profile but Azure AD did not challenge for MFA (a remembered session may have been reused)")
+	}
+
+	return samlAssertion, nil
 }
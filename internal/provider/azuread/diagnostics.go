@@ -0,0 +1,115 @@
+package azuread
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// pageDiagnostics describes a page the state machine does not recognise.
+// It only holds values that are safe to attach to a bug report: no cookies,
+// form values, query strings or flow tokens.
+type pageDiagnostics struct {
+	Time          time.Time    `json:"time"`
+	URL           string       `json:"url,omitempty"`
+	StatusCode    int          `json:"status_code,omitempty"`
+	Title         string       `json:"title,omitempty"`
+	Pgid          string       `json:"pgid,omitempty"`
+	CorrelationID string       `json:"correlation_id,omitempty"`
+	ErrorCode     string       `json:"error_code,omitempty"`
+	Forms         []formLayout `json:"forms,omitempty"`
+}
+
+// formLayout is a form's action and input names
+type formLayout struct {
+	Action string   `json:"action"`
+	Inputs []string `json:"inputs,omitempty"`
+}
+
+// unknownStateError reports an unrecognised page, pointing at the
+// diagnostics file when one could be written
+func (c *Client) unknownStateError(res *http.Response, html string) error {
+	diag := c.collectDiagnostics(res, html)
+
+	path, err := writeDiagnostics(diag)
+	if err != nil {
+		return fmt.Errorf("reached unknown authentication state (page %q)", diag.Title)
+	}
+
+	return fmt.Errorf("reached unknown authentication state (page %q)\nDiagnostics written to %s; please attach it when reporting this issue", diag.Title, path)
+}
+
+// collectDiagnostics extracts the sanitized page description
+func (c *Client) collectDiagnostics(res *http.Response, html string) *pageDiagnostics {
+	diag := &pageDiagnostics{Time: time.Now().UTC()}
+
+	if res != nil {
+		diag.StatusCode = res.StatusCode
+		if res.Request != nil && res.Request.URL != nil {
+			diag.URL = stripQuery(res.Request.URL.String())
+		}
+	}
+
+	var convergedResp ConvergedResponse
+	if err := c.unmarshalEmbeddedJSON(html, &convergedResp); err == nil {
+		diag.Pgid = convergedResp.Pgid
+		diag.CorrelationID = convergedResp.CorrelationID
+		diag.ErrorCode = convergedResp.SErrorCode
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return diag
+	}
+
+	diag.Title = strings.TrimSpace(doc.Find("title").First().Text())
+	doc.Find("form").Each(func(_ int, form *goquery.Selection) {
+		layout := formLayout{Action: stripQuery(form.AttrOr("action", ""))}
+		form.Find("input").Each(func(_ int, input *goquery.Selection) {
+			if name := input.AttrOr("name", ""); name != "" {
+				layout.Inputs = append(layout.Inputs, name)
+			}
+		})
+		diag.Forms = append(diag.Forms, layout)
+	})
+
+	return diag
+}
+
+// writeDiagnostics writes diagnostics to a new file in the temp directory
+func writeDiagnostics(diag *pageDiagnostics) (string, error) {
+	data, err := json.MarshalIndent(diag, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.CreateTemp("", "azure2aws-unknown-state-*.json")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		return "", err
+	}
+
+	return f.Name(), nil
+}
+
+// stripQuery removes the query and fragment, which may carry tokens
+func stripQuery(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	u.RawQuery = ""
+	u.Fragment = ""
+	u.User = nil
+	return u.String()
+}
@@ -0,0 +1,22 @@
+package azuread
+
+import "strings"
+
+// maxHomeRealmRedirects bounds the sign-in pages of other tenants (home-realm
+// discovery of B2B guests) followed in one sign-in
+const maxHomeRealmRedirects = 3
+
+// guestSignInName returns the sign-in name of a B2B guest configured with its
+// UPN in the resource tenant (alice_partner.com#EXT#@contoso.onmicrosoft.com
+// signs in as alice@partner.com); other usernames are returned unchanged
+func guestSignInName(username string) string {
+	local, _, ok := strings.Cut(username, "#EXT#")
+	if !ok {
+		return username
+	}
+	i := strings.LastIndex(local, "_")
+	if i <= 0 || i == len(local)-1 {
+		return username
+	}
+	return local[:i] + "@" + local[i+1:]
+}
@@ -0,0 +1,124 @@
+package azuread
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"github.com/user/azure2aws/internal/output"
+	"github.com/user/azure2aws/internal/provider"
+)
+
+// headlessTimeout bounds the headless browser fallback, which cannot answer
+// prompts
+const headlessTimeout = 2 * time.Minute
+
+// samlEndpointPatterns match the AWS sign-in endpoints the SAML response is
+// posted to
+var samlEndpointPatterns = []string{
+	"https://signin.aws.amazon.com/saml*",
+	"https://*.signin.aws.amazon.com/saml*",
+	"https://signin.amazonaws-us-gov.com/saml*",
+	"https://signin.amazonaws.cn/saml*",
+}
+
+// authenticateHeadless restarts the sign-in in headless Chrome, with the
+// session cookies obtained so far, for pages that only work with
+// JavaScript. The SAML response the browser posts to AWS is intercepted and
+// returned.
+func (c *Client) authenticateHeadless(res *http.Response) (string, error) {
+	output.Statusln("Sign-in page not recognised; continuing in a headless browser...")
+
+	allocOpts := chromedp.DefaultExecAllocatorOptions[:]
+	if userAgent := c.httpClient.UserAgent(); !strings.HasPrefix(userAgent, provider.UserAgent) {
+		// Keep a configured user_agent, which Conditional Access may rely on
+		allocOpts = append(allocOpts, chromedp.UserAgent(userAgent))
+	}
+	ctx, cancel := chromedp.NewExecAllocator(c.ctx, allocOpts...)
+	defer cancel()
+	ctx, cancel = chromedp.NewContext(ctx)
+	defer cancel()
+	ctx, cancel = context.WithTimeout(ctx, headlessTimeout)
+	defer cancel()
+
+	responses := make(chan string, 1)
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		paused, ok := ev.(*fetch.EventRequestPaused)
+		if !ok {
+			return
+		}
+		if samlResponse := postedSAMLResponse(paused.Request); samlResponse != "" {
+			select {
+			case responses <- samlResponse:
+			default:
+			}
+		}
+		// AWS is not signed in to; the CLI takes over from here
+		go chromedp.Run(ctx, fetch.FailRequest(paused.RequestID, network.ErrorReasonAborted))
+	})
+
+	var patterns []*fetch.RequestPattern
+	for _, p := range samlEndpointPatterns {
+		patterns = append(patterns, &fetch.RequestPattern{URLPattern: p, RequestStage: fetch.RequestStageRequest})
+	}
+
+	urls := c.sessionURLs()
+	if res != nil && res.Request != nil {
+		urls = append(urls, res.Request.URL.Scheme+"://"+res.Request.URL.Host+"/")
+	}
+	var cookies []*network.CookieParam
+	for u, jarCookies := range c.httpClient.ExportCookies(urls) {
+		for _, cookie := range jarCookies {
+			cookies = append(cookies, &network.CookieParam{Name: cookie.Name, Value: cookie.Value, URL: u, Secure: strings.HasPrefix(u, "https:")})
+		}
+	}
+
+	err := chromedp.Run(ctx,
+		fetch.Enable().WithPatterns(patterns),
+		network.SetCookies(cookies),
+		chromedp.Navigate(SignInURL(c.baseURL, c.appID, c.tenantID)),
+	)
+
+	// The navigation fails when the SAML post is aborted, so check for a
+	// response first
+	select {
+	case samlResponse := <-responses:
+		return samlResponse, nil
+	default:
+	}
+	if err != nil && ctx.Err() == nil {
+		return "", fmt.Errorf("headless browser failed (Chrome or Chromium must be installed): %w", err)
+	}
+
+	select {
+	case samlResponse := <-responses:
+		return samlResponse, nil
+	case <-ctx.Done():
+		return "", fmt.Errorf("the headless browser did not reach AWS within %s; the sign-in may need interaction (try 'login --browser')", headlessTimeout)
+	}
+}
+
+// postedSAMLResponse returns the SAMLResponse form value of a request
+func postedSAMLResponse(req *network.Request) string {
+	if req == nil {
+		return ""
+	}
+	var body strings.Builder
+	for _, entry := range req.PostDataEntries {
+		if data, err := base64.StdEncoding.DecodeString(entry.Bytes); err == nil {
+			body.Write(data)
+		}
+	}
+	values, err := url.ParseQuery(body.String())
+	if err != nil {
+		return ""
+	}
+	return values.Get("SAMLResponse")
+}
@@ -7,9 +7,10 @@ import (
 	"net/url"
 	"strings"
 	"time"
+	"unicode"
 
+	"github.com/user/azure2aws/internal/output"
 	"github.com/user/azure2aws/internal/provider"
-	"github.com/user/azure2aws/internal/prompter"
 )
 
 // processConvergedTFA handles MFA (Two-Factor Authentication)
@@ -23,7 +24,7 @@ func (c *Client) processConvergedTFA(res *http.Response, resBodyStr string, cred
 
 	// If there's an option to skip MFA registration, use it
 	if convergedResp.URLSkipMfaRegistration != "" {
-		return c.httpClient.Get(convergedResp.URLSkipMfaRegistration)
+		return c.get(convergedResp.URLSkipMfaRegistration)
 	}
 
 	// Process MFA if available
@@ -34,17 +35,87 @@ func (c *Client) processConvergedTFA(res *http.Response, resBodyStr string, cred
 	return res, nil
 }
 
-// processMFA handles the MFA flow
+// maxCodeAttempts is how many verification codes typed at the prompt may be
+// rejected before MFA fails
+const maxCodeAttempts = 3
+
+// mfaNext is how polling for an MFA request ended
+type mfaNext int
+
+const (
+	mfaAnswered mfaNext = iota // Approved, rejected or failed
+	mfaTimedOut                // Not answered within the MFA timeout
+	mfaResend                  // The user asked for the request to be sent again
+	mfaSwitch                  // The user asked for another method
+)
+
+// processMFA handles the MFA flow. A push or call can be resent, or another
+// method chosen, while waiting for it or once it is not answered within the
+// MFA timeout.
 func (c *Client) processMFA(mfas []UserProof, convergedResp *ConvergedResponse, creds *provider.LoginCredentials) (*http.Response, error) {
 	if len(mfas) == 0 {
 		return nil, fmt.Errorf("no MFA methods available")
 	}
 
-	// Begin MFA authentication
-	mfaResp, err := c.processMFABeginAuth(mfas, convergedResp)
+	proof, err := selectProof(mfas, creds)
 	if err != nil {
-		return nil, fmt.Errorf("MFA BeginAuth failed: %w", err)
+		return nil, err
 	}
+	ctx, flowToken := convergedResp.SCtx, convergedResp.SFT
+
+	var mfaResp *MFAResponse
+	for {
+		// Begin MFA authentication
+		mfaResp, err = c.processMFABeginAuth(proof, ctx, flowToken, convergedResp)
+		if err != nil {
+			return nil, fmt.Errorf("MFA BeginAuth failed: %w", err)
+		}
+
+		var next mfaNext
+		mfaResp, next, err = c.pollMFA(proof, mfaResp, convergedResp, creds)
+		if err != nil {
+			return nil, err
+		}
+		if next == mfaAnswered {
+			break
+		}
+
+		switch next {
+		case mfaTimedOut:
+			proof, err = c.chooseAfterMFATimeout(proof, mfas)
+		case mfaSwitch:
+			proof, err = c.chooseProof(proof, mfas, &signInError{kind: ErrMFARequired, message: "MFA cancelled"})
+		case mfaResend:
+			output.Statusln("Sending the MFA request again.")
+		}
+		if err != nil {
+			return nil, err
+		}
+		ctx, flowToken = mfaResp.Ctx, mfaResp.FlowToken
+	}
+
+	if !mfaResp.Success {
+		return nil, fmt.Errorf("MFA authentication failed")
+	}
+
+	c.mfaCompleted = true
+	c.progress(StageMFACompleted, "MFA completed")
+
+	// Complete MFA authentication
+	return c.processMFAAuth(mfaResp, convergedResp)
+}
+
+// pollMFA ends the MFA authentication begun with mfaResp, polling until the
+// request is approved or rejected. While a push or call waits, "r" asks for
+// it to be resent and "s" for another method; one not answered within the
+// MFA timeout ends with mfaTimedOut. A text message is sent again when the
+// user answers the code prompt with "r". A rejected code fails at once when
+// it came from --mfa-token or the TOTP seed, and after maxCodeAttempts when
+// typed.
+func (c *Client) pollMFA(proof UserProof, mfaResp *MFAResponse, convergedResp *ConvergedResponse, creds *provider.LoginCredentials) (last *MFAResponse, next mfaNext, err error) {
+	deadline := time.Now().Add(c.mfaTimeout)
+	codeAttempts := 0
+	var codeRejected string // Why a rejected code is not asked for again
 
 	// MFA polling loop
 	for i := 0; ; i++ {
@@ -57,64 +128,186 @@ func (c *Client) processMFA(mfas []UserProof, convergedResp *ConvergedResponse,
 		}
 
 		// Handle OTP-based MFA methods
-		if mfaReq.AuthMethodID == MFAPhoneAppOTP || mfaReq.AuthMethodID == MFAOneWaySMS {
-			if creds.MFAToken != "" {
+		if entersCode(mfaReq.AuthMethodID) {
+			if creds.MFAToken != "" && takesMFAToken(mfaReq.AuthMethodID) {
 				mfaReq.AdditionalAuthData = creds.MFAToken
+				codeRejected = "the code from --mfa-token (or AZURE2AWS_MFA_TOKEN) was rejected; pass a current code"
+			} else if creds.TOTPSeed != "" && mfaReq.AuthMethodID == MFAPhoneAppOTP {
+				code, err := provider.GenerateTOTP(creds.TOTPSeed, time.Now())
+				if err != nil {
+					return nil, mfaAnswered, err
+				}
+				mfaReq.AdditionalAuthData = code
+				codeRejected = "the code generated from the TOTP seed was rejected; check the seed and this machine's clock"
 			} else {
-				verifyCode, err := prompter.String("Enter verification code", "")
+				prompt := "Enter verification code"
+				switch mfaReq.AuthMethodID {
+				case MFAOneWaySMS:
+					prompt = "Enter verification code (r to resend the text)"
+				case MFAHardwareOTP:
+					prompt = "Enter the code shown on your hardware token"
+				}
+				verifyCode, err := c.prompts.String(prompt, "")
 				if err != nil {
-					return nil, fmt.Errorf("failed to read verification code: %w", err)
+					return nil, mfaAnswered, fmt.Errorf("failed to read verification code: %w", err)
+				}
+				if mfaReq.AuthMethodID == MFAOneWaySMS && strings.EqualFold(strings.TrimSpace(verifyCode), "r") {
+					if mfaResp, err = c.processMFABeginAuth(proof, mfaResp.Ctx, mfaResp.FlowToken, convergedResp); err != nil {
+						return nil, mfaAnswered, fmt.Errorf("MFA BeginAuth failed: %w", err)
+					}
+					output.Statusln("Text message sent again.")
+					continue
 				}
 				mfaReq.AdditionalAuthData = verifyCode
+				codeAttempts++
+				codeRejected = fmt.Sprintf("the verification code was rejected %d times", codeAttempts)
+			}
+		}
+
+		if i == 0 {
+			switch mfaReq.AuthMethodID {
+			case MFAPhoneAppNotification:
+				c.progress(StageMFAWaiting, "Waiting for approval in the Authenticator app")
+			case MFATwoWayVoiceMobile:
+				c.progress(StageMFAWaiting, "Waiting for the call to %s to be answered", proof.Display)
 			}
 		}
 
 		// Handle push notification on first iteration
 		if mfaReq.AuthMethodID == MFAPhoneAppNotification && i == 0 {
 			if mfaResp.Entropy == 0 {
-				fmt.Println("Phone approval required.")
+				output.Statusf("Phone approval required (waiting up to %s).\n", c.mfaTimeout)
 			} else {
-				fmt.Printf("Phone approval required. Number match: %d\n", mfaResp.Entropy)
+				output.Statusf("Phone approval required (waiting up to %s). Number match: %d\n", c.mfaTimeout, mfaResp.Entropy)
 			}
 		}
+		if awaitsApproval(mfaReq.AuthMethodID) && i == 0 && c.prompts.ReadsKeys() {
+			output.Statusln("Press r to send the request again, or s to use another method.")
+		}
 
 		// End MFA authentication
 		mfaResp, err = c.processMFAEndAuth(mfaReq, convergedResp)
 		if err != nil {
-			return nil, fmt.Errorf("MFA EndAuth failed: %w", err)
+			return nil, mfaAnswered, fmt.Errorf("MFA EndAuth failed: %w", err)
 		}
 
 		if mfaResp.ErrCode != 0 {
-			return nil, fmt.Errorf("MFA error %d: %v", mfaResp.ErrCode, mfaResp.Message)
+			return nil, mfaAnswered, fmt.Errorf("MFA error %d: %v", mfaResp.ErrCode, mfaResp.Message)
 		}
 
-		if mfaResp.Success {
-			break
+		if mfaResp.Success || !mfaResp.Retry {
+			return mfaResp, mfaAnswered, nil
 		}
 
-		if !mfaResp.Retry {
-			break
+		// A code method asks to retry when the code was wrong; the same code
+		// would be rejected again
+		if entersCode(mfaReq.AuthMethodID) {
+			if codeAttempts == 0 || codeAttempts >= maxCodeAttempts {
+				return nil, mfaAnswered, &signInError{kind: ErrMFARequired, message: codeRejected}
+			}
+			output.Statusln("The verification code was not accepted; try again.")
+			continue
+		}
+
+		if awaitsApproval(mfaResp.AuthMethodID) && time.Now().After(deadline) {
+			return mfaResp, mfaTimedOut, nil
 		}
 
-		// Wait before polling again
+		// Wait before polling again, or until r or s is pressed
+		wait := 2 * time.Second // Default polling interval
 		if interval, ok := convergedResp.OPerAuthPollingInterval[mfaResp.AuthMethodID]; ok {
-			time.Sleep(time.Duration(interval) * time.Second)
-		} else {
-			time.Sleep(2 * time.Second) // Default polling interval
+			wait = time.Duration(interval) * time.Second
+		}
+		if !awaitsApproval(mfaResp.AuthMethodID) {
+			if err := c.sleep(wait); err != nil {
+				return nil, mfaAnswered, err
+			}
+			continue
+		}
+		key, err := c.prompts.Key(c.ctx, wait)
+		if err != nil {
+			return nil, mfaAnswered, err
+		}
+		switch unicode.ToLower(key) {
+		case 'r':
+			return mfaResp, mfaResend, nil
+		case 's':
+			return mfaResp, mfaSwitch, nil
 		}
 	}
+}
 
-	if !mfaResp.Success {
-		return nil, fmt.Errorf("MFA authentication failed")
+// entersCode reports whether an MFA method is answered with a one-time code
+func entersCode(authMethodID string) bool {
+	return authMethodID == MFAPhoneAppOTP || authMethodID == MFAOneWaySMS || authMethodID == MFAHardwareOTP
+}
+
+// takesMFAToken reports whether an MFA method is answered with a code the
+// user holds, so that --mfa-token can answer it; a texted code is only known
+// once the text is sent
+func takesMFAToken(authMethodID string) bool {
+	return authMethodID == MFAPhoneAppOTP || authMethodID == MFAHardwareOTP
+}
+
+// awaitsApproval reports whether an MFA method waits for the user to answer
+// on another device, rather than for a code
+func awaitsApproval(authMethodID string) bool {
+	return authMethodID == MFAPhoneAppNotification || authMethodID == MFATwoWayVoiceMobile
+}
+
+// chooseAfterMFATimeout asks whether to resend the unanswered push or call,
+// or to switch to another method
+func (c *Client) chooseAfterMFATimeout(proof UserProof, mfas []UserProof) (UserProof, error) {
+	timeoutErr := &signInError{kind: ErrMFARequired, message: fmt.Sprintf(
+		"the MFA request was not answered within %s; check that your phone is online, or raise mfa_timeout", c.mfaTimeout)}
+
+	output.Statusf("No response to the MFA request within %s.\n", c.mfaTimeout)
+	return c.chooseProof(proof, mfas, timeoutErr)
+}
+
+// chooseProof asks whether to resend the MFA request for proof, or to switch
+// to another method. Returns cancelErr when the user cancels.
+func (c *Client) chooseProof(proof UserProof, mfas []UserProof, cancelErr error) (UserProof, error) {
+	options := []string{"Resend: " + proofLabel(proof)}
+	choices := []UserProof{proof}
+	for _, other := range mfas {
+		if other.AuthMethodID != proof.AuthMethodID || other.Data != proof.Data {
+			options = append(options, "Switch: "+proofLabel(other))
+			choices = append(choices, other)
+		}
 	}
+	options = append(options, "Cancel")
 
-	// Complete MFA authentication
-	return c.processMFAAuth(mfaResp, convergedResp)
+	idx, err := c.prompts.Select("What next?", options)
+	if err != nil || idx == len(choices) {
+		return UserProof{}, cancelErr
+	}
+	return choices[idx], nil
 }
 
-// processMFABeginAuth initiates MFA authentication
-func (c *Client) processMFABeginAuth(mfas []UserProof, convergedResp *ConvergedResponse) (*MFAResponse, error) {
-	// Select MFA method (prefer default, otherwise first available)
+// proofLabel describes an MFA method
+func proofLabel(proof UserProof) string {
+	switch proof.AuthMethodID {
+	case MFAPhoneAppNotification:
+		return "approve a notification in the Authenticator app"
+	case MFAPhoneAppOTP:
+		return "enter a code from the Authenticator app"
+	case MFAHardwareOTP:
+		return "enter a code from the hardware token"
+	case MFAOneWaySMS:
+		return "text a code to " + proof.Display
+	case MFATwoWayVoiceMobile:
+		return "call " + proof.Display
+	default:
+		return strings.TrimSpace(proof.AuthMethodID + " " + proof.Display)
+	}
+}
+
+// selectProof picks the MFA method: the default one, or the authenticator
+// app code when a TOTP seed or MFA token can answer it, or else the hardware
+// token code when an MFA token can. An MFA token with neither method is an
+// error, rather than being sent as a texted code.
+func selectProof(mfas []UserProof, creds *provider.LoginCredentials) (UserProof, error) {
 	mfa := mfas[0]
 	for _, v := range mfas {
 		if v.IsDefault {
@@ -123,11 +316,38 @@ func (c *Client) processMFABeginAuth(mfas []UserProof, convergedResp *ConvergedR
 		}
 	}
 
+	if creds.TOTPSeed != "" || creds.MFAToken != "" {
+		for _, v := range mfas {
+			if v.AuthMethodID == MFAPhoneAppOTP {
+				return v, nil
+			}
+		}
+	}
+	if creds.MFAToken != "" {
+		for _, v := range mfas {
+			if v.AuthMethodID == MFAHardwareOTP {
+				return v, nil
+			}
+		}
+
+		labels := make([]string, len(mfas))
+		for i, v := range mfas {
+			labels[i] = proofLabel(v)
+		}
+		return UserProof{}, &signInError{kind: ErrMFARequired, message: fmt.Sprintf(
+			"--mfa-token (or AZURE2AWS_MFA_TOKEN) cannot be used: it answers authenticator app or hardware token codes, but this account can only %s; log in without it",
+			strings.Join(labels, " or "))}
+	}
+	return mfa, nil
+}
+
+// processMFABeginAuth initiates MFA authentication with the given method
+func (c *Client) processMFABeginAuth(mfa UserProof, ctx, flowToken string, convergedResp *ConvergedResponse) (*MFAResponse, error) {
 	mfaReq := MFARequest{
 		AuthMethodID: mfa.AuthMethodID,
 		Method:       "BeginAuth",
-		Ctx:          convergedResp.SCtx,
-		FlowToken:    convergedResp.SFT,
+		Ctx:          ctx,
+		FlowToken:    flowToken,
 	}
 
 	mfaReqJSON, err := json.Marshal(mfaReq)
@@ -135,7 +355,7 @@ func (c *Client) processMFABeginAuth(mfas []UserProof, convergedResp *ConvergedR
 		return nil, fmt.Errorf("failed to marshal MFA request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", convergedResp.URLBeginAuth, strings.NewReader(string(mfaReqJSON)))
+	req, err := c.newRequest("POST", convergedResp.URLBeginAuth, strings.NewReader(string(mfaReqJSON)))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create MFA BeginAuth request: %w", err)
 	}
@@ -167,7 +387,7 @@ func (c *Client) processMFAEndAuth(mfaReq MFARequest, convergedResp *ConvergedRe
 		return nil, fmt.Errorf("failed to marshal MFA request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", convergedResp.URLEndAuth, strings.NewReader(string(mfaReqJSON)))
+	req, err := c.newRequest("POST", convergedResp.URLEndAuth, strings.NewReader(string(mfaReqJSON)))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create MFA EndAuth request: %w", err)
 	}
@@ -197,7 +417,7 @@ func (c *Client) processMFAAuth(mfaResp *MFAResponse, convergedResp *ConvergedRe
 	formValues.Set("login", convergedResp.SPOSTUsername)
 	formValues.Set(convergedResp.SFTName, mfaResp.FlowToken)
 
-	req, err := http.NewRequest("POST", convergedResp.URLPost, strings.NewReader(formValues.Encode()))
+	req, err := c.newRequest("POST", convergedResp.URLPost, strings.NewReader(formValues.Encode()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create MFA completion request: %w", err)
 	}
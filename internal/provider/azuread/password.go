@@ -0,0 +1,150 @@
+package azuread
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/user/azure2aws/internal/output"
+	"github.com/user/azure2aws/internal/provider"
+)
+
+// passwordChangeURL is where users change their password in a browser
+const passwordChangeURL = "https://mysignins.microsoft.com/security-info/password/change"
+
+// maxPasswordChangePolls bounds the wait for Azure AD to apply a new password
+const maxPasswordChangePolls = 30
+
+// ssprRequest is the request body of the password change endpoints
+type ssprRequest struct {
+	Ctx         string `json:"Ctx"`
+	FlowToken   string `json:"FlowToken"`
+	OldPassword string `json:"OldPassword,omitempty"`
+	NewPassword string `json:"NewPassword,omitempty"`
+}
+
+// ssprResponse is the response of the password change endpoints
+type ssprResponse struct {
+	Ctx         string          `json:"Ctx"`
+	FlowToken   string          `json:"FlowToken"`
+	ErrorCode   json.RawMessage `json:"ErrorCode"`
+	Message     string          `json:"Message"`
+	IsCompleted bool            `json:"IsCompleted"`
+}
+
+// failed reports whether the response carries an error code
+func (r *ssprResponse) failed() bool {
+	code := strings.Trim(string(r.ErrorCode), `"`)
+	return code != "" && code != "0" && code != "null"
+}
+
+// processChangePassword handles the page shown when the password has
+// expired or must be changed. The user is offered to set a new one; otherwise
+// the error explains where to change it.
+func (c *Client) processChangePassword(resBodyStr string, creds *provider.LoginCredentials) (*http.Response, error) {
+	required := fmt.Errorf("Azure AD requires a new password for %s; change it at %s, then log in again", creds.Username, passwordChangeURL)
+
+	var convergedResp ConvergedResponse
+	if err := c.unmarshalEmbeddedJSON(resBodyStr, &convergedResp); err != nil || convergedResp.URLAsyncSsprBegin == "" || creds.Password == "" {
+		return nil, required
+	}
+
+	output.Statusf("Azure AD requires a new password for %s.\n", creds.Username)
+	if change, err := c.prompts.Confirm("Change the password now?", true); err != nil || !change {
+		return nil, required
+	}
+
+	newPassword, err := c.promptNewPassword()
+	if err != nil {
+		return nil, err
+	}
+
+	var resp ssprResponse
+	err = c.postSSPR(convergedResp.URLAsyncSsprBegin, ssprRequest{
+		Ctx:         convergedResp.SCtx,
+		FlowToken:   convergedResp.SFT,
+		OldPassword: creds.Password,
+		NewPassword: newPassword,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; !resp.IsCompleted; i++ {
+		if i == maxPasswordChangePolls {
+			return nil, fmt.Errorf("timed out waiting for the password change")
+		}
+		if err := c.sleep(time.Second); err != nil {
+			return nil, err
+		}
+		if err := c.postSSPR(convergedResp.URLAsyncSsprPoll, ssprRequest{Ctx: resp.Ctx, FlowToken: resp.FlowToken}, &resp); err != nil {
+			return nil, err
+		}
+	}
+
+	c.changedPassword = newPassword
+	creds.Password = newPassword
+	output.Statusln("Password changed.")
+
+	formValues := url.Values{}
+	formValues.Set("ctx", resp.Ctx)
+	formValues.Set(convergedResp.SFTName, resp.FlowToken)
+	formValues.Set("canary", convergedResp.Canary)
+
+	req, err := c.newRequest("POST", convergedResp.URLPost, strings.NewReader(formValues.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create password change completion request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return c.httpClient.Do(req)
+}
+
+// promptNewPassword asks for the new password twice
+func (c *Client) promptNewPassword() (string, error) {
+	newPassword, err := c.prompts.Password("New password")
+	if err != nil {
+		return "", fmt.Errorf("failed to read new password: %w", err)
+	}
+	confirm, err := c.prompts.Password("Confirm new password")
+	if err != nil {
+		return "", fmt.Errorf("failed to read new password: %w", err)
+	}
+	if newPassword == "" || newPassword != confirm {
+		return "", fmt.Errorf("the new passwords are empty or do not match")
+	}
+	return newPassword, nil
+}
+
+// postSSPR posts to a password change endpoint
+func (c *Client) postSSPR(endpoint string, body ssprRequest, resp *ssprResponse) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal password change request: %w", err)
+	}
+
+	req, err := c.newRequest("POST", endpoint, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create password change request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("password change request failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	*resp = ssprResponse{}
+	if err := json.NewDecoder(res.Body).Decode(resp); err != nil {
+		return fmt.Errorf("failed to decode password change response: %w", err)
+	}
+	if resp.failed() {
+		return fmt.Errorf("password change rejected: %s", resp.Message)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package azuread
+
+import (
+	"fmt"
+
+	"github.com/user/azure2aws/internal/logging"
+)
+
+// Stage is a step of the sign-in reported through ClientOptions.Progress
+type Stage string
+
+// Sign-in stages, in the order they are usually reached. Stages that do not
+// apply (MFA with a remembered session, the password with SSO) are skipped.
+const (
+	StageStarted          Stage = "started"           // The sign-in page is being loaded
+	StageCredentials      Stage = "credentials"       // The username and password are being submitted
+	StagePasswordAccepted Stage = "password-accepted" // Azure AD (or ADFS) accepted the password
+	StageMFAWaiting       Stage = "mfa-waiting"       // A push or call is waiting for approval
+	StageMFACompleted     Stage = "mfa-completed"     // The MFA challenge was satisfied
+	StageSAMLAssertion    Stage = "saml-assertion"    // The SAML assertion is being retrieved
+)
+
+// ProgressEvent reports that the sign-in reached a stage
+type ProgressEvent struct {
+	Stage   Stage
+	Message string // Human-readable description, e.g. "Password accepted"
+}
+
+// ProgressFunc receives progress events. It is called synchronously from
+// Authenticate and should return quickly.
+type ProgressFunc func(ProgressEvent)
+
+// progress reports a stage to the ProgressFunc, if any
+func (c *Client) progress(stage Stage, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	logging.Debug("Sign-in progress", "stage", string(stage), "message", message)
+	if c.onProgress != nil {
+		c.onProgress(ProgressEvent{Stage: stage, Message: message})
+	}
+}
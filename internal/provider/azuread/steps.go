@@ -0,0 +1,101 @@
+package azuread
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/user/azure2aws/internal/logging"
+)
+
+// defaultMaxAuthSteps bounds the pages handled in one sign-in
+const defaultMaxAuthSteps = 30
+
+// maxRepeatedSteps is how often the same page (state and URL) may be seen
+// before the sign-in is considered to be looping. ADFS pages legitimately
+// repeat up to maxADFSChallenges times.
+const maxRepeatedSteps = maxADFSChallenges
+
+// Page states of the sign-in state machine
+const (
+	stateConvergedSignIn = "ConvergedSignIn"
+	stateConvergedTFA    = "ConvergedTFA"
+	stateChangePassword  = "ConvergedChangePassword"
+	stateKmsiInterrupt   = "KmsiInterrupt"
+	stateConsent         = "ConvergedConsent"
+	stateSAMLRequest     = "SAMLRequest"
+	stateADFSChallenge   = "ADFSChallenge"
+	stateHiddenForm      = "HiddenForm"
+	stateUnknown         = "Unknown"
+)
+
+// pageState classifies a sign-in page
+func (c *Client) pageState(res *http.Response, html string) string {
+	switch {
+	case strings.Contains(html, "ConvergedSignIn"):
+		return stateConvergedSignIn
+	case strings.Contains(html, "ConvergedTFA"):
+		return stateConvergedTFA
+	case strings.Contains(html, "ConvergedChangePassword"):
+		return stateChangePassword
+	case strings.Contains(html, "KmsiInterrupt"):
+		return stateKmsiInterrupt
+	case strings.Contains(html, "ConvergedConsent"):
+		return stateConsent
+	case strings.Contains(html, "SAMLRequest"):
+		return stateSAMLRequest
+	case isADFSChallenge(res, html):
+		return stateADFSChallenge
+	case c.isHiddenForm(html):
+		return stateHiddenForm
+	default:
+		return stateUnknown
+	}
+}
+
+// authStep is a page handled during a sign-in
+type authStep struct {
+	state string
+	url   string // Host and path; the query may hold tokens
+}
+
+// authSteps records the pages of a sign-in to stop it when it runs too long
+// or bounces between the same pages
+type authSteps struct {
+	max   int
+	steps []authStep
+}
+
+// visit records a page, failing if the sign-in takes too many steps or keeps
+// coming back to the same page
+func (s *authSteps) visit(state string, res *http.Response) error {
+	step := authStep{state: state}
+	if res != nil && res.Request != nil {
+		step.url = res.Request.URL.Host + res.Request.URL.Path
+	}
+	s.steps = append(s.steps, step)
+	logging.Debug("Sign-in step", "n", len(s.steps), "state", step.state, "url", step.url)
+
+	if len(s.steps) > s.max {
+		return fmt.Errorf("sign-in did not complete within %d steps:\n%s", s.max, s)
+	}
+	repeats := 0
+	for _, prev := range s.steps {
+		if prev == step {
+			repeats++
+		}
+	}
+	if repeats > maxRepeatedSteps {
+		return fmt.Errorf("sign-in is looping (%s at %s seen %d times):\n%s", step.state, step.url, repeats, s)
+	}
+	return nil
+}
+
+// String lists the steps, one per line
+func (s *authSteps) String() string {
+	var sb strings.Builder
+	for i, step := range s.steps {
+		fmt.Fprintf(&sb, "  %2d. %s %s\n", i+1, step.state, step.url)
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
@@ -0,0 +1,54 @@
+package azuread
+
+import (
+	"net/http"
+	"strings"
+)
+
+// multiTenantSegments are the tenant-independent endpoints of a sign-in URL
+var multiTenantSegments = map[string]bool{"common": true, "organizations": true}
+
+// scopedSignInURL returns the sign-in request of res moved to the configured
+// tenant (instead of a multi-tenant endpoint, where users of several tenants
+// get an account picker) and with the home tenant hint (whr) of a guest
+// added; "" if there is nothing to change
+func (c *Client) scopedSignInURL(res *http.Response) string {
+	if res.Request == nil || res.Request.Method != http.MethodGet {
+		return ""
+	}
+	u := *res.Request.URL
+	changed := false
+
+	if c.tenantID != "" {
+		segment, rest, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+		if multiTenantSegments[strings.ToLower(segment)] {
+			u.Path = "/" + c.tenantID + "/" + rest
+			u.RawPath = ""
+			changed = true
+		}
+	}
+
+	if c.homeTenant != "" {
+		q := u.Query()
+		if q.Get("whr") == "" {
+			q.Set("whr", c.homeTenant)
+			u.RawQuery = q.Encode()
+			changed = true
+		}
+	}
+
+	if !changed {
+		return ""
+	}
+	return u.String()
+}
+
+// signInRealm identifies the tenant a sign-in page belongs to: its host and
+// the tenant segment of the path
+func signInRealm(res *http.Response) string {
+	if res.Request == nil {
+		return ""
+	}
+	tenant, _, _ := strings.Cut(strings.TrimPrefix(res.Request.URL.Path, "/"), "/")
+	return res.Request.URL.Host + "/" + tenant
+}
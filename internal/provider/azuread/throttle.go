@@ -0,0 +1,26 @@
+package azuread
+
+import (
+	"errors"
+	"fmt"
+)
+
+// throttleErrorCode is AADSTS90033, returned when Azure AD is too busy to
+// process a sign-in ("A transient error has occurred. Please try again.")
+const throttleErrorCode = "90033"
+
+// errThrottled reports that Azure AD asked the client to slow down; the
+// sign-in flow is restarted after a backoff
+var errThrottled = errors.New("Azure AD is throttling sign-in requests")
+
+// checkThrottled returns errThrottled for AADSTS90033 or a throttled
+// GetCredentialType response
+func checkThrottled(errorCode string, throttleStatus int) error {
+	if errorCode == throttleErrorCode {
+		return fmt.Errorf("%w (AADSTS%s)", errThrottled, errorCode)
+	}
+	if throttleStatus == 1 {
+		return fmt.Errorf("%w (ThrottleStatus %d)", errThrottled, throttleStatus)
+	}
+	return nil
+}
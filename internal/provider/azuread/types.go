@@ -24,6 +24,15 @@ type ConvergedResponse struct {
 	Canary                  string             `json:"canary"`
 	CorrelationID           string             `json:"correlationId"`
 	SessionID               string             `json:"sessionId"`
+	SAppName                string             `json:"sAppName"`
+	ArrScopes               []ConsentScope     `json:"arrScopes"`
+	URLAsyncSsprBegin       string             `json:"urlAsyncSsprBegin"`
+	URLAsyncSsprPoll        string             `json:"urlAsyncSsprPoll"`
+}
+
+// ConsentScope is a permission requested on the application consent page
+type ConsentScope struct {
+	Label string `json:"label"`
 }
 
 // GetCredentialTypeRequest is the request body for credential type detection
@@ -53,21 +62,32 @@ type GetCredentialTypeResponse struct {
 	IsUnmanaged    bool   `json:"IsUnmanaged"`
 	ThrottleStatus int    `json:"ThrottleStatus"`
 	Credentials    struct {
-		PrefCredential        int         `json:"PrefCredential"`
-		HasPassword           bool        `json:"HasPassword"`
-		RemoteNgcParams       interface{} `json:"RemoteNgcParams"`
-		FidoParams            interface{} `json:"FidoParams"`
-		SasParams             interface{} `json:"SasParams"`
-		CertAuthParams        interface{} `json:"CertAuthParams"`
-		GoogleParams          interface{} `json:"GoogleParams"`
-		FacebookParams        interface{} `json:"FacebookParams"`
-		FederationRedirectURL string      `json:"FederationRedirectUrl"`
+		PrefCredential        int             `json:"PrefCredential"`
+		HasPassword           bool            `json:"HasPassword"`
+		RemoteNgcParams       interface{}     `json:"RemoteNgcParams"`
+		FidoParams            interface{}     `json:"FidoParams"`
+		SasParams             interface{}     `json:"SasParams"`
+		CertAuthParams        *CertAuthParams `json:"CertAuthParams"`
+		GoogleParams          interface{}     `json:"GoogleParams"`
+		FacebookParams        interface{}     `json:"FacebookParams"`
+		FederationRedirectURL string          `json:"FederationRedirectUrl"`
 	} `json:"Credentials"`
 	FlowToken          string `json:"FlowToken"`
 	IsSignupDisallowed bool   `json:"IsSignupDisallowed"`
 	APICanary          string `json:"apiCanary"`
 }
 
+// IfExistsResult values of GetCredentialTypeResponse
+const (
+	ifExistsUserNotFound = 1 // No such user in the tenant of the username's domain
+	ifExistsPersonalOnly = 5 // Only a personal Microsoft account exists
+)
+
+// CertAuthParams describes certificate-based authentication offered for a user
+type CertAuthParams struct {
+	CertAuthURL string `json:"CertAuthUrl"`
+}
+
 // MFARequest is the request body for MFA operations
 type MFARequest struct {
 	AuthMethodID       string `json:"AuthMethodId"`
@@ -108,4 +128,5 @@ const (
 	MFAPhoneAppNotification = "PhoneAppNotification"
 	MFAOneWaySMS            = "OneWaySMS"
 	MFATwoWayVoiceMobile    = "TwoWayVoiceMobile"
+	MFAHardwareOTP          = "HardwareOTP" // OATH hardware token assigned by the tenant
 )
@@ -0,0 +1,335 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// redacted replaces sensitive values in traces
+	redacted = "[REDACTED]"
+
+	// maxTraceBody caps the body size kept per request or response
+	maxTraceBody = 256 << 10
+)
+
+// sensitiveFields are header, query, form and JSON field names whose values
+// are never written to a trace (compared case-insensitively)
+var sensitiveFields = map[string]bool{
+	"authorization": true, "cookie": true, "set-cookie": true, "proxy-authorization": true,
+	"passwd": true, "password": true, "otc": true, "additionalauthdata": true,
+	"samlresponse": true, "wresult": true, "sft": true, "flowtoken": true,
+	"ctx": true, "sctx": true, "originalrequest": true,
+	"canary": true, "apicanary": true, "code": true, "access_token": true,
+	"id_token": true, "refresh_token": true, "client_secret": true,
+}
+
+var (
+	// htmlSensitiveInput matches the value of sensitive hidden inputs
+	htmlSensitiveInput = regexp.MustCompile(`(?i)(<input[^>]*name=["'](?:passwd|password|samlresponse|wresult|flowtoken|ctx|canary|sft|code)["'][^>]*value=["'])[^"']*`)
+	// htmlSensitiveInputValueFirst handles inputs with value before name
+	htmlSensitiveInputValueFirst = regexp.MustCompile(`(?i)(<input[^>]*value=["'])[^"']*(["'][^>]*name=["'](?:passwd|password|samlresponse|wresult|flowtoken|ctx|canary|sft|code)["'])`)
+	// embeddedSensitiveJSON matches sensitive string fields in inline scripts
+	embeddedSensitiveJSON = regexp.MustCompile(`(?i)("(?:sFT|sCtx|canary|apiCanary|flowToken|ctx|originalRequest)"\s*:\s*")[^"]*`)
+)
+
+// HAR (HTTP Archive 1.2) structures, limited to the fields azure2aws records
+type harLog struct {
+	Log struct {
+		Version string     `json:"version"`
+		Creator harCreator `json:"creator"`
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Timings         harTimings  `json:"timings"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+	Error       string         `json:"_error,omitempty"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// traceTransport records every round trip, including redirects, as a HAR entry
+type traceTransport struct {
+	base http.RoundTripper
+
+	mu      sync.Mutex
+	entries []harEntry
+}
+
+// EnableTrace starts recording requests and responses (with secrets
+// redacted) for WriteTrace
+func (c *HTTPClient) EnableTrace() {
+	if _, ok := c.Client.Transport.(*traceTransport); ok {
+		return
+	}
+	base := c.Client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	c.Client.Transport = &traceTransport{base: base}
+}
+
+// WriteTrace writes the recorded requests to path as HAR JSON
+func (c *HTTPClient) WriteTrace(path string) error {
+	t, ok := c.Client.Transport.(*traceTransport)
+	if !ok {
+		return fmt.Errorf("tracing is not enabled")
+	}
+
+	var har harLog
+	har.Log.Version = "1.2"
+	har.Log.Creator = harCreator{Name: "azure2aws", Version: strings.TrimPrefix(UserAgent, "azure2aws/")}
+	t.mu.Lock()
+	har.Log.Entries = append([]harEntry{}, t.entries...)
+	t.mu.Unlock()
+
+	data, err := json.MarshalIndent(har, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal trace: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write trace file: %w", err)
+	}
+	return nil
+}
+
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	entry := harEntry{
+		StartedDateTime: time.Now().UTC(),
+		Request: harRequest{
+			Method:      req.Method,
+			URL:         redactURL(req.URL),
+			HTTPVersion: req.Proto,
+			Headers:     redactHeaders(req.Header),
+			QueryString: redactValues(req.URL.Query()),
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+	}
+
+	if req.Body != nil && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			data, _ := io.ReadAll(io.LimitReader(body, maxTraceBody))
+			body.Close()
+			mimeType := req.Header.Get("Content-Type")
+			entry.Request.BodySize = len(data)
+			entry.Request.PostData = &harPostData{MimeType: mimeType, Text: redactBody(mimeType, string(data))}
+		}
+	}
+
+	start := time.Now()
+	res, err := t.base.RoundTrip(req)
+	wait := time.Since(start)
+
+	if err != nil {
+		entry.Response = harResponse{Status: 0, HeadersSize: -1, BodySize: -1, Error: err.Error()}
+		entry.Time = ms(wait)
+		entry.Timings = harTimings{Wait: ms(wait)}
+		t.record(entry)
+		return res, err
+	}
+
+	receiveStart := time.Now()
+	data, readErr := io.ReadAll(res.Body)
+	res.Body.Close()
+	res.Body = io.NopCloser(bytes.NewReader(data))
+	receive := time.Since(receiveStart)
+
+	mimeType := res.Header.Get("Content-Type")
+	text := string(data)
+	if len(text) > maxTraceBody {
+		text = text[:maxTraceBody]
+	}
+	entry.Response = harResponse{
+		Status:      res.StatusCode,
+		StatusText:  strings.TrimSpace(strings.TrimPrefix(res.Status, fmt.Sprint(res.StatusCode))),
+		HTTPVersion: res.Proto,
+		Headers:     redactHeaders(res.Header),
+		Content:     harContent{Size: len(data), MimeType: mimeType, Text: redactBody(mimeType, text)},
+		RedirectURL: res.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    len(data),
+	}
+	if readErr != nil {
+		entry.Response.Error = readErr.Error()
+	}
+	if entry.Response.RedirectURL != "" {
+		if u, err := url.Parse(entry.Response.RedirectURL); err == nil {
+			entry.Response.RedirectURL = redactURL(u)
+		}
+	}
+	entry.Time = ms(wait + receive)
+	entry.Timings = harTimings{Wait: ms(wait), Receive: ms(receive)}
+
+	t.record(entry)
+	if readErr != nil {
+		return nil, readErr
+	}
+	return res, nil
+}
+
+func (t *traceTransport) record(entry harEntry) {
+	t.mu.Lock()
+	t.entries = append(t.entries, entry)
+	t.mu.Unlock()
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func isSensitive(name string) bool {
+	return sensitiveFields[strings.ToLower(name)]
+}
+
+func redactHeaders(h http.Header) []harNameValue {
+	headers := make([]harNameValue, 0, len(h))
+	for name, values := range h {
+		for _, v := range values {
+			if isSensitive(name) {
+				v = redacted
+			}
+			headers = append(headers, harNameValue{Name: name, Value: v})
+		}
+	}
+	return headers
+}
+
+func redactValues(values url.Values) []harNameValue {
+	out := make([]harNameValue, 0, len(values))
+	for name, vs := range values {
+		for _, v := range vs {
+			if isSensitive(name) {
+				v = redacted
+			}
+			out = append(out, harNameValue{Name: name, Value: v})
+		}
+	}
+	return out
+}
+
+func redactURL(u *url.URL) string {
+	clean := *u
+	clean.User = nil
+	query := clean.Query()
+	for name := range query {
+		if isSensitive(name) {
+			query.Set(name, redacted)
+		}
+	}
+	clean.RawQuery = query.Encode()
+	return clean.String()
+}
+
+// redactBody removes secrets from form, JSON and HTML bodies
+func redactBody(mimeType, body string) string {
+	switch {
+	case strings.Contains(mimeType, "application/x-www-form-urlencoded"):
+		values, err := url.ParseQuery(body)
+		if err != nil {
+			return redacted
+		}
+		for name := range values {
+			if isSensitive(name) {
+				values.Set(name, redacted)
+			}
+		}
+		return values.Encode()
+
+	case strings.Contains(mimeType, "json"):
+		var v interface{}
+		if err := json.Unmarshal([]byte(body), &v); err != nil {
+			return embeddedSensitiveJSON.ReplaceAllString(body, "${1}"+redacted)
+		}
+		data, err := json.Marshal(redactJSON(v))
+		if err != nil {
+			return redacted
+		}
+		return string(data)
+
+	default:
+		body = htmlSensitiveInput.ReplaceAllString(body, "${1}"+redacted)
+		body = htmlSensitiveInputValueFirst.ReplaceAllString(body, "${1}"+redacted+"${2}")
+		return embeddedSensitiveJSON.ReplaceAllString(body, "${1}"+redacted)
+	}
+}
+
+func redactJSON(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, child := range val {
+			if isSensitive(k) {
+				val[k] = redacted
+			} else {
+				val[k] = redactJSON(child)
+			}
+		}
+	case []interface{}:
+		for i, child := range val {
+			val[i] = redactJSON(child)
+		}
+	}
+	return v
+}
@@ -0,0 +1,183 @@
+package provider
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// secret is a value that must never reach a trace
+const secret = "s3cr3t-value"
+
+func TestRedactHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		redact bool
+	}{
+		{"Authorization", "Bearer " + secret, true},
+		{"Cookie", "ESTSAUTH=" + secret, true},
+		{"Set-Cookie", "ESTSAUTH=" + secret + "; Path=/", true},
+		{"Proxy-Authorization", "Basic " + secret, true},
+		{"Content-Type", "text/html", false},
+		{"Location", "https://login.microsoftonline.com/", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := redactHeaders(http.Header{tt.name: {tt.value}})
+			if len(headers) != 1 {
+				t.Fatalf("expected 1 header, got %d", len(headers))
+			}
+			want := tt.value
+			if tt.redact {
+				want = redacted
+			}
+			if headers[0].Value != want {
+				t.Errorf("got %q, want %q", headers[0].Value, want)
+			}
+		})
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		keep []string
+	}{
+		{"saml response", "https://signin.aws.amazon.com/saml?SAMLResponse=" + secret + "&RelayState=x", []string{"RelayState=x"}},
+		{"flow token", "https://login.microsoftonline.com/common/login?flowToken=" + secret, nil},
+		{"ctx", "https://login.microsoftonline.com/common/reprocess?ctx=" + secret + "&sessionid=abc", []string{"sessionid=abc"}},
+		{"code", "https://example.com/callback?code=" + secret + "&state=abc", []string{"state=abc"}},
+		{"user info", "https://user:" + secret + "@example.com/path", []string{"example.com/path"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := redactURL(u)
+			if strings.Contains(got, secret) {
+				t.Errorf("URL still contains the secret: %s", got)
+			}
+			for _, keep := range tt.keep {
+				if !strings.Contains(got, keep) {
+					t.Errorf("expected %q to be kept, got %s", keep, got)
+				}
+			}
+
+			for _, v := range redactValues(u.Query()) {
+				if strings.Contains(v.Value, secret) {
+					t.Errorf("query string value %s still contains the secret", v.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	tests := []struct {
+		name     string
+		mimeType string
+		body     string
+		keep     []string
+	}{
+		{
+			name:     "form login",
+			mimeType: "application/x-www-form-urlencoded",
+			body:     "login=user%40example.com&passwd=" + secret + "&ctx=" + secret + "&flowToken=" + secret + "&canary=" + secret,
+			keep:     []string{"login=user%40example.com"},
+		},
+		{
+			name:     "form saml response",
+			mimeType: "application/x-www-form-urlencoded; charset=utf-8",
+			body:     "SAMLResponse=" + secret + "&RelayState=state",
+			keep:     []string{"RelayState=state"},
+		},
+		{
+			name:     "json nested",
+			mimeType: "application/json",
+			body:     `{"username":"user@example.com","flowToken":"` + secret + `","originalRequest":"` + secret + `","nested":{"ctx":"` + secret + `","password":"` + secret + `"},"list":[{"FlowToken":"` + secret + `"}]}`,
+			keep:     []string{`"username":"user@example.com"`},
+		},
+		{
+			name:     "invalid json",
+			mimeType: "application/json",
+			body:     `{"flowToken":"` + secret + `","sCtx":"` + secret + `",`,
+		},
+		{
+			name:     "html inputs",
+			mimeType: "text/html",
+			body: `<form><input type="hidden" name="SAMLResponse" value="` + secret + `"/>` +
+				`<input type="hidden" value="` + secret + `" name="ctx"/>` +
+				`<input type="hidden" name="flowToken" value="` + secret + `"/>` +
+				`<input type="hidden" name="RelayState" value="relay"/></form>`,
+			keep: []string{`value="relay"`},
+		},
+		{
+			name:     "html config script",
+			mimeType: "text/html; charset=utf-8",
+			body:     `<script>$Config={"sFT":"` + secret + `","sCtx":"` + secret + `","canary":"` + secret + `","apiCanary":"` + secret + `","urlPost":"/common/login"};</script>`,
+			keep:     []string{`"urlPost":"/common/login"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := redactBody(tt.mimeType, tt.body)
+			if strings.Contains(got, secret) {
+				t.Errorf("body still contains the secret: %s", got)
+			}
+			for _, keep := range tt.keep {
+				if !strings.Contains(got, keep) {
+					t.Errorf("expected %q to be kept, got %s", keep, got)
+				}
+			}
+		})
+	}
+}
+
+func TestTraceRedactsSecrets(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "ESTSAUTH", Value: secret})
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"FlowToken":"` + secret + `","Result":"ok"}`))
+	}))
+	defer srv.Close()
+
+	client, err := NewHTTPClient(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.EnableTrace()
+
+	form := url.Values{"login": {"user@example.com"}, "passwd": {secret}, "ctx": {secret}}
+	res, err := client.PostForm(srv.URL+"/login?flowToken="+secret, strings.NewReader(form.Encode()), "application/x-www-form-urlencoded")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	path := filepath.Join(t.TempDir(), "trace.har")
+	if err := client.WriteTrace(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), secret) {
+		t.Errorf("trace contains the secret:\n%s", data)
+	}
+	if !strings.Contains(string(data), `\"Result\":\"ok\"`) {
+		t.Errorf("expected the response body in the trace:\n%s", data)
+	}
+}
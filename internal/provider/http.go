@@ -1,36 +1,94 @@
 package provider
 
 import (
+	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/user/azure2aws/internal/logging"
+	"github.com/user/azure2aws/internal/output"
+	"golang.org/x/crypto/pkcs12"
 	"golang.org/x/net/publicsuffix"
 )
 
 const (
 	UserAgent = "azure2aws/1.0"
+
+	// UserAgentBrowser is the user_agent preset sending browserUserAgent
+	UserAgentBrowser = "browser"
+
+	// browserUserAgent is a current Edge on Windows, for Conditional Access
+	// policies that only admit browsers
+	browserUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36 Edg/131.0.0.0"
+)
+
+const (
+	// retryBackoff is the delay before the first retry after a transient
+	// network error; it doubles on each attempt
+	retryBackoff = 500 * time.Millisecond
+
+	// throttleBackoff is the delay before the first retry of a throttled
+	// request without Retry-After; it doubles on each attempt
+	throttleBackoff = 5 * time.Second
+
+	// maxThrottleWait caps the delay before retrying a throttled request
+	maxThrottleWait = 2 * time.Minute
 )
 
 type HTTPClient struct {
 	*http.Client
 	skipVerify bool
+	maxRetries int
+	userAgent  string
+
+	throttleRetries int
+
+	// stats records request timings when enabled
+	stats *statsRecorder
 }
 
 type HTTPClientOptions struct {
-	SkipVerify bool
-	Timeout    time.Duration
+	SkipVerify     bool
+	CABundle       string        // PEM file of CA certificates trusted in addition to the system roots
+	Timeout        time.Duration // Overall request timeout
+	ConnectTimeout time.Duration // TCP connect timeout
+	KeepAlive      time.Duration // TCP keep-alive period
+	MaxRetries     int           // Retries of idempotent requests on transient network errors
+
+	ThrottleRetries int // Retries of requests throttled by the server (HTTP 429)
+
+	UserAgent string // User-Agent header, or UserAgentBrowser; empty identifies azure2aws
+
+	// Client certificate presented when a server asks for one (mTLS,
+	// certificate-based authentication)
+	ClientCert         string // PEM certificate, or PKCS#12 (.p12/.pfx) bundle
+	ClientKey          string // PEM private key; empty if in ClientCert
+	ClientCertPassword string // PKCS#12 bundle password
 }
 
 func DefaultHTTPClientOptions() *HTTPClientOptions {
 	return &HTTPClientOptions{
-		SkipVerify: false,
-		Timeout:    60 * time.Second,
+		SkipVerify:     false,
+		Timeout:        60 * time.Second,
+		ConnectTimeout: 30 * time.Second,
+		KeepAlive:      30 * time.Second,
+		MaxRetries:     2,
+
+		ThrottleRetries: 3,
 	}
 }
 
@@ -46,21 +104,42 @@ func NewHTTPClient(opts *HTTPClientOptions) (*HTTPClient, error) {
 		return nil, fmt.Errorf("failed to create cookie jar: %w", err)
 	}
 
+	var rootCAs *x509.CertPool
+	if opts.CABundle != "" {
+		rootCAs, err = LoadCABundle(opts.CABundle)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: opts.SkipVerify,
+		RootCAs:            rootCAs,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if opts.ClientCert != "" {
+		cert, err := LoadClientCertificate(opts.ClientCert, opts.ClientKey, opts.ClientCertPassword)
+		if err != nil {
+			return nil, err
+		}
+		// Present the certificate even when the server lists no acceptable CAs
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return &cert, nil
+		}
+	}
+
 	transport := &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
 		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
+			Timeout:   opts.ConnectTimeout,
+			KeepAlive: opts.KeepAlive,
 			DualStack: true,
 		}).DialContext,
 		MaxIdleConns:          100,
 		IdleConnTimeout:       90 * time.Second,
 		TLSHandshakeTimeout:   10 * time.Second,
 		ExpectContinueTimeout: 1 * time.Second,
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: opts.SkipVerify,
-			MinVersion:         tls.VersionTLS12,
-		},
+		TLSClientConfig:       tlsConfig,
 	}
 
 	client := &http.Client{
@@ -72,12 +151,182 @@ func NewHTTPClient(opts *HTTPClientOptions) (*HTTPClient, error) {
 	return &HTTPClient{
 		Client:     client,
 		skipVerify: opts.SkipVerify,
+		maxRetries: opts.MaxRetries,
+		userAgent:  ResolveUserAgent(opts.UserAgent),
+
+		throttleRetries: opts.ThrottleRetries,
 	}, nil
 }
 
+// UserAgent returns the User-Agent header sent with requests
+func (c *HTTPClient) UserAgent() string {
+	return c.userAgent
+}
+
+// ResolveUserAgent returns the User-Agent header for a user_agent setting:
+// azure2aws's own when empty, a browser's for the UserAgentBrowser preset, or
+// the value itself
+func ResolveUserAgent(userAgent string) string {
+	switch strings.ToLower(userAgent) {
+	case "":
+		return fmt.Sprintf("%s (%s %s)", UserAgent, runtime.GOOS, runtime.GOARCH)
+	case UserAgentBrowser:
+		return browserUserAgent
+	default:
+		return userAgent
+	}
+}
+
+// LoadCABundle returns the system root CAs plus the PEM certificates in path
+func LoadCABundle(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA bundle %s", path)
+	}
+
+	return pool, nil
+}
+
+// LoadClientCertificate loads a client certificate and its key from PEM
+// files (the key may follow the certificate in certFile), or from a PKCS#12
+// bundle. OS certificate stores and smart cards are not read.
+func LoadClientCertificate(certFile, keyFile, password string) (tls.Certificate, error) {
+	if _, err := os.Stat(certFile); err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to read client certificate: %w (client_cert must be a PEM or PKCS#12 file; export certificates from an OS certificate store first)", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(certFile)) {
+	case ".p12", ".pfx":
+		data, err := os.ReadFile(certFile)
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("failed to read client certificate: %w", err)
+		}
+		key, cert, err := pkcs12.Decode(data, password)
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("failed to decode client certificate %s: %w", certFile, err)
+		}
+		return tls.Certificate{Certificate: [][]byte{cert.Raw}, PrivateKey: key, Leaf: cert}, nil
+	}
+
+	if keyFile == "" {
+		keyFile = certFile
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to load client certificate: %w", err)
+	}
+	return cert, nil
+}
+
+// Do sends a request, retrying idempotent requests without a body on
+// transient network errors, and replayable requests rejected with HTTP 429
+// after the server's Retry-After delay
 func (c *HTTPClient) Do(req *http.Request) (*http.Response, error) {
-	req.Header.Set("User-Agent", fmt.Sprintf("%s (%s %s)", UserAgent, runtime.GOOS, runtime.GOARCH))
-	return c.Client.Do(req)
+	req.Header.Set("User-Agent", c.userAgent)
+
+	idempotent := (req.Method == http.MethodGet || req.Method == http.MethodHead) && req.Body == nil
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	backoff := retryBackoff
+	retries, throttles := 0, 0
+
+	if c.stats != nil {
+		req = c.stats.trace(req)
+	}
+
+	for {
+		res, err := c.Client.Do(req)
+
+		var wait time.Duration
+		switch {
+		case err != nil:
+			if !idempotent || retries >= c.maxRetries || !isTransient(err) {
+				return res, err
+			}
+			retries++
+			wait = backoff
+			backoff *= 2
+			logging.Debug("Retrying request after transient error", "url", req.URL.Redacted(), "attempt", retries, "error", err)
+
+		case res.StatusCode == http.StatusTooManyRequests:
+			if !replayable || throttles >= c.throttleRetries {
+				return res, nil
+			}
+			throttles++
+			wait = RetryAfter(res.Header.Get("Retry-After"), ThrottleBackoff(throttles))
+			res.Body.Close()
+			output.Statusf("%s is throttling requests; retrying in %s (%d/%d)...\n", req.URL.Host, wait, throttles, c.throttleRetries)
+
+		default:
+			return res, nil
+		}
+
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, fmt.Errorf("failed to rewind request body: %w", err)
+			}
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// ThrottleBackoff is the delay before the nth retry of a throttled request
+// when the server does not say how long to wait
+func ThrottleBackoff(attempt int) time.Duration {
+	wait := throttleBackoff << (attempt - 1)
+	if wait > maxThrottleWait {
+		wait = maxThrottleWait
+	}
+	return wait
+}
+
+// RetryAfter parses a Retry-After header (seconds or an HTTP date), returning
+// fallback when it is missing or invalid. Delays are capped.
+func RetryAfter(header string, fallback time.Duration) time.Duration {
+	wait := fallback
+	if seconds, err := strconv.Atoi(strings.TrimSpace(header)); err == nil && seconds >= 0 {
+		wait = time.Duration(seconds) * time.Second
+	} else if t, err := http.ParseTime(header); err == nil {
+		wait = time.Until(t)
+	}
+
+	if wait < 0 {
+		wait = 0
+	}
+	if wait > maxThrottleWait {
+		wait = maxThrottleWait
+	}
+	return wait
+}
+
+// isTransient reports whether a request error is worth retrying: timeouts,
+// refused or reset connections, and connections closed mid-response
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED)
 }
 
 func (c *HTTPClient) Get(url string) (*http.Response, error) {
@@ -107,6 +356,40 @@ func (c *HTTPClient) EnableFollowRedirect() {
 	c.Client.CheckRedirect = nil
 }
 
+// ExportCookies returns the cookies the jar would send to each URL, keyed by URL
+func (c *HTTPClient) ExportCookies(urls []string) map[string][]*http.Cookie {
+	exported := make(map[string][]*http.Cookie)
+	if c.Client.Jar == nil {
+		return exported
+	}
+
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil {
+			continue
+		}
+		if cookies := c.Client.Jar.Cookies(u); len(cookies) > 0 {
+			exported[raw] = cookies
+		}
+	}
+	return exported
+}
+
+// ImportCookies adds previously exported cookies to the jar
+func (c *HTTPClient) ImportCookies(cookies map[string][]*http.Cookie) {
+	if c.Client.Jar == nil {
+		return
+	}
+
+	for raw, list := range cookies {
+		u, err := url.Parse(raw)
+		if err != nil {
+			continue
+		}
+		c.Client.Jar.SetCookies(u, list)
+	}
+}
+
 func (c *HTTPClient) ClearCookies() error {
 	jar, err := cookiejar.New(&cookiejar.Options{
 		PublicSuffixList: publicsuffix.List,
@@ -0,0 +1,41 @@
+package provider
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+// Negotiator produces the tokens of an HTTP Negotiate (SPNEGO) exchange with
+// one service, using the signed-in user's Kerberos or Windows credentials.
+// NewNegotiator is implemented per platform.
+type Negotiator interface {
+	// Step returns the next token to send; challenge is the server's last
+	// token, nil for the first step
+	Step(challenge []byte) ([]byte, error)
+	// Close releases the security context
+	Close() error
+}
+
+// NegotiateChallenge returns the token of a Negotiate WWW-Authenticate
+// header (nil if the server sent none); ok is false if the response does
+// not offer Negotiate
+func NegotiateChallenge(h http.Header) (token []byte, ok bool) {
+	for _, v := range h.Values("WWW-Authenticate") {
+		scheme, param, _ := strings.Cut(strings.TrimSpace(v), " ")
+		if !strings.EqualFold(scheme, "Negotiate") {
+			continue
+		}
+		token, err := base64.StdEncoding.DecodeString(strings.TrimSpace(param))
+		if err != nil || len(token) == 0 {
+			return nil, true
+		}
+		return token, true
+	}
+	return nil, false
+}
+
+// negotiateSPN is the service principal of a web server
+func negotiateSPN(host string) string {
+	return "HTTP/" + host
+}
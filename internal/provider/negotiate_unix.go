@@ -0,0 +1,87 @@
+//go:build !windows
+
+package provider
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jcmturner/gokrb5/v8/client"
+	krb5config "github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/credentials"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+)
+
+// krb5Negotiator answers Negotiate challenges with a service ticket obtained
+// from the Kerberos ticket cache (as filled by kinit)
+type krb5Negotiator struct {
+	client *client.Client
+	spn    string
+}
+
+// NewNegotiator starts a Negotiate exchange with the web server host
+func NewNegotiator(host string) (Negotiator, error) {
+	configPath := "/etc/krb5.conf"
+	if v := os.Getenv("KRB5_CONFIG"); v != "" {
+		configPath = strings.Split(v, ":")[0]
+	}
+	cfg, err := krb5config.Load(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Kerberos configuration: %w", err)
+	}
+
+	cachePath, err := credentialCachePath()
+	if err != nil {
+		return nil, err
+	}
+	ccache, err := credentials.LoadCCache(cachePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Kerberos ticket cache %s (run kinit first): %w", cachePath, err)
+	}
+
+	cl, err := client.NewFromCCache(ccache, cfg, client.DisablePAFXFAST(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to use Kerberos ticket cache %s: %w", cachePath, err)
+	}
+	return &krb5Negotiator{client: cl, spn: negotiateSPN(host)}, nil
+}
+
+// credentialCachePath returns the file of the user's ticket cache. Only
+// file caches can be read.
+func credentialCachePath() (string, error) {
+	name := os.Getenv("KRB5CCNAME")
+	switch {
+	case name == "":
+		return fmt.Sprintf("/tmp/krb5cc_%d", os.Getuid()), nil
+	case strings.HasPrefix(name, "FILE:"):
+		return strings.TrimPrefix(name, "FILE:"), nil
+	case strings.HasPrefix(name, "/"):
+		return name, nil
+	default:
+		return "", fmt.Errorf("Kerberos ticket cache %s is not supported; use a file cache (e.g. export KRB5CCNAME=FILE:/tmp/krb5cc_%d and run kinit)", name, os.Getuid())
+	}
+}
+
+func (n *krb5Negotiator) Step(challenge []byte) ([]byte, error) {
+	// Kerberos needs a single round trip; a second challenge means the
+	// server did not accept the ticket
+	if challenge != nil {
+		return nil, fmt.Errorf("the server rejected the Kerberos ticket for %s", n.spn)
+	}
+
+	s := spnego.SPNEGOClient(n.client, n.spn)
+	if err := s.AcquireCred(); err != nil {
+		return nil, fmt.Errorf("failed to acquire Kerberos credentials: %w", err)
+	}
+	token, err := s.InitSecContext()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get a Kerberos service ticket for %s: %w", n.spn, err)
+	}
+	return token.Marshal()
+}
+
+func (n *krb5Negotiator) Close() error {
+	n.client.Destroy()
+	return nil
+}
@@ -0,0 +1,51 @@
+//go:build windows
+
+package provider
+
+import (
+	"fmt"
+
+	"github.com/alexbrainman/sspi"
+	"github.com/alexbrainman/sspi/negotiate"
+)
+
+// sspiNegotiator answers Negotiate challenges with the credentials of the
+// signed-in Windows user (Kerberos, or NTLM when Kerberos is unavailable)
+type sspiNegotiator struct {
+	cred *sspi.Credentials
+	ctx  *negotiate.ClientContext
+	spn  string
+}
+
+// NewNegotiator starts a Negotiate exchange with the web server host
+func NewNegotiator(host string) (Negotiator, error) {
+	cred, err := negotiate.AcquireCurrentUserCredentials()
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire Windows credentials: %w", err)
+	}
+	return &sspiNegotiator{cred: cred, spn: negotiateSPN(host)}, nil
+}
+
+func (n *sspiNegotiator) Step(challenge []byte) ([]byte, error) {
+	if n.ctx == nil {
+		ctx, token, err := negotiate.NewClientContext(n.cred, n.spn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start Windows authentication for %s: %w", n.spn, err)
+		}
+		n.ctx = ctx
+		return token, nil
+	}
+
+	_, token, err := n.ctx.Update(challenge)
+	if err != nil {
+		return nil, fmt.Errorf("Windows authentication for %s failed: %w", n.spn, err)
+	}
+	return token, nil
+}
+
+func (n *sspiNegotiator) Close() error {
+	if n.ctx != nil {
+		n.ctx.Release()
+	}
+	return n.cred.Release()
+}
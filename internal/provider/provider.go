@@ -1,9 +1,12 @@
 package provider
 
+import "context"
+
 // Provider interface defines the contract for SAML identity providers
 type Provider interface {
-	// Authenticate performs authentication and returns the SAML assertion
-	Authenticate(creds *LoginCredentials) (string, error)
+	// Authenticate performs authentication and returns the SAML assertion.
+	// Cancelling ctx aborts it.
+	Authenticate(ctx context.Context, creds *LoginCredentials) (string, error)
 }
 
 // LoginCredentials contains the credentials for authentication
@@ -11,6 +14,7 @@ type LoginCredentials struct {
 	Username string
 	Password string
 	MFAToken string // Optional MFA token for OTP-based authentication
+	TOTPSeed string // Optional base32 seed to generate authenticator app codes
 }
 
 // NewLoginCredentials creates a new LoginCredentials instance
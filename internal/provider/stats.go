@@ -0,0 +1,67 @@
+package provider
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// RequestStats accumulates where time was spent across HTTP requests
+type RequestStats struct {
+	Requests   int           // Requests sent
+	Connect    time.Duration // TCP connects (to the host or proxy)
+	TLS        time.Duration // TLS handshakes
+	ServerWait time.Duration // From request written to first response byte
+}
+
+// statsRecorder collects RequestStats through httptrace hooks
+type statsRecorder struct {
+	mu    sync.Mutex
+	stats RequestStats
+}
+
+// EnableStats starts recording request timings, resetting earlier ones
+func (c *HTTPClient) EnableStats() {
+	c.stats = &statsRecorder{}
+}
+
+// Stats returns the timings recorded since EnableStats
+func (c *HTTPClient) Stats() RequestStats {
+	if c.stats == nil {
+		return RequestStats{}
+	}
+	c.stats.mu.Lock()
+	defer c.stats.mu.Unlock()
+	return c.stats.stats
+}
+
+// trace attaches the recorder's hooks to a request
+func (r *statsRecorder) trace(req *http.Request) *http.Request {
+	var connectStart, tlsStart, wroteRequest time.Time
+
+	add := func(field *time.Duration, since time.Time) {
+		if since.IsZero() {
+			return
+		}
+		r.mu.Lock()
+		*field += time.Since(since)
+		r.mu.Unlock()
+	}
+
+	r.mu.Lock()
+	r.stats.Requests++
+	r.mu.Unlock()
+
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		ConnectStart:      func(_, _ string) { connectStart = time.Now() },
+		ConnectDone:       func(_, _ string, _ error) { add(&r.stats.Connect, connectStart) },
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone: func(_ tls.ConnectionState, _ error) {
+			add(&r.stats.TLS, tlsStart)
+		},
+		WroteRequest:         func(_ httptrace.WroteRequestInfo) { wroteRequest = time.Now() },
+		GotFirstResponseByte: func() { add(&r.stats.ServerWait, wroteRequest) },
+	}))
+}
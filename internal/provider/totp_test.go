@@ -0,0 +1,38 @@
+package provider
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+func TestGenerateTOTP(t *testing.T) {
+	// RFC 6238 appendix B test vectors (SHA1), truncated to 6 digits
+	seed := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		got, err := GenerateTOTP(seed, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("GenerateTOTP(%d) failed: %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("GenerateTOTP(%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateTOTPInvalidSeed(t *testing.T) {
+	if _, err := GenerateTOTP("not base32!", time.Now()); err == nil {
+		t.Error("expected error for invalid seed")
+	}
+}
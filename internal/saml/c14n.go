@@ -0,0 +1,181 @@
+package saml
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// algExcC14N is the Exclusive XML Canonicalization (without comments) algorithm
+const algExcC14N = "http://www.w3.org/2001/10/xml-exc-c14n#"
+
+// excC14N implements Exclusive XML Canonicalization 1.0 (without comments)
+// over an etree element. Namespace declarations are resolved against the
+// element's ancestors in the original document, so the element must not be
+// detached before canonicalizing.
+type excC14N struct {
+	// inclusivePrefixes are treated as in inclusive canonicalization
+	// (the InclusiveNamespaces PrefixList)
+	inclusivePrefixes map[string]bool
+
+	// skip is an element excluded from the output (the enveloped signature)
+	skip *etree.Element
+}
+
+// canonicalize returns the canonical form of el
+func (c *excC14N) canonicalize(el *etree.Element) []byte {
+	var sb strings.Builder
+	c.writeElement(&sb, el, map[string]string{})
+	return []byte(sb.String())
+}
+
+func (c *excC14N) writeElement(sb *strings.Builder, el *etree.Element, rendered map[string]string) {
+	// Namespace prefixes visibly utilized by this element and its attributes
+	utilized := map[string]bool{el.Space: true}
+	for _, a := range el.Attr {
+		if isNamespaceDecl(a) || a.Space == "" {
+			continue
+		}
+		utilized[a.Space] = true
+	}
+	for prefix := range c.inclusivePrefixes {
+		if _, ok := lookupNamespace(el, prefix); ok {
+			utilized[prefix] = true
+		}
+	}
+
+	// Declarations that differ from what the output ancestors already rendered
+	type nsDecl struct{ prefix, uri string }
+	var decls []nsDecl
+	scope := make(map[string]string, len(rendered)+len(utilized))
+	for k, v := range rendered {
+		scope[k] = v
+	}
+	for prefix := range utilized {
+		if prefix == "xml" {
+			continue
+		}
+		uri, _ := lookupNamespace(el, prefix)
+		prev, wasRendered := rendered[prefix]
+		if prefix == "" && uri == "" && (!wasRendered || prev == "") {
+			continue
+		}
+		if wasRendered && prev == uri {
+			continue
+		}
+		decls = append(decls, nsDecl{prefix, uri})
+		scope[prefix] = uri
+	}
+	sort.Slice(decls, func(i, j int) bool { return decls[i].prefix < decls[j].prefix })
+
+	// Regular attributes sorted by namespace URI, then local name
+	attrs := make([]etree.Attr, 0, len(el.Attr))
+	for _, a := range el.Attr {
+		if !isNamespaceDecl(a) {
+			attrs = append(attrs, a)
+		}
+	}
+	sort.SliceStable(attrs, func(i, j int) bool {
+		ui, uj := attrNamespace(el, attrs[i]), attrNamespace(el, attrs[j])
+		if ui != uj {
+			return ui < uj
+		}
+		return attrs[i].Key < attrs[j].Key
+	})
+
+	sb.WriteByte('<')
+	sb.WriteString(qualifiedName(el.Space, el.Tag))
+	for _, d := range decls {
+		if d.prefix == "" {
+			sb.WriteString(` xmlns="`)
+		} else {
+			sb.WriteString(` xmlns:` + d.prefix + `="`)
+		}
+		sb.WriteString(escapeAttr(d.uri))
+		sb.WriteByte('"')
+	}
+	for _, a := range attrs {
+		sb.WriteByte(' ')
+		sb.WriteString(qualifiedName(a.Space, a.Key))
+		sb.WriteString(`="`)
+		sb.WriteString(escapeAttr(a.Value))
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('>')
+
+	for _, token := range el.Child {
+		switch t := token.(type) {
+		case *etree.Element:
+			if t == c.skip {
+				continue
+			}
+			c.writeElement(sb, t, scope)
+		case *etree.CharData:
+			sb.WriteString(escapeText(t.Data))
+		case *etree.ProcInst:
+			sb.WriteString("<?" + t.Target)
+			if t.Inst != "" {
+				sb.WriteString(" " + t.Inst)
+			}
+			sb.WriteString("?>")
+		}
+	}
+
+	sb.WriteString("</")
+	sb.WriteString(qualifiedName(el.Space, el.Tag))
+	sb.WriteByte('>')
+}
+
+// isNamespaceDecl reports whether an attribute is an xmlns declaration
+func isNamespaceDecl(a etree.Attr) bool {
+	return a.Space == "xmlns" || (a.Space == "" && a.Key == "xmlns")
+}
+
+// lookupNamespace resolves a prefix ("" for the default namespace) to its URI
+// using the declarations on el and its ancestors
+func lookupNamespace(el *etree.Element, prefix string) (string, bool) {
+	for e := el; e != nil; e = e.Parent() {
+		for _, a := range e.Attr {
+			if prefix == "" && a.Space == "" && a.Key == "xmlns" {
+				return a.Value, true
+			}
+			if prefix != "" && a.Space == "xmlns" && a.Key == prefix {
+				return a.Value, true
+			}
+		}
+	}
+	return "", false
+}
+
+// attrNamespace returns the namespace URI of an attribute on el
+func attrNamespace(el *etree.Element, a etree.Attr) string {
+	if a.Space == "" {
+		return ""
+	}
+	if a.Space == "xml" {
+		return "http://www.w3.org/XML/1998/namespace"
+	}
+	uri, _ := lookupNamespace(el, a.Space)
+	return uri
+}
+
+func qualifiedName(space, local string) string {
+	if space == "" {
+		return local
+	}
+	return space + ":" + local
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
+
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+func escapeAttr(s string) string {
+	return attrEscaper.Replace(s)
+}
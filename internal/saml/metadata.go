@@ -0,0 +1,169 @@
+package saml
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/user/azure2aws/internal/azurecloud"
+)
+
+// federationMetadataURLFormat is the per-tenant, per-app federation metadata
+// endpoint below the cloud's login URL
+const federationMetadataURLFormat = "%s%s/federationmetadata/2007-06/federationmetadata.xml?appid=%s"
+
+// ExtractIssuer returns the Issuer of the first assertion in a SAML response
+func ExtractIssuer(samlAssertion string) (string, error) {
+	decoded, err := base64.StdEncoding.DecodeString(samlAssertion)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode SAML assertion: %w", err)
+	}
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(decoded); err != nil {
+		return "", fmt.Errorf("failed to parse SAML XML: %w", err)
+	}
+
+	issuer := doc.FindElement("//Assertion/Issuer")
+	if issuer == nil {
+		issuer = doc.FindElement("//Issuer")
+	}
+	if issuer == nil {
+		return "", fmt.Errorf("issuer not found in SAML assertion")
+	}
+
+	return strings.TrimSpace(issuer.Text()), nil
+}
+
+// FederationMetadataURL returns the federation metadata URL of a configured
+// tenant (ID or domain) in an Azure cloud. The tenant must come from the
+// config, never from the response being verified.
+func FederationMetadataURL(cloud *azurecloud.Cloud, tenant, appID string) string {
+	return fmt.Sprintf(federationMetadataURLFormat, cloud.LoginURL, url.PathEscape(tenant), url.QueryEscape(appID))
+}
+
+// FederationMetadata is what federation metadata says about the IdP
+type FederationMetadata struct {
+	// EntityID is the Issuer of the assertions the IdP issues
+	EntityID            string
+	SigningCertificates []*x509.Certificate
+}
+
+// FetchFederationMetadata downloads federation metadata. rootCAs overrides
+// the system roots when not nil.
+func FetchFederationMetadata(metadataURL string, rootCAs *x509.CertPool) (*FederationMetadata, error) {
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+	}
+	if rootCAs != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{RootCAs: rootCAs}
+		client.Transport = transport
+	}
+
+	resp, err := client.Get(metadataURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch federation metadata: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("federation metadata request failed with status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read federation metadata: %w", err)
+	}
+
+	return ParseFederationMetadata(data)
+}
+
+// ParseFederationMetadata extracts the entity ID and IdP signing
+// certificates from federation metadata XML
+func ParseFederationMetadata(metadata []byte) (*FederationMetadata, error) {
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(metadata); err != nil {
+		return nil, fmt.Errorf("failed to parse federation metadata: %w", err)
+	}
+
+	entity := doc.FindElement("//EntityDescriptor")
+	if entity == nil || entity.SelectAttrValue("entityID", "") == "" {
+		return nil, fmt.Errorf("federation metadata has no entityID")
+	}
+
+	seen := make(map[string]bool)
+	var certs []*x509.Certificate
+
+	for _, descriptor := range doc.FindElements("//IDPSSODescriptor/KeyDescriptor") {
+		if use := descriptor.SelectAttrValue("use", "signing"); use != "signing" {
+			continue
+		}
+
+		for _, certEl := range descriptor.FindElements(".//X509Certificate") {
+			text := strings.Join(strings.Fields(certEl.Text()), "")
+			if text == "" || seen[text] {
+				continue
+			}
+			seen[text] = true
+
+			der, err := base64.StdEncoding.DecodeString(text)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode signing certificate: %w", err)
+			}
+
+			cert, err := x509.ParseCertificate(der)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse signing certificate: %w", err)
+			}
+			certs = append(certs, cert)
+		}
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no signing certificates found in federation metadata")
+	}
+
+	return &FederationMetadata{EntityID: entity.SelectAttrValue("entityID", ""), SigningCertificates: certs}, nil
+}
+
+// LoadCertificateFile loads one or more PEM-encoded certificates from a file
+func LoadCertificateFile(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate file: %w", err)
+	}
+
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate in %s: %w", path, err)
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no PEM certificates found in %s", path)
+	}
+
+	return certs, nil
+}
@@ -0,0 +1,53 @@
+package saml
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/user/azure2aws/internal/azurecloud"
+)
+
+func TestFederationMetadataURL(t *testing.T) {
+	cloud, err := azurecloud.Lookup("usgovernment")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := FederationMetadataURL(cloud, "contoso.onmicrosoft.us", "app id")
+	want := "https://login.microsoftonline.us/contoso.onmicrosoft.us/federationmetadata/2007-06/federationmetadata.xml?appid=app+id"
+	if got != want {
+		t.Errorf("unexpected URL:\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestFetchFederationMetadata(t *testing.T) {
+	_, cert := newTestCertificate(t)
+	const entityID = "https://sts.windows.net/00000000-0000-0000-0000-000000000000/"
+	metadata := fmt.Sprintf(`<EntityDescriptor xmlns="urn:oasis:names:tc:SAML:2.0:metadata" entityID="%s">`+
+		`<IDPSSODescriptor><KeyDescriptor use="signing"><KeyInfo xmlns="http://www.w3.org/2000/09/xmldsig#">`+
+		`<X509Data><X509Certificate>%s</X509Certificate></X509Data></KeyInfo></KeyDescriptor></IDPSSODescriptor>`+
+		`</EntityDescriptor>`, entityID, base64.StdEncoding.EncodeToString(cert.Raw))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, metadata)
+	}))
+	defer server.Close()
+
+	got, err := FetchFederationMetadata(server.URL, nil)
+	if err != nil {
+		t.Fatalf("FetchFederationMetadata() error = %v", err)
+	}
+	if got.EntityID != entityID {
+		t.Errorf("expected entityID %s, got %s", entityID, got.EntityID)
+	}
+	if len(got.SigningCertificates) != 1 || !got.SigningCertificates[0].Equal(cert) {
+		t.Errorf("expected the signing certificate, got %d certificates", len(got.SigningCertificates))
+	}
+
+	if _, err := ParseFederationMetadata([]byte(`<EntityDescriptor xmlns="urn:oasis:names:tc:SAML:2.0:metadata"/>`)); err == nil {
+		t.Error("expected error for metadata without entityID")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/beevik/etree"
 )
@@ -93,6 +94,39 @@ func ExtractSessionDuration(samlAssertion string) (int64, error) {
 	return 0, nil // Not found, return 0 (will use default)
 }
 
+// ExtractExpiration returns the earliest NotOnOrAfter time of the assertion's
+// conditions and subject confirmations. Returns the zero time if none is set.
+func ExtractExpiration(samlAssertion string) (time.Time, error) {
+	decoded, err := base64.StdEncoding.DecodeString(samlAssertion)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to decode SAML assertion: %w", err)
+	}
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(decoded); err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse SAML XML: %w", err)
+	}
+
+	var expiration time.Time
+	elements := append(doc.FindElements("//Conditions"), doc.FindElements("//SubjectConfirmationData")...)
+	for _, el := range elements {
+		value := el.SelectAttrValue("NotOnOrAfter", "")
+		if value == "" {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid NotOnOrAfter %q: %w", value, err)
+		}
+		if expiration.IsZero() || t.Before(expiration) {
+			expiration = t
+		}
+	}
+
+	return expiration, nil
+}
+
 // ExtractDestination extracts the destination URL from a SAML assertion
 func ExtractDestination(samlAssertion string) (string, error) {
 	// Decode base64
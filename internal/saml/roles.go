@@ -2,6 +2,7 @@ package saml
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 )
 
@@ -68,6 +69,27 @@ func extractRoleName(roleARN string) string {
 	return roleARN
 }
 
+// CompileRoleFilter compiles a role filter regular expression. The pattern
+// must match a whole role ARN or role name.
+func CompileRoleFilter(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(`^(?:` + pattern + `)$`)
+	if err != nil {
+		return nil, fmt.Errorf("invalid role filter %q: %w", pattern, err)
+	}
+	return re, nil
+}
+
+// FilterRoles returns the roles whose ARN or name matches re
+func FilterRoles(roles []*AWSRole, re *regexp.Regexp) []*AWSRole {
+	var filtered []*AWSRole
+	for _, role := range roles {
+		if re.MatchString(role.RoleARN) || re.MatchString(role.Name) {
+			filtered = append(filtered, role)
+		}
+	}
+	return filtered
+}
+
 // String returns a string representation of the role
 func (r *AWSRole) String() string {
 	return fmt.Sprintf("%s (%s)", r.Name, r.RoleARN)
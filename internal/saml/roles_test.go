@@ -0,0 +1,31 @@
+package saml
+
+import "testing"
+
+func TestFilterRoles(t *testing.T) {
+	roles := []*AWSRole{
+		{RoleARN: "arn:aws:iam::111111111111:role/Audit-ReadOnly", Name: "Audit-ReadOnly"},
+		{RoleARN: "arn:aws:iam::111111111111:role/Audit-ReadOnlyPlus", Name: "Audit-ReadOnlyPlus"},
+		{RoleARN: "arn:aws:iam::222222222222:role/Admin", Name: "Admin"},
+	}
+
+	re, err := CompileRoleFilter(".*-ReadOnly")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := FilterRoles(roles, re); len(got) != 1 || got[0].Name != "Audit-ReadOnly" {
+		t.Errorf("expected only Audit-ReadOnly, got %v", got)
+	}
+
+	re, err = CompileRoleFilter("arn:aws:iam::222222222222:.*")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := FilterRoles(roles, re); len(got) != 1 || got[0].Name != "Admin" {
+		t.Errorf("expected only Admin, got %v", got)
+	}
+
+	if _, err := CompileRoleFilter("("); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+}
@@ -0,0 +1,327 @@
+package saml
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	// Register hash implementations used by XML signatures
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+
+	"github.com/beevik/etree"
+)
+
+const (
+	xmldsigNamespace   = "http://www.w3.org/2000/09/xmldsig#"
+	samlAssertionNS    = "urn:oasis:names:tc:SAML:2.0:assertion"
+	transformEnveloped = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+)
+
+var (
+	// ErrNoSignature is returned when the SAML response carries no signature
+	ErrNoSignature = errors.New("SAML response is not signed")
+	// ErrUntrustedSignature is returned when no trusted certificate verifies the signature
+	ErrUntrustedSignature = errors.New("SAML signature was not made by a trusted certificate")
+)
+
+var digestAlgorithms = map[string]crypto.Hash{
+	"http://www.w3.org/2000/09/xmldsig#sha1":        crypto.SHA1,
+	"http://www.w3.org/2001/04/xmlenc#sha256":       crypto.SHA256,
+	"http://www.w3.org/2001/04/xmldsig-more#sha384": crypto.SHA384,
+	"http://www.w3.org/2001/04/xmlenc#sha512":       crypto.SHA512,
+}
+
+var signatureAlgorithms = map[string]crypto.Hash{
+	"http://www.w3.org/2000/09/xmldsig#rsa-sha1":        crypto.SHA1,
+	"http://www.w3.org/2001/04/xmldsig-more#rsa-sha256": crypto.SHA256,
+	"http://www.w3.org/2001/04/xmldsig-more#rsa-sha384": crypto.SHA384,
+	"http://www.w3.org/2001/04/xmldsig-more#rsa-sha512": crypto.SHA512,
+}
+
+// VerifySignature validates the XML signatures in a base64-encoded SAML
+// response against the trusted certificates. Every Assertion and Attribute in
+// the response must be covered by a valid signature (either on the Assertion
+// itself or on the enclosing Response), which prevents signature wrapping attacks.
+func VerifySignature(samlAssertion string, trusted []*x509.Certificate) error {
+	if len(trusted) == 0 {
+		return fmt.Errorf("no trusted signing certificates available")
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(samlAssertion)
+	if err != nil {
+		return fmt.Errorf("failed to decode SAML assertion: %w", err)
+	}
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(decoded); err != nil {
+		return fmt.Errorf("failed to parse SAML XML: %w", err)
+	}
+
+	verified := make(map[*etree.Element]bool)
+	for _, sig := range doc.FindElements("//Signature") {
+		if sig.NamespaceURI() != xmldsigNamespace {
+			continue
+		}
+
+		signed, err := verifySignatureElement(sig, trusted)
+		if err != nil {
+			return err
+		}
+		verified[signed] = true
+	}
+
+	if len(verified) == 0 {
+		return ErrNoSignature
+	}
+
+	assertions := 0
+	for _, assertion := range doc.FindElements("//Assertion") {
+		if assertion.NamespaceURI() != samlAssertionNS {
+			continue
+		}
+		assertions++
+		if !coveredBySignature(assertion, verified) {
+			return fmt.Errorf("assertion %q is not covered by a valid signature", assertion.SelectAttrValue("ID", ""))
+		}
+	}
+
+	if assertions == 0 {
+		return fmt.Errorf("no assertion found in SAML response")
+	}
+
+	// Roles are read from any Attribute element, so all of them must be signed
+	for _, attr := range doc.FindElements("//Attribute") {
+		if !coveredBySignature(attr, verified) {
+			return fmt.Errorf("attribute %q is not covered by a valid signature", attr.SelectAttrValue("Name", ""))
+		}
+	}
+
+	return nil
+}
+
+// coveredBySignature reports whether el or one of its ancestors was verified
+func coveredBySignature(el *etree.Element, verified map[*etree.Element]bool) bool {
+	for e := el; e != nil; e = e.Parent() {
+		if verified[e] {
+			return true
+		}
+	}
+	return false
+}
+
+// verifySignatureElement verifies a single enveloped ds:Signature and returns
+// the element it signs
+func verifySignatureElement(sig *etree.Element, trusted []*x509.Certificate) (*etree.Element, error) {
+	signedInfo := childNS(sig, "SignedInfo", xmldsigNamespace)
+	if signedInfo == nil {
+		return nil, fmt.Errorf("signature has no SignedInfo")
+	}
+
+	signed := sig.Parent()
+	if signed == nil {
+		return nil, fmt.Errorf("signature has no parent element")
+	}
+
+	// Only enveloped signatures referencing their parent by ID are accepted
+	references := signedInfo.SelectElements("Reference")
+	if len(references) != 1 {
+		return nil, fmt.Errorf("expected exactly one signature reference, got %d", len(references))
+	}
+	reference := references[0]
+
+	id := signed.SelectAttrValue("ID", "")
+	if id == "" || reference.SelectAttrValue("URI", "") != "#"+id {
+		return nil, fmt.Errorf("signature reference does not point to the signed element")
+	}
+
+	if err := verifyDigest(signed, sig, reference); err != nil {
+		return nil, err
+	}
+
+	// Canonicalize and verify SignedInfo
+	c14nMethod := childNS(signedInfo, "CanonicalizationMethod", xmldsigNamespace)
+	if c14nMethod == nil {
+		return nil, fmt.Errorf("signature has no CanonicalizationMethod")
+	}
+	canonicalizer, err := newCanonicalizer(c14nMethod, nil)
+	if err != nil {
+		return nil, err
+	}
+	canonicalSignedInfo := canonicalizer.canonicalize(signedInfo)
+
+	sigMethod := childNS(signedInfo, "SignatureMethod", xmldsigNamespace)
+	if sigMethod == nil {
+		return nil, fmt.Errorf("signature has no SignatureMethod")
+	}
+	hash, ok := signatureAlgorithms[sigMethod.SelectAttrValue("Algorithm", "")]
+	if !ok {
+		return nil, fmt.Errorf("unsupported signature algorithm %q", sigMethod.SelectAttrValue("Algorithm", ""))
+	}
+
+	sigValueEl := childNS(sig, "SignatureValue", xmldsigNamespace)
+	if sigValueEl == nil {
+		return nil, fmt.Errorf("signature has no SignatureValue")
+	}
+	sigValue, err := decodeBase64Text(sigValueEl.Text())
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode SignatureValue: %w", err)
+	}
+
+	h := hash.New()
+	h.Write(canonicalSignedInfo)
+	hashed := h.Sum(nil)
+
+	for _, cert := range trusted {
+		pub, ok := cert.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			continue
+		}
+		if rsa.VerifyPKCS1v15(pub, hash, hashed, sigValue) == nil {
+			return signed, nil
+		}
+	}
+
+	return nil, ErrUntrustedSignature
+}
+
+// verifyDigest checks the reference digest of the signed element
+func verifyDigest(signed, sig, reference *etree.Element) error {
+	var canonicalizer *excC14N
+
+	if transforms := childNS(reference, "Transforms", xmldsigNamespace); transforms != nil {
+		for _, t := range transforms.SelectElements("Transform") {
+			algorithm := t.SelectAttrValue("Algorithm", "")
+			switch algorithm {
+			case transformEnveloped:
+				continue
+			default:
+				c, err := newCanonicalizer(t, sig)
+				if err != nil {
+					return err
+				}
+				canonicalizer = c
+			}
+		}
+	}
+
+	if canonicalizer == nil {
+		return fmt.Errorf("signature reference has no canonicalization transform")
+	}
+
+	digestMethod := childNS(reference, "DigestMethod", xmldsigNamespace)
+	if digestMethod == nil {
+		return fmt.Errorf("signature reference has no DigestMethod")
+	}
+	hash, ok := digestAlgorithms[digestMethod.SelectAttrValue("Algorithm", "")]
+	if !ok {
+		return fmt.Errorf("unsupported digest algorithm %q", digestMethod.SelectAttrValue("Algorithm", ""))
+	}
+
+	digestValueEl := childNS(reference, "DigestValue", xmldsigNamespace)
+	if digestValueEl == nil {
+		return fmt.Errorf("signature reference has no DigestValue")
+	}
+	expected, err := decodeBase64Text(digestValueEl.Text())
+	if err != nil {
+		return fmt.Errorf("failed to decode DigestValue: %w", err)
+	}
+
+	h := hash.New()
+	h.Write(canonicalizer.canonicalize(signed))
+	if !bytes.Equal(h.Sum(nil), expected) {
+		return fmt.Errorf("SAML digest mismatch: the signed content was modified")
+	}
+
+	return nil
+}
+
+// newCanonicalizer builds a canonicalizer from a CanonicalizationMethod or
+// Transform element, honoring an InclusiveNamespaces PrefixList
+func newCanonicalizer(method *etree.Element, skip *etree.Element) (*excC14N, error) {
+	algorithm := method.SelectAttrValue("Algorithm", "")
+	if algorithm != algExcC14N {
+		return nil, fmt.Errorf("unsupported canonicalization algorithm %q", algorithm)
+	}
+
+	c := &excC14N{
+		inclusivePrefixes: make(map[string]bool),
+		skip:              skip,
+	}
+
+	if inclusive := method.SelectElement("InclusiveNamespaces"); inclusive != nil {
+		for _, prefix := range strings.Fields(inclusive.SelectAttrValue("PrefixList", "")) {
+			if prefix == "#default" {
+				prefix = ""
+			}
+			c.inclusivePrefixes[prefix] = true
+		}
+	}
+
+	return c, nil
+}
+
+// childNS returns the first child element with the given local name and namespace
+func childNS(el *etree.Element, tag, namespace string) *etree.Element {
+	for _, child := range el.ChildElements() {
+		if child.Tag == tag && child.NamespaceURI() == namespace {
+			return child
+		}
+	}
+	return nil
+}
+
+// decodeBase64Text decodes base64 content that may contain line breaks
+func decodeBase64Text(text string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(text), ""))
+}
+
+// ErrUntrustedIssuer is returned when an assertion names another issuer than
+// the trusted one
+var ErrUntrustedIssuer = errors.New("SAML assertion was issued by an untrusted issuer")
+
+// TrustRoot is what a SAML response is verified against. It comes from the
+// local config, never from the response itself.
+type TrustRoot struct {
+	Certificates []*x509.Certificate
+	// Issuer is the Issuer every assertion must name; empty when only a
+	// pinned certificate is trusted
+	Issuer string
+}
+
+// VerifyResponse verifies the signatures of a base64-encoded SAML response
+// against the trust root, and that its assertions were issued by its issuer
+func VerifyResponse(samlAssertion string, root *TrustRoot) error {
+	if err := VerifySignature(samlAssertion, root.Certificates); err != nil {
+		return err
+	}
+	if root.Issuer == "" {
+		return nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(samlAssertion)
+	if err != nil {
+		return fmt.Errorf("failed to decode SAML assertion: %w", err)
+	}
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(decoded); err != nil {
+		return fmt.Errorf("failed to parse SAML XML: %w", err)
+	}
+
+	issuers := doc.FindElements("//Issuer")
+	if len(issuers) == 0 {
+		return fmt.Errorf("%w: the assertion names no issuer", ErrUntrustedIssuer)
+	}
+	for _, issuer := range issuers {
+		if got := strings.TrimSpace(issuer.Text()); got != root.Issuer {
+			return fmt.Errorf("%w: %s (expected %s)", ErrUntrustedIssuer, got, root.Issuer)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package saml
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+)
+
+const testResponse = `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_resp" Version="2.0">` +
+	`<Assertion xmlns="urn:oasis:names:tc:SAML:2.0:assertion" ID="_assertion" Version="2.0">` +
+	`<Issuer>https://sts.windows.net/00000000-0000-0000-0000-000000000000/</Issuer>` +
+	`<AttributeStatement>` +
+	`<Attribute Name="https://aws.amazon.com/SAML/Attributes/Role">` +
+	`<AttributeValue>arn:aws:iam::123456789012:role/Admin,arn:aws:iam::123456789012:saml-provider/AzureAD</AttributeValue>` +
+	`</Attribute>` +
+	`</AttributeStatement>` +
+	`</Assertion>` +
+	`</samlp:Response>`
+
+func TestExcC14NSpecExample(t *testing.T) {
+	// Example from the Exclusive XML Canonicalization 1.0 specification, section 2.2
+	doc := etree.NewDocument()
+	if err := doc.ReadFromString(`<n0:local xmlns:n0="foo:bar" xmlns:n3="ftp://example.org"><n1:elem2 xmlns:n1="http://example.net" xml:lang="en"><n3:stuff xmlns:n3="ftp://example.org"/></n1:elem2></n0:local>`); err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	c := &excC14N{inclusivePrefixes: map[string]bool{}}
+	got := string(c.canonicalize(doc.FindElement("//elem2")))
+	want := `<n1:elem2 xmlns:n1="http://example.net" xml:lang="en"><n3:stuff xmlns:n3="ftp://example.org"></n3:stuff></n1:elem2>`
+
+	if got != want {
+		t.Errorf("unexpected canonical form:\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	key, cert := newTestCertificate(t)
+	signed := signTestAssertion(t, key)
+
+	if err := VerifySignature(signed, []*x509.Certificate{cert}); err != nil {
+		t.Fatalf("expected valid signature, got %v", err)
+	}
+
+	_, otherCert := newTestCertificate(t)
+	if err := VerifySignature(signed, []*x509.Certificate{otherCert}); err != ErrUntrustedSignature {
+		t.Errorf("expected ErrUntrustedSignature, got %v", err)
+	}
+
+	decoded, _ := base64.StdEncoding.DecodeString(signed)
+	tampered := strings.Replace(string(decoded), "role/Admin", "role/Root!", 1)
+	if err := VerifySignature(base64.StdEncoding.EncodeToString([]byte(tampered)), []*x509.Certificate{cert}); err == nil {
+		t.Error("expected tampered assertion to fail verification")
+	}
+
+	unsigned := base64.StdEncoding.EncodeToString([]byte(testResponse))
+	if err := VerifySignature(unsigned, []*x509.Certificate{cert}); err != ErrNoSignature {
+		t.Errorf("expected ErrNoSignature, got %v", err)
+	}
+}
+
+func TestVerifySignatureRejectsUnsignedAttributes(t *testing.T) {
+	key, cert := newTestCertificate(t)
+	signed := signTestAssertion(t, key)
+
+	// Inject an extra role outside the signed assertion
+	decoded, _ := base64.StdEncoding.DecodeString(signed)
+	injected := strings.Replace(string(decoded), "</samlp:Response>",
+		`<Attribute Name="https://aws.amazon.com/SAML/Attributes/Role"><AttributeValue>evil</AttributeValue></Attribute></samlp:Response>`, 1)
+
+	if err := VerifySignature(base64.StdEncoding.EncodeToString([]byte(injected)), []*x509.Certificate{cert}); err == nil {
+		t.Error("expected unsigned attribute to fail verification")
+	}
+}
+
+func TestVerifyResponse(t *testing.T) {
+	const tenantIssuer = "https://sts.windows.net/00000000-0000-0000-0000-000000000000/"
+	key, cert := newTestCertificate(t)
+	foreignKey, foreignCert := newTestCertificate(t)
+	foreignResponse := strings.Replace(testResponse, tenantIssuer, "https://sts.windows.net/11111111-1111-1111-1111-111111111111/", 1)
+
+	root := &TrustRoot{Certificates: []*x509.Certificate{cert}, Issuer: tenantIssuer}
+
+	tests := []struct {
+		name    string
+		signed  string
+		wantErr error
+	}{
+		{name: "configured tenant", signed: signTestAssertion(t, key)},
+		// A response signed by another tenant's certificate, naming that tenant
+		{name: "foreign tenant certificate", signed: signTestResponse(t, foreignKey, foreignResponse), wantErr: ErrUntrustedSignature},
+		// A response naming another tenant, signed with a trusted certificate
+		{name: "foreign issuer", signed: signTestResponse(t, key, foreignResponse), wantErr: ErrUntrustedIssuer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyResponse(tt.signed, root)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("expected response to verify, got %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	// The foreign tenant's own certificate is only trusted when configured
+	foreignRoot := &TrustRoot{Certificates: []*x509.Certificate{foreignCert}}
+	if err := VerifyResponse(signTestResponse(t, foreignKey, foreignResponse), foreignRoot); err != nil {
+		t.Errorf("expected pinned certificate to verify, got %v", err)
+	}
+}
+
+func newTestCertificate(t *testing.T) (*rsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+
+	return key, cert
+}
+
+// signTestAssertion signs the assertion in testResponse with an enveloped signature
+func signTestAssertion(t *testing.T, key *rsa.PrivateKey) string {
+	t.Helper()
+	return signTestResponse(t, key, testResponse)
+}
+
+// signTestResponse signs the assertion in response with an enveloped signature
+func signTestResponse(t *testing.T, key *rsa.PrivateKey, response string) string {
+	t.Helper()
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromString(response); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+
+	assertion := doc.FindElement("//Assertion")
+	issuer := assertion.SelectElement("Issuer")
+
+	sig := etree.NewElement("Signature")
+	sig.CreateAttr("xmlns", xmldsigNamespace)
+	assertion.InsertChildAt(issuer.Index()+1, sig)
+
+	signedInfo := sig.CreateElement("SignedInfo")
+	signedInfo.CreateElement("CanonicalizationMethod").CreateAttr("Algorithm", algExcC14N)
+	signedInfo.CreateElement("SignatureMethod").CreateAttr("Algorithm", "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256")
+	reference := signedInfo.CreateElement("Reference")
+	reference.CreateAttr("URI", "#_assertion")
+	transforms := reference.CreateElement("Transforms")
+	transforms.CreateElement("Transform").CreateAttr("Algorithm", transformEnveloped)
+	transforms.CreateElement("Transform").CreateAttr("Algorithm", algExcC14N)
+	reference.CreateElement("DigestMethod").CreateAttr("Algorithm", "http://www.w3.org/2001/04/xmlenc#sha256")
+
+	c := &excC14N{inclusivePrefixes: map[string]bool{}, skip: sig}
+	digest := sha256.Sum256(c.canonicalize(assertion))
+	reference.CreateElement("DigestValue").SetText(base64.StdEncoding.EncodeToString(digest[:]))
+
+	c = &excC14N{inclusivePrefixes: map[string]bool{}}
+	hashed := sha256.Sum256(c.canonicalize(signedInfo))
+	sigValue, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	sig.CreateElement("SignatureValue").SetText(base64.StdEncoding.EncodeToString(sigValue))
+
+	out, err := doc.WriteToString()
+	if err != nil {
+		t.Fatalf("failed to serialize: %v", err)
+	}
+
+	return base64.StdEncoding.EncodeToString([]byte(out))
+}
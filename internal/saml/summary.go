@@ -0,0 +1,119 @@
+package saml
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+)
+
+// Summary describes a SAML response for troubleshooting
+type Summary struct {
+	Issuer          string
+	Destination     string
+	Subject         string
+	Audiences       []string
+	NotBefore       time.Time
+	NotOnOrAfter    time.Time
+	SessionDuration int64
+	Roles           []*AWSRole
+	// InvalidRoles are role attribute values that could not be parsed
+	InvalidRoles []string
+	Signed       bool
+}
+
+// Summarize extracts the fields of a base64-encoded SAML response that matter
+// for AWS sign-in. Unlike ParseAssertion, a response without (valid) roles is
+// not an error.
+func Summarize(samlAssertion string) (*Summary, error) {
+	doc, err := decodeDocument(samlAssertion)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &Summary{}
+
+	if el := doc.FindElement("//Assertion/Issuer"); el != nil {
+		summary.Issuer = strings.TrimSpace(el.Text())
+	} else if el := doc.FindElement("//Issuer"); el != nil {
+		summary.Issuer = strings.TrimSpace(el.Text())
+	}
+	if el := doc.FindElement("//Response"); el != nil {
+		summary.Destination = el.SelectAttrValue("Destination", "")
+	}
+	if el := doc.FindElement("//Subject/NameID"); el != nil {
+		summary.Subject = strings.TrimSpace(el.Text())
+	}
+	for _, el := range doc.FindElements("//AudienceRestriction/Audience") {
+		summary.Audiences = append(summary.Audiences, strings.TrimSpace(el.Text()))
+	}
+	summary.Signed = doc.FindElement("//Signature") != nil
+
+	if conditions := doc.FindElement("//Conditions"); conditions != nil {
+		if summary.NotBefore, err = parseTimeAttr(conditions, "NotBefore"); err != nil {
+			return nil, err
+		}
+		if summary.NotOnOrAfter, err = parseTimeAttr(conditions, "NotOnOrAfter"); err != nil {
+			return nil, err
+		}
+	}
+
+	if summary.SessionDuration, err = ExtractSessionDuration(samlAssertion); err != nil {
+		return nil, err
+	}
+
+	// No roles is reported in the summary rather than as an error
+	roleStrings, _ := ExtractRoles(samlAssertion)
+	for _, roleStr := range roleStrings {
+		role, err := parseRoleString(roleStr)
+		if err != nil {
+			summary.InvalidRoles = append(summary.InvalidRoles, roleStr)
+			continue
+		}
+		summary.Roles = append(summary.Roles, role)
+	}
+
+	return summary, nil
+}
+
+// PrettyXML returns the decoded SAML response as indented XML
+func PrettyXML(samlAssertion string) (string, error) {
+	doc, err := decodeDocument(samlAssertion)
+	if err != nil {
+		return "", err
+	}
+
+	doc.Indent(2)
+	return doc.WriteToString()
+}
+
+// decodeDocument base64-decodes and parses a SAML response
+func decodeDocument(samlAssertion string) (*etree.Document, error) {
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(samlAssertion))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode SAML assertion: %w", err)
+	}
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(decoded); err != nil {
+		return nil, fmt.Errorf("failed to parse SAML XML: %w", err)
+	}
+
+	return doc, nil
+}
+
+// parseTimeAttr parses an optional xs:dateTime attribute
+func parseTimeAttr(el *etree.Element, name string) (time.Time, error) {
+	value := el.SelectAttrValue(name, "")
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q: %w", name, value, err)
+	}
+	return t, nil
+}
@@ -0,0 +1,101 @@
+// Package secretbox encrypts small blobs with a passphrase (PBKDF2-SHA256 key
+// derivation and AES-256-GCM). Sealed data is a text header line followed by
+// the base64-encoded salt, nonce, and ciphertext.
+package secretbox
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+const (
+	kdfIterations = 600000
+	kdfSaltSize   = 16
+	kdfKeySize    = 32
+)
+
+// ErrWrongPassphrase is returned when sealed data cannot be decrypted
+var ErrWrongPassphrase = errors.New("wrong passphrase or corrupted data")
+
+// IsSealed reports whether data starts with the given header line
+func IsSealed(header string, data []byte) bool {
+	return bytes.HasPrefix(data, []byte(header+"\n"))
+}
+
+// Seal encrypts plaintext with passphrase. The header identifies the format
+// and is authenticated along with the ciphertext.
+func Seal(header string, plaintext []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, kdfSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	gcm, err := newCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := append(salt, nonce...)
+	sealed = gcm.Seal(sealed, nonce, plaintext, []byte(header))
+
+	return []byte(header + "\n" + base64.StdEncoding.EncodeToString(sealed) + "\n"), nil
+}
+
+// Open decrypts data produced by Seal with the same header
+func Open(header string, data []byte, passphrase string) ([]byte, error) {
+	if !IsSealed(header, data) {
+		return nil, fmt.Errorf("data is not sealed with %q", header)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data[len(header)+1:])))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode sealed data: %w", err)
+	}
+
+	if len(sealed) < kdfSaltSize {
+		return nil, ErrWrongPassphrase
+	}
+
+	gcm, err := newCipher(passphrase, sealed[:kdfSaltSize])
+	if err != nil {
+		return nil, err
+	}
+
+	sealed = sealed[kdfSaltSize:]
+	if len(sealed) < gcm.NonceSize() {
+		return nil, ErrWrongPassphrase
+	}
+
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], []byte(header))
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+
+	return plaintext, nil
+}
+
+func newCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, kdfIterations, kdfKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	return cipher.NewGCM(block)
+}
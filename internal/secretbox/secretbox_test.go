@@ -0,0 +1,86 @@
+package secretbox
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+const testHeader = "azure2aws-test-v1"
+
+func TestSealOpen(t *testing.T) {
+	plaintext := []byte(`{"secret":"value"}`)
+	sealed, err := Seal(testHeader, plaintext, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsSealed(testHeader, sealed) {
+		t.Fatalf("expected the sealed data to start with the header, got %q", sealed)
+	}
+	if bytes.Contains(sealed, plaintext) {
+		t.Fatal("sealed data contains the plaintext")
+	}
+
+	body := bytes.TrimSpace(sealed[len(testHeader)+1:])
+	raw, err := base64.StdEncoding.DecodeString(string(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := append([]byte(nil), raw...)
+	tampered[len(tampered)-1] ^= 1
+
+	tests := []struct {
+		name       string
+		header     string
+		data       []byte
+		passphrase string
+		wantErr    error // nil for any error
+		wantOK     bool
+	}{
+		{name: "round trip", header: testHeader, data: sealed, passphrase: "passphrase", wantOK: true},
+		{name: "wrong passphrase", header: testHeader, data: sealed, passphrase: "other", wantErr: ErrWrongPassphrase},
+		{name: "tampered ciphertext", header: testHeader, data: []byte(testHeader + "\n" + base64.StdEncoding.EncodeToString(tampered) + "\n"), passphrase: "passphrase", wantErr: ErrWrongPassphrase},
+		// The header is authenticated: the same body under another header fails
+		{name: "tampered header", header: "azure2aws-test-v2", data: append([]byte("azure2aws-test-v2\n"), body...), passphrase: "passphrase", wantErr: ErrWrongPassphrase},
+		{name: "other header", header: "azure2aws-test-v2", data: sealed, passphrase: "passphrase"},
+		{name: "too short for the salt", header: testHeader, data: []byte(testHeader + "\n" + base64.StdEncoding.EncodeToString(raw[:8]) + "\n"), passphrase: "passphrase", wantErr: ErrWrongPassphrase},
+		{name: "too short for the nonce", header: testHeader, data: []byte(testHeader + "\n" + base64.StdEncoding.EncodeToString(raw[:kdfSaltSize+4]) + "\n"), passphrase: "passphrase", wantErr: ErrWrongPassphrase},
+		{name: "not base64", header: testHeader, data: []byte(testHeader + "\nnot base64!\n"), passphrase: "passphrase"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Open(tt.header, tt.data, tt.passphrase)
+			if tt.wantOK {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !bytes.Equal(got, plaintext) {
+					t.Errorf("got %q, want %q", got, plaintext)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestSealUsesFreshSalt(t *testing.T) {
+	a, err := Seal(testHeader, []byte("secret"), "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Seal(testHeader, []byte("secret"), "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(a, b) {
+		t.Error("expected two seals of the same plaintext to differ")
+	}
+}
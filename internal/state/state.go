@@ -0,0 +1,132 @@
+// Package state persists mutable per-profile data (last role used, the role
+// to use without prompting, the roles seen in the last SAML assertion, last
+// login time, credentials kept in the keyring) in
+// ~/.azure2aws/state/<profile>.json, so the config file stays a declaration
+// that is never rewritten to remember things. Secrets belong in the keyring.
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Role is an AWS role offered by the identity provider
+type Role struct {
+	RoleARN      string `json:"role_arn"`
+	PrincipalARN string `json:"principal_arn"`
+}
+
+// State is the mutable data remembered for a profile
+type State struct {
+	// LastRoleARN is the role assumed by the last successful login
+	LastRoleARN string `json:"last_role_arn,omitempty"`
+	// RememberedRoleARN is the role the user chose to use without being
+	// prompted
+	RememberedRoleARN string `json:"remembered_role_arn,omitempty"`
+	// Roles are the roles from the last SAML assertion
+	Roles []Role `json:"roles,omitempty"`
+	// LastLogin is the time of the last successful login
+	LastLogin time.Time `json:"last_login,omitempty"`
+	// AccountAliases are account aliases discovered after login, by account ID
+	AccountAliases map[string]string `json:"account_aliases,omitempty"`
+	// KeyringCredentials describes the AWS credentials when they are kept in
+	// the keyring instead of the credentials file
+	KeyringCredentials *KeyringCredentials `json:"keyring_credentials,omitempty"`
+}
+
+// KeyringCredentials is what can be known about credentials in the keyring
+// without reading them
+type KeyringCredentials struct {
+	Backend        string    `json:"backend"` // Keyring backend holding them
+	Expiration     time.Time `json:"expiration"`
+	AssumedRoleARN string    `json:"assumed_role_arn,omitempty"`
+}
+
+// DefaultDir returns the directory holding profile state files
+func DefaultDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".azure2aws", "state"), nil
+}
+
+// Path returns the state file for a profile
+func Path(profile string) (string, error) {
+	dir, err := DefaultDir()
+	if err != nil {
+		return "", err
+	}
+	// Keep profile names a single path segment
+	return filepath.Join(dir, url.PathEscape(profile)+".json"), nil
+}
+
+// Load returns the state for a profile; a profile without state returns an
+// empty State
+func Load(profile string) (*State, error) {
+	path, err := Path(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &State{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	var st State
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %w", path, err)
+	}
+
+	return &st, nil
+}
+
+// Save writes the state for a profile
+func Save(profile string, st *State) error {
+	path, err := Path(profile)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	// Write to a temp file and rename so concurrent readers never see a partial file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes the state for a profile
+func Delete(profile string) error {
+	path, err := Path(profile)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove state file: %w", err)
+	}
+	return nil
+}